@@ -0,0 +1,56 @@
+package api
+
+type HealthResponse struct {
+	Status    string `json:"status"`
+	Version   string `json:"version"`
+	Timestamp string `json:"timestamp"`
+}
+
+type ReloadResponse struct {
+	Reloaded bool `json:"reloaded"`
+}
+
+type UpdateCheckResponse struct {
+	Current         string `json:"current"`
+	Latest          string `json:"latest"`
+	UpdateAvailable bool   `json:"update_available"`
+	URL             string `json:"url"`
+	CheckedAt       string `json:"checked_at"`
+}
+
+// SessionsResponse lists a multi-tenant proxy's open sessions and the
+// accounts it holds Google tokens for. No token material is included.
+type SessionsResponse struct {
+	Sessions []SessionInfo `json:"sessions"`
+	Accounts []AccountInfo `json:"accounts"`
+}
+
+type SessionInfo struct {
+	ID        string `json:"id"` // for DELETE /admin/sessions/{id}; not usable as a session
+	User      string `json:"user"`
+	Client    string `json:"client,omitempty"` // User-Agent the session was opened with
+	CreatedAt string `json:"created_at"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+// AccountInfo describes one account's stored Google token: a tenant's, or
+// one of cli.tokens_file's when the proxy is not multi-tenant.
+type AccountInfo struct {
+	User        string `json:"user"`
+	Authorized  bool   `json:"authorized"` // a Google token is stored
+	Refreshable bool   `json:"refreshable"`
+	TokenExpiry string `json:"token_expiry,omitempty"` // of the access token
+	Sessions    int    `json:"sessions"`
+}
+
+type ExpireSessionResponse struct {
+	Expired bool `json:"expired"`
+}
+
+// ReauthResponse reports what POST /admin/accounts/{user}/reauth did.
+type ReauthResponse struct {
+	User          string `json:"user"`
+	Revoked       bool   `json:"revoked"` // the grant was revoked at Google
+	TokenDeleted  bool   `json:"token_deleted"`
+	EndedSessions int    `json:"ended_sessions"`
+}
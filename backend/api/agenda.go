@@ -0,0 +1,20 @@
+package api
+
+// AgendaItem is a task in an agenda, with the list it belongs to.
+type AgendaItem struct {
+	List TaskList `json:"list"`
+	Task Task     `json:"task"`
+}
+
+// AgendaResponse is the body of GET /api/agenda: open tasks by due date and
+// recently completed tasks, across every list. Tasks in each section are
+// ordered by due date (completion time for RecentlyCompleted, newest first).
+type AgendaResponse struct {
+	Date              string          `json:"date"` // YYYY-MM-DD
+	TimeZone          string          `json:"time_zone"`
+	Overdue           []AgendaItem    `json:"overdue"`
+	DueToday          []AgendaItem    `json:"due_today"`
+	DueThisWeek       []AgendaItem    `json:"due_this_week"`      // the 6 days after Date
+	RecentlyCompleted []AgendaItem    `json:"recently_completed"` // since the start of the day before Date
+	Failed            []ListWithTasks `json:"failed,omitempty"`   // lists that could not be fetched
+}
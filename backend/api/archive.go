@@ -0,0 +1,32 @@
+package api
+
+// ArchivedTask is a completed task kept by the proxy when its list was
+// cleared. Completed is the task's completion time, Archived when it was
+// kept; both are RFC 3339. Account is the ID of the Google account the
+// list belongs to: the ID of its default task list.
+type ArchivedTask struct {
+	Account   string   `json:"account"`
+	List      TaskList `json:"list"`
+	Task      Task     `json:"task"`
+	Completed string   `json:"completed"`
+	Archived  string   `json:"archived"`
+}
+
+// ArchiveResponse is the archived tasks completed from From through To
+// (YYYY-MM-DD, in the request's time zone), most recently completed first.
+type ArchiveResponse struct {
+	From  string         `json:"from,omitempty"`
+	To    string         `json:"to,omitempty"`
+	Tasks []ArchivedTask `json:"tasks"`
+	Total int            `json:"total"`
+}
+
+// ClearResponse reports a list's completed tasks archived before they were
+// cleared from it. Request is the clear sent to Google (or that would be,
+// for a dry run).
+type ClearResponse struct {
+	List     TaskList  `json:"list"`
+	DryRun   bool      `json:"dry_run"`
+	Archived []Task    `json:"archived"`
+	Request  TaskWrite `json:"request"`
+}
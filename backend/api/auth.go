@@ -0,0 +1,50 @@
+package api
+
+type AuthStartResponse struct {
+	AuthURL  string `json:"authUrl"`
+	State    string `json:"state"`
+	UserCode string `json:"userCode,omitempty"` // to enter at AuthURL, for the GitHub provider's device flow
+}
+
+// PollResponse carries Google's token response once the flow completed.
+type PollResponse struct {
+	Completed bool           `json:"completed"`
+	Tokens    map[string]any `json:"tokens,omitempty"`
+}
+
+type TokenRequest struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// GoogleToken is Google's token endpoint response, which /auth/token and
+// /auth/refresh pass through unchanged.
+type GoogleToken struct {
+	AccessToken  string `json:"access_token"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	Scope        string `json:"scope"`
+	TokenType    string `json:"token_type"`
+	IDToken      string `json:"id_token,omitempty"`
+}
+
+// SessionHeader carries the session of a multi-tenant proxy's user on /auth
+// and /api requests.
+const SessionHeader = "X-Gtask-Session"
+
+// SessionRequest opens a session on a multi-tenant proxy with one of the
+// user secrets in its tenants.users.
+type SessionRequest struct {
+	User   string `json:"user"`
+	Secret string `json:"secret"`
+}
+
+type SessionResponse struct {
+	Session   string `json:"session"`
+	User      string `json:"user"`
+	ExpiresAt string `json:"expires_at"`
+}
@@ -0,0 +1,111 @@
+package api
+
+// Backup is what a backup file holds, gzip-compressed: the Google tasks of
+// every account the proxy has a token for and the proxy's own data about
+// them.
+type Backup struct {
+	Version  int             `json:"version"`
+	Created  string          `json:"created"` // RFC 3339
+	Accounts []BackupAccount `json:"accounts"`
+}
+
+// BackupVersion is the Version of backups written by this proxy.
+const BackupVersion = 1
+
+// BackupAccount is one account's data in a Backup. User is the tenant, or
+// empty for the account of a proxy that is not multi-tenant; Account is
+// the ID of the Google account, the ID of its default task list. Only that
+// account can list or restore it.
+type BackupAccount struct {
+	User     string                    `json:"user,omitempty"`
+	Account  string                    `json:"account"`
+	Lists    []ListWithTasks           `json:"lists"`
+	Snoozes  map[string]string         `json:"snoozes"` // until, RFC 3339, by task ID
+	Metadata map[string]TaskMetadata   `json:"metadata"`
+	Archive  []ArchivedTask            `json:"archive"`
+	History  map[string][]HistoryEntry `json:"history"`
+}
+
+// BackupStatusResponse is the body of GET /api/backup/status.
+type BackupStatusResponse struct {
+	Enabled  bool   `json:"enabled"`
+	Interval string `json:"interval"`
+	Dir      string `json:"dir"`
+	Keep     int    `json:"keep"`
+
+	// LastAttempt is when a backup was last tried, and LastError why it
+	// failed, if it did. Both are empty until the first try.
+	LastAttempt string `json:"last_attempt,omitempty"`
+	LastError   string `json:"last_error,omitempty"`
+	// Next is when the next backup is due, when enabled.
+	Next string `json:"next,omitempty"`
+
+	Backups []BackupFile `json:"backups"` // newest first
+}
+
+// BackupFile is a backup in the backup directory. Its ID is the time stamp
+// in its name.
+type BackupFile struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Created string `json:"created"`
+	Size    int64  `json:"size"` // bytes, compressed
+}
+
+// BackupListResponse is the body of GET /api/backup/list.
+type BackupListResponse struct {
+	Backups []BackupSummary `json:"backups"` // newest first
+}
+
+// BackupSummary is a backup with the lists it holds for the caller's
+// account. Error is set instead when the file cannot be read, and Lists is
+// empty when the backup has nothing for the account.
+type BackupSummary struct {
+	BackupFile
+	Lists []BackupListSummary `json:"lists"`
+	Error string              `json:"error,omitempty"`
+}
+
+// BackupListSummary is a list in a backup and how many tasks it had.
+type BackupListSummary struct {
+	List  TaskList `json:"list"`
+	Tasks int      `json:"tasks"`
+}
+
+// RestoreRequest is the body of POST /api/backup/{id}/restore, which may be
+// empty to restore everything. List and Task are IDs in the backup.
+type RestoreRequest struct {
+	List string `json:"list,omitempty"`
+	Task string `json:"task,omitempty"` // restored with its subtasks
+}
+
+// RestoreResponse reports what a restore re-creates: the lists and tasks of
+// the backup that no longer exist. Tasks that still exist are left alone
+// and only counted in Existing.
+type RestoreResponse struct {
+	Backup   string         `json:"backup"`
+	Created  string         `json:"created"`
+	DryRun   bool           `json:"dry_run"`
+	Lists    []RestoredList `json:"lists"`
+	Tasks    []RestoredTask `json:"tasks"`
+	Existing int            `json:"existing"`
+}
+
+// RestoredList is a deleted list created again; List is the new list,
+// unless dry-running or creating it failed.
+type RestoredList struct {
+	From  TaskList  `json:"from"`
+	List  *TaskList `json:"list,omitempty"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+// RestoredTask is a task created again from its copy in the backup. The
+// request names the backup's IDs for lists and parents that are created
+// again by the same restore.
+type RestoredTask struct {
+	From    Task      `json:"from"`
+	List    TaskList  `json:"list"` // in the backup
+	Request TaskWrite `json:"request"`
+	Task    *Task     `json:"task,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
+}
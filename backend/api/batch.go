@@ -0,0 +1,45 @@
+package api
+
+// Change is one operation in a batch, as sent by a buffer sync.
+type Change struct {
+	Op       string         `json:"op"` // create, update, delete or move
+	List     string         `json:"list"`
+	Task     string         `json:"task,omitempty"`     // ID, for update, delete and move
+	Parent   string         `json:"parent,omitempty"`   // move, and create as a subtask
+	Previous string         `json:"previous,omitempty"` // move, and create after a sibling
+	Fields   map[string]any `json:"fields,omitempty"`   // create and update
+}
+
+// ChangeResult reports what a change sends to Google and, unless dry-running,
+// what came back.
+type ChangeResult struct {
+	Request TaskWrite `json:"request"`
+	Task    *Task     `json:"task,omitempty"`
+	Error   *APIError `json:"error,omitempty"`
+}
+
+type BatchRequest struct {
+	Changes []Change `json:"changes"`
+}
+
+type BatchResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Results []ChangeResult `json:"results"`
+}
+
+// BulkStatusRequest is the body of POST /api/tasks/bulk-status: Tasks are
+// set to Status, "completed" or "needsAction". A task given without its
+// list is looked up in every list.
+type BulkStatusRequest struct {
+	Status string    `json:"status"`
+	Tasks  []TaskRef `json:"tasks"`
+}
+
+// BulkStatusResponse has a result per task, in the order they were given.
+// A task that could not be found has no request, only the error.
+type BulkStatusResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Updated int            `json:"updated"`
+	Failed  int            `json:"failed"`
+	Results []ChangeResult `json:"results"`
+}
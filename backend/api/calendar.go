@@ -0,0 +1,32 @@
+package api
+
+// ScheduleRequest is the body of POST /api/tasks/{task}/schedule.
+type ScheduleRequest struct {
+	// Start is when the time block begins: RFC 3339, YYYY-MM-DD HH:MM, or
+	// a time (14:30, 9am) on the task's due date, or today when it has none.
+	Start    string `json:"start"`
+	Duration string `json:"duration,omitempty"` // e.g. 45m; calendar.duration when empty
+	Calendar string `json:"calendar,omitempty"` // calendar ID; calendar.id when empty
+	List     string `json:"list,omitempty"`     // the task's list ID, looked up when empty
+	TZ       string `json:"tz,omitempty"`       // IANA zone or UTC offset for Start without an offset
+}
+
+// CalendarEvent is the Google Calendar event that time-blocks a task.
+type CalendarEvent struct {
+	ID       string `json:"id"`
+	Calendar string `json:"calendar"`
+	HTMLLink string `json:"html_link"`
+	Start    string `json:"start"` // RFC 3339
+	End      string `json:"end"`   // RFC 3339
+}
+
+// ScheduleResponse reports the event a task was scheduled with. Created is
+// false when the task's existing event was moved instead. For a dry run,
+// Event has no ID or link unless the existing event would be moved.
+type ScheduleResponse struct {
+	DryRun   bool          `json:"dry_run"`
+	Created  bool          `json:"created"`
+	Event    CalendarEvent `json:"event"`
+	Task     Task          `json:"task"`
+	Metadata TaskMetadata  `json:"metadata"`
+}
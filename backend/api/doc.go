@@ -0,0 +1,4 @@
+// Package api defines the JSON bodies exchanged with the gtask auth proxy.
+// The proxy encodes them and package client decodes them, so both sides of
+// the wire share one definition.
+package api
@@ -0,0 +1,50 @@
+package api
+
+// DuplicateTask is a task of a duplicate group, with its list.
+type DuplicateTask struct {
+	List TaskList `json:"list"`
+	Task Task     `json:"task"`
+}
+
+// DuplicateGroup is tasks whose titles are alike, in the same list or in
+// different ones. Title is the first task's title normalized as they were
+// compared; Similarity (0 to 1) is that of the least alike pair that joined
+// the group, 1 when the normalized titles are equal. The first task is the
+// one suggested to keep.
+type DuplicateGroup struct {
+	Title      string          `json:"title"`
+	Similarity float64         `json:"similarity"`
+	Tasks      []DuplicateTask `json:"tasks"`
+}
+
+// DuplicatesResponse is the body of GET /api/duplicates, most alike groups
+// first.
+type DuplicatesResponse struct {
+	Groups []DuplicateGroup `json:"groups"`
+	Failed []ListWithTasks  `json:"failed,omitempty"` // lists that could not be fetched
+}
+
+// TaskRef names a task by its list and ID. Where a request says so, the
+// list may be left out for the proxy to find.
+type TaskRef struct {
+	List string `json:"list,omitempty"`
+	Task string `json:"task"`
+}
+
+// MergeDuplicatesRequest is the body of POST /api/duplicates/merge: the
+// Duplicates are merged into Keep and deleted.
+type MergeDuplicatesRequest struct {
+	Keep       TaskRef   `json:"keep"`
+	Duplicates []TaskRef `json:"duplicates"`
+}
+
+// MergeDuplicatesResponse reports the writes of a merge in the order they
+// were sent: moves of the duplicates' subtasks under the kept task, the
+// update of its notes and due date, then the deletes. When a move or the
+// update fails the deletes are not sent, so nothing is lost; their results
+// say so. Task is the kept task afterwards (as it would be, for a dry run).
+type MergeDuplicatesResponse struct {
+	DryRun  bool           `json:"dry_run"`
+	Task    Task           `json:"task"`
+	Results []ChangeResult `json:"results"`
+}
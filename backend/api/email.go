@@ -0,0 +1,31 @@
+package api
+
+// FromEmailRequest is the body of POST /api/tasks/from-email. Message is a
+// Gmail message ID; otherwise the newest message matching Query (Gmail
+// search syntax, like "from:boss is:unread") is used.
+type FromEmailRequest struct {
+	Message string `json:"message,omitempty"`
+	Query   string `json:"query,omitempty"`
+	List    string `json:"list,omitempty"` // list ID; the first list when empty
+}
+
+// EmailMessage is the Gmail message a task was created from. Link opens
+// its thread in Gmail.
+type EmailMessage struct {
+	ID      string `json:"id"`
+	Thread  string `json:"thread"`
+	Subject string `json:"subject"`
+	From    string `json:"from"`
+	Date    string `json:"date,omitempty"` // as in the Date header
+	Link    string `json:"link"`
+}
+
+// FromEmailResponse reports the message and the task created from it.
+// Request is the insert sent to Google (or that would be, for a dry run).
+type FromEmailResponse struct {
+	Email   EmailMessage `json:"email"`
+	List    TaskList     `json:"list"`
+	DryRun  bool         `json:"dry_run"`
+	Request TaskWrite    `json:"request"`
+	Task    *Task        `json:"task,omitempty"`
+}
@@ -0,0 +1,54 @@
+package api
+
+// Error codes used in the error envelope. Clients should branch on these
+// rather than on messages or HTTP status.
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeUnauthorized        = "unauthorized"
+	CodeNotFound            = "not_found"
+	CodeInvalidState        = "invalid_state"
+	CodeInternal            = "internal_error"
+	CodeUpstreamError       = "upstream_error"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeNotConfigured       = "not_configured"
+	CodeRateLimited         = "rate_limited"
+	CodeTimeout             = "timeout"
+	CodeCancelled           = "cancelled"
+)
+
+// ErrorCodes are the values of APIError.Code.
+var ErrorCodes = []string{
+	CodeInvalidRequest, CodeMethodNotAllowed, CodeUnauthorized, CodeNotFound, CodeInvalidState,
+	CodeInternal, CodeUpstreamError, CodeUpstreamUnavailable, CodeNotConfigured, CodeRateLimited,
+	CodeTimeout, CodeCancelled,
+}
+
+// ErrorResponse is the body of every error response:
+//
+//	{"error": {"code": "...", "message": "...", "retryable": false, "upstream": {...}}}
+type ErrorResponse struct {
+	Error *APIError `json:"error"`
+}
+
+// APIError is a machine-readable error. Upstream carries Google's own error
+// fields when the failure originated there.
+type APIError struct {
+	Status     int             `json:"-"`
+	Code       string          `json:"code"`
+	Message    string          `json:"message"`
+	Retryable  bool            `json:"retryable"`
+	Upstream   *UpstreamDetail `json:"upstream,omitempty"`
+	RetryAfter int             `json:"retry_after,omitempty"` // seconds, also sent as the Retry-After header
+}
+
+func (e *APIError) Error() string {
+	return e.Code + ": " + e.Message
+}
+
+// UpstreamDetail passes Google's OAuth/API error through unchanged.
+type UpstreamDetail struct {
+	Status      int    `json:"status"`
+	Error       string `json:"error,omitempty"`
+	Description string `json:"error_description,omitempty"`
+}
@@ -0,0 +1,49 @@
+package api
+
+// Reminder is the data of a "reminder" event on GET /api/events: a task
+// that is due soon or overdue. SnoozedUntil is set when the reminder comes
+// back after a snooze.
+type Reminder struct {
+	Kind         string   `json:"kind"` // "due" or "overdue"
+	Due          string   `json:"due"`  // YYYY-MM-DD
+	List         TaskList `json:"list"`
+	Task         Task     `json:"task"`
+	SnoozedUntil string   `json:"snoozed_until,omitempty"`
+}
+
+// Types of TaskEvent.
+const (
+	EventListCreated      = "list.created"
+	EventListDeleted      = "list.deleted"
+	EventListRenamed      = "list.renamed"
+	EventTaskCreated      = "task.created"
+	EventTaskDeleted      = "task.deleted"
+	EventTaskCompleted    = "task.completed"
+	EventTaskReopened     = "task.reopened"
+	EventTaskTitleChanged = "task.title_changed"
+	EventTaskNotesChanged = "task.notes_changed"
+	EventTaskDueChanged   = "task.due_changed"
+	EventTaskMoved        = "task.moved"      // to another list; From and To are list IDs
+	EventTaskReparented   = "task.reparented" // From and To are parent task IDs
+)
+
+// EventTypes are the values of TaskEvent.Type.
+var EventTypes = []string{
+	EventListCreated, EventListDeleted, EventListRenamed,
+	EventTaskCreated, EventTaskDeleted, EventTaskCompleted, EventTaskReopened,
+	EventTaskTitleChanged, EventTaskNotesChanged, EventTaskDueChanged,
+	EventTaskMoved, EventTaskReparented,
+}
+
+// TaskEvent is the data of a "change" event on GET /api/events: one
+// difference between two successive polls of the user's tasks, whichever
+// client made it. Task is unset for list events. From and To hold the old
+// and new value of what changed (a title, notes, a YYYY-MM-DD due date or
+// an ID); an empty value is omitted.
+type TaskEvent struct {
+	Type string   `json:"type"`
+	List TaskList `json:"list"`
+	Task *Task    `json:"task,omitempty"`
+	From string   `json:"from,omitempty"`
+	To   string   `json:"to,omitempty"`
+}
@@ -0,0 +1,42 @@
+package api
+
+// Changes of a HistoryEntry.
+const (
+	HistoryCreated = "created"
+	HistoryUpdated = "updated" // Field says which
+	HistoryMoved   = "moved"   // Field is list, parent or position
+	HistoryDeleted = "deleted"
+	HistoryCleared = "cleared" // hidden by clearing its list's completed tasks
+)
+
+// Sources of a HistoryEntry.
+const (
+	HistoryProxy    = "proxy"    // made by the proxy for one of its endpoints
+	HistoryObserved = "observed" // made by another client and found by polling
+)
+
+// HistoryEntry is one change to a task. From and To hold the old and new
+// value of Field (a title, notes, a YYYY-MM-DD due date, a status or an
+// ID); an empty value is omitted. Via, Client and User say who made a
+// change the proxy made: the endpoint, like "POST /api/batch", the
+// caller's User-Agent and, in multi-tenant mode, its user. Account is the
+// ID of the Google account the task belongs to: the ID of its default task
+// list.
+type HistoryEntry struct {
+	Account string `json:"account"`
+	At      string `json:"at"` // RFC 3339
+	Source  string `json:"source"`
+	Change  string `json:"change"`
+	Field   string `json:"field,omitempty"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Via     string `json:"via,omitempty"`
+	Client  string `json:"client,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// TaskHistoryResponse is a task's recorded changes, newest first.
+type TaskHistoryResponse struct {
+	Task    string         `json:"task"`
+	Entries []HistoryEntry `json:"entries"`
+}
@@ -0,0 +1,37 @@
+package api
+
+// Events that can run hooks (hooks.events).
+const (
+	HookAuthCompleted = "auth.completed"
+	HookSyncFinished  = "sync.finished"
+	HookTaskOverdue   = "task.overdue"
+)
+
+// HookEvents are the values of HookEvent.Event.
+var HookEvents = []string{HookAuthCompleted, HookSyncFinished, HookTaskOverdue}
+
+// HookEvent is what a hook is run with: JSON on the stdin of hooks.command,
+// and the data of the User GtaskHook autocommand sent to hooks.nvim. Data
+// is an AuthCompletedHook, a SyncFinishedHook or, for task.overdue, a
+// Reminder.
+type HookEvent struct {
+	Event string `json:"event"`
+	At    string `json:"at"`             // RFC 3339
+	User  string `json:"user,omitempty"` // the tenant, in multi-tenant mode
+	Data  any    `json:"data"`
+}
+
+// AuthCompletedHook is the data of auth.completed: an OAuth sign-in that
+// finished, through the plugin or `auth login`.
+type AuthCompletedHook struct {
+	Scope string `json:"scope,omitempty"` // the scopes granted, space separated
+}
+
+// SyncFinishedHook is the data of sync.finished: a buffer reconciled with
+// its list through POST /api/reconcile.
+type SyncFinishedHook struct {
+	List      string `json:"list"`
+	Changes   int    `json:"changes"` // writes sent to Google
+	Failed    int    `json:"failed"`  // of those, the ones Google refused
+	Conflicts int    `json:"conflicts"`
+}
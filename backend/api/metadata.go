@@ -0,0 +1,70 @@
+package api
+
+// TaskMetadata is what the proxy keeps about a task beyond its Google
+// fields. Event is the calendar event scheduling it; Links are the URLs and
+// files attached to it. RestoredFrom is the ID of the task it was created
+// again from by restoring a backup. Fingerprint identifies the source-code
+// comment it was imported from, and Location is where in the code the task
+// was created.
+type TaskMetadata struct {
+	Event        *CalendarEvent `json:"event,omitempty"`
+	Links        []Attachment   `json:"links,omitempty"`
+	RestoredFrom string         `json:"restored_from,omitempty"`
+	Fingerprint  string         `json:"fingerprint,omitempty"`
+	Location     *CodeLocation  `json:"location,omitempty"`
+}
+
+// Empty reports whether m holds nothing.
+func (m TaskMetadata) Empty() bool {
+	return m.Event == nil && len(m.Links) == 0 && m.RestoredFrom == "" && m.Fingerprint == "" && m.Location == nil
+}
+
+// Attachment is a URL or local file attached to a task by the proxy. Type
+// is "url" or "file", like TaskLink's for Google's own links.
+type Attachment struct {
+	ID          string `json:"id"`
+	Type        string `json:"type"`
+	Link        string `json:"link"` // the URL, or the file's path
+	Description string `json:"description,omitempty"`
+	Added       string `json:"added"` // RFC 3339
+}
+
+// AddLinkRequest is the body of POST /api/tasks/{task}/links. Link is a
+// URL (anything with a scheme, like https: or file:) or a file path.
+type AddLinkRequest struct {
+	Link        string `json:"link"`
+	Description string `json:"description,omitempty"`
+	List        string `json:"list,omitempty"` // the task's list ID, looked up when empty
+}
+
+// TaskLinksResponse is every link of a task: Google holds the ones Google
+// made (such as the email a task was created from in Gmail), which cannot
+// be changed through its API, and Attached the ones added through the
+// proxy.
+type TaskLinksResponse struct {
+	Task     string       `json:"task"`
+	Google   []TaskLink   `json:"google"`
+	Attached []Attachment `json:"attached"`
+}
+
+// CodeLocation is a position in source code a task is about. File is
+// relative to the root of the git repository named Repo, when there is one,
+// and absolute otherwise; Commit is the commit Line was read at.
+type CodeLocation struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"` // 1-based
+	Repo   string `json:"repo,omitempty"`
+	Commit string `json:"commit,omitempty"`
+}
+
+// SetLocationRequest is the body of PUT /api/tasks/{task}/location.
+type SetLocationRequest struct {
+	CodeLocation
+	List string `json:"list,omitempty"` // the task's list ID, looked up when empty
+}
+
+// TaskLocationResponse is a task's code location, null when it has none.
+type TaskLocationResponse struct {
+	Task     string        `json:"task"`
+	Location *CodeLocation `json:"location"`
+}
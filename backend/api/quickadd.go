@@ -0,0 +1,28 @@
+package api
+
+// QuickAddRequest is the body of POST /api/quickadd: one line of text like
+// "pay rent tomorrow 9am #finance !p1 @personal".
+type QuickAddRequest struct {
+	Text string `json:"text"`
+	List string `json:"list,omitempty"` // list ID used when the text names none; the first list when empty
+	TZ   string `json:"tz,omitempty"`   // IANA zone or UTC offset for today and tomorrow
+
+	// Location is kept in the task's metadata, for tasks added from code.
+	Location *CodeLocation `json:"location,omitempty"`
+}
+
+// QuickAddResponse reports what the text was parsed into and the task
+// created from it. Google Tasks has no times, tags or priorities, so those
+// are written into the task's notes. Request is the insert sent to Google
+// (or that would be, for a dry run).
+type QuickAddResponse struct {
+	Title    string    `json:"title"`
+	Due      string    `json:"due,omitempty"`  // YYYY-MM-DD
+	Time     string    `json:"time,omitempty"` // HH:MM
+	Tags     []string  `json:"tags"`
+	Priority int       `json:"priority,omitempty"` // 1 (highest) to 4
+	List     TaskList  `json:"list"`
+	DryRun   bool      `json:"dry_run"`
+	Request  TaskWrite `json:"request"`
+	Task     *Task     `json:"task,omitempty"`
+}
@@ -0,0 +1,28 @@
+package api
+
+// QuickfixItem is a task as a Neovim quickfix entry (:help setqflist-what).
+// Tasks live in no file, so Module (the list's title) takes the place of a
+// file name and Valid keeps the entry from being shown as plain text.
+type QuickfixItem struct {
+	Text     string           `json:"text"`
+	Type     string           `json:"type"` // E overdue, W due today, I later or undated
+	Module   string           `json:"module"`
+	Valid    bool             `json:"valid"`
+	UserData QuickfixUserData `json:"user_data"`
+}
+
+// QuickfixUserData identifies an entry's task.
+type QuickfixUserData struct {
+	Task string `json:"task"`
+	List string `json:"list"`
+	Due  string `json:"due,omitempty"` // YYYY-MM-DD
+}
+
+// QuickfixResponse is the body of GET /api/tasks/quickfix. Title and Items
+// are the {what} argument of setqflist() and setloclist() as they are; the
+// functions ignore Failed.
+type QuickfixResponse struct {
+	Title  string          `json:"title"`
+	Items  []QuickfixItem  `json:"items"`
+	Failed []ListWithTasks `json:"failed,omitempty"` // lists that could not be fetched
+}
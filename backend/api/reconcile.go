@@ -0,0 +1,41 @@
+package api
+
+// ReconcileRequest is a buffer's view of one list. Base is the list's tasks
+// as the buffer was rendered from them and Local the tasks the buffer shows
+// now; a local task without an ID was added in the buffer. Only title,
+// notes, status, due (the date) and parent are compared.
+type ReconcileRequest struct {
+	List  string `json:"list"`
+	Base  []Task `json:"base"`
+	Local []Task `json:"local"`
+}
+
+// ReconcileResponse carries the changes that merged cleanly and the
+// conflicts that did not. Remote is the list as it is after the changes (or
+// before them, when dry-running): the buffer's base for the next reconcile.
+// When it could not be read back, RemoteError says why and the list must be
+// fetched again before reconciling, as the changes were applied.
+type ReconcileResponse struct {
+	DryRun      bool                `json:"dry_run"`
+	Results     []ChangeResult      `json:"results"`
+	Conflicts   []ReconcileConflict `json:"conflicts"`
+	Remote      []Task              `json:"remote"`
+	RemoteError *APIError           `json:"remote_error,omitempty"`
+}
+
+// ReconcileConflict is a task edited both in the buffer and remotely. Fields
+// lists the fields changed on both sides to different values, whose local
+// values were not applied (the task's other edits were). It is ["deleted"]
+// when one side deleted the task and the other edited it, which leaves the
+// task as it is; Local or Remote is nil on the side that deleted it.
+// Markers renders the local and remote versions as the plugin's markdown
+// between git-style conflict markers, ready to replace the task's lines in
+// the buffer; a side that deleted the task is empty.
+type ReconcileConflict struct {
+	Task    string   `json:"task"`
+	Fields  []string `json:"fields"`
+	Base    Task     `json:"base"`
+	Local   *Task    `json:"local,omitempty"`
+	Remote  *Task    `json:"remote,omitempty"`
+	Markers string   `json:"markers"`
+}
@@ -0,0 +1,25 @@
+package api
+
+// FuzzyMatch is a task whose title matches a fuzzy query. Positions are the
+// 0-based byte offsets of the matched characters in the title, for
+// highlighting; a higher Score is a better match.
+type FuzzyMatch struct {
+	List      TaskList `json:"list"`
+	Task      Task     `json:"task"`
+	Score     int      `json:"score"`
+	Positions []int    `json:"positions"`
+
+	// Location is where in the code the task was created, for jumping there.
+	Location *CodeLocation `json:"location,omitempty"`
+}
+
+// FuzzySearchResponse is the body of GET /api/search/fuzzy: the best
+// matches first. Total counts every match, including those past the limit,
+// and NextCursor fetches the page after this one; it is empty on the last.
+type FuzzySearchResponse struct {
+	Query      string          `json:"query"`
+	Matches    []FuzzyMatch    `json:"matches"`
+	Total      int             `json:"total"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+	Failed     []ListWithTasks `json:"failed,omitempty"` // lists that could not be fetched
+}
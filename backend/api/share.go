@@ -0,0 +1,46 @@
+package api
+
+// ShareRequest is the body of POST /api/tasklists/{list}/share, which may
+// be empty.
+type ShareRequest struct {
+	// Expires is how long the link works: a duration (30m, 2h, 3d, 1w) or
+	// an RFC 3339 time. It works until revoked when empty.
+	Expires string `json:"expires,omitempty"`
+}
+
+// ShareInfo describes a shared snapshot of a list. No token material is
+// included.
+type ShareInfo struct {
+	ID        string   `json:"id"` // for DELETE /api/shares/{id}; not usable as a link
+	List      TaskList `json:"list"`
+	Tasks     int      `json:"tasks"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at,omitempty"`
+}
+
+// ShareResponse reports a new share. URL is the only way to the snapshot:
+// the proxy keeps a hash of Token, so neither can be shown again.
+type ShareResponse struct {
+	ShareInfo
+	URL   string `json:"url"`
+	Token string `json:"token"`
+}
+
+// SharesResponse is the body of GET /api/shares, newest first.
+type SharesResponse struct {
+	Shares []ShareInfo `json:"shares"`
+}
+
+type RevokeShareResponse struct {
+	Revoked bool `json:"revoked"`
+}
+
+// SharedList is the body of GET /share/{token}?format=json: the list as it
+// was when shared, subtasks after their parents. Tasks carry no links into
+// Google.
+type SharedList struct {
+	List      TaskList `json:"list"`
+	Tasks     []Task   `json:"tasks"`
+	CreatedAt string   `json:"created_at"`
+	ExpiresAt string   `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,30 @@
+package api
+
+// SmartListInfo describes one of the proxy's smart lists: virtual lists of
+// open tasks gathered from every real list by due date.
+type SmartListInfo struct {
+	ID    string `json:"id"` // today, upcoming, overdue or no-date
+	Title string `json:"title"`
+	Count int    `json:"count"`
+}
+
+// SmartListsResponse is the body of GET /api/smart.
+type SmartListsResponse struct {
+	Date     string          `json:"date"` // YYYY-MM-DD
+	TimeZone string          `json:"time_zone"`
+	Lists    []SmartListInfo `json:"lists"`
+	Failed   []ListWithTasks `json:"failed,omitempty"` // lists that could not be fetched
+}
+
+// SmartListResponse is the body of GET /api/smart/{list}. Each item carries
+// the real list its task is in. Items are ordered by due date, undated
+// tasks last, then by list title and position, unless ?sort= and ?order=
+// say otherwise.
+type SmartListResponse struct {
+	ID       string          `json:"id"`
+	Title    string          `json:"title"`
+	Date     string          `json:"date"` // YYYY-MM-DD
+	TimeZone string          `json:"time_zone"`
+	Items    []AgendaItem    `json:"items"`
+	Failed   []ListWithTasks `json:"failed,omitempty"` // lists that could not be fetched
+}
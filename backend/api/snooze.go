@@ -0,0 +1,22 @@
+package api
+
+// SnoozeRequest is the body of POST /api/tasks/{task}/snooze.
+type SnoozeRequest struct {
+	// Duration is how long to snooze: a duration (30m, 2h, 3d, 1w), tonight,
+	// tomorrow, next-week, a weekday or YYYY-MM-DD.
+	Duration string `json:"duration"`
+	List     string `json:"list,omitempty"` // the task's list ID, looked up when empty
+	TZ       string `json:"tz,omitempty"`   // IANA zone or UTC offset for tonight and friends
+}
+
+// SnoozeResponse reports a snooze. Reminders for the task are held until
+// Until. Request is the update of the due date sent to Google (or that
+// would be, for a dry run); it is absent when the task is already due on or
+// after the snoozed-until date.
+type SnoozeResponse struct {
+	Until   string     `json:"until"` // RFC 3339
+	Due     string     `json:"due"`   // YYYY-MM-DD
+	DryRun  bool       `json:"dry_run"`
+	Request *TaskWrite `json:"request,omitempty"`
+	Task    *Task      `json:"task,omitempty"`
+}
@@ -0,0 +1,48 @@
+package api
+
+// TrendsResponse is the body of GET /api/stats/trends: how tasks were
+// completed over the Days days from From through To (YYYY-MM-DD, in the
+// request's time zone).
+type TrendsResponse struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+	Days int    `json:"days"`
+
+	// Daily has one entry per day of the range, oldest first, for a chart
+	// or heatmap.
+	Daily     []TrendDay `json:"daily"`
+	Completed int        `json:"completed"`
+	Open      int        `json:"open"`
+	Overdue   int        `json:"overdue"` // open tasks due before today
+
+	// AverageAgeDays is the mean time from creation to completion of the
+	// tasks completed in the range whose creation is in the task history;
+	// AgeSample is how many those are.
+	AverageAgeDays float64 `json:"average_age_days"`
+	AgeSample      int     `json:"age_sample"`
+
+	// OverdueRate is the fraction of the tasks due in the range that were
+	// not completed by their due date (completed later or still open), 0
+	// when none were due.
+	OverdueRate float64 `json:"overdue_rate"`
+	DueSample   int     `json:"due_sample"`
+
+	Lists  []TrendList     `json:"lists"`
+	Failed []ListWithTasks `json:"failed,omitempty"` // lists that could not be fetched
+}
+
+// TrendDay is the number of tasks completed on Date (YYYY-MM-DD).
+type TrendDay struct {
+	Date      string `json:"date"`
+	Completed int    `json:"completed"`
+}
+
+// TrendList is one list's share of a TrendsResponse. Lists that were
+// deleted but have archived tasks are included by their archived title.
+type TrendList struct {
+	List        TaskList `json:"list"`
+	Completed   int      `json:"completed"`
+	Open        int      `json:"open"`
+	Overdue     int      `json:"overdue"`
+	OverdueRate float64  `json:"overdue_rate"`
+}
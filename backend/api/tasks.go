@@ -0,0 +1,81 @@
+package api
+
+import "net/url"
+
+// TaskList is a Google Tasks task list.
+type TaskList struct {
+	ID      string `json:"id"`
+	Title   string `json:"title"`
+	Updated string `json:"updated,omitempty"`
+	ETag    string `json:"etag,omitempty"`
+}
+
+// Task is a Google Tasks task.
+type Task struct {
+	ID          string     `json:"id,omitempty"`
+	ETag        string     `json:"etag,omitempty"`
+	Title       string     `json:"title"`
+	Notes       string     `json:"notes,omitempty"`
+	Status      string     `json:"status"`
+	Due         string     `json:"due,omitempty"`
+	Completed   string     `json:"completed,omitempty"`
+	Parent      string     `json:"parent,omitempty"`
+	Position    string     `json:"position,omitempty"`
+	Updated     string     `json:"updated,omitempty"`
+	Deleted     bool       `json:"deleted,omitempty"`
+	Hidden      bool       `json:"hidden,omitempty"`
+	Links       []TaskLink `json:"links,omitempty"`
+	WebViewLink string     `json:"webViewLink,omitempty"`
+}
+
+// TaskLink is a link Google attached to a task, such as the email it was
+// created from. Type is "email", or another kind Google adds later.
+type TaskLink struct {
+	Type        string `json:"type"`
+	Description string `json:"description,omitempty"`
+	Link        string `json:"link"`
+}
+
+// Google's pages of task lists and tasks, which the /api/lists endpoints
+// pass through unchanged.
+type TaskListsPage struct {
+	Kind          string     `json:"kind"`
+	ETag          string     `json:"etag,omitempty"`
+	NextPageToken string     `json:"nextPageToken,omitempty"`
+	Items         []TaskList `json:"items,omitempty"`
+}
+
+type TasksPage struct {
+	Kind          string `json:"kind"`
+	ETag          string `json:"etag,omitempty"`
+	NextPageToken string `json:"nextPageToken,omitempty"`
+	Items         []Task `json:"items,omitempty"`
+
+	// NextCursor is set instead of NextPageToken on the proxy's own pages
+	// (?limit= and ?cursor=), until the last one.
+	NextCursor string `json:"nextCursor,omitempty"`
+}
+
+// ListWithTasks is one list and its tasks in a fan-out result. Error is set
+// instead of Tasks when that list could not be fetched.
+type ListWithTasks struct {
+	TaskList
+	Tasks []Task    `json:"tasks"`
+	Error *APIError `json:"error,omitempty"`
+}
+
+type AllTasksResponse struct {
+	Lists []ListWithTasks `json:"lists"`
+}
+
+// TaskWrite is one mutating Tasks API request. It is built before being
+// sent so it can also be reported without sending it (a dry run).
+type TaskWrite struct {
+	Method string     `json:"method"`
+	Path   string     `json:"path"`
+	Query  url.Values `json:"query,omitempty"`
+	Body   any        `json:"body,omitempty"`
+
+	List string `json:"-"` // whose cached reads the write invalidates
+	Task string `json:"-"` // the task changed, except for an insert
+}
@@ -0,0 +1,46 @@
+package api
+
+// TodoComment is a TODO-style comment found by scanning source code.
+type TodoComment struct {
+	File   string `json:"file"` // relative to the repository root, so it is the same on every machine
+	Line   int    `json:"line"`
+	Kind   string `json:"kind,omitempty"` // TODO, FIXME, HACK, ...; TODO when empty
+	Text   string `json:"text"`           // the comment after its keyword
+	Author string `json:"author,omitempty"`
+}
+
+// ImportTodosRequest is the body of POST /api/import/todos. The tasks go to
+// the list with ID List, or else the one titled ListTitle, which is created
+// when missing, or else the first list. Repo names the repository scanned,
+// so identical comments in different repositories are different tasks, and
+// Commit is the commit scanned, kept in each task's code location.
+type ImportTodosRequest struct {
+	List      string        `json:"list,omitempty"`
+	ListTitle string        `json:"list_title,omitempty"`
+	Repo      string        `json:"repo,omitempty"`
+	Commit    string        `json:"commit,omitempty"`
+	Todos     []TodoComment `json:"todos"`
+}
+
+// ImportTodosResponse reports what an import did (or would do, for a dry
+// run) with each comment. List has no ID when a dry run would create it.
+type ImportTodosResponse struct {
+	DryRun    bool           `json:"dry_run"`
+	List      TaskList       `json:"list"`
+	Created   int            `json:"created"`
+	Updated   int            `json:"updated"`
+	Unchanged int            `json:"unchanged"`
+	Results   []ImportedTodo `json:"results"`
+}
+
+// ImportedTodo is the outcome for one comment: Action is "created",
+// "updated" or "unchanged". Request is the write sent to Google, if any,
+// and Task the task afterwards.
+type ImportedTodo struct {
+	Todo        TodoComment `json:"todo"`
+	Fingerprint string      `json:"fingerprint"`
+	Action      string      `json:"action"`
+	Request     *TaskWrite  `json:"request,omitempty"`
+	Task        *Task       `json:"task,omitempty"`
+	Error       *APIError   `json:"error,omitempty"`
+}
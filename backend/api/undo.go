@@ -0,0 +1,26 @@
+package api
+
+// UndoEntry is a mutation made through the proxy that POST /api/undo can
+// revert. Changes are what reverting it applies, in order. A deleted task
+// is brought back by a create (with its subtasks), so it gets a new ID;
+// later changes that name the old ID are sent with the new one.
+type UndoEntry struct {
+	ID      int      `json:"id"`
+	Summary string   `json:"summary"` // what was done, e.g. "batch of 3 changes"
+	At      string   `json:"at"`      // RFC 3339
+	Changes []Change `json:"changes"`
+}
+
+// UndoHistoryResponse is the body of GET /api/undo, newest entry first.
+type UndoHistoryResponse struct {
+	Entries []UndoEntry `json:"entries"`
+}
+
+// UndoResponse reports the entry that was reverted (or would be, for a dry
+// run) and the result of each of its changes.
+type UndoResponse struct {
+	DryRun    bool           `json:"dry_run"`
+	Undone    UndoEntry      `json:"undone"`
+	Results   []ChangeResult `json:"results"`
+	Remaining int            `json:"remaining"` // entries left to undo
+}
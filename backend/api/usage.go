@@ -0,0 +1,38 @@
+package api
+
+// UsageResponse is the body of GET /api/usage: the calls the proxy made to
+// Google since it started, for comparing with the client's quota.
+type UsageResponse struct {
+	Since string `json:"since"` // RFC 3339, when counting started
+
+	// Google resets daily quotas at midnight Pacific Time; QuotaDay is that
+	// day (YYYY-MM-DD), and TasksToday the Tasks API calls made on it.
+	QuotaDay   string `json:"quota_day"`
+	TasksToday int    `json:"tasks_today"`
+	DailyQuota int    `json:"daily_quota"` // upstream.daily_quota
+	Remaining  int    `json:"remaining"`   // never below 0
+
+	// Hours covers the last 48 hours and Days the last 30 quota days, newest
+	// first, leaving out those without calls.
+	Hours     []UsagePeriod   `json:"hours"`
+	Days      []UsagePeriod   `json:"days"`
+	Endpoints []UsageEndpoint `json:"endpoints"` // totals since Since, most called first
+}
+
+// UsagePeriod is the calls made in one hour or day.
+type UsagePeriod struct {
+	Start     string          `json:"start"` // RFC 3339 for hours, YYYY-MM-DD for days
+	Calls     int             `json:"calls"`
+	Endpoints []UsageEndpoint `json:"endpoints"`
+}
+
+// UsageEndpoint counts the calls to one endpoint, named by method, host and
+// path with IDs replaced, e.g. "GET tasks.googleapis.com/tasks/v1/lists/{id}/tasks".
+// Retries are separate calls. Errors include network failures and every
+// non-2xx/3xx response, RateLimited those that were rate limits.
+type UsageEndpoint struct {
+	Endpoint    string `json:"endpoint"`
+	Calls       int    `json:"calls"`
+	Errors      int    `json:"errors"`
+	RateLimited int    `json:"rate_limited"`
+}
@@ -0,0 +1,104 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// OpenSession opens a session on a multi-tenant proxy as user. Set Session
+// to the result before any other call.
+func (c *Client) OpenSession(ctx context.Context, user, secret string) (*api.SessionResponse, error) {
+	var out api.SessionResponse
+	if err := c.do(ctx, "POST", "/auth/session", nil, "", api.SessionRequest{User: user, Secret: secret}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// StartAuth begins an authorization. Open AuthURL in a browser, then wait
+// for the tokens with WaitForAuth (or ExchangeCode, if the redirect lands in
+// your own program).
+func (c *Client) StartAuth(ctx context.Context) (*api.AuthStartResponse, error) {
+	var out api.AuthStartResponse
+	if err := c.do(ctx, "POST", "/auth/start", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PollAuth checks once whether the authorization for state has completed.
+// The tokens are handed out only once.
+func (c *Client) PollAuth(ctx context.Context, state string) (*api.PollResponse, error) {
+	var out api.PollResponse
+	if err := c.do(ctx, "GET", "/auth/poll/"+url.PathEscape(state), nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WaitForAuth polls every interval until the authorization for state
+// completes or ctx ends. A refused authorization is returned as an
+// *api.APIError with Google's error in Upstream.
+func (c *Client) WaitForAuth(ctx context.Context, state string, interval time.Duration) (*api.GoogleToken, error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		poll, err := c.PollAuth(ctx, state)
+		if err != nil {
+			return nil, err
+		}
+		if poll.Completed {
+			return tokenFromPoll(poll.Tokens)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// tokenFromPoll decodes Google's token response as relayed by the poll.
+func tokenFromPoll(tokens map[string]any) (*api.GoogleToken, error) {
+	if googleErr, _ := tokens["error"].(string); googleErr != "" {
+		description, _ := tokens["error_description"].(string)
+		return nil, &api.APIError{
+			Status:   http.StatusBadRequest,
+			Code:     api.CodeUpstreamError,
+			Message:  "Google refused the authorization",
+			Upstream: &api.UpstreamDetail{Status: http.StatusBadRequest, Error: googleErr, Description: description},
+		}
+	}
+
+	data, err := json.Marshal(tokens)
+	if err != nil {
+		return nil, err
+	}
+	var token api.GoogleToken
+	return &token, json.Unmarshal(data, &token)
+}
+
+// ExchangeCode trades the code and state from the OAuth redirect for tokens.
+func (c *Client) ExchangeCode(ctx context.Context, code, state string) (*api.GoogleToken, error) {
+	var out api.GoogleToken
+	if err := c.do(ctx, "POST", "/auth/token", nil, "", api.TokenRequest{Code: code, State: state}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Refresh gets a new access token. Google usually omits RefreshToken from
+// the result; keep using the old one.
+func (c *Client) Refresh(ctx context.Context, refreshToken string) (*api.GoogleToken, error) {
+	var out api.GoogleToken
+	if err := c.do(ctx, "POST", "/auth/refresh", nil, "", api.RefreshRequest{RefreshToken: refreshToken}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
@@ -0,0 +1,180 @@
+// Package client is a typed Go client for the gtask auth proxy, for tools
+// (TUIs, bots, scripts) that want the proxy's auth flow and Tasks endpoints
+// without re-implementing the wire protocol. Request and response bodies are
+// the types in package api.
+//
+//	c := client.New("http://localhost:3000")
+//	start, err := c.StartAuth(ctx)
+//	// open start.AuthURL in a browser, then
+//	token, err := c.WaitForAuth(ctx, start.State, 2*time.Second)
+//	c.AccessToken = token.AccessToken
+//	lists, err := c.AllTasks(ctx, nil)
+//
+// A multi-tenant proxy first needs a session, after which AccessToken may be
+// left empty once the user has authorized:
+//
+//	session, err := c.OpenSession(ctx, "alice", secret)
+//	c.Session = session.Session
+//
+// Failed requests return an *api.APIError carrying the proxy's error
+// envelope, with Status and RetryAfter filled in from the response.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Client calls one proxy. Its fields may be changed between calls but not
+// during them.
+type Client struct {
+	BaseURL     string       // e.g. http://localhost:3000
+	AccessToken string       // Google access token, sent to the /api endpoints
+	AdminToken  string       // the proxy's admin.token, sent to the /admin endpoints
+	Session     string       // multi-tenant session from OpenSession, sent with every request
+	HTTPClient  *http.Client // http.DefaultClient when nil
+}
+
+// New returns a client for the proxy at baseURL.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Health reports the proxy's status and version.
+func (c *Client) Health(ctx context.Context) (*api.HealthResponse, error) {
+	var out api.HealthResponse
+	if err := c.do(ctx, "GET", "/health", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// OpenAPI returns the proxy's OpenAPI description.
+func (c *Client) OpenAPI(ctx context.Context) (json.RawMessage, error) {
+	var out json.RawMessage
+	if err := c.do(ctx, "GET", "/openapi.json", nil, "", nil, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Reload makes the proxy re-read its configuration.
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, "POST", "/admin/reload", nil, c.AdminToken, nil, &api.ReloadResponse{})
+}
+
+// UpdateCheck compares the proxy's version with the latest release.
+func (c *Client) UpdateCheck(ctx context.Context) (*api.UpdateCheckResponse, error) {
+	var out api.UpdateCheckResponse
+	if err := c.do(ctx, "GET", "/admin/update-check", nil, c.AdminToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Sessions lists the proxy's open sessions and the accounts it holds
+// Google tokens for.
+func (c *Client) Sessions(ctx context.Context) (*api.SessionsResponse, error) {
+	var out api.SessionsResponse
+	if err := c.do(ctx, "GET", "/admin/sessions", nil, c.AdminToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ExpireSession ends the session with id (from Sessions).
+func (c *Client) ExpireSession(ctx context.Context, id string) error {
+	return c.do(ctx, "DELETE", "/admin/sessions/"+url.PathEscape(id), nil, c.AdminToken, nil, &api.ExpireSessionResponse{})
+}
+
+// Reauth revokes user's Google grant and ends their sessions, so every
+// client of theirs has to authorize again.
+func (c *Client) Reauth(ctx context.Context, user string) (*api.ReauthResponse, error) {
+	var out api.ReauthResponse
+	if err := c.do(ctx, "POST", "/admin/accounts/"+url.PathEscape(user)+"/reauth", nil, c.AdminToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// do sends a request with body encoded as JSON (unless nil) and decodes a
+// successful response into out. bearer is sent as the Authorization token.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, bearer string, body, out any) error {
+	resp, err := c.send(ctx, method, path, query, bearer, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("%s %s: decoding response: %w", method, path, err)
+	}
+	return nil
+}
+
+// send is do without decoding: it returns a successful response for the
+// caller to read and close.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, bearer string, body any) (*http.Response, error) {
+	target := c.BaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if bearer != "" {
+		req.Header.Set("Authorization", "Bearer "+bearer)
+	}
+	if c.Session != "" {
+		req.Header.Set(api.SessionHeader, c.Session)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, responseError(method, path, resp)
+	}
+	return resp, nil
+}
+
+// responseError decodes the error envelope of a failed response.
+func responseError(method, path string, resp *http.Response) error {
+	var envelope api.ErrorResponse
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&envelope); err != nil || envelope.Error == nil {
+		return fmt.Errorf("%s %s: %s", method, path, resp.Status)
+	}
+	envelope.Error.Status = resp.StatusCode
+	if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+		envelope.Error.RetryAfter = seconds
+	}
+	return envelope.Error
+}
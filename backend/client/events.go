@@ -0,0 +1,48 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"strings"
+)
+
+// Events listens on the proxy's event stream for the access token's user,
+// calling handle with each event's name ("reminder", "change", ...) and JSON
+// data. It returns when ctx is cancelled, the proxy ends the stream or
+// handle returns an error. Reminder events decode into an api.Reminder and
+// change events into an api.TaskEvent.
+func (c *Client) Events(ctx context.Context, handle func(name string, data json.RawMessage) error) error {
+	resp, err := c.send(ctx, "GET", "/api/events", nil, c.AccessToken, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(nil, 1<<20)
+	name, data := "message", ""
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != "" {
+				if err := handle(name, json.RawMessage(data)); err != nil {
+					return err
+				}
+			}
+			name, data = "message", ""
+		case strings.HasPrefix(line, "event:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != "" {
+				data += "\n"
+			}
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
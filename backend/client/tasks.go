@@ -0,0 +1,555 @@
+package client
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Lists returns one page of the user's task lists. query takes Google's
+// maxResults and pageToken.
+func (c *Client) Lists(ctx context.Context, query url.Values) (*api.TaskListsPage, error) {
+	var out api.TaskListsPage
+	if err := c.do(ctx, "GET", "/api/lists", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Tasks returns one page of the tasks in listID. query takes Google's
+// filters (showCompleted, dueMin, ...) and pageToken, or limit, cursor,
+// sort (due, alpha, position or updated) and order (asc or desc) for the
+// proxy's own pages, which NextCursor continues.
+func (c *Client) Tasks(ctx context.Context, listID string, query url.Values) (*api.TasksPage, error) {
+	var out api.TasksPage
+	if err := c.do(ctx, "GET", "/api/lists/"+url.PathEscape(listID)+"/tasks", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AllTasks returns every list with all of its tasks, sorted as query's sort
+// and order say. A list that could not be fetched has Error set instead of
+// Tasks.
+func (c *Client) AllTasks(ctx context.Context, query url.Values) ([]api.ListWithTasks, error) {
+	var out api.AllTasksResponse
+	if err := c.do(ctx, "GET", "/api/tasks", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return out.Lists, nil
+}
+
+// Agenda returns the overdue, due today, due this week and recently
+// completed tasks across lists. date takes today, tomorrow, a weekday or
+// YYYY-MM-DD (empty for today) and tz an IANA zone or UTC offset (empty for
+// the proxy's zone).
+func (c *Client) Agenda(ctx context.Context, date, tz string) (*api.AgendaResponse, error) {
+	var out api.AgendaResponse
+	if err := c.do(ctx, "GET", "/api/agenda", agendaQuery(date, tz), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func agendaQuery(date, tz string) url.Values {
+	query := url.Values{}
+	if date != "" {
+		query.Set("date", date)
+	}
+	if tz != "" {
+		query.Set("tz", tz)
+	}
+	return query
+}
+
+// SmartLists returns the proxy's smart lists with their task counts. date
+// and tz are as for Agenda.
+func (c *Client) SmartLists(ctx context.Context, date, tz string) (*api.SmartListsResponse, error) {
+	var out api.SmartListsResponse
+	if err := c.do(ctx, "GET", "/api/smart", agendaQuery(date, tz), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SmartListOptions are the query of SmartList. Date and TZ are as for
+// Agenda; Sort is due, alpha, position or updated and Order asc or desc.
+// Empty fields keep the proxy's defaults.
+type SmartListOptions struct {
+	Date  string
+	TZ    string
+	Sort  string
+	Order string
+}
+
+func (o SmartListOptions) query() url.Values {
+	query := agendaQuery(o.Date, o.TZ)
+	if o.Sort != "" {
+		query.Set("sort", o.Sort)
+	}
+	if o.Order != "" {
+		query.Set("order", o.Order)
+	}
+	return query
+}
+
+// SmartList returns the open tasks of the smart list id (today, upcoming,
+// overdue or no-date) across every list.
+func (c *Client) SmartList(ctx context.Context, id string, opts SmartListOptions) (*api.SmartListResponse, error) {
+	var out api.SmartListResponse
+	if err := c.do(ctx, "GET", "/api/smart/"+url.PathEscape(id), opts.query(), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Quickfix returns the open tasks that pass filter (overdue, today, week or
+// open; empty for open) as Neovim quickfix entries. tz is as for Agenda.
+func (c *Client) Quickfix(ctx context.Context, filter, tz string) (*api.QuickfixResponse, error) {
+	query := url.Values{}
+	if filter != "" {
+		query.Set("filter", filter)
+	}
+	if tz != "" {
+		query.Set("tz", tz)
+	}
+	var out api.QuickfixResponse
+	if err := c.do(ctx, "GET", "/api/tasks/quickfix", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FuzzySearch returns the tasks whose titles best match q, fzf-style. limit
+// 0 takes the proxy's default.
+func (c *Client) FuzzySearch(ctx context.Context, q string, limit int) (*api.FuzzySearchResponse, error) {
+	query := url.Values{"q": {q}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var out api.FuzzySearchResponse
+	if err := c.do(ctx, "GET", "/api/search/fuzzy", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FuzzySearchNext returns the page of matches after the one that returned
+// cursor, for the same q.
+func (c *Client) FuzzySearchNext(ctx context.Context, q, cursor string, limit int) (*api.FuzzySearchResponse, error) {
+	query := url.Values{"q": {q}, "cursor": {cursor}}
+	if limit > 0 {
+		query.Set("limit", strconv.Itoa(limit))
+	}
+	var out api.FuzzySearchResponse
+	if err := c.do(ctx, "GET", "/api/search/fuzzy", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Duplicates returns the groups of likely duplicate tasks. query takes
+// threshold, list, across and completed; nil uses the proxy's defaults.
+func (c *Client) Duplicates(ctx context.Context, query url.Values) (*api.DuplicatesResponse, error) {
+	var out api.DuplicatesResponse
+	if err := c.do(ctx, "GET", "/api/duplicates", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// MergeDuplicates merges req.Duplicates into req.Keep and deletes them.
+func (c *Client) MergeDuplicates(ctx context.Context, req api.MergeDuplicatesRequest) (*api.MergeDuplicatesResponse, error) {
+	var out api.MergeDuplicatesResponse
+	if err := c.do(ctx, "POST", "/api/duplicates/merge", nil, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Snooze pushes taskID's due date back and holds its reminders until the
+// snooze ends. req.List may be left empty for the proxy to find the task.
+func (c *Client) Snooze(ctx context.Context, taskID string, req api.SnoozeRequest) (*api.SnoozeResponse, error) {
+	return c.snooze(ctx, taskID, req, nil)
+}
+
+// PreviewSnooze reports what Snooze would change, without changing it.
+func (c *Client) PreviewSnooze(ctx context.Context, taskID string, req api.SnoozeRequest) (*api.SnoozeResponse, error) {
+	return c.snooze(ctx, taskID, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) snooze(ctx context.Context, taskID string, req api.SnoozeRequest, query url.Values) (*api.SnoozeResponse, error) {
+	var out api.SnoozeResponse
+	if err := c.do(ctx, "POST", "/api/tasks/"+url.PathEscape(taskID)+"/snooze", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// QuickAdd parses a line like "pay rent tomorrow 9am #finance !p1
+// @personal" into a task and creates it.
+func (c *Client) QuickAdd(ctx context.Context, req api.QuickAddRequest) (*api.QuickAddResponse, error) {
+	return c.quickAdd(ctx, req, nil)
+}
+
+// PreviewQuickAdd reports how QuickAdd would parse req, without creating
+// the task.
+func (c *Client) PreviewQuickAdd(ctx context.Context, req api.QuickAddRequest) (*api.QuickAddResponse, error) {
+	return c.quickAdd(ctx, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) quickAdd(ctx context.Context, req api.QuickAddRequest, query url.Values) (*api.QuickAddResponse, error) {
+	var out api.QuickAddResponse
+	if err := c.do(ctx, "POST", "/api/quickadd", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Schedule time-blocks taskID with a Google Calendar event, moving the
+// event it was scheduled with before. req.List may be left empty for the
+// proxy to find the task.
+func (c *Client) Schedule(ctx context.Context, taskID string, req api.ScheduleRequest) (*api.ScheduleResponse, error) {
+	return c.schedule(ctx, taskID, req, nil)
+}
+
+// PreviewSchedule reports the event Schedule would write, without writing
+// it.
+func (c *Client) PreviewSchedule(ctx context.Context, taskID string, req api.ScheduleRequest) (*api.ScheduleResponse, error) {
+	return c.schedule(ctx, taskID, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) schedule(ctx context.Context, taskID string, req api.ScheduleRequest, query url.Values) (*api.ScheduleResponse, error) {
+	var out api.ScheduleResponse
+	if err := c.do(ctx, "POST", "/api/tasks/"+url.PathEscape(taskID)+"/schedule", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// FromEmail creates a task from a Gmail message: req.Message, or the newest
+// message matching req.Query.
+func (c *Client) FromEmail(ctx context.Context, req api.FromEmailRequest) (*api.FromEmailResponse, error) {
+	return c.fromEmail(ctx, req, nil)
+}
+
+// PreviewFromEmail returns the task FromEmail would create, without
+// creating it.
+func (c *Client) PreviewFromEmail(ctx context.Context, req api.FromEmailRequest) (*api.FromEmailResponse, error) {
+	return c.fromEmail(ctx, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) fromEmail(ctx context.Context, req api.FromEmailRequest, query url.Values) (*api.FromEmailResponse, error) {
+	var out api.FromEmailResponse
+	if err := c.do(ctx, "POST", "/api/tasks/from-email", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TaskLinks returns taskID's links: Google's own and those attached through
+// the proxy. listID may be empty for the proxy to find the task.
+func (c *Client) TaskLinks(ctx context.Context, listID, taskID string) (*api.TaskLinksResponse, error) {
+	var out api.TaskLinksResponse
+	if err := c.do(ctx, "GET", "/api/tasks/"+url.PathEscape(taskID)+"/links", listQuery(listID), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// AddTaskLink attaches a URL or file path to taskID.
+func (c *Client) AddTaskLink(ctx context.Context, taskID string, req api.AddLinkRequest) (*api.TaskLinksResponse, error) {
+	var out api.TaskLinksResponse
+	if err := c.do(ctx, "POST", "/api/tasks/"+url.PathEscape(taskID)+"/links", nil, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveTaskLink removes the attachment linkID from taskID.
+func (c *Client) RemoveTaskLink(ctx context.Context, listID, taskID, linkID string) (*api.TaskLinksResponse, error) {
+	var out api.TaskLinksResponse
+	path := "/api/tasks/" + url.PathEscape(taskID) + "/links/" + url.PathEscape(linkID)
+	if err := c.do(ctx, "DELETE", path, listQuery(listID), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TaskLocation returns where in the code taskID was created; Location is
+// nil when it was not. listID may be empty for the proxy to find the task.
+func (c *Client) TaskLocation(ctx context.Context, listID, taskID string) (*api.TaskLocationResponse, error) {
+	var out api.TaskLocationResponse
+	if err := c.do(ctx, "GET", "/api/tasks/"+url.PathEscape(taskID)+"/location", listQuery(listID), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// SetTaskLocation sets taskID's code location.
+func (c *Client) SetTaskLocation(ctx context.Context, taskID string, req api.SetLocationRequest) (*api.TaskLocationResponse, error) {
+	var out api.TaskLocationResponse
+	if err := c.do(ctx, "PUT", "/api/tasks/"+url.PathEscape(taskID)+"/location", nil, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RemoveTaskLocation forgets taskID's code location.
+func (c *Client) RemoveTaskLocation(ctx context.Context, listID, taskID string) (*api.TaskLocationResponse, error) {
+	var out api.TaskLocationResponse
+	if err := c.do(ctx, "DELETE", "/api/tasks/"+url.PathEscape(taskID)+"/location", listQuery(listID), c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func listQuery(listID string) url.Values {
+	query := url.Values{}
+	if listID != "" {
+		query.Set("list", listID)
+	}
+	return query
+}
+
+// ClearList archives listID's completed tasks in the proxy, then clears
+// them from the list.
+func (c *Client) ClearList(ctx context.Context, listID string) (*api.ClearResponse, error) {
+	return c.clearList(ctx, listID, nil)
+}
+
+// PreviewClearList reports the tasks ClearList would archive and clear,
+// without doing either.
+func (c *Client) PreviewClearList(ctx context.Context, listID string) (*api.ClearResponse, error) {
+	return c.clearList(ctx, listID, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) clearList(ctx context.Context, listID string, query url.Values) (*api.ClearResponse, error) {
+	var out api.ClearResponse
+	if err := c.do(ctx, "POST", "/api/lists/"+url.PathEscape(listID)+"/clear", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ShareList snapshots listID's tasks into a read-only link that needs no
+// Google access. req.Expires may be empty for a link that works until
+// revoked; the response holds the only copy of the URL.
+func (c *Client) ShareList(ctx context.Context, listID string, req api.ShareRequest) (*api.ShareResponse, error) {
+	var out api.ShareResponse
+	if err := c.do(ctx, "POST", "/api/tasklists/"+url.PathEscape(listID)+"/share", nil, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Shares returns the shared snapshots whose links still work, newest first.
+func (c *Client) Shares(ctx context.Context) (*api.SharesResponse, error) {
+	var out api.SharesResponse
+	if err := c.do(ctx, "GET", "/api/shares", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RevokeShare stops the link of the share shareID, as named by Shares.
+func (c *Client) RevokeShare(ctx context.Context, shareID string) (*api.RevokeShareResponse, error) {
+	var out api.RevokeShareResponse
+	if err := c.do(ctx, "DELETE", "/api/shares/"+url.PathEscape(shareID), nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Archive returns archived tasks completed from from through to (YYYY-MM-DD,
+// either may be empty) in tz, of listID or every list when it is empty.
+func (c *Client) Archive(ctx context.Context, from, to, tz, listID string) (*api.ArchiveResponse, error) {
+	query := listQuery(listID)
+	for key, value := range map[string]string{"from": from, "to": to, "tz": tz} {
+		if value != "" {
+			query.Set(key, value)
+		}
+	}
+	var out api.ArchiveResponse
+	if err := c.do(ctx, "GET", "/api/archive", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Trends returns completion statistics over rangeSpec ("30d", "12w"; ""
+// for the proxy's default), with days counted in tz.
+func (c *Client) Trends(ctx context.Context, rangeSpec, tz string) (*api.TrendsResponse, error) {
+	query := url.Values{}
+	if rangeSpec != "" {
+		query.Set("range", rangeSpec)
+	}
+	if tz != "" {
+		query.Set("tz", tz)
+	}
+	var out api.TrendsResponse
+	if err := c.do(ctx, "GET", "/api/stats/trends", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Usage returns the calls the proxy made to Google since it started, and
+// how many Tasks API calls are left in today's quota.
+func (c *Client) Usage(ctx context.Context) (*api.UsageResponse, error) {
+	var out api.UsageResponse
+	if err := c.do(ctx, "GET", "/api/usage", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BackupStatus returns the proxy's backup settings and the backups it
+// keeps.
+func (c *Client) BackupStatus(ctx context.Context) (*api.BackupStatusResponse, error) {
+	var out api.BackupStatusResponse
+	if err := c.do(ctx, "GET", "/api/backup/status", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Backups returns the backups the proxy keeps, newest first, with the lists
+// each holds for the caller.
+func (c *Client) Backups(ctx context.Context) (*api.BackupListResponse, error) {
+	var out api.BackupListResponse
+	if err := c.do(ctx, "GET", "/api/backup/list", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Restore creates the lists and tasks of backup id that no longer exist:
+// all of them, or only req's list or task.
+func (c *Client) Restore(ctx context.Context, id string, req api.RestoreRequest) (*api.RestoreResponse, error) {
+	return c.restore(ctx, id, req, nil)
+}
+
+// PreviewRestore reports what Restore would create, without creating it.
+func (c *Client) PreviewRestore(ctx context.Context, id string, req api.RestoreRequest) (*api.RestoreResponse, error) {
+	return c.restore(ctx, id, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) restore(ctx context.Context, id string, req api.RestoreRequest, query url.Values) (*api.RestoreResponse, error) {
+	var out api.RestoreResponse
+	if err := c.do(ctx, "POST", "/api/backup/"+url.PathEscape(id)+"/restore", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// TaskHistory returns the recorded changes of taskID, newest first.
+func (c *Client) TaskHistory(ctx context.Context, taskID string) (*api.TaskHistoryResponse, error) {
+	var out api.TaskHistoryResponse
+	if err := c.do(ctx, "GET", "/api/tasks/"+url.PathEscape(taskID)+"/history", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Batch applies changes in order. A change that fails has Error set in its
+// result; the others are still applied.
+func (c *Client) Batch(ctx context.Context, changes []api.Change) (*api.BatchResponse, error) {
+	return c.batch(ctx, changes, nil)
+}
+
+// PreviewBatch validates changes and returns the requests Batch would send,
+// without sending them.
+func (c *Client) PreviewBatch(ctx context.Context, changes []api.Change) (*api.BatchResponse, error) {
+	return c.batch(ctx, changes, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) batch(ctx context.Context, changes []api.Change, query url.Values) (*api.BatchResponse, error) {
+	var out api.BatchResponse
+	if err := c.do(ctx, "POST", "/api/batch", query, c.AccessToken, api.BatchRequest{Changes: changes}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BulkStatus sets tasks to status, "completed" or "needsAction",
+// concurrently. A task that fails has Error set in its result; the others
+// are still updated.
+func (c *Client) BulkStatus(ctx context.Context, status string, tasks []api.TaskRef) (*api.BulkStatusResponse, error) {
+	var out api.BulkStatusResponse
+	if err := c.do(ctx, "POST", "/api/tasks/bulk-status", nil, c.AccessToken, api.BulkStatusRequest{Status: status, Tasks: tasks}, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Reconcile merges a buffer's edits of a list with the list's current state
+// and applies the changes that do not conflict. Use the response's Remote
+// as the base of the next call.
+func (c *Client) Reconcile(ctx context.Context, req api.ReconcileRequest) (*api.ReconcileResponse, error) {
+	return c.reconcile(ctx, req, nil)
+}
+
+// PreviewReconcile returns the changes and conflicts Reconcile would find,
+// without applying anything.
+func (c *Client) PreviewReconcile(ctx context.Context, req api.ReconcileRequest) (*api.ReconcileResponse, error) {
+	return c.reconcile(ctx, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) reconcile(ctx context.Context, req api.ReconcileRequest, query url.Values) (*api.ReconcileResponse, error) {
+	var out api.ReconcileResponse
+	if err := c.do(ctx, "POST", "/api/reconcile", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// UndoHistory returns the session's mutations that Undo can revert, newest
+// first.
+func (c *Client) UndoHistory(ctx context.Context) (*api.UndoHistoryResponse, error) {
+	var out api.UndoHistoryResponse
+	if err := c.do(ctx, "GET", "/api/undo", nil, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Undo reverts the session's latest mutation made through the proxy.
+func (c *Client) Undo(ctx context.Context) (*api.UndoResponse, error) {
+	return c.undo(ctx, nil)
+}
+
+// PreviewUndo reports what Undo would revert, without reverting it.
+func (c *Client) PreviewUndo(ctx context.Context) (*api.UndoResponse, error) {
+	return c.undo(ctx, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) undo(ctx context.Context, query url.Values) (*api.UndoResponse, error) {
+	var out api.UndoResponse
+	if err := c.do(ctx, "POST", "/api/undo", query, c.AccessToken, nil, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// ImportTodos creates a task for each of req's TODO comments that has none
+// yet and updates those whose comment changed.
+func (c *Client) ImportTodos(ctx context.Context, req api.ImportTodosRequest) (*api.ImportTodosResponse, error) {
+	return c.importTodos(ctx, req, nil)
+}
+
+// PreviewImportTodos reports what ImportTodos would do with each comment,
+// without writing anything.
+func (c *Client) PreviewImportTodos(ctx context.Context, req api.ImportTodosRequest) (*api.ImportTodosResponse, error) {
+	return c.importTodos(ctx, req, url.Values{"dry_run": {"1"}})
+}
+
+func (c *Client) importTodos(ctx context.Context, req api.ImportTodosRequest, query url.Values) (*api.ImportTodosResponse, error) {
+	var out api.ImportTodosResponse
+	if err := c.do(ctx, "POST", "/api/import/todos", query, c.AccessToken, req, &out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
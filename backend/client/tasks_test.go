@@ -0,0 +1,108 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// proxy answers every request with status and body, keeping the last
+// request and its decoded JSON body.
+func proxy(t *testing.T, status int, body any) (*Client, *http.Request, *api.ImportTodosRequest) {
+	t.Helper()
+	var got http.Request
+	var sent api.ImportTodosRequest
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = *r.Clone(context.Background())
+		if err := json.NewDecoder(r.Body).Decode(&sent); err != nil {
+			t.Errorf("request body: %v", err)
+		}
+		if status == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "30")
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(body)
+	}))
+	t.Cleanup(srv.Close)
+	c := New(srv.URL + "/")
+	c.AccessToken = "token"
+	c.Session = "session"
+	return c, &got, &sent
+}
+
+func TestImportTodos(t *testing.T) {
+	req := api.ImportTodosRequest{ListTitle: "gtask.nvim", Repo: "github.com/p-tupe/gtask.nvim", Todos: []api.TodoComment{
+		{File: "lua/gtask/api.lua", Line: 12, Kind: "FIXME", Text: "retry on 429"},
+	}}
+	c, got, sent := proxy(t, http.StatusOK, api.ImportTodosResponse{
+		List:    api.TaskList{ID: "L1", Title: "gtask.nvim"},
+		Created: 1,
+		Results: []api.ImportedTodo{{Todo: req.Todos[0], Fingerprint: "f1", Action: "created"}},
+	})
+
+	resp, err := c.ImportTodos(context.Background(), req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Method != "POST" || got.URL.Path != "/api/import/todos" || got.URL.RawQuery != "" {
+		t.Errorf("sent %s %s", got.Method, got.URL)
+	}
+	if got.Header.Get("Authorization") != "Bearer token" || got.Header.Get(api.SessionHeader) != "session" {
+		t.Errorf("headers = %v", got.Header)
+	}
+	if sent.Repo != req.Repo || len(sent.Todos) != 1 || sent.Todos[0] != req.Todos[0] {
+		t.Errorf("body = %+v", sent)
+	}
+	if resp.DryRun || resp.List.ID != "L1" || resp.Created != 1 || resp.Results[0].Action != "created" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestPreviewImportTodos(t *testing.T) {
+	c, got, _ := proxy(t, http.StatusOK, api.ImportTodosResponse{DryRun: true, List: api.TaskList{Title: "new list"}})
+
+	resp, err := c.PreviewImportTodos(context.Background(), api.ImportTodosRequest{ListTitle: "new list"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got.URL.Query().Get("dry_run") != "1" {
+		t.Errorf("query = %q, want dry_run=1", got.URL.RawQuery)
+	}
+	// A dry run that would create the list has no ID for it yet
+	if !resp.DryRun || resp.List.ID != "" {
+		t.Errorf("response = %+v", resp)
+	}
+}
+
+func TestImportTodosError(t *testing.T) {
+	c, _, _ := proxy(t, http.StatusTooManyRequests, api.ErrorResponse{Error: &api.APIError{
+		Code: api.CodeRateLimited, Message: "Google's rate limit was reached", Retryable: true,
+	}})
+
+	resp, err := c.ImportTodos(context.Background(), api.ImportTodosRequest{})
+
+	var apiErr *api.APIError
+	if resp != nil || !errors.As(err, &apiErr) {
+		t.Fatalf("ImportTodos = %v, %v; want an *api.APIError", resp, err)
+	}
+	if apiErr.Code != api.CodeRateLimited || apiErr.Status != http.StatusTooManyRequests || apiErr.RetryAfter != 30 {
+		t.Errorf("error = %+v", apiErr)
+	}
+}
+
+func TestSmartListOptionsQuery(t *testing.T) {
+	if got := (SmartListOptions{}).query().Encode(); got != "" {
+		t.Errorf("no options = %q, want the proxy's defaults", got)
+	}
+	opts := SmartListOptions{Date: "2025-03-14", TZ: "Europe/Berlin", Sort: "alpha", Order: "desc"}
+	if got, want := opts.query().Encode(), "date=2025-03-14&order=desc&sort=alpha&tz=Europe%2FBerlin"; got != want {
+		t.Errorf("query = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,13 @@
+// Command gtask-auth-proxy is the OAuth proxy and command line client for
+// gtask.nvim. See the backend README for its commands and configuration.
+package main
+
+import (
+	"os"
+
+	"github.com/p-tupe/gtask.nvim/backend/proxy"
+)
+
+func main() {
+	os.Exit(proxy.Main(os.Args[1:]))
+}
@@ -1,3 +1,3 @@
-module gtask-auth-proxy
+module github.com/p-tupe/gtask.nvim/backend
 
 go 1.25
\ No newline at end of file
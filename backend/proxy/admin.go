@@ -0,0 +1,249 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"log"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// authorizeAdmin checks the admin bearer token. Admin endpoints are disabled
+// entirely when no token is configured.
+func (s *Server) authorizeAdmin(w http.ResponseWriter, r *http.Request) bool {
+	token := s.config().AdminToken
+	if token == "" {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Admin endpoints are disabled")
+		return false
+	}
+
+	given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+		writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "Unauthorized")
+		return false
+	}
+	return true
+}
+
+// POST /admin/reload - Reload configuration without restarting
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	if err := s.reload(); err != nil {
+		log.Printf("Config reload failed, keeping previous configuration: %v", err)
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Reload failed: "+err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.ReloadResponse{Reloaded: true})
+}
+
+// GET /admin/metrics - Runtime and cache eviction counters (expvar format)
+func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	expvar.Handler().ServeHTTP(w, r)
+}
+
+// GET /admin/sessions - Open sessions and the accounts with a stored Google token
+//
+// In multi-tenant mode the accounts are the tenants; otherwise they are
+// those in cli.tokens_file and there are no sessions.
+func (s *Server) handleSessions(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	cfg := s.config()
+
+	resp := api.SessionsResponse{Sessions: []api.SessionInfo{}, Accounts: []api.AccountInfo{}}
+	counts := make(map[string]int)
+	tokens := make(map[string]Token)
+	var users []string
+	if s.tenants != nil {
+		sessions, err := s.tenants.sessions.List()
+		if err != nil {
+			log.Printf("Reading sessions: %v", err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read sessions")
+			return
+		}
+		for id, session := range sessions {
+			resp.Sessions = append(resp.Sessions, api.SessionInfo{
+				ID:        id,
+				User:      session.User,
+				Client:    session.Client,
+				CreatedAt: session.Created.Format(time.RFC3339),
+				ExpiresAt: session.Expires.Format(time.RFC3339),
+			})
+			counts[session.User]++
+		}
+		slices.SortFunc(resp.Sessions, func(a, b api.SessionInfo) int {
+			return cmp.Or(cmp.Compare(a.User, b.User), cmp.Compare(a.CreatedAt, b.CreatedAt))
+		})
+
+		users = slices.Sorted(maps.Keys(cfg.Tenants.Users))
+		for _, user := range users {
+			token, err := s.tenants.Get(user).Tokens.Load(defaultAccount)
+			if err == nil {
+				tokens[user] = token
+			} else if !errors.Is(err, ErrNotLoggedIn) {
+				log.Printf("Reading token of %s: %v", user, err)
+			}
+		}
+	} else {
+		var err error
+		if tokens, err = NewTokenStore(cfg.TokensFile).All(); err != nil {
+			log.Printf("Reading tokens: %v", err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read tokens")
+			return
+		}
+		users = slices.Sorted(maps.Keys(tokens))
+	}
+
+	for _, user := range users {
+		account := api.AccountInfo{User: user, Sessions: counts[user]}
+		if token, ok := tokens[user]; ok {
+			account.Authorized = true
+			account.Refreshable = token.RefreshToken != ""
+			if !token.Expiry.IsZero() {
+				account.TokenExpiry = token.Expiry.Format(time.RFC3339)
+			}
+		}
+		resp.Accounts = append(resp.Accounts, account)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// DELETE /admin/sessions/{id} - End one session and its event streams
+func (s *Server) handleExpireSession(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	if s.tenants == nil {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Multi-tenant mode is disabled")
+		return
+	}
+
+	expired, err := s.tenants.sessions.Expire(r.PathValue("id"))
+	if err != nil {
+		log.Printf("Expiring session: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to expire session")
+		return
+	}
+	if !expired {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Session not found")
+		return
+	}
+	for _, sub := range s.events.Subscribers() {
+		if sub.session == r.PathValue("id") {
+			s.events.Drop(sub)
+		}
+	}
+	log.Printf("Admin expired session %s", r.PathValue("id"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.ExpireSessionResponse{Expired: true})
+}
+
+// POST /admin/accounts/{user}/reauth - Cut an account off until it authorizes again
+//
+// The stored token's grant is revoked at Google, which signs out every
+// client holding a token from it (including ones the proxy never saw), the
+// token is deleted, and in multi-tenant mode the user's sessions and event
+// streams end.
+func (s *Server) handleReauth(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+	cfg := s.config()
+	user := r.PathValue("user")
+
+	store, account := NewTokenStore(cfg.TokensFile), user
+	if s.tenants != nil {
+		if _, ok := cfg.Tenants.Users[user]; !ok {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, "Unknown user")
+			return
+		}
+		store, account = s.tenants.Get(user).Tokens, defaultAccount
+	}
+
+	resp := api.ReauthResponse{User: user}
+	token, err := store.Load(account)
+	switch {
+	case errors.Is(err, ErrNotLoggedIn):
+		if s.tenants == nil {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, "No token is stored for "+user)
+			return
+		}
+	case err != nil:
+		log.Printf("Reading token of %s: %v", user, err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read token")
+		return
+	default:
+		// Keep the token when Google could not be asked, so revoking can be retried
+		revoked, err := s.revokeToken(r.Context(), cmp.Or(token.RefreshToken, token.AccessToken))
+		if err != nil {
+			log.Printf("Revoking token of %s: %v", user, err)
+			writeAPIError(w, asAPIError(err, "Token revocation failed"))
+			return
+		}
+		resp.Revoked = revoked
+		if err := store.Delete(account); err != nil {
+			log.Printf("Deleting token of %s: %v", user, err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to delete token")
+			return
+		}
+		resp.TokenDeleted = true
+	}
+
+	if s.tenants != nil {
+		if resp.EndedSessions, err = s.tenants.sessions.ExpireUser(user); err != nil {
+			log.Printf("Expiring sessions of %s: %v", user, err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to expire sessions")
+			return
+		}
+		for _, sub := range s.events.Subscribers() {
+			if sub.user == user {
+				s.events.Drop(sub)
+			}
+		}
+	}
+	log.Printf("Admin cut off %s (grant revoked: %t, sessions ended: %d)", user, resp.Revoked, resp.EndedSessions)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// revokeToken revokes token's grant at Google. revoked is false when Google
+// no longer knew the token (already revoked or expired).
+func (s *Server) revokeToken(ctx context.Context, token string) (revoked bool, err error) {
+	resp, err := s.upstream.PostForm(ctx, googleRevokeURL, url.Values{"token": {token}})
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		return true, nil
+	case resp.StatusCode == http.StatusBadRequest:
+		return false, nil
+	default:
+		return false, upstreamResponseError(resp, "Token revocation failed")
+	}
+}
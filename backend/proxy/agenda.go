@@ -0,0 +1,133 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// agendaDays is how many days from the agenda date DueThisWeek covers,
+// including that date.
+const agendaDays = 7
+
+// buildAgenda sorts the tasks in lists into the sections of the agenda for
+// date, which is midnight in the user's time zone.
+func buildAgenda(lists []api.ListWithTasks, date time.Time) api.AgendaResponse {
+	day := date.Format(time.DateOnly)
+	weekEnd := date.AddDate(0, 0, agendaDays-1).Format(time.DateOnly)
+	completedSince := date.AddDate(0, 0, -1)
+	completedUntil := date.AddDate(0, 0, 1)
+
+	agenda := api.AgendaResponse{
+		Date:              day,
+		TimeZone:          date.Location().String(),
+		Overdue:           []api.AgendaItem{},
+		DueToday:          []api.AgendaItem{},
+		DueThisWeek:       []api.AgendaItem{},
+		RecentlyCompleted: []api.AgendaItem{},
+	}
+	for _, list := range lists {
+		if list.Error != nil {
+			agenda.Failed = append(agenda.Failed, list)
+			continue
+		}
+		for _, task := range list.Tasks {
+			if task.Deleted {
+				continue
+			}
+			item := api.AgendaItem{List: list.TaskList, Task: task}
+			if task.Status == "completed" {
+				if completed, err := time.Parse(time.RFC3339, task.Completed); err == nil &&
+					!completed.Before(completedSince) && completed.Before(completedUntil) {
+					agenda.RecentlyCompleted = append(agenda.RecentlyCompleted, item)
+				}
+				continue
+			}
+
+			due := dueDate(task)
+			switch {
+			case due == "":
+			case due < day:
+				agenda.Overdue = append(agenda.Overdue, item)
+			case due == day:
+				agenda.DueToday = append(agenda.DueToday, item)
+			case due <= weekEnd:
+				agenda.DueThisWeek = append(agenda.DueThisWeek, item)
+			}
+		}
+	}
+
+	byDue := func(a, b api.AgendaItem) int {
+		return cmp.Or(cmp.Compare(dueDate(a.Task), dueDate(b.Task)), cmp.Compare(a.List.Title, b.List.Title), cmp.Compare(a.Task.Position, b.Task.Position))
+	}
+	slices.SortFunc(agenda.Overdue, byDue)
+	slices.SortFunc(agenda.DueToday, byDue)
+	slices.SortFunc(agenda.DueThisWeek, byDue)
+	slices.SortFunc(agenda.RecentlyCompleted, func(a, b api.AgendaItem) int {
+		return cmp.Compare(b.Task.Completed, a.Task.Completed)
+	})
+	return agenda
+}
+
+// agendaDate resolves the date and tz parameters of an agenda request. tz
+// is an IANA zone name or a UTC offset like +05:30 and defaults to the
+// server's zone; date takes the forms of parseDate and defaults to today.
+func agendaDate(date, tz string, now time.Time) (time.Time, error) {
+	loc := now.Location()
+	if tz != "" {
+		var err error
+		if loc, err = parseTimeZone(tz); err != nil {
+			return time.Time{}, err
+		}
+	}
+	return parseDate(cmp.Or(date, "today"), now.In(loc))
+}
+
+// parseTimeZone reads an IANA zone name or a UTC offset (+05:30 or +0530,
+// as printed by date +%z).
+func parseTimeZone(tz string) (*time.Location, error) {
+	if offset, err := time.Parse("-07:00", tz); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(tz, seconds), nil
+	}
+	if offset, err := time.Parse("-0700", tz); err == nil {
+		_, seconds := offset.Zone()
+		return time.FixedZone(tz, seconds), nil
+	}
+	loc, err := time.LoadLocation(tz)
+	if err != nil || strings.EqualFold(tz, "local") {
+		return nil, fmt.Errorf("unknown time zone %q, use an IANA name like Europe/Berlin or an offset like +01:00", tz)
+	}
+	return loc, nil
+}
+
+// GET /api/agenda - Overdue, due today, due this week and recently completed tasks
+func (s *Server) handleAgenda(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	date, err := agendaDate(r.URL.Query().Get("date"), r.URL.Query().Get("tz"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	// The same query as change polling, so each can use the other's cached reads
+	lists, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildAgenda(lists, date))
+}
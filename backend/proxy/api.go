@@ -0,0 +1,224 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// bearerToken extracts the caller's Google access token. /api endpoints act
+// on behalf of that token; the backend never stores it.
+func bearerToken(w http.ResponseWriter, r *http.Request) (string, bool) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "Missing Authorization: Bearer <access token>")
+		return "", false
+	}
+	return token, true
+}
+
+// accountOf remembers the accounts of up to maxAccounts tokens for
+// accountTTL, which is how long a token revoked at Google may still read
+// the proxy's records.
+const (
+	accountTTL  = 5 * time.Minute
+	maxAccounts = 1000
+)
+
+// verifiedToken is bearerToken for endpoints that serve the proxy's own
+// records rather than Google's: any page may call the API, so the token is
+// first checked with Google. account is the ID of its account (see
+// accountOf), by which those records are kept.
+func (s *Server) verifiedToken(w http.ResponseWriter, r *http.Request) (token, account string, ok bool) {
+	token, ok = bearerToken(w, r)
+	if !ok {
+		return "", "", false
+	}
+	account, err := s.accountOf(r.Context(), token)
+	if err != nil {
+		writeTasksError(w, err)
+		return "", "", false
+	}
+	return token, account, true
+}
+
+// accountOf returns a stable ID of the account of token: the ID of its
+// default task list, which the Tasks scope can always read and Google never
+// changes. It is remembered by token for accountTTL.
+func (s *Server) accountOf(ctx context.Context, token string) (string, error) {
+	key := sessionKey(token)
+	if account, ok := s.accounts.Get(key); ok {
+		return account, nil
+	}
+	list, err := s.tasks.GetTaskList(ctx, token, "@default")
+	if err != nil {
+		return "", err
+	}
+	if list.ID == "" {
+		return "", errors.New("the default task list has no ID")
+	}
+	s.accounts.Set(key, list.ID, accountTTL)
+	return list.ID, nil
+}
+
+// passQuery copies the allowed query parameters from r.
+func passQuery(r *http.Request, allowed ...string) url.Values {
+	query := url.Values{}
+	for _, name := range allowed {
+		if v := r.URL.Query().Get(name); v != "" {
+			query.Set(name, v)
+		}
+	}
+	return query
+}
+
+// proxyGet streams a (possibly cached) Google Tasks API response to the client.
+func (s *Server) proxyGet(w http.ResponseWriter, r *http.Request, path string, query url.Values) {
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	body, err := s.tasks.Open(r.Context(), token, path, query)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", body.ContentType)
+	w.Header().Set("X-Cache", body.CacheStatus)
+	if body.ETag != "" {
+		w.Header().Set("ETag", body.ETag)
+	}
+	if _, err := io.Copy(w, body); err != nil {
+		log.Printf("Error streaming Tasks API response: %v", err)
+	}
+}
+
+// writeTasksError responds to a failed Tasks API call.
+func writeTasksError(w http.ResponseWriter, err error) {
+	apiErr := asAPIError(err, "Google Tasks request failed")
+	if apiErr.Upstream == nil && apiErr.Code != api.CodeCancelled {
+		log.Printf("Tasks API error: %v", err)
+	}
+	writeAPIError(w, apiErr)
+}
+
+// GET /api/lists - Task lists of the authenticated user
+func (s *Server) handleListTaskLists(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+	s.proxyGet(w, r, "/users/@me/lists", passQuery(r, "maxResults", "pageToken"))
+}
+
+// taskFilters are the Tasks API's filters of a list's tasks.
+var taskFilters = []string{
+	"completedMax", "completedMin", "dueMax", "dueMin", "updatedMin",
+	"showCompleted", "showDeleted", "showHidden",
+}
+
+// GET /api/lists/{list}/tasks - Tasks in one list
+//
+// Passed through to Google page by page, unless ?limit=, ?cursor=, ?sort=
+// or ?order= ask for the proxy's own pages: then the whole list is read
+// through the cache, sorted (by position, Google's order, unless ?sort=
+// says otherwise) and paged, with nextCursor fetching the next page.
+func (s *Server) handleListTasks(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+	path := "/lists/" + url.PathEscape(r.PathValue("list")) + "/tasks"
+	query := r.URL.Query()
+	if !query.Has("limit") && !query.Has("cursor") && !query.Has("sort") && !query.Has("order") {
+		s.proxyGet(w, r, path, passQuery(r, slices.Concat(taskFilters, []string{"maxResults", "pageToken"})...))
+		return
+	}
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	if query.Has("maxResults") || query.Has("pageToken") {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Use either limit and cursor or maxResults and pageToken")
+		return
+	}
+	limit, err := pageLimit(query, defaultPageLimit, maxPageLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	order, sorted, err := parseTaskSort(query, "position")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	if !sorted {
+		order = taskSort{by: "position"}
+	}
+	filters := passQuery(r, taskFilters...)
+	tasks, err := s.tasks.ListTasks(r.Context(), token, r.PathValue("list"), filters)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	key := order.sortTasks(tasks)
+	filters.Set("list", r.PathValue("list"))
+	filters.Set("sort", order.by)
+	if order.desc {
+		filters.Set("order", "desc")
+	}
+	scope := cursorScope(filters, slices.Concat(taskFilters, []string{"list", "sort", "order"})...)
+	resp := api.TasksPage{Kind: "tasks#tasks"}
+	resp.Items, resp.NextCursor, err = cursorPage(tasks, key, scope, query.Get("cursor"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GET /api/tasks - Every list with its tasks, fetched concurrently
+//
+// ?sort= and ?order= sort each list's tasks; they are in Google's order
+// otherwise.
+func (s *Server) handleAllTasks(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	order, sorted, err := parseTaskSort(r.URL.Query(), "position")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	query := url.Values{"showCompleted": {"true"}, "showHidden": {"true"}}
+	for name, v := range passQuery(r, "showCompleted", "showHidden", "dueMin", "dueMax", "updatedMin") {
+		query[name] = v
+	}
+
+	lists, err := s.tasks.FetchAll(r.Context(), token, query, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	if sorted {
+		for _, list := range lists {
+			order.sortTasks(list.Tasks)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.AllTasksResponse{Lists: lists})
+}
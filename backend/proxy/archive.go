@@ -0,0 +1,230 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	defaultArchiveLimit = 100
+	maxArchiveLimit     = 1000
+)
+
+// ArchiveStore keeps completed tasks from cleared lists, by task ID, in a
+// JSON file readable only by its owner. Google hides cleared tasks for
+// good, so this is the only record of them.
+type ArchiveStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewArchiveStore(path string) *ArchiveStore {
+	return &ArchiveStore{path: path}
+}
+
+// Add archives the completed tasks of account's list. A task archived
+// before is updated in place, so clearing a list twice keeps one copy.
+func (s *ArchiveStore) Add(account string, list api.TaskList, tasks []api.Task) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	archive, err := s.read()
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	list = api.TaskList{ID: list.ID, Title: list.Title}
+	for _, task := range tasks {
+		archived := now
+		if prev, ok := archive[task.ID]; ok {
+			archived = prev.Archived
+		}
+		archive[task.ID] = api.ArchivedTask{Account: account, List: list, Task: task, Completed: task.Completed, Archived: archived}
+	}
+	data, err := json.MarshalIndent(archive, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// Query returns account's archived tasks completed in [from, to), either
+// of which may be zero for no bound, of listID or every list when it is
+// empty. They are sorted most recently completed first.
+func (s *ArchiveStore) Query(account string, from, to time.Time, listID string) ([]api.ArchivedTask, error) {
+	s.mutex.Lock()
+	archive, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []api.ArchivedTask{}
+	for _, archived := range archive {
+		if archived.Account != account || listID != "" && archived.List.ID != listID {
+			continue
+		}
+		completed, err := time.Parse(time.RFC3339, archived.Completed)
+		if err != nil && (!from.IsZero() || !to.IsZero()) {
+			continue
+		}
+		if (!from.IsZero() && completed.Before(from)) || (!to.IsZero() && !completed.Before(to)) {
+			continue
+		}
+		tasks = append(tasks, archived)
+	}
+	slices.SortFunc(tasks, func(a, b api.ArchivedTask) int {
+		return cmp.Or(cmp.Compare(b.Completed, a.Completed), cmp.Compare(a.Task.ID, b.Task.ID))
+	})
+	return tasks, nil
+}
+
+func (s *ArchiveStore) read() (map[string]api.ArchivedTask, error) {
+	archive := make(map[string]api.ArchivedTask)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return archive, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &archive); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return archive, nil
+}
+
+// ClearWrite hides every completed task of listID, as Google's clear does.
+func ClearWrite(listID string) api.TaskWrite {
+	return api.TaskWrite{Method: "POST", Path: "/lists/" + url.PathEscape(listID) + "/clear", List: listID}
+}
+
+// POST /api/lists/{list}/clear - Archive a list's completed tasks, then clear them
+//
+// Google's clear hides completed tasks from every client and its API, so
+// they are first copied into api.archive_file, where GET /api/archive finds
+// them. Nothing is cleared if archiving fails. ?dry_run=1 reports the
+// tasks without archiving or clearing them.
+func (s *Server) handleClearList(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+
+	lists, err := s.tasks.ListTaskLists(r.Context(), token)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	list, ok := listByID(lists, r.PathValue("list"))
+	if !ok {
+		writeListNotFound(w, r.PathValue("list"))
+		return
+	}
+	tasks, err := s.tasks.ListTasks(r.Context(), token, list.ID, watchQuery)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+
+	resp := api.ClearResponse{List: list, DryRun: dryRun(r), Archived: []api.Task{}, Request: ClearWrite(list.ID)}
+	for _, task := range tasks {
+		if task.Status == "completed" && !task.Deleted {
+			resp.Archived = append(resp.Archived, task)
+		}
+	}
+	if !resp.DryRun {
+		if err := s.archiveStore(tenantUser(r.Context())).Add(account, list, resp.Archived); err != nil {
+			log.Printf("Archiving completed tasks: %v", err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to archive completed tasks, nothing was cleared")
+			return
+		}
+		if _, err := s.tasks.Write(r.Context(), token, resp.Request); err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		changes := make(map[string][]api.HistoryEntry)
+		for _, task := range resp.Archived {
+			if !task.Hidden {
+				changes[task.ID] = []api.HistoryEntry{{Change: api.HistoryCleared}}
+			}
+		}
+		s.recordHistory(r, token, changes)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GET /api/archive - Completed tasks archived when their lists were cleared
+//
+// ?from= and ?to= (YYYY-MM-DD, today or tomorrow, both inclusive, in ?tz=)
+// bound the completion date; ?list= keeps one list. Returns the most
+// recently completed ?limit= tasks (default 100, at most 1000) and the total.
+func (s *Server) handleArchive(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	limit := defaultArchiveLimit
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > maxArchiveLimit {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+	var from, to time.Time
+	for _, bound := range []struct {
+		name string
+		t    *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		if query.Get(bound.name) == "" {
+			continue
+		}
+		day, err := agendaDate(query.Get(bound.name), query.Get("tz"), time.Now())
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, bound.name+": "+err.Error())
+			return
+		}
+		*bound.t = day
+	}
+	resp := api.ArchiveResponse{}
+	if !from.IsZero() {
+		resp.From = from.Format(time.DateOnly)
+	}
+	if !to.IsZero() {
+		resp.To = to.Format(time.DateOnly)
+		to = to.AddDate(0, 0, 1) // through the end of the day
+	}
+
+	tasks, err := s.archiveStore(tenantUser(r.Context())).Query(account, from, to, query.Get("list"))
+	if err != nil {
+		log.Printf("Reading the archive: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the archive")
+		return
+	}
+	resp.Tasks, resp.Total = tasks[:min(limit, len(tasks))], len(tasks)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
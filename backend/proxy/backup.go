@@ -0,0 +1,254 @@
+package proxy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	backupPrefix = "gtask-"
+	backupSuffix = ".json.gz"
+	backupLayout = "20060102T150405Z"
+
+	// backupCheckInterval is the longest the backup job waits between
+	// checks for a due backup, so a restart does not postpone one by a
+	// whole backup.interval.
+	backupCheckInterval = time.Hour
+)
+
+// backupState is what the backup job last did, for /api/backup/status.
+type backupState struct {
+	mutex       sync.Mutex
+	lastAttempt time.Time
+	lastError   string
+}
+
+// listBackups returns the backups in dir, newest first. Files not named
+// like a backup are ignored; a missing dir has none.
+func listBackups(dir string) ([]api.BackupFile, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var backups []api.BackupFile
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, backupPrefix)
+		stamp, ok2 := strings.CutSuffix(stamp, backupSuffix)
+		if !ok || !ok2 || entry.IsDir() {
+			continue
+		}
+		created, err := time.Parse(backupLayout, stamp)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, api.BackupFile{ID: stamp, Name: name, Created: created.Format(time.RFC3339), Size: info.Size()})
+	}
+	// The time stamps in the names sort like the times
+	slices.SortFunc(backups, func(a, b api.BackupFile) int { return strings.Compare(b.Name, a.Name) })
+	return backups, nil
+}
+
+// backupAccount collects one account's data. tokens is where its Google
+// token is stored; an account without one cannot be backed up, since its
+// tasks cannot be read and the proxy's data is kept by Google account.
+func (s *Server) backupAccount(ctx context.Context, user string, tokens *TokenStore) (api.BackupAccount, error) {
+	account := api.BackupAccount{User: user}
+	token, err := s.AccessToken(ctx, tokens, defaultAccount)
+	if errors.Is(err, ErrNotLoggedIn) && user != "" {
+		return account, errors.New("no Google token is stored; authorize with /auth/start")
+	}
+	if err != nil {
+		return account, err
+	}
+	if account.Account, err = s.accountOf(ctx, token); err != nil {
+		return account, err
+	}
+	lists, err := s.tasks.FetchAll(ctx, token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		return account, err
+	}
+	for _, list := range lists {
+		if list.Error != nil {
+			return account, fmt.Errorf("list %s could not be fetched: %s", list.Title, list.Error.Message)
+		}
+	}
+	account.Lists = lists
+
+	snoozes, err := s.snoozeStore(user).All()
+	if err != nil {
+		return account, err
+	}
+	account.Snoozes = make(map[string]string, len(snoozes))
+	for id, until := range snoozes {
+		account.Snoozes[id] = until.UTC().Format(time.RFC3339)
+	}
+	if account.Metadata, err = s.metadataStore(user).All(); err != nil {
+		return account, err
+	}
+	if account.Archive, err = s.archiveStore(user).Query(account.Account, time.Time{}, time.Time{}, ""); err != nil {
+		return account, err
+	}
+	if account.History, err = s.historyStore(user).All(account.Account); err != nil {
+		return account, err
+	}
+	return account, nil
+}
+
+// writeBackup backs up every account and removes the oldest backups beyond
+// backup.keep. A backup is all or nothing: if any account has no token or
+// any list cannot be fetched, no file is written.
+func (s *Server) writeBackup(ctx context.Context, now time.Time) error {
+	cfg := s.config()
+	backup := api.Backup{Version: api.BackupVersion, Created: now.UTC().Format(time.RFC3339)}
+	if s.tenants != nil {
+		for _, user := range slices.Sorted(maps.Keys(cfg.Tenants.Users)) {
+			account, err := s.backupAccount(ctx, user, s.tenants.Get(user).Tokens)
+			if err != nil {
+				return fmt.Errorf("backing up %s: %w", user, err)
+			}
+			backup.Accounts = append(backup.Accounts, account)
+		}
+	} else {
+		account, err := s.backupAccount(ctx, "", NewTokenStore(cfg.TokensFile))
+		if err != nil {
+			return fmt.Errorf("backing up: %w", err)
+		}
+		backup.Accounts = append(backup.Accounts, account)
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(backup); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	name := backupPrefix + now.UTC().Format(backupLayout) + backupSuffix
+	if err := writeFileAtomic(filepath.Join(cfg.Backup.Dir, name), buf.Bytes()); err != nil {
+		return err
+	}
+	log.Printf("Backup written to %s", filepath.Join(cfg.Backup.Dir, name))
+
+	backups, err := listBackups(cfg.Backup.Dir)
+	if err != nil {
+		return err
+	}
+	for _, old := range backups[min(cfg.Backup.Keep, len(backups)):] {
+		if err := os.Remove(filepath.Join(cfg.Backup.Dir, old.Name)); err != nil {
+			log.Printf("Removing old backup: %v", err)
+		}
+	}
+	return nil
+}
+
+// backupDue returns when the next backup is due: backup.interval after the
+// newest one, or now when there is none.
+func backupDue(cfg *Config, now time.Time) (time.Time, error) {
+	backups, err := listBackups(cfg.Backup.Dir)
+	if err != nil || len(backups) == 0 {
+		return now, err
+	}
+	newest, _ := time.Parse(time.RFC3339, backups[0].Created)
+	return newest.Add(cfg.Backup.Interval), nil
+}
+
+// runBackups is the periodic backup job. It checks for a due backup at
+// least every backupCheckInterval, so backups stay on schedule across
+// restarts.
+func (s *Server) runBackups(ctx context.Context) error {
+	cfg := s.config()
+	if !cfg.Backup.Enabled {
+		return nil
+	}
+	now := time.Now()
+	due, err := backupDue(cfg, now)
+	if err != nil {
+		return fmt.Errorf("reading backups: %w", err)
+	}
+	if now.Before(due) {
+		return nil
+	}
+
+	err = s.writeBackup(ctx, now)
+	s.backups.mutex.Lock()
+	s.backups.lastAttempt, s.backups.lastError = now, ""
+	if err != nil {
+		s.backups.lastError = err.Error()
+	}
+	s.backups.mutex.Unlock()
+	return err
+}
+
+// backupCheckEvery is how often runBackups is submitted.
+func (s *Server) backupCheckEvery() time.Duration {
+	return min(s.config().Backup.Interval, backupCheckInterval)
+}
+
+// GET /api/backup/status - Backup settings, the last attempt and the backups
+// kept
+//
+// With backup.enabled, every account's tasks and the proxy's snoozes,
+// metadata, archive and history are written to backup.dir every
+// backup.interval as gzip-compressed JSON, keeping the newest backup.keep.
+func (s *Server) handleBackupStatus(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	if _, _, ok := s.verifiedToken(w, r); !ok {
+		return
+	}
+	cfg := s.config()
+	backups, err := listBackups(cfg.Backup.Dir)
+	if err != nil {
+		log.Printf("Reading backups: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the backup directory")
+		return
+	}
+	resp := api.BackupStatusResponse{
+		Enabled:  cfg.Backup.Enabled,
+		Interval: cfg.Backup.Interval.String(),
+		Dir:      cfg.Backup.Dir,
+		Keep:     cfg.Backup.Keep,
+		Backups:  backups,
+	}
+	if resp.Backups == nil {
+		resp.Backups = []api.BackupFile{}
+	}
+	s.backups.mutex.Lock()
+	if !s.backups.lastAttempt.IsZero() {
+		resp.LastAttempt = s.backups.lastAttempt.UTC().Format(time.RFC3339)
+	}
+	resp.LastError = s.backups.lastError
+	s.backups.mutex.Unlock()
+	if cfg.Backup.Enabled {
+		if next, err := backupDue(cfg, time.Now()); err == nil {
+			resp.Next = next.UTC().Format(time.RFC3339)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
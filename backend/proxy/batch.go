@@ -0,0 +1,256 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// maxBatchChanges bounds one POST /api/batch request.
+const maxBatchChanges = 500
+
+// changeWrite converts c into the Tasks API request that carries it out.
+func changeWrite(c api.Change) (api.TaskWrite, error) {
+	switch c.Op {
+	case "create", "update", "delete", "move":
+	default:
+		return api.TaskWrite{}, fmt.Errorf("unknown op %q", c.Op)
+	}
+	if c.List == "" {
+		return api.TaskWrite{}, fmt.Errorf("%s: missing list", c.Op)
+	}
+	if c.Op != "create" && c.Task == "" {
+		return api.TaskWrite{}, fmt.Errorf("%s: missing task", c.Op)
+	}
+
+	switch c.Op {
+	case "create":
+		var task api.Task
+		if err := remarshal(c.Fields, &task); err != nil {
+			return api.TaskWrite{}, fmt.Errorf("create: invalid fields: %w", err)
+		}
+		if task.Title == "" {
+			return api.TaskWrite{}, errors.New("create: missing title")
+		}
+		if task.Status == "" {
+			task.Status = "needsAction"
+		}
+		write := InsertWrite(c.List, task)
+		query := url.Values{}
+		if c.Parent != "" {
+			query.Set("parent", c.Parent)
+		}
+		if c.Previous != "" {
+			query.Set("previous", c.Previous)
+		}
+		if len(query) > 0 {
+			write.Query = query
+		}
+		return write, nil
+	case "update":
+		if len(c.Fields) == 0 {
+			return api.TaskWrite{}, errors.New("update: no fields")
+		}
+		return PatchWrite(c.List, c.Task, c.Fields), nil
+	case "delete":
+		return DeleteWrite(c.List, c.Task), nil
+	default:
+		return MoveWrite(c.List, c.Task, c.Parent, c.Previous), nil
+	}
+}
+
+// remarshal decodes a generic JSON object into out.
+func remarshal(in any, out any) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// dryRun reports whether r asks for a preview instead of execution.
+func dryRun(r *http.Request) bool {
+	v, _ := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+	return v
+}
+
+// POST /api/batch - Apply creates/updates/deletes/moves in order
+//
+// Every change is validated before any is sent. Changes run in order and a
+// failed change does not stop the rest; its result carries the error. With
+// ?dry_run=1 nothing is sent and the results list the requests that would be.
+func (s *Server) handleBatch(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.BatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if len(req.Changes) > maxBatchChanges {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest,
+			fmt.Sprintf("At most %d changes per batch", maxBatchChanges))
+		return
+	}
+
+	results := make([]api.ChangeResult, len(req.Changes))
+	for i, change := range req.Changes {
+		write, err := changeWrite(change)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("changes[%d]: %v", i, err))
+			return
+		}
+		results[i].Request = write
+	}
+
+	resp := api.BatchResponse{DryRun: dryRun(r), Results: results}
+	if !resp.DryRun {
+		undo := make([][]undoStep, len(results))
+		for i := range results {
+			task, steps, err := s.writeUndoable(r, token, results[i].Request)
+			if err != nil {
+				results[i].Error = asAPIError(err, "Google Tasks request failed")
+				continue
+			}
+			results[i].Task = task
+			undo[i] = steps
+		}
+		s.recordUndo(r, token, changesSummary("batch", len(results)), undo...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// statusFields are the fields a status change sends: reopening a task
+// clears its completion time too.
+func statusFields(status string) (map[string]any, error) {
+	switch status {
+	case "completed":
+		return map[string]any{"status": status}, nil
+	case "needsAction":
+		return map[string]any{"status": status, "completed": nil}, nil
+	}
+	return nil, errors.New(`status must be "completed" or "needsAction"`)
+}
+
+// POST /api/tasks/bulk-status - Complete or reopen many tasks at once
+//
+// The updates are sent concurrently, at most api.fanout_workers at a time, and
+// one failing does not stop the rest; each task's result carries its error.
+// Tasks named without their list are found through the cache. The whole
+// request is undone as one entry. ?dry_run=1 returns the updates without
+// sending them.
+func (s *Server) handleBulkStatus(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.BulkStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	fields, err := statusFields(req.Status)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	if len(req.Tasks) == 0 || len(req.Tasks) > maxBatchChanges {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest,
+			fmt.Sprintf("Between 1 and %d tasks", maxBatchChanges))
+		return
+	}
+	seen := make(map[string]bool, len(req.Tasks))
+	for i, ref := range req.Tasks {
+		if ref.Task == "" || seen[ref.Task] {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("tasks[%d]: missing or repeated task", i))
+			return
+		}
+		seen[ref.Task] = true
+	}
+
+	results := make([]api.ChangeResult, len(req.Tasks))
+	var lists map[string]string // task ID -> list ID, for tasks given without one
+	for i, ref := range req.Tasks {
+		if ref.List == "" && lists == nil {
+			all, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+			if err != nil {
+				writeTasksError(w, err)
+				return
+			}
+			lists = make(map[string]string)
+			for _, list := range all {
+				for _, task := range list.Tasks {
+					lists[task.ID] = list.ID
+				}
+			}
+		}
+		list := cmp.Or(ref.List, lists[ref.Task])
+		if list == "" {
+			results[i].Error = &api.APIError{Status: http.StatusNotFound, Code: api.CodeNotFound, Message: "Task not found"}
+			continue
+		}
+		results[i].Request = PatchWrite(list, ref.Task, fields)
+	}
+
+	resp := api.BulkStatusResponse{DryRun: dryRun(r), Results: results}
+	if !resp.DryRun {
+		undo := make([][]undoStep, len(results))
+		sem := make(chan struct{}, s.config().FanoutWorkers)
+		var wg sync.WaitGroup
+		for i := range results {
+			if results[i].Error != nil {
+				continue
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-r.Context().Done():
+					results[i].Error = upstreamFailure(r.Context().Err(), "Cancelled")
+					return
+				}
+				task, steps, err := s.writeUndoable(r, token, results[i].Request)
+				if err != nil {
+					results[i].Error = asAPIError(err, "Google Tasks request failed")
+					return
+				}
+				results[i].Task = task
+				undo[i] = steps
+			}()
+		}
+		wg.Wait()
+		for _, result := range results {
+			if result.Error == nil {
+				resp.Updated++
+			}
+		}
+		s.recordUndo(r, token, changesSummary("bulk status", resp.Updated), undo...)
+	}
+	for _, result := range results {
+		if result.Error != nil {
+			resp.Failed++
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,102 @@
+package proxy
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrUpstreamUnavailable is returned without contacting Google while the
+// circuit breaker is open.
+var ErrUpstreamUnavailable = errors.New("upstream unavailable")
+
+// UnavailableError wraps ErrUpstreamUnavailable with the time until the
+// breaker will let the next probe through.
+type UnavailableError struct {
+	Host    string
+	RetryIn time.Duration
+}
+
+func (e *UnavailableError) Error() string {
+	return e.Host + ": " + ErrUpstreamUnavailable.Error()
+}
+
+func (e *UnavailableError) Unwrap() error {
+	return ErrUpstreamUnavailable
+}
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// CircuitBreaker fails fast after consecutive upstream failures. Once
+// openFor has passed it lets a single probe through; success closes the
+// circuit again, failure re-opens it.
+type CircuitBreaker struct {
+	mutex     sync.Mutex
+	state     breakerState
+	failures  int
+	openedAt  time.Time
+	threshold int
+	openFor   time.Duration
+}
+
+func NewCircuitBreaker(threshold int, openFor time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{threshold: threshold, openFor: openFor}
+}
+
+// Allow reports whether a request may be sent. When it returns false, retryIn
+// is how long until the next probe is allowed.
+func (b *CircuitBreaker) Allow() (ok bool, retryIn time.Duration) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		elapsed := time.Since(b.openedAt)
+		if elapsed < b.openFor {
+			return false, b.openFor - elapsed
+		}
+		b.state = breakerHalfOpen
+		return true, 0
+	case breakerHalfOpen:
+		// A probe is already in flight
+		return false, b.openFor
+	default:
+		return true, 0
+	}
+}
+
+// Record reports the outcome of a request that Allow let through.
+func (b *CircuitBreaker) Record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if success {
+		b.state = breakerClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == breakerHalfOpen || b.failures >= b.threshold {
+		b.state = breakerOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// Abandon releases a probe whose outcome says nothing about upstream health,
+// such as one cancelled by the caller, so the next request can probe again.
+func (b *CircuitBreaker) Abandon() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.state == breakerHalfOpen {
+		b.state = breakerOpen
+		b.openedAt = time.Now().Add(-b.openFor)
+	}
+}
@@ -0,0 +1,108 @@
+package proxy
+
+import (
+	"testing"
+	"time"
+)
+
+// expire pretends the open period ended a moment ago.
+func (b *CircuitBreaker) expire() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.openedAt = time.Now().Add(-b.openFor - time.Millisecond)
+}
+
+func mustAllow(t *testing.T, b *CircuitBreaker, want bool) {
+	t.Helper()
+	if ok, _ := b.Allow(); ok != want {
+		t.Fatalf("Allow = %t in state %d, want %t", ok, b.state, want)
+	}
+}
+
+func TestBreakerOpensAfterThreshold(t *testing.T) {
+	b := NewCircuitBreaker(3, time.Minute)
+
+	for range 2 {
+		mustAllow(t, b, true)
+		b.Record(false)
+	}
+	// A success resets the count, so failures must be consecutive
+	mustAllow(t, b, true)
+	b.Record(true)
+	for range 2 {
+		mustAllow(t, b, true)
+		b.Record(false)
+	}
+	mustAllow(t, b, true)
+
+	b.Record(false)
+	if b.state != breakerOpen {
+		t.Fatalf("state = %d after 3 consecutive failures, want open", b.state)
+	}
+	ok, retryIn := b.Allow()
+	if ok || retryIn <= 0 || retryIn > time.Minute {
+		t.Errorf("open breaker: Allow = %t, %v", ok, retryIn)
+	}
+}
+
+func TestBreakerHalfOpenLetsOneProbeThrough(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	b.Record(false)
+	b.expire()
+
+	mustAllow(t, b, true)
+	if b.state != breakerHalfOpen {
+		t.Fatalf("state = %d after the open period, want half-open", b.state)
+	}
+	// Everyone else waits for the probe
+	for range 3 {
+		mustAllow(t, b, false)
+	}
+
+	b.Record(true)
+	if b.state != breakerClosed || b.failures != 0 {
+		t.Errorf("a good probe should close the breaker, got state %d, %d failures", b.state, b.failures)
+	}
+	mustAllow(t, b, true)
+}
+
+func TestBreakerFailedProbeReopens(t *testing.T) {
+	b := NewCircuitBreaker(5, time.Minute)
+	for range 5 {
+		b.Record(false)
+	}
+	b.expire()
+	mustAllow(t, b, true)
+
+	// One failure is enough while half-open, whatever the threshold
+	b.Record(false)
+	if b.state != breakerOpen {
+		t.Fatalf("state = %d after a failed probe, want open", b.state)
+	}
+	if _, retryIn := b.Allow(); retryIn < time.Minute-time.Second {
+		t.Errorf("a failed probe should restart the open period, retry in %v", retryIn)
+	}
+}
+
+func TestBreakerAbandon(t *testing.T) {
+	b := NewCircuitBreaker(1, time.Minute)
+	b.Record(false)
+	b.expire()
+	mustAllow(t, b, true)
+
+	// An abandoned probe frees the slot straight away
+	b.Abandon()
+	if b.state != breakerOpen {
+		t.Fatalf("state = %d after Abandon, want open", b.state)
+	}
+	mustAllow(t, b, true)
+	mustAllow(t, b, false)
+
+	// Abandon only concerns probes
+	closed := NewCircuitBreaker(2, time.Minute)
+	closed.Record(false)
+	closed.Abandon()
+	if closed.state != breakerClosed || closed.failures != 1 {
+		t.Errorf("Abandon changed a closed breaker: state %d, %d failures", closed.state, closed.failures)
+	}
+}
@@ -0,0 +1,70 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"time"
+)
+
+// CachedResponse is a successful upstream GET, kept for reuse.
+type CachedResponse struct {
+	Body        []byte
+	ContentType string
+	ETag        string
+	FetchedAt   time.Time
+}
+
+// ResponseCache holds upstream GET responses. Within ttl an entry is served
+// as-is; after that it is revalidated with If-None-Match and refreshed on 304.
+// Stale entries are retained (up to the LRU bound) only for revalidation.
+type ResponseCache struct {
+	entries   *LRU[*CachedResponse]
+	ttl       func() time.Duration
+	retention time.Duration
+}
+
+func NewResponseCache(maxEntries int, ttl func() time.Duration) *ResponseCache {
+	return &ResponseCache{
+		entries:   NewLRU[*CachedResponse]("response_cache", maxEntries),
+		ttl:       ttl,
+		retention: time.Hour,
+	}
+}
+
+// cacheKey scopes a URL to the caller's credentials so users never see each
+// other's data. Only a hash of the token is kept.
+func cacheKey(accessToken, url string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return hex.EncodeToString(sum[:8]) + " " + url
+}
+
+// Lookup returns the cached response for key and whether it is still fresh.
+func (c *ResponseCache) Lookup(key string) (*CachedResponse, bool) {
+	entry, ok := c.entries.Get(key)
+	if !ok {
+		return nil, false
+	}
+	return entry, time.Since(entry.FetchedAt) < c.ttl()
+}
+
+// Store caches a 200 response.
+func (c *ResponseCache) Store(key string, resp *CachedResponse) {
+	c.entries.Set(key, resp, c.retention)
+}
+
+// Revalidated marks entry fresh again after a 304 Not Modified.
+func (c *ResponseCache) Revalidated(key string, entry *CachedResponse) {
+	refreshed := *entry
+	refreshed.FetchedAt = time.Now()
+	c.entries.Set(key, &refreshed, c.retention)
+}
+
+// Invalidate drops the caller's cached responses for every URL starting with
+// urlPrefix, after a write has made them stale.
+func (c *ResponseCache) Invalidate(accessToken, urlPrefix string) {
+	prefix := cacheKey(accessToken, urlPrefix)
+	c.entries.DeleteFunc(func(key string) bool {
+		return strings.HasPrefix(key, prefix)
+	})
+}
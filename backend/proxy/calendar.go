@@ -0,0 +1,202 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	googleCalendarBaseURL = "https://www.googleapis.com/calendar/v3"
+	// googleCalendarScope must be in google.scope for scheduling
+	googleCalendarScope = "https://www.googleapis.com/auth/calendar.events"
+)
+
+// maxScheduleDuration bounds the time block of one task.
+const maxScheduleDuration = 24 * time.Hour
+
+// googleEvent is the part of a Calendar event the proxy writes and reads.
+type googleEvent struct {
+	ID          string       `json:"id,omitempty"`
+	HTMLLink    string       `json:"htmlLink,omitempty"`
+	Summary     string       `json:"summary,omitempty"`
+	Description string       `json:"description,omitempty"`
+	Start       *eventTime   `json:"start,omitempty"`
+	End         *eventTime   `json:"end,omitempty"`
+	Source      *eventSource `json:"source,omitempty"`
+}
+
+type eventTime struct {
+	DateTime string `json:"dateTime"`
+}
+
+type eventSource struct {
+	Title string `json:"title"`
+	URL   string `json:"url"`
+}
+
+// taskEvent is the event time-blocking task from start to end.
+func taskEvent(task api.Task, start, end time.Time) googleEvent {
+	event := googleEvent{
+		Summary:     task.Title,
+		Description: task.Notes,
+		Start:       &eventTime{DateTime: start.Format(time.RFC3339)},
+		End:         &eventTime{DateTime: end.Format(time.RFC3339)},
+	}
+	if task.WebViewLink != "" {
+		event.Source = &eventSource{Title: "Google Tasks", URL: task.WebViewLink}
+	}
+	return event
+}
+
+// parseScheduleStart reads the start of a time block: RFC 3339, a date and
+// time (YYYY-MM-DD HH:MM) in now's zone, or a time on due (YYYY-MM-DD, the
+// task's due date) or today when due is empty.
+func parseScheduleStart(spec, due string, now time.Time) (time.Time, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return time.Time{}, errors.New("start is required")
+	}
+	if t, err := time.Parse(time.RFC3339, spec); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if t, err := time.ParseInLocation(layout, spec, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	hhmm, ok := parseQuickTime(strings.ToLower(spec))
+	if !ok {
+		return time.Time{}, fmt.Errorf("unrecognised start %q, use RFC 3339, YYYY-MM-DD HH:MM or a time like 14:30 or 9am", spec)
+	}
+	day := cmp.Or(due, now.Format(time.DateOnly))
+	return time.ParseInLocation("2006-01-02 15:04", day+" "+hhmm, now.Location())
+}
+
+// calendarEvent sends one Calendar API request for an event and returns
+// the event Google responds with.
+func (s *Server) calendarEvent(ctx context.Context, token, method, path string, event googleEvent) (googleEvent, error) {
+	var written googleEvent
+	err := s.callGoogle(ctx, token, method, googleCalendarBaseURL+path, event, &written, "Google Calendar", googleCalendarScope)
+	return written, err
+}
+
+func eventsPath(calendar string) string {
+	return "/calendars/" + url.PathEscape(calendar) + "/events"
+}
+
+// POST /api/tasks/{task}/schedule - Time-block a task on Google Calendar
+//
+// The first schedule of a task creates an event titled like it; later ones
+// move that event, or create a new one when it was deleted or another
+// calendar is asked for. The event is recorded in the task's metadata.
+// Needs the calendar.events scope in google.scope. ?dry_run=1 reports the
+// event without writing it.
+func (s *Server) handleSchedule(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.ScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	cfg := s.config()
+	now := time.Now()
+	if req.TZ != "" {
+		loc, err := parseTimeZone(req.TZ)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+			return
+		}
+		now = now.In(loc)
+	}
+	duration := cfg.Calendar.Duration
+	if req.Duration != "" {
+		d, err := time.ParseDuration(req.Duration)
+		if err != nil || d <= 0 || d > maxScheduleDuration {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "duration must be a positive duration of at most 24h, like 45m")
+			return
+		}
+		duration = d
+	}
+
+	_, task, found, err := s.findTask(r.Context(), token, req.List, r.PathValue("task"))
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Task not found")
+		return
+	}
+	start, err := parseScheduleStart(req.Start, dueDate(task), now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	store := s.metadataStore(tenantUser(r.Context()))
+	meta, err := store.Get(task.ID)
+	if err != nil {
+		log.Printf("Reading task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task metadata")
+		return
+	}
+	calendar := cmp.Or(req.Calendar, cfg.Calendar.ID)
+	event := taskEvent(task, start, start.Add(duration))
+	existing := meta.Event
+	if existing != nil && existing.Calendar != calendar {
+		existing = nil
+	}
+
+	resp := api.ScheduleResponse{DryRun: dryRun(r), Created: existing == nil, Task: task, Metadata: meta}
+	scheduled := api.CalendarEvent{Calendar: calendar, Start: event.Start.DateTime, End: event.End.DateTime}
+	if existing != nil {
+		scheduled.ID, scheduled.HTMLLink = existing.ID, existing.HTMLLink
+	}
+	if !resp.DryRun {
+		var written googleEvent
+		if existing != nil {
+			written, err = s.calendarEvent(r.Context(), token, "PATCH", eventsPath(calendar)+"/"+url.PathEscape(existing.ID), event)
+			var apiErr *api.APIError
+			if errors.As(err, &apiErr) && apiErr.Upstream != nil &&
+				(apiErr.Upstream.Status == http.StatusNotFound || apiErr.Upstream.Status == http.StatusGone) {
+				// Deleted from the calendar, so it is scheduled afresh
+				resp.Created = true
+			}
+		}
+		if resp.Created {
+			written, err = s.calendarEvent(r.Context(), token, "POST", eventsPath(calendar), event)
+		}
+		if err != nil {
+			writeAPIError(w, asAPIError(err, "Google Calendar request failed"))
+			return
+		}
+		scheduled.ID, scheduled.HTMLLink = written.ID, written.HTMLLink
+
+		resp.Metadata, err = store.Update(task.ID, func(m *api.TaskMetadata) { m.Event = &scheduled })
+		if err != nil {
+			log.Printf("Recording task metadata: %v", err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "The event was written but could not be recorded")
+			return
+		}
+	}
+	resp.Event = scheduled
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Google Tasks has no push notifications, so changes made by other clients
+// are found by polling: each poll is reduced to a snapshot and compared with
+// the previous one, and the differences go to the change listeners (the
+// event streams and the reminder scan).
+
+// snapshot is one poll of a user's lists and tasks.
+type snapshot struct {
+	lists map[string]api.TaskList
+	tasks map[string]snapshotTask // by task ID
+}
+
+type snapshotTask struct {
+	list string
+	task api.Task
+}
+
+// newSnapshot builds a snapshot of lists. A list that could not be fetched
+// keeps its tasks from prev, so a failed request does not look like every
+// task in it was deleted.
+func newSnapshot(lists []api.ListWithTasks, prev *snapshot) *snapshot {
+	snap := &snapshot{lists: make(map[string]api.TaskList), tasks: make(map[string]snapshotTask)}
+	for _, list := range lists {
+		snap.lists[list.ID] = list.TaskList
+		if list.Error != nil {
+			if prev != nil {
+				for id, t := range prev.tasks {
+					if t.list == list.ID {
+						snap.tasks[id] = t
+					}
+				}
+			}
+			continue
+		}
+		for _, task := range list.Tasks {
+			snap.tasks[task.ID] = snapshotTask{list.ID, task}
+		}
+	}
+	return snap
+}
+
+// diffSnapshots returns what changed from prev to next: list events first,
+// then task events, each ordered by ID. Tasks of a deleted list only produce
+// the list.deleted event.
+func diffSnapshots(prev, next *snapshot) []api.TaskEvent {
+	var events []api.TaskEvent
+
+	for _, id := range slices.Sorted(maps.Keys(next.lists)) {
+		list := next.lists[id]
+		old, ok := prev.lists[id]
+		switch {
+		case !ok:
+			events = append(events, api.TaskEvent{Type: api.EventListCreated, List: list})
+		case old.Title != list.Title:
+			events = append(events, api.TaskEvent{Type: api.EventListRenamed, List: list, From: old.Title, To: list.Title})
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(prev.lists)) {
+		if _, ok := next.lists[id]; !ok {
+			events = append(events, api.TaskEvent{Type: api.EventListDeleted, List: prev.lists[id]})
+		}
+	}
+
+	for _, id := range slices.Sorted(maps.Keys(next.tasks)) {
+		t := next.tasks[id]
+		task := t.task
+		list := next.lists[t.list]
+		event := func(typ, from, to string) {
+			events = append(events, api.TaskEvent{Type: typ, List: list, Task: &task, From: from, To: to})
+		}
+
+		old, ok := prev.tasks[id]
+		if !ok {
+			event(api.EventTaskCreated, "", "")
+			continue
+		}
+		if old.list != t.list {
+			event(api.EventTaskMoved, old.list, t.list)
+		}
+		if old.task.Parent != task.Parent {
+			event(api.EventTaskReparented, old.task.Parent, task.Parent)
+		}
+		if old.task.Title != task.Title {
+			event(api.EventTaskTitleChanged, old.task.Title, task.Title)
+		}
+		if old.task.Notes != task.Notes {
+			event(api.EventTaskNotesChanged, old.task.Notes, task.Notes)
+		}
+		if oldDue, due := dueDate(old.task), dueDate(task); oldDue != due {
+			event(api.EventTaskDueChanged, oldDue, due)
+		}
+		if old.task.Status != task.Status {
+			if task.Status == "completed" {
+				event(api.EventTaskCompleted, "", "")
+			} else {
+				event(api.EventTaskReopened, "", "")
+			}
+		}
+	}
+	for _, id := range slices.Sorted(maps.Keys(prev.tasks)) {
+		old := prev.tasks[id]
+		if _, ok := next.tasks[id]; ok {
+			continue
+		}
+		if _, ok := next.lists[old.list]; !ok {
+			continue // reported as list.deleted
+		}
+		task := old.task
+		events = append(events, api.TaskEvent{Type: api.EventTaskDeleted, List: prev.lists[old.list], Task: &task})
+	}
+
+	return events
+}
+
+// dueDate returns the YYYY-MM-DD part of a task's due timestamp.
+func dueDate(task api.Task) string {
+	if len(task.Due) < len(time.DateOnly) {
+		return task.Due
+	}
+	return task.Due[:len(time.DateOnly)]
+}
+
+// watch is the polling state of one user: the account of an event stream,
+// or the account logged in with `auth login`.
+type watch struct {
+	sub *Subscriber // nil for the `auth login` account
+
+	mutex    sync.Mutex
+	account  string // ID of the polled account (see Server.accountOf)
+	snapshot *snapshot
+	reminded map[string]bool // reminders already sent
+}
+
+// changeListener is told about the changes found by one poll. lists is the
+// poll's result, so listeners need not fetch again.
+type changeListener func(ctx context.Context, w *watch, lists []api.ListWithTasks, events []api.TaskEvent)
+
+// watchQuery fetches completed and hidden tasks too, so completing a task
+// elsewhere is seen as task.completed rather than task.deleted.
+var watchQuery = url.Values{"showCompleted": {"true"}, "showHidden": {"true"}}
+
+// observe polls the tasks of token's user, compares them with w's previous
+// snapshot and passes any differences to the change listeners. The first
+// poll of a watch only records a baseline.
+func (s *Server) observe(ctx context.Context, token string, w *watch) ([]api.ListWithTasks, error) {
+	// Polls of one watch are serialized so snapshots are stored in order
+	w.mutex.Lock()
+	account, err := s.accountOf(ctx, token)
+	if err != nil {
+		w.mutex.Unlock()
+		return nil, err
+	}
+	lists, err := s.tasks.FetchAll(ctx, token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		w.mutex.Unlock()
+		return nil, err
+	}
+	if account != w.account {
+		// Another account logged in: its tasks are not changes to the last one's
+		w.account, w.snapshot = account, nil
+	}
+	prev := w.snapshot
+	w.snapshot = newSnapshot(lists, prev)
+	var events []api.TaskEvent
+	if prev != nil {
+		events = diffSnapshots(prev, w.snapshot)
+	}
+	w.mutex.Unlock()
+
+	if len(events) > 0 {
+		slog.Debug("changes detected", "events", len(events), "stream", w.sub != nil)
+		for _, listener := range s.onChange {
+			listener(ctx, w, lists, events)
+		}
+	}
+	return lists, nil
+}
+
+// observeSubscriber polls for sub's stream. A token Google no longer accepts
+// ends the stream, so the client reconnects with a fresh one.
+func (s *Server) observeSubscriber(ctx context.Context, sub *Subscriber) ([]api.ListWithTasks, bool) {
+	lists, err := s.observe(ctx, sub.token, &sub.watch)
+	if err != nil {
+		apiErr := asAPIError(err, "Google Tasks request failed")
+		if apiErr.Status == http.StatusUnauthorized {
+			s.events.Send(sub, "error", apiErr)
+			s.events.Drop(sub)
+		} else {
+			slog.Warn("polling tasks for an event stream failed", "error", err)
+		}
+		return nil, false
+	}
+	return lists, true
+}
+
+// observeLocal polls for the `auth login` account. ok is false when nobody
+// is logged in.
+func (s *Server) observeLocal(ctx context.Context) (lists []api.ListWithTasks, ok bool, err error) {
+	token, err := s.AccessToken(ctx, NewTokenStore(s.config().TokensFile), defaultAccount)
+	if errors.Is(err, ErrNotLoggedIn) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	lists, err = s.observe(ctx, token, &s.local)
+	if err != nil {
+		return nil, false, err
+	}
+	return lists, true, nil
+}
+
+// pollChanges is the periodic change-detection job. Event streams are
+// polled with their own tokens; the `auth login` account is polled too
+// while some listener acts on it (reminders to a local sink, overdue
+// hooks).
+func (s *Server) pollChanges(ctx context.Context) error {
+	cfg := s.config()
+	if !cfg.Changes.Enabled {
+		return nil
+	}
+	for _, sub := range s.events.Subscribers() {
+		s.observeSubscriber(ctx, sub)
+	}
+	if cfg.Reminders.Enabled && cfg.scansLocally() {
+		if _, _, err := s.observeLocal(ctx); err != nil {
+			return fmt.Errorf("polling changes: %w", err)
+		}
+	}
+	return nil
+}
+
+// streamChanges is the change listener that sends each change to the
+// stream it was found for.
+func (s *Server) streamChanges(_ context.Context, w *watch, _ []api.ListWithTasks, events []api.TaskEvent) {
+	if w.sub == nil || !s.config().Changes.Enabled {
+		return
+	}
+	for _, event := range events {
+		s.events.Send(w.sub, "change", event)
+	}
+}
+
+// onSubscribe polls for a new stream right away, so it has a baseline for
+// change events and gets its current reminders without waiting for a scan.
+func (s *Server) onSubscribe(sub *Subscriber) {
+	cfg := s.config()
+	if !cfg.Changes.Enabled && !(cfg.Reminders.Enabled && slices.Contains(cfg.Reminders.Sinks, "sse")) {
+		return
+	}
+	s.jobs.Submit(Job{
+		Name:     "watch-subscribe",
+		Priority: PriorityLow,
+		Run: func(ctx context.Context) error {
+			if lists, ok := s.observeSubscriber(ctx, sub); ok {
+				s.remind(ctx, &sub.watch, lists)
+			}
+			return nil
+		},
+	})
+}
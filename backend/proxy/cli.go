@@ -0,0 +1,621 @@
+package proxy
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// defaultAccount is the token store account used by the CLI.
+const defaultAccount = "default"
+
+const usage = `Usage: gtask-auth-proxy <command> [flags]
+
+Commands:
+  serve                     run the auth proxy (default)
+  auth login                sign in to Google and store tokens locally
+                            (-no-browser to print the URL instead)
+  auth logout               forget the stored tokens
+  auth status               show whether tokens are stored
+  tasks list                print tasks grouped by list
+  tasks add "title"         create a task (-due, -list, -notes, -dry-run)
+  tasks agenda              print overdue, today's and this week's tasks
+                            (-date, -json)
+  doctor                    check the configuration and connectivity
+  openapi                   print the OpenAPI description of the HTTP API
+  self-update               replace this binary with the latest release
+
+Every command accepts the config flags listed by "serve -h".
+`
+
+// Main runs the gtask-auth-proxy command line: it dispatches a subcommand
+// (args excludes the program name) and returns the process exit code.
+// Without a subcommand, or with only flags, the proxy is served as before.
+func Main(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return serve(args)
+	}
+
+	switch args[0] {
+	case "serve":
+		return serve(args[1:])
+	case "auth":
+		return runSubcommand("auth", args[1:], map[string]func(*cli, context.Context) error{
+			"login":  (*cli).authLogin,
+			"logout": (*cli).authLogout,
+			"status": (*cli).authStatus,
+		})
+	case "tasks":
+		return runSubcommand("tasks", args[1:], map[string]func(*cli, context.Context) error{
+			"list":   (*cli).tasksList,
+			"add":    (*cli).tasksAdd,
+			"agenda": (*cli).tasksAgenda,
+		})
+	case "doctor":
+		return runDoctor(args[1:])
+	case "openapi":
+		spec, err := openAPISpec()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Building the OpenAPI description failed:", err)
+			return 1
+		}
+		fmt.Println(string(spec))
+		return 0
+	case "self-update":
+		if err := selfUpdate(context.Background(), NewUpstreamClient()); err != nil {
+			fmt.Fprintln(os.Stderr, "Self-update failed:", err)
+			return 1
+		}
+		return 0
+	case "help":
+		fmt.Print(usage)
+		return 0
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+}
+
+// cli is the state shared by the auth and tasks subcommands: they run on the
+// same Server (and so the same token and Google code) as the proxy.
+type cli struct {
+	fs     *flag.FlagSet
+	args   []string
+	cfg    *Config
+	server *Server
+	store  *TokenStore
+}
+
+// runSubcommand runs one of group's commands. Each command registers its own
+// flags on c.fs and then calls c.parse.
+func runSubcommand(group string, args []string, commands map[string]func(*cli, context.Context) error) int {
+	names := slices.Sorted(maps.Keys(commands))
+	if len(args) == 0 {
+		fmt.Fprintf(os.Stderr, "usage: gtask-auth-proxy %s <%s>\n", group, strings.Join(names, "|"))
+		return 2
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown %s command %q, expected one of: %s\n", group, args[0], strings.Join(names, ", "))
+		return 2
+	}
+
+	c := &cli{
+		fs:   flag.NewFlagSet("gtask-auth-proxy "+group+" "+args[0], flag.ContinueOnError),
+		args: args[1:],
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := run(c, ctx)
+	var usageErr badUsage
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usageErr):
+		fmt.Fprintln(os.Stderr, err)
+		c.fs.Usage()
+		return 2
+	case errors.Is(err, errFlags):
+		return 2
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return 1
+	}
+}
+
+// badUsage is a command line mistake; the subcommand's usage is printed
+// after it.
+type badUsage string
+
+func (e badUsage) Error() string { return string(e) }
+
+// errFlags reports a flag parse error, which the flag package has already
+// printed.
+var errFlags = errors.New("invalid flags")
+
+// parse parses the subcommand's flags, with the config flags added, and loads
+// the configuration. It returns the positional arguments.
+func (c *cli) parse() ([]string, error) {
+	flags := addConfigFlags(c.fs)
+	positional, err := parseArgs(c.fs, c.args)
+	if errors.Is(err, flag.ErrHelp) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, errFlags
+	}
+
+	c.cfg, err = flags.Load()
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	slog.SetLogLoggerLevel(c.cfg.LogLevel)
+	c.server, err = NewServer(c.cfg, flags.Load)
+	if err != nil {
+		return nil, err
+	}
+	c.store = NewTokenStore(c.cfg.TokensFile)
+	return positional, nil
+}
+
+// parseArgs parses fs, allowing flags after positional arguments as in
+// `tasks add "title" -due tomorrow`, and returns the positional arguments.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// usageError converts a parseArgs error to an exit code. The flag package
+// has already printed the problem and usage.
+func usageError(err error) int {
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	return 2
+}
+
+// authLogin runs the same PKCE flow as the plugin, serving the callback
+// locally, and stores the resulting tokens. google.redirect_uri must point at
+// this machine. The GitHub provider's device flow needs no callback.
+//
+// With -no-browser nothing is opened: the URL is printed on stdout, and on a
+// machine whose browser cannot reach the callback the redirected URL can be
+// pasted on stdin instead.
+func (c *cli) authLogin(ctx context.Context) error {
+	noBrowser := c.fs.Bool("no-browser", false, "print the sign-in URL instead of opening a browser")
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+
+	if c.server.github == nil {
+		redirect, err := url.Parse(c.cfg.Google.RedirectURI)
+		if err != nil || !isLoopback(redirect.Hostname()) {
+			return fmt.Errorf("auth login serves the OAuth callback itself, so google.redirect_uri must be a localhost URL such as http://localhost:%s/auth/callback (got %q)",
+				c.cfg.Port, c.cfg.Google.RedirectURI)
+		}
+		addr := redirect.Host
+		if redirect.Port() == "" {
+			addr = net.JoinHostPort(redirect.Hostname(), "80")
+		}
+		listener, err := net.Listen("tcp", addr)
+		if err != nil {
+			return fmt.Errorf("listening for the OAuth callback: %w", err)
+		}
+		callbackServer := &http.Server{Handler: c.server.routes()}
+		go callbackServer.Serve(listener)
+		defer callbackServer.Close()
+	}
+
+	// The code exchange runs as a job, auth.completed hooks included; stop
+	// the jobs and let it finish before exiting
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	defer func() {
+		stopJobs()
+		waitCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), hookCommandTimeout+nvimHookTimeout)
+		defer cancel()
+		c.server.jobs.Wait(waitCtx)
+	}()
+	c.server.Start(jobsCtx)
+
+	start, err := c.server.beginAuth(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	var pasted chan string
+	switch {
+	case start.UserCode != "":
+		fmt.Fprintf(os.Stderr, "Enter the code %s at this URL to sign in:\n", start.UserCode)
+		fmt.Println(start.AuthURL)
+		if !*noBrowser {
+			if err := openBrowser(start.AuthURL); err != nil {
+				fmt.Fprintln(os.Stderr, "Could not open a browser:", err)
+			}
+		}
+	case *noBrowser:
+		fmt.Fprintln(os.Stderr, "Open this URL in any browser to sign in:")
+		fmt.Println(start.AuthURL)
+		fmt.Fprintln(os.Stderr, "\nIf that browser cannot reach this machine, paste the URL it was redirected to here.")
+		pasted = make(chan string)
+		go readLines(os.Stdin, pasted)
+	default:
+		fmt.Fprintln(os.Stderr, "Open this URL to sign in:")
+		fmt.Println(start.AuthURL)
+		if err := openBrowser(start.AuthURL); err != nil {
+			fmt.Fprintln(os.Stderr, "Could not open a browser:", err)
+		}
+	}
+	fmt.Fprintln(os.Stderr, "Waiting for authorization...")
+
+	auth, err := c.waitForAuth(ctx, start.State, pasted)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(auth.Tokens)
+	if err != nil {
+		return err
+	}
+	token, err := parseToken(body)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Save(defaultAccount, token); err != nil {
+		return fmt.Errorf("saving tokens: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Logged in, tokens saved to", c.cfg.TokensFile)
+	return nil
+}
+
+// waitForAuth polls for the callback to complete state, giving up when the
+// state would have expired. Redirect URLs received on pasted complete it
+// directly.
+func (c *cli) waitForAuth(ctx context.Context, state string, pasted <-chan string) (CompletedAuth, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.StateTTL)
+	defer cancel()
+
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return CompletedAuth{}, errors.New("timed out waiting for authorization")
+			}
+			return CompletedAuth{}, ctx.Err()
+		case line := <-pasted:
+			if err := c.completePasted(ctx, state, line); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+			}
+		case <-ticker.C:
+		}
+		if auth, ok := c.server.completedAuth.Take(state); ok {
+			return auth, nil
+		}
+	}
+}
+
+// completePasted exchanges the code in a pasted redirect URL.
+func (c *cli) completePasted(ctx context.Context, state, line string) error {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return nil
+	}
+	redirect, err := url.Parse(line)
+	if err != nil {
+		return fmt.Errorf("not a URL: %w", err)
+	}
+	query := redirect.Query()
+	if e := query.Get("error"); e != "" {
+		return fmt.Errorf("google denied authorization: %s", e)
+	}
+	if query.Get("state") != state || query.Get("code") == "" {
+		return errors.New("that is not the redirect for this sign-in, paste the full URL from the address bar")
+	}
+	return c.server.exchangeCode(ctx, c.cfg.Google, state, query.Get("code"))
+}
+
+// readLines sends each line of r to lines until r is exhausted.
+func readLines(r io.Reader, lines chan<- string) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		lines <- scanner.Text()
+	}
+}
+
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func openBrowser(target string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", target)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", target)
+	default:
+		cmd = exec.Command("xdg-open", target)
+	}
+	return cmd.Start()
+}
+
+func (c *cli) authLogout(ctx context.Context) error {
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+	if err := c.store.Delete(defaultAccount); err != nil {
+		return err
+	}
+	fmt.Println("Logged out")
+	return nil
+}
+
+func (c *cli) authStatus(ctx context.Context) error {
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+	token, err := c.store.Load(defaultAccount)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Logged in, tokens stored in", c.cfg.TokensFile)
+	switch {
+	case token.expiring():
+		fmt.Println("Access token expired, it will be refreshed on next use")
+	case token.Expiry.IsZero():
+		fmt.Println("Access token does not expire")
+	default:
+		fmt.Println("Access token valid until", token.Expiry.Local().Format(time.DateTime))
+	}
+	return nil
+}
+
+func (c *cli) tasksList(ctx context.Context) error {
+	listName := c.fs.String("list", "", "only show this list (title or ID)")
+	completed := c.fs.Bool("completed", false, "include completed tasks")
+	asJSON := c.fs.Bool("json", false, "print the lists and tasks as JSON")
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+
+	token, err := c.server.AccessToken(ctx, c.store, defaultAccount)
+	if err != nil {
+		return err
+	}
+	query := url.Values{}
+	query.Set("showCompleted", fmt.Sprint(*completed))
+	query.Set("showHidden", fmt.Sprint(*completed))
+	results, err := c.server.tasks.FetchAll(ctx, token, query, c.cfg.FanoutWorkers)
+	if err != nil {
+		return err
+	}
+	if *listName != "" {
+		results = slices.DeleteFunc(results, func(l api.ListWithTasks) bool {
+			return l.ID != *listName && !strings.EqualFold(l.Title, *listName)
+		})
+		if len(results) == 0 {
+			return fmt.Errorf("no task list named %q", *listName)
+		}
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(results)
+	}
+
+	for i, list := range results {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Println(list.Title)
+		if list.Error != nil {
+			fmt.Println("  (failed to fetch tasks:", list.Error.Message+")")
+			continue
+		}
+		printTasks(list.Tasks, "", "  ")
+	}
+	return nil
+}
+
+// printTasks prints the tasks under parent in Google's order, indenting
+// subtasks beneath their parent.
+func printTasks(tasks []api.Task, parent, indent string) {
+	var children []api.Task
+	for _, task := range tasks {
+		if task.Parent == parent {
+			children = append(children, task)
+		}
+	}
+	slices.SortFunc(children, func(a, b api.Task) int { return cmp.Compare(a.Position, b.Position) })
+
+	for _, task := range children {
+		check := " "
+		if task.Status == "completed" {
+			check = "x"
+		}
+		line := indent + "[" + check + "] " + task.Title
+		if due, err := time.Parse(time.RFC3339, task.Due); err == nil {
+			line += "  (due " + due.UTC().Format(time.DateOnly) + ")"
+		}
+		fmt.Println(line)
+		printTasks(tasks, task.ID, indent+"  ")
+	}
+}
+
+func (c *cli) tasksAgenda(ctx context.Context) error {
+	dateFlag := c.fs.String("date", "today", "agenda date: today, tomorrow, a weekday or YYYY-MM-DD")
+	asJSON := c.fs.Bool("json", false, "print the agenda as JSON")
+	if _, err := c.parse(); err != nil {
+		return err
+	}
+	date, err := parseDate(*dateFlag, time.Now())
+	if err != nil {
+		return badUsage(err.Error())
+	}
+
+	token, err := c.server.AccessToken(ctx, c.store, defaultAccount)
+	if err != nil {
+		return err
+	}
+	lists, err := c.server.tasks.FetchAll(ctx, token, watchQuery, c.cfg.FanoutWorkers)
+	if err != nil {
+		return err
+	}
+	agenda := buildAgenda(lists, date)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(agenda)
+	}
+
+	fmt.Println("Agenda for", date.Format("Monday, 2 January 2006"))
+	sections := []struct {
+		title   string
+		items   []api.AgendaItem
+		showDue bool
+	}{
+		{"Overdue", agenda.Overdue, true},
+		{"Due today", agenda.DueToday, false},
+		{"Due this week", agenda.DueThisWeek, true},
+		{"Recently completed", agenda.RecentlyCompleted, false},
+	}
+	for _, section := range sections {
+		if len(section.items) == 0 {
+			continue
+		}
+		fmt.Printf("\n%s (%d)\n", section.title, len(section.items))
+		for _, item := range section.items {
+			line := "  " + item.Task.Title + "  [" + item.List.Title + "]"
+			if section.showDue {
+				line += "  (due " + dueDate(item.Task) + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+	if len(agenda.Overdue)+len(agenda.DueToday)+len(agenda.DueThisWeek)+len(agenda.RecentlyCompleted) == 0 {
+		fmt.Println("\nNothing due.")
+	}
+	for _, list := range agenda.Failed {
+		fmt.Printf("\n(failed to fetch %s: %s)\n", list.Title, list.Error.Message)
+	}
+	return nil
+}
+
+func (c *cli) tasksAdd(ctx context.Context) error {
+	due := c.fs.String("due", "", "due date: today, tomorrow, a weekday or YYYY-MM-DD")
+	listName := c.fs.String("list", "", "task list title or ID (default: the first list)")
+	notes := c.fs.String("notes", "", "task notes")
+	dryRunFlag := c.fs.Bool("dry-run", false, "print the request that would be sent instead of sending it")
+	args, err := c.parse()
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || strings.TrimSpace(args[0]) == "" {
+		return badUsage(`expected exactly one task title, e.g. tasks add "Buy milk" -due tomorrow`)
+	}
+
+	task := api.Task{Title: args[0], Notes: *notes, Status: "needsAction"}
+	if *due != "" {
+		date, err := parseDate(*due, time.Now())
+		if err != nil {
+			return badUsage(err.Error())
+		}
+		task.Due = googleDue(date)
+	}
+
+	token, err := c.server.AccessToken(ctx, c.store, defaultAccount)
+	if err != nil {
+		return err
+	}
+	list, err := c.findList(ctx, token, *listName)
+	if err != nil {
+		return err
+	}
+	if *dryRunFlag {
+		return printDryRun(InsertWrite(list.ID, task))
+	}
+	created, err := c.server.tasks.InsertTask(ctx, token, list.ID, task)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Added %q to %s\n", created.Title, list.Title)
+	return nil
+}
+
+// findList resolves a list by ID or case-insensitive title; an empty name
+// selects the first (default) list.
+func (c *cli) findList(ctx context.Context, token, name string) (api.TaskList, error) {
+	lists, err := c.server.tasks.ListTaskLists(ctx, token)
+	if err != nil {
+		return api.TaskList{}, err
+	}
+	for _, list := range lists {
+		if name == "" || list.ID == name || strings.EqualFold(list.Title, name) {
+			return list, nil
+		}
+	}
+	if name == "" {
+		return api.TaskList{}, errors.New("the account has no task lists")
+	}
+	return api.TaskList{}, fmt.Errorf("no task list named %q", name)
+}
+
+// printDryRun shows a write that was not sent.
+func printDryRun(w api.TaskWrite) error {
+	target := w.Path
+	if len(w.Query) > 0 {
+		target += "?" + w.Query.Encode()
+	}
+	fmt.Println("Dry run, would send:", w.Method, target)
+	if w.Body == nil {
+		return nil
+	}
+	body, err := json.MarshalIndent(w.Body, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(body))
+	return nil
+}
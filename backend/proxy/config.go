@@ -0,0 +1,978 @@
+package proxy
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Config holds every backend setting. Values are layered, lowest precedence
+// first: built-in defaults, the legacy credentials JSON file, the TOML config
+// file, environment variables and finally command line flags.
+type Config struct {
+	Path            string
+	Port            string
+	Listen          []string // as configured; see listenAddrs
+	ShutdownTimeout time.Duration
+	MaxTimeout      time.Duration // longest X-Timeout a request may ask for
+	CredentialsFile string
+	Provider        string
+	FixturesMode    string
+	FixturesFile    string
+	Google          GoogleConfig
+	GitHub          GitHubConfig
+	Upstream        UpstreamConfig
+	StateTTL        time.Duration
+	CleanupInterval time.Duration
+	MaxPendingAuth  int
+	FanoutWorkers   int
+	UndoDepth       int
+	MetadataFile    string
+	ArchiveFile     string
+	HistoryFile     string
+	ShareFile       string
+	ShareURL        string // public URL of the proxy, for share links
+	JobWorkers      int
+	CacheTTL        time.Duration
+	CacheMaxEntries int
+	LogLevel        slog.Level
+	AccessLog       bool
+	LogFile         string
+	LogMaxSize      int64
+	LogMaxFiles     int
+	Language        string
+	Reminders       RemindersConfig
+	Hooks           HooksConfig
+	Changes         ChangesConfig
+	Calendar        CalendarConfig
+	Backup          BackupConfig
+	Tenants         TenantsConfig
+	AdminToken      string
+	TokensFile      string
+}
+
+// GitHubConfig is the OAuth app the GitHub provider signs in with, by
+// GitHub's device flow.
+type GitHubConfig struct {
+	ClientID     string
+	ClientSecret string // optional: for refreshing expiring tokens and revoking grants
+	Scope        string
+}
+
+// UpstreamConfig controls outbound connections to Google.
+type UpstreamConfig struct {
+	Proxy       string // proxy URL; HTTP_PROXY and friends when empty
+	CAFile      string // PEM certificates trusted besides (or instead of) the system's
+	SystemRoots bool
+	DailyQuota  int // Tasks API calls a day the OAuth client may make
+}
+
+// RemindersConfig controls the due-task reminder scan.
+type RemindersConfig struct {
+	Enabled    bool
+	Interval   time.Duration
+	Ahead      time.Duration
+	Sinks      []string // desktop, sse and/or command
+	Command    string
+	SnoozeFile string
+}
+
+// remindsLocally reports whether reminders go to a sink on this machine,
+// for the `auth login` account.
+func (c *Config) remindsLocally() bool {
+	return slices.Contains(c.Reminders.Sinks, "desktop") || slices.Contains(c.Reminders.Sinks, "command")
+}
+
+// scansLocally reports whether the reminder scan polls the `auth login`
+// account: for the local sinks or task.overdue hooks.
+func (c *Config) scansLocally() bool {
+	return c.remindsLocally() || c.hooksOn(api.HookTaskOverdue)
+}
+
+// HooksConfig controls the commands and Neovim notifications run for
+// events.
+type HooksConfig struct {
+	Events  []string // of api.HookEvents
+	Command string
+	Nvim    string // address of a Neovim server: socket path or host:port
+}
+
+// hooksOn reports whether event runs hooks.
+func (c *Config) hooksOn(event string) bool {
+	return slices.Contains(c.Hooks.Events, event)
+}
+
+// ChangesConfig controls polling for changes made by other clients.
+type ChangesConfig struct {
+	Enabled  bool
+	Interval time.Duration
+}
+
+// CalendarConfig controls scheduling tasks on Google Calendar.
+type CalendarConfig struct {
+	ID       string // calendar events are created in
+	Duration time.Duration
+}
+
+// BackupConfig controls scheduled backups of task data.
+type BackupConfig struct {
+	Enabled  bool
+	Interval time.Duration
+	Dir      string
+	Keep     int // backups kept, newest first
+}
+
+// TenantsConfig controls multi-tenant mode, where several users share the
+// proxy and each identifies with a session.
+type TenantsConfig struct {
+	Enabled    bool
+	Users      map[string]string // secret by user name
+	Dir        string
+	SessionTTL time.Duration
+}
+
+// ErrMissingCredentials is returned by Load when no OAuth client is
+// configured.
+var ErrMissingCredentials = errors.New("google client_id and client_secret are required")
+
+// setting describes one configurable value and every place it can come from.
+type setting struct {
+	key   string // config file key, "section.name"
+	env   string
+	flag  string
+	usage string
+	set   func(c *Config, v string) error
+}
+
+var settings = []setting{
+	{"server.port", "PORT", "port", "port to listen on", func(c *Config, v string) error {
+		c.Port = v
+		return nil
+	}},
+	{"server.listen", "LISTEN", "listen", "addresses to listen on, comma separated: host:port, a host for server.port, or unix:/path for a unix socket (default: every interface on server.port)", func(c *Config, v string) error {
+		var listen []string
+		for _, entry := range strings.Split(v, ",") {
+			if entry = strings.TrimSpace(entry); entry != "" {
+				listen = append(listen, entry)
+			}
+		}
+		c.Listen = listen
+		return nil
+	}},
+	{"server.shutdown_timeout", "SHUTDOWN_TIMEOUT", "shutdown-timeout", "how long to wait for in-flight work on shutdown", func(c *Config, v string) error {
+		return setDuration(&c.ShutdownTimeout, v)
+	}},
+	{"server.max_timeout", "MAX_TIMEOUT", "max-timeout", "longest deadline a request may set with X-Timeout or ?timeout=; longer ones are cut to it", func(c *Config, v string) error {
+		return setDuration(&c.MaxTimeout, v)
+	}},
+	{"provider.name", "PROVIDER", "provider", "google, mock for in-memory data without credentials, or github for the GitHub issues assigned to you", func(c *Config, v string) error {
+		if v != "google" && v != "mock" && v != "github" {
+			return fmt.Errorf("unknown provider %q, expected google, mock or github", v)
+		}
+		c.Provider = v
+		return nil
+	}},
+	{"fixtures.mode", "FIXTURES_MODE", "fixtures-mode", "off, record (save Google traffic to fixtures.file) or replay (serve it from there)", func(c *Config, v string) error {
+		if v != "off" && v != "record" && v != "replay" {
+			return fmt.Errorf("unknown fixtures mode %q, expected off, record or replay", v)
+		}
+		c.FixturesMode = v
+		return nil
+	}},
+	{"fixtures.file", "FIXTURES_FILE", "fixtures-file", "fixture (cassette) file to record to or replay from", func(c *Config, v string) error {
+		c.FixturesFile = v
+		return nil
+	}},
+	{"google.credentials_file", "GOOGLE_CREDENTIALS_FILE", "credentials-file", "legacy google-auth-credentials.json path", func(c *Config, v string) error {
+		c.CredentialsFile = v
+		return nil
+	}},
+	{"google.client_id", "GOOGLE_CLIENT_ID", "client-id", "OAuth 2.0 client ID", func(c *Config, v string) error {
+		c.Google.ClientID = v
+		return nil
+	}},
+	{"google.client_secret", "GOOGLE_CLIENT_SECRET", "client-secret", "OAuth 2.0 client secret", func(c *Config, v string) error {
+		c.Google.ClientSecret = v
+		return nil
+	}},
+	{"google.redirect_uri", "REDIRECT_URI", "redirect-uri", "OAuth redirect URI", func(c *Config, v string) error {
+		c.Google.RedirectURI = v
+		return nil
+	}},
+	{"google.scope", "GOOGLE_SCOPE", "scope", "OAuth scopes, space separated", func(c *Config, v string) error {
+		c.Google.Scope = v
+		return nil
+	}},
+	{"github.client_id", "GITHUB_CLIENT_ID", "github-client-id", "client ID of the GitHub OAuth app the github provider signs in with, with device flow enabled", func(c *Config, v string) error {
+		c.GitHub.ClientID = v
+		return nil
+	}},
+	{"github.client_secret", "GITHUB_CLIENT_SECRET", "github-client-secret", "the GitHub OAuth app's client secret, only needed to refresh expiring tokens and revoke grants", func(c *Config, v string) error {
+		c.GitHub.ClientSecret = v
+		return nil
+	}},
+	{"github.scope", "GITHUB_SCOPE", "github-scope", "GitHub OAuth scopes, space separated; repo to close issues in private repositories, public_repo otherwise", func(c *Config, v string) error {
+		c.GitHub.Scope = v
+		return nil
+	}},
+	{"upstream.proxy", "UPSTREAM_PROXY", "upstream-proxy", "proxy for all calls to Google: http://host:port or socks5://[user:password@]host:port (default: HTTP_PROXY, HTTPS_PROXY and NO_PROXY)", func(c *Config, v string) error {
+		if _, err := parseProxyURL(v); err != nil {
+			return err
+		}
+		c.Upstream.Proxy = v
+		return nil
+	}},
+	{"upstream.ca_file", "UPSTREAM_CA_FILE", "upstream-ca-file", "PEM file of CA certificates to trust for calls to Google, such as a TLS-intercepting proxy's (~/ is expanded)", func(c *Config, v string) error {
+		c.Upstream.CAFile = expandHome(v)
+		return nil
+	}},
+	{"upstream.system_roots", "UPSTREAM_SYSTEM_ROOTS", "upstream-system-roots", "trust the system's CA certificates as well as upstream.ca_file (true or false)", func(c *Config, v string) error {
+		return setBool(&c.Upstream.SystemRoots, v)
+	}},
+	{"upstream.daily_quota", "UPSTREAM_DAILY_QUOTA", "upstream-daily-quota", "Tasks API calls a day the OAuth client may make, as shown in Google Cloud Console, for GET /api/usage", func(c *Config, v string) error {
+		return setPositiveInt(&c.Upstream.DailyQuota, v)
+	}},
+	{"auth.state_ttl", "STATE_TTL", "state-ttl", "how long pending and completed auth states are kept", func(c *Config, v string) error {
+		return setDuration(&c.StateTTL, v)
+	}},
+	{"auth.cleanup_interval", "CLEANUP_INTERVAL", "cleanup-interval", "how often expired auth states are purged", func(c *Config, v string) error {
+		return setDuration(&c.CleanupInterval, v)
+	}},
+	{"auth.max_pending", "MAX_PENDING_AUTH", "max-pending-auth", "maximum pending (and completed) auth states kept; oldest are evicted", func(c *Config, v string) error {
+		return setPositiveInt(&c.MaxPendingAuth, v)
+	}},
+	{"api.fanout_workers", "FANOUT_WORKERS", "fanout-workers", "concurrent list fetches for /api/tasks", func(c *Config, v string) error {
+		return setPositiveInt(&c.FanoutWorkers, v)
+	}},
+	{"api.undo_depth", "UNDO_DEPTH", "undo-depth", "mutations /api/undo can revert, per session", func(c *Config, v string) error {
+		return setPositiveInt(&c.UndoDepth, v)
+	}},
+	{"api.metadata_file", "METADATA_FILE", "metadata-file", "where task metadata such as calendar events and attached links is recorded (~/ is expanded)", func(c *Config, v string) error {
+		c.MetadataFile = expandHome(v)
+		return nil
+	}},
+	{"api.archive_file", "ARCHIVE_FILE", "archive-file", "where completed tasks are archived before a list is cleared (~/ is expanded)", func(c *Config, v string) error {
+		c.ArchiveFile = expandHome(v)
+		return nil
+	}},
+	{"api.history_file", "HISTORY_FILE", "history-file", "where the changes made to each task are recorded (~/ is expanded)", func(c *Config, v string) error {
+		c.HistoryFile = expandHome(v)
+		return nil
+	}},
+	{"api.share_file", "SHARE_FILE", "share-file", "where shared snapshots of lists are kept (~/ is expanded)", func(c *Config, v string) error {
+		c.ShareFile = expandHome(v)
+		return nil
+	}},
+	{"api.share_url", "SHARE_URL", "share-url", "public URL of the proxy that share links start with (default: the host the request came to)", func(c *Config, v string) error {
+		if v != "" {
+			if u, err := url.Parse(v); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("not an http(s) URL: %s", v)
+			}
+		}
+		c.ShareURL = v
+		return nil
+	}},
+	{"jobs.workers", "JOB_WORKERS", "job-workers", "background job worker pool size", func(c *Config, v string) error {
+		return setPositiveInt(&c.JobWorkers, v)
+	}},
+	{"cache.ttl", "CACHE_TTL", "cache-ttl", "how long Tasks API reads are served without revalidation", func(c *Config, v string) error {
+		return setDuration(&c.CacheTTL, v)
+	}},
+	{"cache.max_entries", "CACHE_MAX_ENTRIES", "cache-max-entries", "maximum cached Tasks API responses", func(c *Config, v string) error {
+		return setPositiveInt(&c.CacheMaxEntries, v)
+	}},
+	{"log.level", "LOG_LEVEL", "log-level", "debug, info, warn or error", func(c *Config, v string) error {
+		return c.LogLevel.UnmarshalText([]byte(v))
+	}},
+	{"log.access", "ACCESS_LOG", "access-log", "log every HTTP request (true or false)", func(c *Config, v string) error {
+		return setBool(&c.AccessLog, v)
+	}},
+	{"log.file", "LOG_FILE", "log-file", "also write the log to this file (~/ is expanded)", func(c *Config, v string) error {
+		c.LogFile = expandHome(v)
+		return nil
+	}},
+	{"log.max_size", "LOG_MAX_SIZE", "log-max-size", "rotate log.file when it would exceed this size (bytes, or with KB, MB, GB)", func(c *Config, v string) error {
+		size, err := parseSize(v)
+		if err != nil {
+			return err
+		}
+		c.LogMaxSize = size
+		return nil
+	}},
+	{"log.max_files", "LOG_MAX_FILES", "log-max-files", "rotated log files kept", func(c *Config, v string) error {
+		return setPositiveInt(&c.LogMaxFiles, v)
+	}},
+	{"ui.language", "UI_LANGUAGE", "ui-language", "language of browser pages when Accept-Language has no supported one", func(c *Config, v string) error {
+		if !slices.Contains(languages, v) {
+			return fmt.Errorf("unsupported language %q, expected one of %s", v, strings.Join(languages, ", "))
+		}
+		c.Language = v
+		return nil
+	}},
+	{"reminders.enabled", "REMINDERS_ENABLED", "reminders", "scan for due and overdue tasks and send reminders (true or false)", func(c *Config, v string) error {
+		return setBool(&c.Reminders.Enabled, v)
+	}},
+	{"reminders.interval", "REMINDERS_INTERVAL", "reminders-interval", "how often tasks are scanned for reminders", func(c *Config, v string) error {
+		return setDuration(&c.Reminders.Interval, v)
+	}},
+	{"reminders.ahead", "REMINDERS_AHEAD", "reminders-ahead", "remind of tasks due within this long", func(c *Config, v string) error {
+		return setDuration(&c.Reminders.Ahead, v)
+	}},
+	{"reminders.sinks", "REMINDERS_SINKS", "reminders-sinks", "where reminders go: desktop, sse and/or command, comma separated", func(c *Config, v string) error {
+		var sinks []string
+		for _, sink := range strings.Split(v, ",") {
+			sink = strings.TrimSpace(sink)
+			if sink == "" {
+				continue
+			}
+			if !slices.Contains(reminderSinks, sink) {
+				return fmt.Errorf("unknown reminder sink %q, expected desktop, sse or command", sink)
+			}
+			sinks = append(sinks, sink)
+		}
+		c.Reminders.Sinks = sinks
+		return nil
+	}},
+	{"reminders.command", "REMINDERS_COMMAND", "reminders-command", "shell command run for each reminder by the command sink", func(c *Config, v string) error {
+		c.Reminders.Command = v
+		return nil
+	}},
+	{"reminders.snooze_file", "REMINDERS_SNOOZE_FILE", "reminders-snooze-file", "where snoozes are recorded (~/ is expanded)", func(c *Config, v string) error {
+		c.Reminders.SnoozeFile = expandHome(v)
+		return nil
+	}},
+	{"hooks.events", "HOOKS_EVENTS", "hooks-events", "events that run hooks: auth.completed, sync.finished and/or task.overdue, comma separated", func(c *Config, v string) error {
+		var events []string
+		for _, event := range strings.Split(v, ",") {
+			event = strings.TrimSpace(event)
+			if event == "" {
+				continue
+			}
+			if !slices.Contains(api.HookEvents, event) {
+				return fmt.Errorf("unknown hook event %q, expected %s", event, strings.Join(api.HookEvents, ", "))
+			}
+			events = append(events, event)
+		}
+		c.Hooks.Events = events
+		return nil
+	}},
+	{"hooks.command", "HOOKS_COMMAND", "hooks-command", "shell command run for each hook event", func(c *Config, v string) error {
+		c.Hooks.Command = v
+		return nil
+	}},
+	{"hooks.nvim", "HOOKS_NVIM", "hooks-nvim", "Neovim server notified of each hook event: socket path or host:port (~/ is expanded)", func(c *Config, v string) error {
+		c.Hooks.Nvim = expandHome(v)
+		return nil
+	}},
+	{"changes.enabled", "CHANGES_ENABLED", "changes", "poll for changes made by other clients and stream them as events (true or false)", func(c *Config, v string) error {
+		return setBool(&c.Changes.Enabled, v)
+	}},
+	{"changes.interval", "CHANGES_INTERVAL", "changes-interval", "how often tasks are polled for changes", func(c *Config, v string) error {
+		return setDuration(&c.Changes.Interval, v)
+	}},
+	{"calendar.id", "CALENDAR_ID", "calendar-id", "Google Calendar that scheduled tasks are added to", func(c *Config, v string) error {
+		c.Calendar.ID = v
+		return nil
+	}},
+	{"calendar.duration", "CALENDAR_DURATION", "calendar-duration", "length of a scheduled task when none is given", func(c *Config, v string) error {
+		return setDuration(&c.Calendar.Duration, v)
+	}},
+	{"backup.enabled", "BACKUP_ENABLED", "backup", "write scheduled backups of tasks and the proxy's task data (true or false)", func(c *Config, v string) error {
+		return setBool(&c.Backup.Enabled, v)
+	}},
+	{"backup.interval", "BACKUP_INTERVAL", "backup-interval", "how often a backup is written", func(c *Config, v string) error {
+		return setDuration(&c.Backup.Interval, v)
+	}},
+	{"backup.dir", "BACKUP_DIR", "backup-dir", "directory backups are written to (~/ is expanded)", func(c *Config, v string) error {
+		c.Backup.Dir = expandHome(v)
+		return nil
+	}},
+	{"backup.keep", "BACKUP_KEEP", "backup-keep", "backups kept; older ones are removed", func(c *Config, v string) error {
+		return setPositiveInt(&c.Backup.Keep, v)
+	}},
+	{"tenants.enabled", "TENANTS_ENABLED", "tenants", "multi-tenant mode: users open sessions and get their own tokens and snoozes (true or false)", func(c *Config, v string) error {
+		return setBool(&c.Tenants.Enabled, v)
+	}},
+	{"tenants.users", "TENANTS_USERS", "tenants-users", "users allowed to open sessions, as name:secret, comma separated", func(c *Config, v string) error {
+		users := make(map[string]string)
+		for _, entry := range strings.Split(v, ",") {
+			entry = strings.TrimSpace(entry)
+			if entry == "" {
+				continue
+			}
+			name, secret, ok := strings.Cut(entry, ":")
+			if !ok || secret == "" {
+				return fmt.Errorf("tenant %q has no secret, expected name:secret", name)
+			}
+			if !tenantName.MatchString(name) {
+				return fmt.Errorf("invalid tenant name %q, use letters, digits, '.', '_' and '-'", name)
+			}
+			if _, dup := users[name]; dup {
+				return fmt.Errorf("tenant %q is listed twice", name)
+			}
+			users[name] = secret
+		}
+		c.Tenants.Users = users
+		return nil
+	}},
+	{"tenants.dir", "TENANTS_DIR", "tenants-dir", "where sessions and each user's tokens and snoozes are kept (~/ is expanded)", func(c *Config, v string) error {
+		c.Tenants.Dir = expandHome(v)
+		return nil
+	}},
+	{"tenants.session_ttl", "TENANTS_SESSION_TTL", "tenants-session-ttl", "how long a session lasts", func(c *Config, v string) error {
+		return setDuration(&c.Tenants.SessionTTL, v)
+	}},
+	{"admin.token", "ADMIN_TOKEN", "admin-token", "bearer token for /admin endpoints (disabled when empty)", func(c *Config, v string) error {
+		c.AdminToken = v
+		return nil
+	}},
+	{"cli.tokens_file", "TOKENS_FILE", "tokens-file", "where the CLI stores Google tokens", func(c *Config, v string) error {
+		c.TokensFile = v
+		return nil
+	}},
+}
+
+func setDuration(d *time.Duration, v string) error {
+	parsed, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("duration must be positive: %s", v)
+	}
+	*d = parsed
+	return nil
+}
+
+func setPositiveInt(n *int, v string) error {
+	parsed, err := strconv.Atoi(v)
+	if err != nil {
+		return err
+	}
+	if parsed <= 0 {
+		return fmt.Errorf("must be positive: %s", v)
+	}
+	*n = parsed
+	return nil
+}
+
+func setBool(b *bool, v string) error {
+	parsed, err := strconv.ParseBool(v)
+	if err != nil {
+		return err
+	}
+	*b = parsed
+	return nil
+}
+
+// DefaultConfig returns the settings used when nothing overrides them.
+func DefaultConfig() *Config {
+	return &Config{
+		Path:            defaultConfigPath(),
+		Port:            "3000",
+		ShutdownTimeout: 15 * time.Second,
+		MaxTimeout:      5 * time.Minute,
+		CredentialsFile: "./google-auth-credentials.json",
+		Provider:        "google",
+		FixturesMode:    "off",
+		FixturesFile:    "fixtures.json",
+		Google: GoogleConfig{
+			RedirectURI: "https://app.priteshtupe.com/gtask/auth/callback",
+			Scope:       "https://www.googleapis.com/auth/tasks",
+		},
+		GitHub:          GitHubConfig{Scope: "repo"},
+		StateTTL:        10 * time.Minute,
+		CleanupInterval: 5 * time.Minute,
+		MaxPendingAuth:  10000,
+		FanoutWorkers:   4,
+		UndoDepth:       20,
+		MetadataFile:    defaultDataPath("metadata.json"),
+		ArchiveFile:     defaultDataPath("archive.json"),
+		HistoryFile:     defaultDataPath("history.json"),
+		ShareFile:       defaultDataPath("shares.json"),
+		JobWorkers:      2,
+		CacheTTL:        30 * time.Second,
+		CacheMaxEntries: 1000,
+		LogLevel:        slog.LevelInfo,
+		AccessLog:       true,
+		LogMaxSize:      10 << 20,
+		LogMaxFiles:     5,
+		Language:        "en",
+		Reminders: RemindersConfig{
+			Interval:   5 * time.Minute,
+			Ahead:      24 * time.Hour,
+			Sinks:      []string{"desktop", "sse"},
+			SnoozeFile: defaultDataPath("snoozes.json"),
+		},
+		Changes:  ChangesConfig{Interval: time.Minute},
+		Calendar: CalendarConfig{ID: "primary", Duration: 30 * time.Minute},
+		Backup:   BackupConfig{Interval: 24 * time.Hour, Dir: defaultDataPath("backups"), Keep: 7},
+		Tenants: TenantsConfig{
+			Dir:        defaultDataPath("tenants"),
+			SessionTTL: 30 * 24 * time.Hour,
+		},
+		TokensFile: defaultDataPath("tokens.json"),
+		Upstream:   UpstreamConfig{SystemRoots: true, DailyQuota: 50000},
+	}
+}
+
+// defaultConfigPath returns $XDG_CONFIG_HOME/gtask/config.toml, falling back
+// to ~/.config/gtask/config.toml.
+func defaultConfigPath() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "gtask", "config.toml")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".config", "gtask", "config.toml")
+}
+
+// defaultDataPath returns $XDG_DATA_HOME/gtask/name, falling back to
+// ~/.local/share/gtask/name.
+func defaultDataPath(name string) string {
+	if dir := os.Getenv("XDG_DATA_HOME"); dir != "" {
+		return filepath.Join(dir, "gtask", name)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".local", "share", "gtask", name)
+}
+
+// configFlags are the config flags registered on a FlagSet, so every
+// subcommand accepts them alongside its own flags.
+type configFlags struct {
+	fs     *flag.FlagSet
+	path   *string
+	values map[string]*string
+}
+
+func addConfigFlags(fs *flag.FlagSet) *configFlags {
+	f := &configFlags{
+		fs:     fs,
+		path:   fs.String("config", "", "config file path (default "+defaultConfigPath()+")"),
+		values: make(map[string]*string, len(settings)),
+	}
+	for _, s := range settings {
+		f.values[s.flag] = fs.String(s.flag, "", s.usage)
+	}
+	return f
+}
+
+// Load resolves the configuration once the FlagSet has been parsed. It can be
+// called again to pick up changes to the file and environment.
+func (f *configFlags) Load() (*Config, error) {
+	cfg, err := f.LoadIncomplete()
+	if err != nil {
+		return nil, err
+	}
+	if !cfg.configured() {
+		return nil, ErrMissingCredentials
+	}
+	return cfg, nil
+}
+
+// configured reports whether an OAuth client is set.
+func (c *Config) configured() bool {
+	if c.Provider == "github" {
+		return c.GitHub.ClientID != ""
+	}
+	return c.Google.ClientID != "" && c.Google.ClientSecret != ""
+}
+
+// LoadIncomplete is Load without requiring an OAuth client, for serving the
+// setup page.
+func (f *configFlags) LoadIncomplete() (*Config, error) {
+	cfg := DefaultConfig()
+
+	explicitPath := *f.path != ""
+	switch {
+	case explicitPath:
+		cfg.Path = *f.path
+	case os.Getenv("CONFIG_FILE") != "":
+		cfg.Path = os.Getenv("CONFIG_FILE")
+		explicitPath = true
+	}
+
+	// The credentials file location can itself come from any layer, so
+	// resolve it before reading the file.
+	fileValues, err := readConfigFile(cfg.Path, explicitPath)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := fileValues["google.credentials_file"]; ok {
+		cfg.CredentialsFile = v
+	}
+	if v := os.Getenv("GOOGLE_CREDENTIALS_FILE"); v != "" {
+		cfg.CredentialsFile = v
+	}
+	if v := *f.values["credentials-file"]; v != "" {
+		cfg.CredentialsFile = v
+	}
+	if err := loadCredentialsFile(cfg); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.apply(fileValues, f.fs, f.values); err != nil {
+		return nil, err
+	}
+
+	// The mock provider accepts any client, so none has to be configured
+	if cfg.Provider == "mock" {
+		cfg.Google.ClientID = cmp.Or(cfg.Google.ClientID, "mock-client-id")
+		cfg.Google.ClientSecret = cmp.Or(cfg.Google.ClientSecret, "mock-client-secret")
+	}
+
+	// The setup page only configures Google clients
+	if cfg.Provider == "github" && cfg.GitHub.ClientID == "" {
+		return nil, errors.New("provider.name is github but github.client_id is empty")
+	}
+	if slices.Contains(cfg.Reminders.Sinks, "command") && cfg.Reminders.Command == "" {
+		return nil, errors.New("reminders.sinks includes command but reminders.command is empty")
+	}
+	if len(cfg.Hooks.Events) > 0 && cfg.Hooks.Command == "" && cfg.Hooks.Nvim == "" {
+		return nil, errors.New("hooks.events is set but neither hooks.command nor hooks.nvim is")
+	}
+	if cfg.Tenants.Enabled && len(cfg.Tenants.Users) == 0 {
+		return nil, errors.New("tenants.enabled is set but tenants.users is empty")
+	}
+
+	return cfg, nil
+}
+
+// apply layers file values, then environment variables, then flags that
+// were explicitly set on the command line.
+func (c *Config) apply(fileValues map[string]string, fs *flag.FlagSet, flagValues map[string]*string) error {
+	for _, s := range settings {
+		if v, ok := fileValues[s.key]; ok {
+			if err := s.set(c, v); err != nil {
+				return fmt.Errorf("%s: %s: %w", c.Path, s.key, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if v := os.Getenv(s.env); v != "" {
+			if err := s.set(c, v); err != nil {
+				return fmt.Errorf("$%s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range settings {
+			if s.flag == f.Name && flagErr == nil {
+				if err := s.set(c, *flagValues[s.flag]); err != nil {
+					flagErr = fmt.Errorf("-%s: %w", s.flag, err)
+				}
+			}
+		}
+	})
+	return flagErr
+}
+
+// loadCredentialsFile reads the legacy google-auth-credentials.json. A
+// missing file is fine when credentials are supplied another way.
+func loadCredentialsFile(cfg *Config) error {
+	if cfg.CredentialsFile == "" {
+		return nil
+	}
+
+	file, err := os.Open(cfg.CredentialsFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := json.NewDecoder(file).Decode(&cfg.Google); err != nil {
+		return fmt.Errorf("%s: %w", cfg.CredentialsFile, err)
+	}
+	return nil
+}
+
+// readConfigFile parses the TOML config file into flat "section.key" values.
+// A missing file is only an error when its path was given explicitly.
+func readConfigFile(path string, required bool) (map[string]string, error) {
+	if path == "" {
+		return map[string]string{}, nil
+	}
+
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return map[string]string{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	values, err := parseTOML(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	known := make(map[string]bool, len(settings))
+	for _, s := range settings {
+		known[s.key] = true
+	}
+	for key := range values {
+		if !known[key] {
+			return nil, fmt.Errorf("%s: unknown setting %q", path, key)
+		}
+	}
+
+	return values, nil
+}
+
+// parseTOML understands the subset of TOML used by the config file: [tables],
+// comments, and key = value pairs whose values are strings, integers,
+// booleans or single-line arrays of strings. Arrays are joined with commas.
+func parseTOML(r io.Reader) (map[string]string, error) {
+	values := make(map[string]string)
+	section := ""
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(stripComment(scanner.Text()))
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: malformed table header", lineNo)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		key, raw, ok := strings.Cut(line, "=")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key = value", lineNo)
+		}
+		key = strings.TrimSpace(key)
+		if section != "" {
+			key = section + "." + key
+		}
+
+		value, err := parseTOMLValue(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo, err)
+		}
+		values[key] = value
+	}
+
+	return values, scanner.Err()
+}
+
+// stripComment drops a trailing # comment that is not inside a string.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
+
+func parseTOMLValue(raw string) (string, error) {
+	switch {
+	case raw == "":
+		return "", errors.New("missing value")
+	case strings.HasPrefix(raw, `"`):
+		return strconv.Unquote(raw)
+	case strings.HasPrefix(raw, "'"):
+		if len(raw) < 2 || !strings.HasSuffix(raw, "'") {
+			return "", errors.New("unterminated string")
+		}
+		return raw[1 : len(raw)-1], nil
+	case strings.HasPrefix(raw, "["):
+		if !strings.HasSuffix(raw, "]") {
+			return "", errors.New("unterminated array")
+		}
+		var items []string
+		for _, item := range splitTOMLArray(raw[1 : len(raw)-1]) {
+			v, err := parseTOMLValue(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, v)
+		}
+		return strings.Join(items, ","), nil
+	case raw == "true" || raw == "false":
+		return raw, nil
+	default:
+		if _, err := strconv.ParseInt(strings.ReplaceAll(raw, "_", ""), 10, 64); err != nil {
+			return "", fmt.Errorf("unsupported value %s", raw)
+		}
+		return strings.ReplaceAll(raw, "_", ""), nil
+	}
+}
+
+func splitTOMLArray(body string) []string {
+	var items []string
+	var quote byte
+	start := 0
+	for i := 0; i < len(body); i++ {
+		switch c := body[i]; {
+		case quote != 0:
+			if c == '\\' && quote == '"' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ',':
+			items = append(items, strings.TrimSpace(body[start:i]))
+			start = i + 1
+		}
+	}
+	if last := strings.TrimSpace(body[start:]); last != "" {
+		items = append(items, last)
+	}
+	return items
+}
+
+// updateConfigFile sets keys ("section.name") in the TOML file at path,
+// replacing existing lines and adding missing ones to their table, which is
+// created if needed. Other lines and comments are kept.
+func updateConfigFile(path string, values map[string]string) error {
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	pending := make(map[string]string, len(values))
+	for key, v := range values {
+		pending[key] = v
+	}
+	// insert adds the pending keys of section after its last non-blank line
+	var out []string
+	insert := func(section string) {
+		var added []string
+		for _, key := range slices.Sorted(maps.Keys(pending)) {
+			table, name, _ := strings.Cut(key, ".")
+			if table == section {
+				added = append(added, name+" = "+strconv.Quote(pending[key]))
+				delete(pending, key)
+			}
+		}
+		end := len(out)
+		for end > 0 && strings.TrimSpace(out[end-1]) == "" {
+			end--
+		}
+		out = append(out[:end], append(added, out[end:]...)...)
+	}
+
+	section := ""
+	if len(data) > 0 {
+		for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+			trimmed := strings.TrimSpace(stripComment(line))
+			if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+				insert(section)
+				section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			} else if name, _, ok := strings.Cut(trimmed, "="); ok {
+				key := section + "." + strings.TrimSpace(name)
+				if v, ok := pending[key]; ok {
+					line = strings.TrimSpace(name) + " = " + strconv.Quote(v)
+					delete(pending, key)
+				}
+			}
+			out = append(out, line)
+		}
+	}
+	insert(section)
+
+	for len(pending) > 0 {
+		table, _, _ := strings.Cut(slices.Sorted(maps.Keys(pending))[0], ".")
+		if len(out) > 0 {
+			out = append(out, "")
+		}
+		out = append(out, "["+table+"]")
+		insert(table)
+	}
+
+	return writeFileAtomic(path, []byte(strings.Join(out, "\n")+"\n"))
+}
+
+// reload re-reads every configuration source and swaps the result in
+// atomically. Pending and completed auth states are kept, so flows that are
+// in progress survive the reload. The listen port cannot change at runtime.
+func (s *Server) reload() error {
+	next, err := s.loadConfig()
+	if err != nil {
+		return err
+	}
+
+	prev := s.current.Swap(next)
+	slog.SetLogLoggerLevel(next.LogLevel)
+	if next.Provider != prev.Provider {
+		log.Printf("Config reload: provider change to %s requires a restart, still using %s", next.Provider, prev.Provider)
+	}
+	if next.FixturesMode != prev.FixturesMode || next.FixturesFile != prev.FixturesFile {
+		log.Printf("Config reload: fixtures changes require a restart")
+	}
+	if next.LogFile != prev.LogFile || next.LogMaxSize != prev.LogMaxSize || next.LogMaxFiles != prev.LogMaxFiles {
+		log.Printf("Config reload: log file changes require a restart")
+	}
+	if next.Reminders.SnoozeFile != prev.Reminders.SnoozeFile {
+		log.Printf("Config reload: reminders.snooze_file change requires a restart")
+	}
+	if next.MetadataFile != prev.MetadataFile || next.ArchiveFile != prev.ArchiveFile || next.HistoryFile != prev.HistoryFile || next.ShareFile != prev.ShareFile {
+		log.Printf("Config reload: api.metadata_file, api.archive_file, api.history_file and api.share_file changes require a restart")
+	}
+	if next.Tenants.Enabled != prev.Tenants.Enabled || next.Tenants.Dir != prev.Tenants.Dir {
+		log.Printf("Config reload: tenants.enabled and tenants.dir changes require a restart")
+	}
+	if next.Upstream.Proxy != prev.Upstream.Proxy || next.Upstream.CAFile != prev.Upstream.CAFile || next.Upstream.SystemRoots != prev.Upstream.SystemRoots {
+		log.Printf("Config reload: upstream.proxy, upstream.ca_file and upstream.system_roots changes require a restart")
+	}
+	if !slices.Equal(next.Listen, prev.Listen) {
+		log.Printf("Config reload: server.listen change requires a restart")
+	}
+	if next.Port != prev.Port {
+		log.Printf("Config reload: port change to %s requires a restart, still listening on %s", next.Port, prev.Port)
+	}
+	if next.MaxPendingAuth != prev.MaxPendingAuth || next.CacheMaxEntries != prev.CacheMaxEntries || next.JobWorkers != prev.JobWorkers {
+		log.Printf("Config reload: auth.max_pending, cache.max_entries and jobs.workers changes require a restart")
+	}
+
+	log.Printf("Configuration reloaded")
+	return nil
+}
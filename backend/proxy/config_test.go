@@ -0,0 +1,176 @@
+package proxy
+
+import (
+	"flag"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTOML(t *testing.T) {
+	in := `
+# top-level keys come before any table
+port = 3_000
+
+[google]
+client_id = "id # not a comment" # but this is
+client_secret = "say \"hi\" # still inside"
+redirect_uri = 'C:\raw\path'#no space before the comment
+
+[ log ]
+level = "debug"
+tags = ["a, b", 'c', ]
+empty = []
+enabled = true
+`
+	got, err := parseTOML(strings.NewReader(in))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"port":                 "3000",
+		"google.client_id":     "id # not a comment",
+		"google.client_secret": `say "hi" # still inside`,
+		"google.redirect_uri":  `C:\raw\path`,
+		"log.level":            "debug",
+		"log.tags":             "a, b,c",
+		"log.empty":            "",
+		"log.enabled":          "true",
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("parseTOML =\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	tests := map[string]string{
+		"[google\nport = 1":         "line 1: malformed table header",
+		"port = 1\n\n# x\nport":     "line 4: expected key = value",
+		"port =   # nothing left":   "line 1: missing value",
+		`a = 'open`:                 "line 1: unterminated string",
+		`a = ["x", "y"`:             "line 1: unterminated array",
+		`a = ["x", y]`:              "line 1: unsupported value y",
+		"a = 1.5":                   "line 1: unsupported value 1.5",
+		"a = \"bad \\q escape\"":    "line 1:",
+		"[a]\nb = 2020-01-01T00:00": "line 2: unsupported value",
+	}
+	for in, want := range tests {
+		_, err := parseTOML(strings.NewReader(in))
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseTOML(%q) error = %v, want %q", in, err, want)
+		}
+	}
+}
+
+// isolateConfig points every config source at an empty temporary directory
+// so the test does not pick up the developer's own settings.
+func isolateConfig(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("CONFIG_FILE", "")
+	for _, s := range settings {
+		t.Setenv(s.env, "")
+	}
+	t.Setenv("GOOGLE_CREDENTIALS_FILE", filepath.Join(dir, "missing.json"))
+	return dir
+}
+
+// loadConfig resolves the configuration the way a subcommand does.
+func loadConfig(args []string) (*Config, error) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := addConfigFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	return flags.Load()
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadConfigPrecedence(t *testing.T) {
+	dir := isolateConfig(t)
+	writeFile(t, filepath.Join(dir, "gtask", "config.toml"), `
+[server]
+port = 4000
+
+[google]
+client_id = "file-id"
+client_secret = "file-secret"
+`)
+
+	cfg, err := loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "4000" || cfg.Google.ClientID != "file-id" {
+		t.Errorf("file: port %s, client_id %s", cfg.Port, cfg.Google.ClientID)
+	}
+	if cfg.Google.Scope != "https://www.googleapis.com/auth/tasks" {
+		t.Errorf("unset values keep their default, got scope %q", cfg.Google.Scope)
+	}
+
+	t.Setenv("PORT", "5000")
+	cfg, err = loadConfig(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "5000" {
+		t.Errorf("environment should override the file, got port %s", cfg.Port)
+	}
+
+	cfg, err = loadConfig([]string{"-port", "6000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Port != "6000" || cfg.Google.ClientSecret != "file-secret" {
+		t.Errorf("flags should override the environment, got port %s", cfg.Port)
+	}
+}
+
+func TestLoadConfigCredentialsFile(t *testing.T) {
+	dir := isolateConfig(t)
+	credentials := filepath.Join(dir, "credentials.json")
+	writeFile(t, credentials, `{"client_id": "json-id", "client_secret": "json-secret"}`)
+	writeFile(t, filepath.Join(dir, "gtask", "config.toml"), "[google]\nclient_secret = \"file-secret\"\n")
+
+	cfg, err := loadConfig([]string{"-credentials-file", credentials})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Google.ClientID != "json-id" || cfg.Google.ClientSecret != "file-secret" {
+		t.Errorf("the config file should layer over the credentials file, got %q, %q", cfg.Google.ClientID, cfg.Google.ClientSecret)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	dir := isolateConfig(t)
+	path := filepath.Join(dir, "gtask", "config.toml")
+
+	if _, err := loadConfig(nil); err == nil || !strings.Contains(err.Error(), "client_id and client_secret are required") {
+		t.Errorf("no credentials: %v", err)
+	}
+	if _, err := loadConfig([]string{"-config", filepath.Join(dir, "nope.toml")}); err == nil {
+		t.Error("an explicit config path that does not exist should fail")
+	}
+
+	writeFile(t, path, "[google]\nclient_id = \"id\"\nclient_secret = \"s\"\n[server]\nprot = 1\n")
+	if _, err := loadConfig(nil); err == nil || !strings.Contains(err.Error(), `unknown setting "server.prot"`) {
+		t.Errorf("misspelt key: %v", err)
+	}
+
+	writeFile(t, path, "[google]\nclient_id = \"id\"\nclient_secret = \"s\"\n[auth]\nstate_ttl = \"-1m\"\n")
+	if _, err := loadConfig(nil); err == nil || !strings.Contains(err.Error(), "auth.state_ttl") {
+		t.Errorf("bad duration should name its key: %v", err)
+	}
+}
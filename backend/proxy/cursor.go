@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Limits of cursor pagination.
+const (
+	defaultPageLimit = 100
+	maxPageLimit     = 1000
+)
+
+var errBadCursor = errors.New("cursor is invalid or belongs to a different query")
+
+// cursorScope ties cursors to the query they page through: the values of
+// names in query, so a cursor is not reused with other filters.
+func cursorScope(query url.Values, names ...string) string {
+	h := sha256.New()
+	for _, name := range names {
+		fmt.Fprintf(h, "%s=%q\x00", name, query[name])
+	}
+	return base64.RawURLEncoding.EncodeToString(h.Sum(nil)[:6])
+}
+
+// sortByKey sorts items by key, which pagination pages through in order.
+func sortByKey[T any](items []T, key func(T) string) {
+	keys := make([]string, len(items))
+	order := make([]int, len(items))
+	for i, item := range items {
+		order[i], keys[i] = i, key(item)
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return strings.Compare(keys[a], keys[b]) })
+	sorted := make([]T, len(items))
+	for i, j := range order {
+		sorted[i] = items[j]
+	}
+	copy(items, sorted)
+}
+
+// cursorPage returns up to limit of items, which are sorted by key, after the
+// one cursor was made for, and the cursor of the next page ("" on the last).
+// The cursor holds the last item's key rather than an offset, so items
+// added or removed before it do not shift later pages.
+func cursorPage[T any](items []T, key func(T) string, scope, cursor string, limit int) ([]T, string, error) {
+	start := 0
+	if cursor != "" {
+		data, err := base64.RawURLEncoding.DecodeString(cursor)
+		if err != nil {
+			return nil, "", errBadCursor
+		}
+		cursorScope, after, ok := strings.Cut(string(data), "\x00")
+		if !ok || cursorScope != scope {
+			return nil, "", errBadCursor
+		}
+		start = sort.Search(len(items), func(i int) bool { return key(items[i]) > after })
+	}
+	end := min(start+limit, len(items))
+	page := items[start:end]
+	if end == len(items) {
+		return page, "", nil
+	}
+	next := base64.RawURLEncoding.EncodeToString([]byte(scope + "\x00" + key(items[end-1])))
+	return page, next, nil
+}
+
+// pageLimit reads ?limit=, between 1 and max.
+func pageLimit(query url.Values, fallback, max int) (int, error) {
+	v := query.Get("limit")
+	if v == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 || n > max {
+		return 0, fmt.Errorf("limit must be between 1 and %d", max)
+	}
+	return n, nil
+}
+
+// taskOrderKey orders a list's tasks as Google shows them: by position,
+// each subtask right after its parent and the subtasks before the next
+// top-level task. Positions are zero-padded, so they compare as strings.
+func taskOrderKey(tasks []api.Task) func(api.Task) string {
+	positions := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		positions[t.ID] = t.Position
+	}
+	return func(t api.Task) string {
+		if t.Parent == "" {
+			return t.Position + "\x00" + t.ID
+		}
+		return positions[t.Parent] + "\x00" + t.Parent + "\x00" + t.Position + "\x00" + t.ID
+	}
+}
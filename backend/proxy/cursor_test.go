@@ -0,0 +1,167 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+func identity(s string) string { return s }
+
+// pages reads every page of items, limit at a time.
+func pages(t *testing.T, items []string, limit int) [][]string {
+	t.Helper()
+	var all [][]string
+	cursor := ""
+	for {
+		page, next, err := cursorPage(items, identity, "s", cursor, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		all = append(all, page)
+		if next == "" {
+			return all
+		}
+		if len(all) > len(items) {
+			t.Fatalf("no last page after %v", all)
+		}
+		cursor = next
+	}
+}
+
+func TestCursorPageWalksEveryItemOnce(t *testing.T) {
+	items := []string{"a", "b", "c", "d", "e"}
+	got := pages(t, items, 2)
+	if want := [][]string{{"a", "b"}, {"c", "d"}, {"e"}}; !slices.EqualFunc(got, want, slices.Equal) {
+		t.Errorf("pages = %v, want %v", got, want)
+	}
+
+	// A last page that is exactly full has no cursor after it
+	if got := pages(t, items[:4], 2); len(got) != 2 {
+		t.Errorf("pages = %v, want no empty page at the end", got)
+	}
+	// Nothing to page through is one empty page
+	if got := pages(t, nil, 2); len(got) != 1 || len(got[0]) != 0 {
+		t.Errorf("pages = %v", got)
+	}
+}
+
+func TestCursorPageSurvivesChanges(t *testing.T) {
+	first, cursor, err := cursorPage([]string{"b", "d", "f", "h"}, identity, "s", "", 2)
+	if err != nil || !slices.Equal(first, []string{"b", "d"}) {
+		t.Fatalf("first page = %v, %v", first, err)
+	}
+
+	// The last item seen was deleted and items were added on both sides of
+	// it: the next page starts right after where it was, without repeating
+	// or skipping anything that was already there
+	changed := []string{"a", "b", "c", "e", "f", "h"}
+	page, _, err := cursorPage(changed, identity, "s", cursor, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"e", "f"}; !slices.Equal(page, want) {
+		t.Errorf("next page = %v, want %v", page, want)
+	}
+
+	// Everything after the cursor was deleted
+	page, next, err := cursorPage([]string{"a", "b"}, identity, "s", cursor, 2)
+	if err != nil || len(page) != 0 || next != "" {
+		t.Errorf("past the end = %v, %q, %v", page, next, err)
+	}
+}
+
+func TestCursorPageRejectsForeignCursors(t *testing.T) {
+	items := []string{"a", "b", "c"}
+	_, cursor, _ := cursorPage(items, identity, "scope-1", "", 1)
+
+	for name, bad := range map[string]string{
+		"another query's": cursor,
+		"not base64":      "%%%",
+		"without a scope": base64.RawURLEncoding.EncodeToString([]byte("b")),
+	} {
+		if _, _, err := cursorPage(items, identity, "scope-2", bad, 1); !errors.Is(err, errBadCursor) {
+			t.Errorf("%s cursor: err = %v", name, err)
+		}
+	}
+
+	// A key may hold the separator itself
+	keyed := []string{"a\x00", "a\x00b", "a\x01"}
+	_, cursor, _ = cursorPage(keyed, identity, "s", "", 2)
+	if page, _, err := cursorPage(keyed, identity, "s", cursor, 2); err != nil || !slices.Equal(page, []string{"a\x01"}) {
+		t.Errorf("page after a key with a NUL = %q, %v", page, err)
+	}
+}
+
+func TestCursorScope(t *testing.T) {
+	scope := func(raw string) string {
+		query, _ := url.ParseQuery(raw)
+		return cursorScope(query, "q", "list")
+	}
+
+	if scope("q=milk&list=L1&limit=5&cursor=x") != scope("list=L1&q=milk") {
+		t.Error("limit and cursor should not change the scope")
+	}
+	for _, other := range []string{"q=milk&list=L2", "q=milk", "q=milk&list=L1&list=L2", "q=milk,list=L1"} {
+		if scope(other) == scope("q=milk&list=L1") {
+			t.Errorf("%q has the same scope as q=milk&list=L1", other)
+		}
+	}
+	// Repeated values are not joined into one
+	if scope("q=a&q=b") == scope("q=a,b") {
+		t.Error("different values should have different scopes")
+	}
+	if strings.ContainsAny(scope("q=milk"), "\x00+/=") {
+		t.Error("scope should be URL-safe and without the cursor separator")
+	}
+}
+
+func TestPageLimit(t *testing.T) {
+	query := func(limit string) url.Values { return url.Values{"limit": {limit}} }
+
+	if n, err := pageLimit(url.Values{}, 100, 1000); n != 100 || err != nil {
+		t.Errorf("no limit = %d, %v", n, err)
+	}
+	if n, err := pageLimit(query("1000"), 100, 1000); n != 1000 || err != nil {
+		t.Errorf("limit at the maximum = %d, %v", n, err)
+	}
+	for _, bad := range []string{"0", "-1", "1001", "ten", "5.5"} {
+		if _, err := pageLimit(query(bad), 100, 1000); err == nil || err.Error() != "limit must be between 1 and 1000" {
+			t.Errorf("limit=%s: err = %v", bad, err)
+		}
+	}
+}
+
+func TestTaskOrderKey(t *testing.T) {
+	tasks := []api.Task{
+		{ID: "sub2", Parent: "top1", Position: "00000000000000000001"},
+		{ID: "top2", Position: "00000000000000000001"},
+		{ID: "sub1", Parent: "top1", Position: "00000000000000000000"},
+		{ID: "top1", Position: "00000000000000000000"},
+		{ID: "orphan", Parent: "gone", Position: "00000000000000000000"},
+	}
+
+	sortByKey(tasks, taskOrderKey(tasks))
+
+	var got []string
+	for _, task := range tasks {
+		got = append(got, task.ID)
+	}
+	// Subtasks follow their parent; one whose parent is missing sorts first
+	if want := []string{"orphan", "top1", "sub1", "sub2", "top2"}; !slices.Equal(got, want) {
+		t.Errorf("order = %v, want %v", got, want)
+	}
+}
+
+func TestSortByKeyIsStable(t *testing.T) {
+	items := []string{"b2", "a1", "b1", "a2"}
+	sortByKey(items, func(s string) string { return s[:1] })
+	if want := []string{"a1", "a2", "b2", "b1"}; !slices.Equal(items, want) {
+		t.Errorf("sorted = %v, want %v", items, want)
+	}
+}
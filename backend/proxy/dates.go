@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseDate reads a human date relative to now: "today", "tomorrow", a
+// weekday name (its next occurrence, never today) or YYYY-MM-DD. The result
+// is midnight in now's location.
+func parseDate(s string, now time.Time) (time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	}
+
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		name := strings.ToLower(day.String())
+		if s == name || s == name[:3] {
+			ahead := (int(day) - int(today.Weekday()) + 6) % 7
+			return today.AddDate(0, 0, ahead+1), nil
+		}
+	}
+
+	date, err := time.ParseInLocation(time.DateOnly, s, now.Location())
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unrecognised date %q, use today, tomorrow, a weekday or YYYY-MM-DD", s)
+	}
+	return date, nil
+}
+
+// googleDue formats a date for a task's due field. Google Tasks stores only
+// the date part, as midnight UTC.
+func googleDue(date time.Time) string {
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC).Format(time.RFC3339)
+}
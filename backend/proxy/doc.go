@@ -0,0 +1,4 @@
+// Package proxy implements the gtask auth proxy: the OAuth and Google Tasks
+// HTTP server, and the command line built on the same code. Main runs the
+// command line; NewServer and Server.Handler embed the server elsewhere.
+package proxy
@@ -0,0 +1,394 @@
+package proxy
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+type checkStatus int
+
+const (
+	checkOK checkStatus = iota
+	checkWarn
+	checkFail
+	checkSkip
+)
+
+func (s checkStatus) String() string {
+	return [...]string{"ok", "warn", "FAIL", "skip"}[s]
+}
+
+// checkResult is one line of doctor output. Fix says what to do about a
+// warning or failure.
+type checkResult struct {
+	name   string
+	status checkStatus
+	detail string
+	fix    string
+}
+
+// doctor checks the setup the way support would: configuration, OAuth client,
+// redirect URI, token store and connectivity to Google.
+type doctor struct {
+	cfg    *Config
+	client *http.Client
+	token  *Token // stored CLI token, when there is one
+}
+
+var (
+	clientIDPattern     = regexp.MustCompile(`^[0-9]+-[a-z0-9]+\.apps\.googleusercontent\.com$`)
+	clientSecretPattern = regexp.MustCompile(`^GOCSPX-[A-Za-z0-9_-]{20,}$`)
+)
+
+// runDoctor prints one line per check and returns 1 if any failed.
+func runDoctor(args []string) int {
+	fs := flag.NewFlagSet("gtask-auth-proxy doctor", flag.ContinueOnError)
+	flags := addConfigFlags(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return usageError(err)
+	}
+
+	cfg, err := flags.Load()
+	if err != nil {
+		printCheck(configError(err))
+		return 1
+	}
+
+	upstream := NewUpstreamClient()
+	if err := upstream.UseProxy(cfg.Upstream.Proxy); err != nil {
+		printCheck(configError(err))
+		return 1
+	}
+	if err := upstream.UseCA(cfg.Upstream.CAFile, cfg.Upstream.SystemRoots); err != nil {
+		printCheck(configError(fmt.Errorf("upstream.ca_file: %w", err)))
+		return 1
+	}
+	d := &doctor{
+		cfg:    cfg,
+		client: &http.Client{Transport: upstream.client.Transport, Timeout: 5 * time.Second},
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	failed := false
+	for _, check := range []func(context.Context) checkResult{
+		d.checkConfig,
+		d.checkClientID,
+		d.checkClientSecret,
+		d.checkRedirectURI,
+		d.checkTokenStore,
+		d.checkGoogle,
+		d.checkClient,
+		d.checkToken,
+	} {
+		result := check(ctx)
+		printCheck(result)
+		failed = failed || result.status == checkFail
+	}
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+func printCheck(r checkResult) {
+	fmt.Printf("%-4s  %-16s %s\n", r.status, r.name, r.detail)
+	if r.fix != "" && (r.status == checkWarn || r.status == checkFail) {
+		fmt.Printf("      %-16s fix: %s\n", "", r.fix)
+	}
+}
+
+func configError(err error) checkResult {
+	result := checkResult{name: "config", status: checkFail, detail: err.Error()}
+	switch {
+	case errors.Is(err, ErrMissingCredentials):
+		result.fix = "set google.client_id and google.client_secret in " + defaultConfigPath() +
+			" (or $GOOGLE_CLIENT_ID / $GOOGLE_CLIENT_SECRET), run serve and use its setup page, or use -provider mock to try things out"
+	case errors.Is(err, os.ErrNotExist):
+		result.fix = "check the -config / $CONFIG_FILE path"
+	default:
+		result.fix = "compare the setting with config.example.toml; every key, variable and flag is listed in the README"
+	}
+	return result
+}
+
+func (d *doctor) checkConfig(context.Context) checkResult {
+	result := checkResult{name: "config", status: checkOK}
+	if _, err := os.Stat(d.cfg.Path); err == nil {
+		result.detail = "loaded " + d.cfg.Path
+	} else {
+		result.detail = "no config file at " + d.cfg.Path + ", using environment and flags"
+	}
+	if d.cfg.Provider != "google" {
+		result.detail += " (" + d.cfg.Provider + " provider)"
+	}
+	return result
+}
+
+func (d *doctor) checkClientID(context.Context) checkResult {
+	result := checkResult{name: "client id"}
+	switch {
+	case d.cfg.Provider != "google":
+		result.status, result.detail = checkSkip, d.cfg.Provider+" provider"
+	case clientIDPattern.MatchString(d.cfg.Google.ClientID):
+		result.status, result.detail = checkOK, "looks like a Google OAuth client ID"
+	default:
+		result.status = checkFail
+		result.detail = fmt.Sprintf("%q is not shaped like <number>-<id>.apps.googleusercontent.com", d.cfg.Google.ClientID)
+		result.fix = "copy the Client ID of a Web application client from Google Cloud Console > APIs & Services > Credentials"
+	}
+	return result
+}
+
+func (d *doctor) checkClientSecret(context.Context) checkResult {
+	result := checkResult{name: "client secret"}
+	switch {
+	case d.cfg.Provider != "google":
+		result.status, result.detail = checkSkip, d.cfg.Provider+" provider"
+	case clientSecretPattern.MatchString(d.cfg.Google.ClientSecret):
+		result.status, result.detail = checkOK, "looks like a Google OAuth client secret"
+	case strings.TrimSpace(d.cfg.Google.ClientSecret) != d.cfg.Google.ClientSecret:
+		result.status, result.detail = checkFail, "has leading or trailing whitespace"
+		result.fix = "remove the whitespace around the secret"
+	default:
+		// Older secrets do not have the GOCSPX- prefix
+		result.status, result.detail = checkWarn, "does not start with GOCSPX-, which current Google client secrets do"
+		result.fix = "if sign-in fails with invalid_client, create a new secret for the client in Google Cloud Console"
+	}
+	return result
+}
+
+// checkRedirectURI requests the callback without parameters, which a running
+// proxy answers with its error page.
+func (d *doctor) checkRedirectURI(ctx context.Context) checkResult {
+	result := checkResult{name: "redirect uri"}
+	if d.cfg.Provider == "github" {
+		result.status, result.detail = checkSkip, "the github provider's device flow has no redirect"
+		return result
+	}
+	redirect, err := url.Parse(d.cfg.Google.RedirectURI)
+	if err != nil || (redirect.Scheme != "http" && redirect.Scheme != "https") || redirect.Host == "" {
+		result.status, result.detail = checkFail, fmt.Sprintf("%q is not an http(s) URL", d.cfg.Google.RedirectURI)
+		result.fix = "set google.redirect_uri to this server's /auth/callback, e.g. http://localhost:" + d.cfg.Port + "/auth/callback"
+		return result
+	}
+	if !strings.HasSuffix(redirect.Path, "/auth/callback") {
+		result.status, result.detail = checkWarn, "does not end in /auth/callback, the proxy's callback route"
+		result.fix = "point it at /auth/callback unless a reverse proxy rewrites the path"
+		return result
+	}
+	if redirect.Scheme == "http" && !isLoopback(redirect.Hostname()) {
+		result.status, result.detail = checkFail, "Google only allows plain http redirects to localhost"
+		result.fix = "serve the proxy over https, or use a localhost redirect URI"
+		return result
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", redirect.String(), nil)
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	resp, err := d.client.Do(req)
+	var opErr *net.OpError
+	switch {
+	case err != nil && errors.As(err, &opErr) && isLoopback(redirect.Hostname()):
+		result.status, result.detail = checkWarn, "nothing is listening at "+redirect.Host
+		result.fix = "run `serve` before signing in from Neovim (`auth login` serves the callback itself)"
+	case err != nil:
+		result.status, result.detail = checkFail, "unreachable: "+err.Error()
+		result.fix = "check DNS and that the proxy is deployed at " + redirect.Host
+	default:
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			result.status, result.detail = checkOK, "reachable ("+redirect.String()+")"
+		} else {
+			result.status, result.detail = checkWarn, fmt.Sprintf("answered %s, expected the proxy's callback page", resp.Status)
+			result.fix = "make sure the URL reaches this proxy's /auth/callback"
+		}
+	}
+	return result
+}
+
+// checkTokenStore confirms the CLI can write its token file and that only
+// its owner can read it.
+func (d *doctor) checkTokenStore(context.Context) checkResult {
+	result := checkResult{name: "token store"}
+	path := d.cfg.TokensFile
+	dir := filepath.Dir(path)
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		result.fix = "set cli.tokens_file to a writable location"
+		return result
+	}
+	probe, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		result.status, result.detail = checkFail, dir+" is not writable: "+err.Error()
+		result.fix = "fix the directory's ownership or set cli.tokens_file elsewhere"
+		return result
+	}
+	probe.Close()
+	os.Remove(probe.Name())
+
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		result.status, result.detail = checkOK, path+" (not logged in yet)"
+		return result
+	}
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("%s is readable by others (mode %s)", path, info.Mode().Perm())
+		result.fix = "chmod 600 " + path
+		return result
+	}
+
+	token, err := NewTokenStore(path).Load(defaultAccount)
+	switch {
+	case errors.Is(err, ErrNotLoggedIn):
+		result.status, result.detail = checkOK, path+" (not logged in yet)"
+	case err != nil:
+		result.status, result.detail = checkFail, err.Error()
+		result.fix = "delete the file and run `auth login` again"
+	default:
+		d.token = &token
+		result.status, result.detail = checkOK, path
+	}
+	return result
+}
+
+// checkGoogle makes an unauthenticated Tasks API call; a 401 shows Google is
+// reachable.
+func (d *doctor) checkGoogle(ctx context.Context) checkResult {
+	result := checkResult{name: "google"}
+	if d.cfg.Provider != "google" {
+		result.status, result.detail = checkSkip, d.cfg.Provider+" provider"
+		return result
+	}
+
+	resp, err := d.get(ctx, googleTasksBaseURL+"/users/@me/lists", "")
+	if err != nil {
+		result.status, result.detail = checkFail, "unreachable: "+err.Error()
+		result.fix = unreachableFix(err)
+		return result
+	}
+	resp.Body.Close()
+	result.status, result.detail = checkOK, "tasks.googleapis.com reachable"
+	return result
+}
+
+// unreachableFix suggests what to do when Google could not be reached.
+func unreachableFix(err error) string {
+	if errors.As(err, new(x509.UnknownAuthorityError)) {
+		return "a proxy or firewall is intercepting TLS; set upstream.ca_file to its CA certificate"
+	}
+	return "check the network, DNS and any upstream.proxy or HTTPS_PROXY setting"
+}
+
+// checkClient exchanges a bogus refresh token: Google says invalid_grant
+// when it recognises the client and invalid_client when it does not.
+func (d *doctor) checkClient(ctx context.Context) checkResult {
+	result := checkResult{name: "oauth client"}
+	if d.cfg.Provider != "google" {
+		result.status, result.detail = checkSkip, d.cfg.Provider+" provider"
+		return result
+	}
+
+	data := url.Values{}
+	data.Set("client_id", d.cfg.Google.ClientID)
+	data.Set("client_secret", d.cfg.Google.ClientSecret)
+	data.Set("refresh_token", "gtask-doctor")
+	data.Set("grant_type", "refresh_token")
+	req, err := http.NewRequestWithContext(ctx, "POST", googleTokenURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := d.client.Do(req)
+	if err != nil {
+		result.status, result.detail = checkFail, "token endpoint unreachable: "+err.Error()
+		result.fix = unreachableFix(err)
+		return result
+	}
+	apiErr := upstreamResponseError(resp, "")
+	resp.Body.Close()
+
+	switch apiErr.Upstream.Error {
+	case "invalid_grant":
+		result.status, result.detail = checkOK, "Google accepts the client ID and secret"
+	case "invalid_client", "unauthorized_client":
+		result.status, result.detail = checkFail, "Google rejects the client: "+apiErr.Upstream.Description
+		result.fix = "check that the client ID and secret belong together and the client was not deleted"
+	default:
+		result.status = checkWarn
+		result.detail = fmt.Sprintf("unexpected token endpoint answer (%d %s)", apiErr.Upstream.Status, apiErr.Upstream.Error)
+	}
+	return result
+}
+
+// checkToken uses the stored CLI token, refreshing it if needed.
+func (d *doctor) checkToken(ctx context.Context) checkResult {
+	result := checkResult{name: "cli login"}
+	if d.token == nil {
+		result.status, result.detail = checkSkip, "not logged in"
+		return result
+	}
+	if d.cfg.Provider != "google" {
+		result.status, result.detail = checkSkip, d.cfg.Provider+" provider"
+		return result
+	}
+
+	server, err := NewServer(d.cfg, nil)
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	access, err := server.AccessToken(ctx, NewTokenStore(d.cfg.TokensFile), defaultAccount)
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		result.fix = "run `auth login` again"
+		return result
+	}
+	resp, err := d.get(ctx, googleTasksBaseURL+"/users/@me/lists?maxResults=1", access)
+	if err != nil {
+		result.status, result.detail = checkFail, err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		result.status, result.detail = checkFail, "Tasks API answered "+resp.Status
+		result.fix = "run `auth login` again; if it persists, enable the Tasks API for the Google Cloud project"
+		return result
+	}
+	result.status, result.detail = checkOK, "stored token can read task lists"
+	return result
+}
+
+func (d *doctor) get(ctx context.Context, target, accessToken string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	return d.client.Do(req)
+}
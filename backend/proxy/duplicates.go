@@ -0,0 +1,475 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Limits of duplicate detection and merging.
+const (
+	defaultDuplicateThreshold = 0.8
+	maxDuplicateTasks         = 10000 // tasks compared by one GET /api/duplicates
+	maxMergeDuplicates        = 50
+)
+
+// normalizeTitle is title as duplicates are compared: lower case, with
+// punctuation dropped and spaces collapsed.
+func normalizeTitle(title string) string {
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return strings.Join(words, " ")
+}
+
+// titleBigrams returns the distinct pairs of adjacent runes in a normalized
+// title, sorted.
+func titleBigrams(title string) []uint64 {
+	runes := []rune(title)
+	grams := make([]uint64, 0, len(runes))
+	for i := 1; i < len(runes); i++ {
+		grams = append(grams, uint64(runes[i-1])<<32|uint64(runes[i]))
+	}
+	slices.Sort(grams)
+	return slices.Compact(grams)
+}
+
+// diceSimilarity is the Sørensen-Dice coefficient of two sorted bigram
+// sets: twice the bigrams they share over how many they have.
+func diceSimilarity(a, b []uint64) float64 {
+	if len(a)+len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for i, j := 0, 0; i < len(a) && j < len(b); {
+		switch {
+		case a[i] == b[j]:
+			shared++
+			i++
+			j++
+		case a[i] < b[j]:
+			i++
+		default:
+			j++
+		}
+	}
+	return 2 * float64(shared) / float64(len(a)+len(b))
+}
+
+// keeperOrder sorts the tasks of a duplicate group, the one to keep first:
+// open tasks, then tasks with a due date, then those with more notes.
+func keeperOrder(a, b api.DuplicateTask) int {
+	return cmp.Or(
+		cmp.Compare(boolInt(a.Task.Status == "completed"), boolInt(b.Task.Status == "completed")),
+		cmp.Compare(boolInt(a.Task.Due == ""), boolInt(b.Task.Due == "")),
+		cmp.Compare(len(b.Task.Notes), len(a.Task.Notes)),
+		strings.Compare(a.List.ID, b.List.ID),
+		strings.Compare(a.Task.ID, b.Task.ID),
+	)
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// findDuplicates groups the tasks in lists whose normalized titles are at
+// least threshold alike. Pairs link transitively, so a group may hold
+// tasks less alike than threshold through one between them. Unless across,
+// only tasks of the same list are compared; completed tasks are left out
+// unless completed.
+func findDuplicates(lists []api.ListWithTasks, threshold float64, across, completed bool) []api.DuplicateGroup {
+	type candidate struct {
+		task  api.DuplicateTask
+		title string
+		grams []uint64
+	}
+	var candidates []candidate
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			if task.Deleted || task.Hidden || task.Status == "completed" && !completed {
+				continue
+			}
+			if title := normalizeTitle(task.Title); title != "" {
+				candidates = append(candidates, candidate{api.DuplicateTask{List: list.TaskList, Task: task}, title, titleBigrams(title)})
+			}
+		}
+	}
+	// By size, so each task is only compared with those its size allows
+	// to be alike enough
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return cmp.Compare(len(a.grams), len(b.grams)) })
+
+	parent := make([]int, len(candidates))
+	weakest := make([]float64, len(candidates))
+	for i := range parent {
+		parent[i], weakest[i] = i, 1
+	}
+	var root func(int) int
+	root = func(i int) int {
+		if parent[i] != i {
+			parent[i] = root(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i, a := range candidates {
+		for j := i + 1; j < len(candidates); j++ {
+			b := candidates[j]
+			// Dice is at most 2|a| / (|a| + |b|)
+			if float64(len(b.grams))*threshold > float64(len(a.grams))*(2-threshold) {
+				break
+			}
+			if !across && a.task.List.ID != b.task.List.ID {
+				continue
+			}
+			similarity := 1.0
+			if a.title != b.title {
+				similarity = diceSimilarity(a.grams, b.grams)
+			}
+			if similarity < threshold {
+				continue
+			}
+			if ra, rb := root(i), root(j); ra != rb {
+				parent[rb] = ra
+				weakest[ra] = min(weakest[ra], weakest[rb], similarity)
+			}
+		}
+	}
+
+	byRoot := make(map[int]*api.DuplicateGroup)
+	var roots []int
+	for i, c := range candidates {
+		r := root(i)
+		group, ok := byRoot[r]
+		if !ok {
+			group = &api.DuplicateGroup{Similarity: weakest[r]}
+			byRoot[r] = group
+			roots = append(roots, r)
+		}
+		group.Tasks = append(group.Tasks, c.task)
+	}
+	groups := []api.DuplicateGroup{}
+	for _, r := range roots {
+		group := byRoot[r]
+		if len(group.Tasks) < 2 {
+			continue
+		}
+		slices.SortFunc(group.Tasks, keeperOrder)
+		group.Title = normalizeTitle(group.Tasks[0].Task.Title)
+		group.Similarity = float64(int(group.Similarity*1000)) / 1000
+		groups = append(groups, *group)
+	}
+	slices.SortFunc(groups, func(a, b api.DuplicateGroup) int {
+		return cmp.Or(cmp.Compare(b.Similarity, a.Similarity), strings.Compare(a.Title, b.Title))
+	})
+	return groups
+}
+
+// queryBool reads a true/false query parameter, fallback when absent.
+func queryBool(query url.Values, name string, fallback bool) (bool, error) {
+	v := query.Get(name)
+	if v == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
+
+// GET /api/duplicates - Likely duplicate tasks, within and across lists
+//
+// Titles are compared normalized (case and punctuation ignored) by the
+// bigrams they share, and tasks at least ?threshold= alike (0.8 by default,
+// at most 1 for equal titles) are grouped. ?list= looks in one list only,
+// ?across=false compares tasks only with others of their list, and
+// ?completed=true includes completed tasks. Tasks are read through the
+// cache.
+func (s *Server) handleDuplicates(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	threshold := defaultDuplicateThreshold
+	if v := query.Get("threshold"); v != "" {
+		t, err := strconv.ParseFloat(v, 64)
+		if err != nil || t <= 0 || t > 1 {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "threshold must be a number above 0 and at most 1")
+			return
+		}
+		threshold = t
+	}
+	across, err := queryBool(query, "across", true)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	completed, err := queryBool(query, "completed", false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	if id := query.Get("list"); id != "" {
+		lists = slices.DeleteFunc(lists, func(list api.ListWithTasks) bool { return list.ID != id })
+		if len(lists) == 0 {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, "List not found")
+			return
+		}
+	}
+
+	resp := api.DuplicatesResponse{}
+	tasks := 0
+	for _, list := range lists {
+		if list.Error != nil {
+			resp.Failed = append(resp.Failed, list)
+		}
+		tasks += len(list.Tasks)
+	}
+	if tasks > maxDuplicateTasks {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest,
+			fmt.Sprintf("More than %d tasks to compare, pass ?list= to look in one list", maxDuplicateTasks))
+		return
+	}
+	resp.Groups = findDuplicates(lists, threshold, across, completed)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// mergeNotes appends the notes of each duplicate to notes, skipping those
+// notes already has.
+func mergeNotes(notes string, duplicates []api.Task) string {
+	for _, dup := range duplicates {
+		extra := strings.TrimSpace(dup.Notes)
+		switch {
+		case extra == "" || strings.Contains(notes, extra):
+		case strings.TrimSpace(notes) == "":
+			notes = extra
+		default:
+			notes = strings.TrimRight(notes, "\n") + "\n\n" + extra
+		}
+	}
+	return notes
+}
+
+// mergeWrites plans merging duplicates into keep, which is in the list
+// keepList of tasks: moving the duplicates' own subtasks under keep,
+// updating its notes and due date, then deleting the duplicates, subtasks
+// before their parents. It returns the writes and keep as it will be.
+// lists holds the tasks of every list a duplicate is in.
+func mergeWrites(keep api.Task, keepList string, duplicates []api.DuplicateTask, lists map[string][]api.Task) ([]api.TaskWrite, api.Task, error) {
+	isDuplicate := make(map[string]bool, len(duplicates))
+	for _, dup := range duplicates {
+		isDuplicate[dup.Task.ID] = true
+	}
+	if isDuplicate[keep.Parent] {
+		return nil, keep, errors.New("the kept task is a subtask of a duplicate, and deleting that would delete it too")
+	}
+
+	var writes []api.TaskWrite
+	previous := lastChild(lists[keepList], keep.ID)
+	for _, dup := range duplicates {
+		children := slices.DeleteFunc(slices.Clone(lists[dup.List.ID]), func(t api.Task) bool {
+			return t.Parent != dup.Task.ID || t.Deleted || isDuplicate[t.ID]
+		})
+		if len(children) == 0 {
+			continue
+		}
+		if dup.List.ID != keepList {
+			return nil, keep, fmt.Errorf("%q has subtasks in another list than the kept task, move them first", dup.Task.Title)
+		}
+		if keep.Parent != "" {
+			return nil, keep, fmt.Errorf("the kept task is a subtask, so it cannot take the subtasks of %q", dup.Task.Title)
+		}
+		slices.SortFunc(children, func(a, b api.Task) int { return strings.Compare(a.Position, b.Position) })
+		for _, child := range children {
+			writes = append(writes, MoveWrite(keepList, child.ID, keep.ID, previous))
+			previous = child.ID
+		}
+	}
+
+	merged := keep
+	tasks := make([]api.Task, len(duplicates))
+	for i, dup := range duplicates {
+		tasks[i] = dup.Task
+	}
+	merged.Notes = mergeNotes(keep.Notes, tasks)
+	if merged.Due == "" {
+		for _, t := range tasks {
+			if t.Due != "" && (merged.Due == "" || t.Due < merged.Due) {
+				merged.Due = t.Due
+			}
+		}
+	}
+	fields := map[string]any{}
+	if merged.Notes != keep.Notes {
+		fields["notes"] = merged.Notes
+	}
+	if merged.Due != keep.Due {
+		fields["due"] = merged.Due
+	}
+	if len(fields) > 0 {
+		writes = append(writes, PatchWrite(keepList, keep.ID, fields))
+	}
+
+	// Subtasks first: deleting a parent deletes them along with it
+	order := slices.Clone(duplicates)
+	slices.SortStableFunc(order, func(a, b api.DuplicateTask) int {
+		return cmp.Compare(boolInt(a.Task.Parent == ""), boolInt(b.Task.Parent == ""))
+	})
+	for _, dup := range order {
+		writes = append(writes, DeleteWrite(dup.List.ID, dup.Task.ID))
+	}
+	return writes, merged, nil
+}
+
+// lastChild returns the ID of parent's last subtask in tasks, or "".
+func lastChild(tasks []api.Task, parent string) string {
+	last, position := "", ""
+	for _, t := range tasks {
+		if t.Parent == parent && !t.Deleted && t.Position >= position {
+			last, position = t.ID, t.Position
+		}
+	}
+	return last
+}
+
+// POST /api/duplicates/merge - Merge duplicate tasks into one and delete them
+//
+// The kept task gets the notes of the duplicates it lacks and, if it has no
+// due date, the earliest of theirs; subtasks of duplicates in its list move
+// under it. The tasks are read fresh first. Deletes are only sent once the
+// rest succeeded, and the merge is undone as one step. ?dry_run=1 returns
+// the writes without sending them.
+func (s *Server) handleMergeDuplicates(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.MergeDuplicatesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if err := validateMerge(req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists := make(map[string][]api.Task)
+	for _, ref := range append([]api.TaskRef{req.Keep}, req.Duplicates...) {
+		if _, ok := lists[ref.List]; ok {
+			continue
+		}
+		tasks, err := s.tasks.ListTasks(withRevalidation(r.Context()), token, ref.List, watchQuery)
+		if err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		lists[ref.List] = tasks
+	}
+	find := func(ref api.TaskRef) (api.Task, bool) {
+		i := slices.IndexFunc(lists[ref.List], func(t api.Task) bool { return t.ID == ref.Task && !t.Deleted })
+		if i < 0 {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, fmt.Sprintf("Task %s not found in list %s", ref.Task, ref.List))
+			return api.Task{}, false
+		}
+		return lists[ref.List][i], true
+	}
+	keep, ok := find(req.Keep)
+	if !ok {
+		return
+	}
+	duplicates := make([]api.DuplicateTask, len(req.Duplicates))
+	for i, ref := range req.Duplicates {
+		if duplicates[i].Task, ok = find(ref); !ok {
+			return
+		}
+		duplicates[i].List.ID = ref.List
+	}
+
+	writes, merged, err := mergeWrites(keep, req.Keep.List, duplicates, lists)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	results := make([]api.ChangeResult, len(writes))
+	for i, write := range writes {
+		results[i].Request = write
+	}
+
+	resp := api.MergeDuplicatesResponse{DryRun: dryRun(r), Task: merged, Results: results}
+	if !resp.DryRun {
+		undo := make([][]undoStep, len(results))
+		failed := false
+		for i := range results {
+			if failed && results[i].Request.Method == http.MethodDelete {
+				results[i].Error = &api.APIError{Code: api.CodeInvalidState, Message: "Not sent, as merging into the kept task failed"}
+				continue
+			}
+			task, steps, err := s.writeUndoable(r, token, results[i].Request)
+			if err != nil {
+				results[i].Error = asAPIError(err, "Google Tasks request failed")
+				failed = failed || results[i].Request.Method != http.MethodDelete
+				continue
+			}
+			results[i].Task = task
+			undo[i] = steps
+			if task != nil && task.ID == keep.ID {
+				resp.Task = *task
+			}
+		}
+		if failed {
+			resp.Task = keep
+		}
+		s.recordUndo(r, token, "merge of duplicates into "+keep.Title, undo...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateMerge checks the tasks a merge names, before any is read.
+func validateMerge(req api.MergeDuplicatesRequest) error {
+	if req.Keep.List == "" || req.Keep.Task == "" {
+		return errors.New("keep needs a list and a task")
+	}
+	if len(req.Duplicates) == 0 || len(req.Duplicates) > maxMergeDuplicates {
+		return fmt.Errorf("between 1 and %d duplicates", maxMergeDuplicates)
+	}
+	seen := map[string]bool{req.Keep.Task: true}
+	for i, ref := range req.Duplicates {
+		if ref.List == "" || ref.Task == "" {
+			return fmt.Errorf("duplicates[%d] needs a list and a task", i)
+		}
+		if seen[ref.Task] {
+			return fmt.Errorf("duplicates[%d]: %s is the kept task or named twice", i, ref.Task)
+		}
+		seen[ref.Task] = true
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// statusClientClosedRequest is nginx's status for a client that went away
+// before its response; it is only ever seen in the access log.
+const statusClientClosedRequest = 499
+
+func writeAPIError(w http.ResponseWriter, e *api.APIError) {
+	if e.RetryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(e.RetryAfter))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(e.Status)
+	json.NewEncoder(w).Encode(api.ErrorResponse{Error: e})
+}
+
+// writeError responds with a non-retryable error, or a retryable one for 5xx.
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeAPIError(w, &api.APIError{
+		Status:    status,
+		Code:      code,
+		Message:   message,
+		Retryable: status >= 500,
+	})
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	writeError(w, http.StatusMethodNotAllowed, api.CodeMethodNotAllowed, "Method not allowed")
+}
+
+// writeUpstreamError responds to an upstream call that failed before Google
+// returned a response, distinguishing an open circuit from other failures.
+func writeUpstreamError(w http.ResponseWriter, err error, message string) {
+	writeAPIError(w, upstreamFailure(err, message))
+}
+
+func upstreamFailure(err error, message string) *api.APIError {
+	// The request's own deadline or the client hanging up, not Google
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return &api.APIError{
+			Status:    http.StatusGatewayTimeout,
+			Code:      api.CodeTimeout,
+			Message:   "The request's timeout ran out before Google answered",
+			Retryable: true,
+		}
+	case errors.Is(err, context.Canceled):
+		return &api.APIError{
+			Status:  statusClientClosedRequest,
+			Code:    api.CodeCancelled,
+			Message: "The request was cancelled",
+		}
+	}
+
+	var limited *RateLimitedError
+	if errors.As(err, &limited) {
+		return rateLimitedError(limited.RetryIn, nil)
+	}
+
+	var unavailable *UnavailableError
+	if errors.As(err, &unavailable) {
+		return &api.APIError{
+			Status:     http.StatusServiceUnavailable,
+			Code:       api.CodeUpstreamUnavailable,
+			Message:    "Google is unreachable, try again shortly",
+			Retryable:  true,
+			RetryAfter: int(math.Ceil(unavailable.RetryIn.Seconds())),
+		}
+	}
+
+	return &api.APIError{
+		Status:    http.StatusBadGateway,
+		Code:      api.CodeUpstreamError,
+		Message:   message,
+		Retryable: true,
+	}
+}
+
+// upstreamResponseError converts a non-2xx Google response into an APIError,
+// keeping Google's status for 4xx and reporting 5xx as a bad gateway.
+func upstreamResponseError(resp *http.Response, message string) *api.APIError {
+	detail := &api.UpstreamDetail{Status: resp.StatusCode}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	var oauthErr struct {
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if json.Unmarshal(body, &oauthErr) == nil && oauthErr.Error != "" {
+		detail.Error = oauthErr.Error
+		detail.Description = oauthErr.ErrorDescription
+	} else {
+		// Google APIs (as opposed to OAuth) nest the error object
+		var apiErr struct {
+			Error struct {
+				Status  string `json:"status"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		if json.Unmarshal(body, &apiErr) == nil {
+			detail.Error = apiErr.Error.Status
+			detail.Description = apiErr.Error.Message
+		}
+	}
+
+	if _, limited := rateLimitReason(resp.StatusCode, body); limited {
+		retryAfter, _ := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return rateLimitedError(retryAfter, detail)
+	}
+
+	status := resp.StatusCode
+	retryable := status >= 500 || status == http.StatusTooManyRequests
+	if status >= 500 {
+		status = http.StatusBadGateway
+	}
+
+	return &api.APIError{
+		Status:    status,
+		Code:      api.CodeUpstreamError,
+		Message:   message,
+		Retryable: retryable,
+		Upstream:  detail,
+	}
+}
+
+// rateLimitedError reports that Google's quota is exhausted for retryIn,
+// always as a 429 so clients need only look for one status.
+func rateLimitedError(retryIn time.Duration, detail *api.UpstreamDetail) *api.APIError {
+	seconds := retryAfterSeconds(retryIn)
+	return &api.APIError{
+		Status:     http.StatusTooManyRequests,
+		Code:       api.CodeRateLimited,
+		Message:    fmt.Sprintf("Google is rate limiting requests, retry in %ds", seconds),
+		Retryable:  true,
+		Upstream:   detail,
+		RetryAfter: seconds,
+	}
+}
+
+// asAPIError returns err itself when it is already an *APIError (an upstream
+// response error) and otherwise describes it as an upstream failure.
+func asAPIError(err error, message string) *api.APIError {
+	var apiErr *api.APIError
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return upstreamFailure(err, message)
+}
@@ -0,0 +1,156 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// sseHeartbeat keeps idle event streams from being closed by proxies.
+const sseHeartbeat = 30 * time.Second
+
+// sseEvent is one server-sent event, already encoded.
+type sseEvent struct {
+	name string
+	data []byte
+}
+
+// Subscriber is one open GET /api/events stream.
+type Subscriber struct {
+	token   string // the listener's Google access token
+	user    string // the listener's tenant, in multi-tenant mode
+	session string // sessionID of the listener's session, in multi-tenant mode
+	events  chan sseEvent
+	watch   watch
+}
+
+// EventHub fans server-sent events out to the open /api/events streams.
+type EventHub struct {
+	mutex       sync.Mutex
+	subscribers map[*Subscriber]bool
+	closed      bool
+}
+
+func NewEventHub() *EventHub {
+	return &EventHub{subscribers: make(map[*Subscriber]bool)}
+}
+
+// Subscribe registers a stream for token, of user's session when
+// multi-tenant. Its events channel is closed when the stream is dropped or
+// the hub closes.
+func (h *EventHub) Subscribe(token, user, session string) *Subscriber {
+	sub := &Subscriber{token: token, user: user, session: session, events: make(chan sseEvent, 32)}
+	sub.watch.sub = sub
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.closed {
+		close(sub.events)
+	} else {
+		h.subscribers[sub] = true
+	}
+	return sub
+}
+
+// Drop ends sub's stream.
+func (h *EventHub) Drop(sub *Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.subscribers[sub] {
+		delete(h.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Subscribers returns the open streams.
+func (h *EventHub) Subscribers() []*Subscriber {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	subs := make([]*Subscriber, 0, len(h.subscribers))
+	for sub := range h.subscribers {
+		subs = append(subs, sub)
+	}
+	return subs
+}
+
+// Send queues an event for sub. Events for a stream that is not keeping up
+// are dropped rather than blocking the sender.
+func (h *EventHub) Send(sub *Subscriber, name string, data any) {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("Encoding %s event: %v", name, err)
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !h.subscribers[sub] {
+		return
+	}
+	select {
+	case sub.events <- sseEvent{name, encoded}:
+	default:
+		log.Printf("Dropping %s event for a slow event stream", name)
+	}
+}
+
+// Close ends every stream, so server shutdown does not wait for them.
+func (h *EventHub) Close() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.closed = true
+	for sub := range h.subscribers {
+		close(sub.events)
+	}
+	clear(h.subscribers)
+}
+
+// GET /api/events - Server-sent events for the caller (reminders, changes)
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no")
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, "retry: 30000\n\n")
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	session := ""
+	if tenantFrom(r.Context()) != nil {
+		session = sessionID(sessionKey(r.Header.Get(api.SessionHeader)))
+	}
+	sub := s.events.Subscribe(token, tenantUser(r.Context()), session)
+	defer s.events.Drop(sub)
+	s.onSubscribe(sub)
+
+	heartbeat := time.NewTicker(sseHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-sub.events:
+			if !ok {
+				return
+			}
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.name, event.data)
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+// redacted replaces secrets in fixtures.
+const redacted = "REDACTED"
+
+// Secrets stripped from recorded form bodies, query strings and JSON
+// responses. Request headers (Authorization) are never recorded.
+var (
+	secretParams = []string{"client_id", "client_secret", "code", "code_verifier", "refresh_token", "access_token", "key"}
+	secretFields = []string{"access_token", "refresh_token", "id_token"}
+)
+
+// Cassette is a fixture file: the outbound interactions of a session, in
+// the order they happened.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+	Body   string `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"`
+}
+
+// recordedHeaders are the response headers clients act on.
+var recordedHeaders = []string{"Content-Type", "ETag", "Retry-After"}
+
+// FixtureTransport records upstream interactions to a cassette file or
+// replays them from one instead of using the network.
+type FixtureTransport struct {
+	mode string // record or replay
+	path string
+	next http.RoundTripper
+
+	mutex    sync.Mutex
+	cassette Cassette
+	replayed map[string]int // times each request key has been replayed
+}
+
+// NewFixtureTransport records through next, or replays from path. Recording
+// starts a new cassette, overwriting path.
+func NewFixtureTransport(mode, path string, next http.RoundTripper) (*FixtureTransport, error) {
+	t := &FixtureTransport{mode: mode, path: path, next: next, replayed: make(map[string]int)}
+	if mode == "record" {
+		return t, t.save()
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading fixtures: %w", err)
+	}
+	if err := json.Unmarshal(data, &t.cassette); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return t, nil
+}
+
+func (t *FixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	recorded := RecordedRequest{Method: req.Method, URL: sanitizeURL(req.URL)}
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		recorded.Body = sanitizeBody(req.Header.Get("Content-Type"), body)
+
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	if t.mode == "replay" {
+		return t.replay(req, recorded)
+	}
+	return t.record(req, recorded)
+}
+
+func (t *FixtureTransport) record(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxUpstreamBody))
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: recorded,
+		Response: RecordedResponse{
+			Status:  resp.StatusCode,
+			Headers: make(map[string]string),
+			Body:    sanitizeBody(resp.Header.Get("Content-Type"), body),
+		},
+	}
+	for _, name := range recordedHeaders {
+		if v := resp.Header.Get(name); v != "" {
+			interaction.Response.Headers[name] = v
+		}
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	if err := t.save(); err != nil {
+		return nil, fmt.Errorf("saving fixtures: %w", err)
+	}
+	return resp, nil
+}
+
+// replay answers with the recorded responses for the same request in the
+// order they were recorded, repeating the last once they run out.
+func (t *FixtureTransport) replay(req *http.Request, recorded RecordedRequest) (*http.Response, error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	key := recorded.Method + " " + recorded.URL + "\n" + recorded.Body
+	var matches []RecordedResponse
+	for _, interaction := range t.cassette.Interactions {
+		r := interaction.Request
+		if r.Method+" "+r.URL+"\n"+r.Body == key {
+			matches = append(matches, interaction.Response)
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("fixtures: no recorded response for %s %s", recorded.Method, recorded.URL)
+	}
+	i := min(t.replayed[key], len(matches)-1)
+	t.replayed[key]++
+	match := matches[i]
+
+	header := make(http.Header)
+	for name, v := range match.Headers {
+		header.Set(name, v)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", match.Status, http.StatusText(match.Status)),
+		StatusCode:    match.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(match.Body)),
+		ContentLength: int64(len(match.Body)),
+		Request:       req,
+	}, nil
+}
+
+// save writes the cassette. Callers hold the mutex (or own t).
+func (t *FixtureTransport) save() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(t.cassette); err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path, buf.Bytes())
+}
+
+func sanitizeURL(u *url.URL) string {
+	clean := *u
+	query := clean.Query()
+	for _, name := range secretParams {
+		if query.Has(name) {
+			query.Set(name, redacted)
+		}
+	}
+	clean.RawQuery = query.Encode()
+	return clean.String()
+}
+
+// sanitizeBody redacts secrets in form and JSON bodies. Anything else is
+// kept as is.
+func sanitizeBody(contentType string, body []byte) string {
+	switch {
+	case strings.HasPrefix(contentType, "application/x-www-form-urlencoded"):
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return string(body)
+		}
+		for _, name := range secretParams {
+			if form.Has(name) {
+				form.Set(name, redacted)
+			}
+		}
+		return form.Encode()
+	case strings.HasPrefix(contentType, "application/json"):
+		var object map[string]any
+		if json.Unmarshal(body, &object) != nil {
+			return string(body)
+		}
+		changed := false
+		for _, name := range secretFields {
+			if _, ok := object[name]; ok {
+				object[name] = redacted
+				changed = true
+			}
+		}
+		if !changed {
+			return string(body)
+		}
+		clean, err := json.Marshal(object)
+		if err != nil {
+			return string(body)
+		}
+		return string(clean)
+	default:
+		return string(body)
+	}
+}
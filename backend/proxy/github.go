@@ -0,0 +1,649 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	githubDeviceCodeURL = "https://github.com/login/device/code"
+	githubTokenURL      = "https://github.com/login/oauth/access_token"
+	githubAPIURL        = "https://api.github.com"
+	githubLoginTTL      = time.Hour
+)
+
+// githubNextPage finds the next page in a GitHub Link header.
+var githubNextPage = regexp.MustCompile(`<([^>]+)>;\s*rel="next"`)
+
+// GitHubTasks stands in for Google's token endpoint and Tasks API with the
+// GitHub issues assigned to the signed-in user, selected with provider.name
+// = "github". Every repository with open issues assigned to the user is a
+// task list, "owner:repo", and each issue assigned to them in it a task,
+// its number the ID. Closed issues are completed tasks, and an issue's
+// milestone due date is the task's. Issues are read-only but for their
+// state: completing a task closes its issue and reopening it reopens the
+// issue, while any other write is refused. Sign-in is GitHub's device flow
+// (see beginDeviceAuth), so there is no redirect and no code exchange.
+type GitHubTasks struct {
+	mux    *http.ServeMux
+	client *http.Client // to GitHub
+	config func() GitHubConfig
+	logins *LRU[string] // by SHA-256 of the Authorization header
+}
+
+// githubIssue is the part of a GitHub issue that makes a task.
+type githubIssue struct {
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	State     string `json:"state"`
+	HTMLURL   string `json:"html_url"`
+	UpdatedAt string `json:"updated_at"`
+	ClosedAt  string `json:"closed_at"`
+	Milestone *struct {
+		DueOn string `json:"due_on"`
+	} `json:"milestone"`
+	Repository *struct {
+		FullName string `json:"full_name"`
+	} `json:"repository"` // only in /issues
+	PullRequest any `json:"pull_request"` // set for pull requests, which the issue endpoints return too
+}
+
+// NewGitHubTasks creates the GitHub provider. base carries its own calls to
+// GitHub; config is read on each call so reloads take effect.
+func NewGitHubTasks(base http.RoundTripper, config func() GitHubConfig) *GitHubTasks {
+	g := &GitHubTasks{
+		mux:    http.NewServeMux(),
+		client: &http.Client{Transport: base, Timeout: 10 * time.Second},
+		config: config,
+		logins: NewLRU[string]("github_logins", 1000),
+	}
+
+	g.mux.HandleFunc("POST /token", g.handleToken)
+	g.mux.HandleFunc("POST /revoke", g.handleRevoke)
+	g.mux.HandleFunc("GET /tasks/v1/users/@me/lists", g.listLists)
+	g.mux.HandleFunc("GET /tasks/v1/users/@me/lists/@default", g.defaultList)
+	g.mux.HandleFunc("GET /tasks/v1/users/@me/lists/{list}", g.getList)
+	g.mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks", g.listTasks)
+	g.mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks/{task}", g.getTask)
+	g.mux.HandleFunc("PATCH /tasks/v1/lists/{list}/tasks/{task}", g.updateTask)
+	g.mux.HandleFunc("PUT /tasks/v1/lists/{list}/tasks/{task}", g.updateTask)
+	g.mux.HandleFunc("/tasks/v1/", g.readOnly)
+	return g
+}
+
+// ServeHTTP serves the token endpoint at /token, token revocation at
+// /revoke and the Tasks API under /tasks/v1, the paths Google uses.
+func (g *GitHubTasks) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mux.ServeHTTP(w, r)
+}
+
+// RoundTrip answers requests for Google's token endpoint and Tasks API from
+// GitHub, so the upstream client can use GitHubTasks as its transport.
+// Google's other APIs are refused, and anything else, GitHub included, is
+// sent on.
+func (g *GitHubTasks) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Host {
+	case "oauth2.googleapis.com", "tasks.googleapis.com":
+		return serveRoundTrip(g, req), nil
+	case "www.googleapis.com", "gmail.googleapis.com":
+		return nil, fmt.Errorf("github provider: Google APIs are not available (%s)", req.URL.Host)
+	}
+	return g.client.Transport.RoundTrip(req)
+}
+
+// handleToken refreshes a GitHub token. Only GitHub Apps with expiring
+// tokens hand out refresh tokens; OAuth app tokens last until revoked.
+func (g *GitHubTasks) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("grant_type") != "refresh_token" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "The GitHub provider signs in with the device flow")
+		return
+	}
+	cfg := g.config()
+	form := url.Values{}
+	form.Set("client_id", cfg.ClientID)
+	if cfg.ClientSecret != "" {
+		form.Set("client_secret", cfg.ClientSecret)
+	}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", r.PostFormValue("refresh_token"))
+
+	resp, err := postGitHubOAuth(r.Context(), g.client.Do, githubTokenURL, form)
+	if err != nil {
+		writeGoogleError(w, http.StatusBadGateway, "UNAVAILABLE", "GitHub unreachable: "+err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// handleRevoke revokes the grant of an access token, which GitHub only
+// lets the OAuth app do with its client secret.
+func (g *GitHubTasks) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	cfg := g.config()
+	if cfg.ClientSecret == "" {
+		writeOAuthError(w, http.StatusBadRequest, "unsupported", "Revoking GitHub tokens needs github.client_secret; revoke the app in GitHub's settings instead")
+		return
+	}
+	body, _ := json.Marshal(map[string]string{"access_token": r.PostFormValue("token")})
+	req, err := http.NewRequestWithContext(r.Context(), "DELETE", githubAPIURL+"/applications/"+url.PathEscape(cfg.ClientID)+"/grant", bytes.NewReader(body))
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", err.Error())
+		return
+	}
+	req.SetBasicAuth(cfg.ClientID, cfg.ClientSecret)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := g.client.Do(req)
+	if err != nil {
+		writeGoogleError(w, http.StatusBadGateway, "UNAVAILABLE", "GitHub unreachable: "+err.Error())
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_token", "GitHub answered "+resp.Status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// listLists makes a list of every repository with open issues assigned to
+// the user, by name.
+func (g *GitHubTasks) listLists(w http.ResponseWriter, r *http.Request) {
+	issues, ok := githubPages[githubIssue](g, w, r, githubAPIURL+"/issues?filter=assigned&state=open&per_page=100")
+	if !ok {
+		return
+	}
+	lists := map[string]*api.TaskList{}
+	for _, issue := range issues {
+		if issue.PullRequest != nil || issue.Repository == nil {
+			continue
+		}
+		id := githubListID(issue.Repository.FullName)
+		list, seen := lists[id]
+		if !seen {
+			list = &api.TaskList{ID: id, Title: issue.Repository.FullName}
+			lists[id] = list
+		}
+		list.Updated = max(list.Updated, issue.UpdatedAt)
+	}
+	items := make([]api.TaskList, 0, len(lists))
+	for _, list := range lists {
+		list.ETag = `"` + list.Updated + `"`
+		items = append(items, *list)
+	}
+	slices.SortFunc(items, func(a, b api.TaskList) int { return strings.Compare(a.Title, b.Title) })
+	writePage(w, r, "tasks#taskLists", items)
+}
+
+// defaultList answers for Google's default list. GitHub has no default
+// repository, so it stands for the signed-in user, which is what the proxy
+// asks it for (see Server.accountOf).
+func (g *GitHubTasks) defaultList(w http.ResponseWriter, r *http.Request) {
+	login, ok := g.login(w, r)
+	if !ok {
+		return
+	}
+	writeGoogleJSON(w, r, http.StatusOK, api.TaskList{ID: "@" + login, Title: login})
+}
+
+func (g *GitHubTasks) getList(w http.ResponseWriter, r *http.Request) {
+	repo, ok := githubRepo(w, r)
+	if !ok {
+		return
+	}
+	var info struct {
+		FullName  string `json:"full_name"`
+		UpdatedAt string `json:"updated_at"`
+	}
+	if _, ok := g.call(w, r, "GET", githubAPIURL+"/repos/"+repo, nil, &info); !ok {
+		return
+	}
+	writeGoogleJSON(w, r, http.StatusOK, api.TaskList{
+		ID:      githubListID(info.FullName),
+		Title:   info.FullName,
+		Updated: info.UpdatedAt,
+		ETag:    `"` + info.UpdatedAt + `"`,
+	})
+}
+
+// listTasks lists the repository's issues assigned to the user, oldest
+// first. Google's filters apply, but for showHidden and showDeleted: GitHub
+// has no hidden or deleted issues.
+func (g *GitHubTasks) listTasks(w http.ResponseWriter, r *http.Request) {
+	repo, ok := githubRepo(w, r)
+	if !ok {
+		return
+	}
+	login, ok := g.login(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	search := url.Values{"assignee": {login}, "state": {"all"}, "sort": {"created"}, "direction": {"asc"}, "per_page": {"100"}}
+	if query.Get("showCompleted") == "false" {
+		search.Set("state", "open")
+	}
+	if since := query.Get("updatedMin"); since != "" {
+		search.Set("since", since)
+	}
+	issues, ok := githubPages[githubIssue](g, w, r, githubAPIURL+"/repos/"+repo+"/issues?"+search.Encode())
+	if !ok {
+		return
+	}
+
+	items := []api.Task{}
+	for _, issue := range issues {
+		if issue.PullRequest != nil {
+			continue
+		}
+		task := githubTask(issue)
+		if withinBounds(task.Due, query.Get("dueMin"), query.Get("dueMax")) &&
+			withinBounds(task.Completed, query.Get("completedMin"), query.Get("completedMax")) {
+			items = append(items, task)
+		}
+	}
+	writePage(w, r, "tasks#tasks", items)
+}
+
+func (g *GitHubTasks) getTask(w http.ResponseWriter, r *http.Request) {
+	path, ok := githubIssuePath(w, r)
+	if !ok {
+		return
+	}
+	var issue githubIssue
+	if _, ok := g.call(w, r, "GET", path, nil, &issue); !ok {
+		return
+	}
+	if issue.PullRequest != nil {
+		writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task not found.")
+		return
+	}
+	writeGoogleJSON(w, r, http.StatusOK, githubTask(issue))
+}
+
+// updateTask closes or reopens the issue when the status changes. Other
+// fields may be sent as they are, as a PUT of the whole task does, but not
+// changed.
+func (g *GitHubTasks) updateTask(w http.ResponseWriter, r *http.Request) {
+	path, ok := githubIssuePath(w, r)
+	if !ok {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if err := json.NewDecoder(r.Body).Decode(&fields); err != nil {
+		writeGoogleError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload received.")
+		return
+	}
+	var in api.Task
+	data, _ := json.Marshal(fields)
+	json.Unmarshal(data, &in)
+
+	var issue githubIssue
+	if _, ok := g.call(w, r, "GET", path, nil, &issue); !ok {
+		return
+	}
+	if issue.PullRequest != nil {
+		writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task not found.")
+		return
+	}
+	task := githubTask(issue)
+	for name := range fields {
+		var changed bool
+		switch name {
+		case "title":
+			changed = in.Title != task.Title
+		case "notes":
+			changed = in.Notes != task.Notes
+		case "due":
+			changed = dueDate(in) != dueDate(task)
+		case "parent":
+			changed = in.Parent != ""
+		}
+		if changed {
+			writeGoogleError(w, http.StatusForbidden, "PERMISSION_DENIED",
+				"GitHub issues can only be completed or reopened here; change the "+name+" on GitHub")
+			return
+		}
+	}
+
+	if _, ok := fields["status"]; ok && in.Status != task.Status {
+		state := map[string]string{"state": "open", "state_reason": "reopened"}
+		if in.Status == "completed" {
+			state = map[string]string{"state": "closed", "state_reason": "completed"}
+		}
+		if _, ok := g.call(w, r, "PATCH", path, state, &issue); !ok {
+			return
+		}
+	}
+	writeGoogleJSON(w, r, http.StatusOK, githubTask(issue))
+}
+
+// readOnly refuses the Tasks API calls GitHub issues have no counterpart
+// for: creating, deleting and moving tasks, and changing lists.
+func (g *GitHubTasks) readOnly(w http.ResponseWriter, r *http.Request) {
+	writeGoogleError(w, http.StatusForbidden, "PERMISSION_DENIED",
+		"GitHub issues are read-only here, but for completing and reopening them")
+}
+
+// login returns the GitHub login of r's token, which the issue search
+// needs.
+func (g *GitHubTasks) login(w http.ResponseWriter, r *http.Request) (string, bool) {
+	hash := sha256.Sum256([]byte(r.Header.Get("Authorization")))
+	key := hex.EncodeToString(hash[:])
+	if login, ok := g.logins.Get(key); ok {
+		return login, true
+	}
+	var user struct {
+		Login string `json:"login"`
+	}
+	if _, ok := g.call(w, r, "GET", githubAPIURL+"/user", nil, &user); !ok {
+		return "", false
+	}
+	g.logins.Set(key, user.Login, githubLoginTTL)
+	return user.Login, true
+}
+
+// call sends a GitHub API request with r's token and decodes the response
+// into out, returning the URL of the next page if there is one. Failures
+// are written to w in Google's error format.
+func (g *GitHubTasks) call(w http.ResponseWriter, r *http.Request, method, rawURL string, body, out any) (next string, ok bool) {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			writeGoogleError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+			return "", false
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(r.Context(), method, rawURL, payload)
+	if err != nil {
+		writeGoogleError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+		return "", false
+	}
+	req.Header.Set("Authorization", r.Header.Get("Authorization"))
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		writeGoogleError(w, http.StatusBadGateway, "UNAVAILABLE", "GitHub unreachable: "+err.Error())
+		return "", false
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		writeGitHubError(w, resp)
+		return "", false
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCachedBody)).Decode(out); err != nil {
+		writeGoogleError(w, http.StatusBadGateway, "INTERNAL", "Failed to parse GitHub response")
+		return "", false
+	}
+	if match := githubNextPage.FindStringSubmatch(resp.Header.Get("Link")); match != nil {
+		next = match[1]
+	}
+	return next, true
+}
+
+// githubPages reads every page of a GitHub list, starting at rawURL.
+func githubPages[T any](g *GitHubTasks, w http.ResponseWriter, r *http.Request, rawURL string) ([]T, bool) {
+	var items []T
+	for rawURL != "" {
+		var page []T
+		next, ok := g.call(w, r, "GET", rawURL, nil, &page)
+		if !ok {
+			return nil, false
+		}
+		items = append(items, page...)
+		rawURL = next
+	}
+	return items, true
+}
+
+// writeGitHubError relays a GitHub error response in Google's format, so
+// an expired token still gets the plugin to refresh and a rate limit to
+// back off.
+func writeGitHubError(w http.ResponseWriter, resp *http.Response) {
+	var body struct {
+		Message string `json:"message"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&body)
+	message := "GitHub: " + cmp.Or(body.Message, resp.Status)
+
+	status, code := resp.StatusCode, "INTERNAL"
+	switch {
+	case status == http.StatusUnauthorized:
+		code = "UNAUTHENTICATED"
+	case status == http.StatusTooManyRequests, status == http.StatusForbidden && resp.Header.Get("X-RateLimit-Remaining") == "0":
+		status, code = http.StatusTooManyRequests, "RESOURCE_EXHAUSTED"
+		retryAfter := resp.Header.Get("Retry-After")
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil && retryAfter == "" {
+			retryAfter = strconv.FormatInt(max(reset-time.Now().Unix(), 1), 10)
+		}
+		if retryAfter != "" {
+			w.Header().Set("Retry-After", retryAfter)
+		}
+	case status == http.StatusForbidden:
+		code = "PERMISSION_DENIED"
+	case status == http.StatusNotFound:
+		code = "NOT_FOUND"
+	case status == http.StatusUnprocessableEntity:
+		status, code = http.StatusBadRequest, "INVALID_ARGUMENT"
+	case status >= 500:
+		code = "UNAVAILABLE"
+	}
+	writeGoogleError(w, status, code, message)
+}
+
+// writeOAuthError responds like Google's token endpoint does to a bad
+// request.
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": code, "error_description": description})
+}
+
+// postGitHubOAuth posts form to one of GitHub's OAuth endpoints. GitHub
+// answers errors there with 200 OK; they come back with status 400, as
+// Google's would.
+func postGitHubOAuth(ctx context.Context, do func(*http.Request) (*http.Response, error), rawURL string, form url.Values) (*tokenResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", rawURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	resp, err := do(req)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := readTokenResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	var body struct {
+		Error string `json:"error"`
+	}
+	if raw.status == http.StatusOK && json.Unmarshal(raw.body, &body) == nil && body.Error != "" {
+		raw.status = http.StatusBadRequest
+	}
+	return raw, nil
+}
+
+// githubTask turns an issue into a task.
+func githubTask(issue githubIssue) api.Task {
+	task := api.Task{
+		ID:          strconv.Itoa(issue.Number),
+		ETag:        `"` + issue.UpdatedAt + `"`,
+		Title:       issue.Title,
+		Notes:       issue.Body,
+		Status:      "needsAction",
+		Position:    fmt.Sprintf("%020d", issue.Number),
+		Updated:     issue.UpdatedAt,
+		WebViewLink: issue.HTMLURL,
+		Links:       []api.TaskLink{{Type: "github", Description: "#" + strconv.Itoa(issue.Number), Link: issue.HTMLURL}},
+	}
+	if issue.State == "closed" {
+		task.Status = "completed"
+		task.Completed = issue.ClosedAt
+	}
+	if issue.Milestone != nil {
+		if due, err := time.Parse(time.RFC3339, issue.Milestone.DueOn); err == nil {
+			task.Due = googleDue(due)
+		}
+	}
+	return task
+}
+
+// githubListID is the list ID of a repository: its full name with a colon,
+// which needs no escaping in a path, for the slash.
+func githubListID(fullName string) string {
+	return strings.Replace(fullName, "/", ":", 1)
+}
+
+// githubRepo returns the repository path ("owner/repo") of r's list,
+// writing a 404 if the ID cannot be one.
+func githubRepo(w http.ResponseWriter, r *http.Request) (string, bool) {
+	owner, repo, ok := strings.Cut(r.PathValue("list"), ":")
+	if !ok || owner == "" || repo == "" || strings.Contains(repo, ":") {
+		writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task list not found.")
+		return "", false
+	}
+	return url.PathEscape(owner) + "/" + url.PathEscape(repo), true
+}
+
+// githubIssuePath returns the API URL of r's task.
+func githubIssuePath(w http.ResponseWriter, r *http.Request) (string, bool) {
+	repo, ok := githubRepo(w, r)
+	if !ok {
+		return "", false
+	}
+	number, err := strconv.Atoi(r.PathValue("task"))
+	if err != nil || number <= 0 {
+		writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task not found.")
+		return "", false
+	}
+	return githubAPIURL + "/repos/" + repo + "/issues/" + strconv.Itoa(number), true
+}
+
+// withinBounds reports whether the RFC 3339 time value is within Google's
+// min and max filters, either of which may be empty. An empty value is
+// outside any bound.
+func withinBounds(value, minimum, maximum string) bool {
+	if minimum == "" && maximum == "" {
+		return true
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return false
+	}
+	if bound, err := time.Parse(time.RFC3339, minimum); err == nil && t.Before(bound) {
+		return false
+	}
+	if bound, err := time.Parse(time.RFC3339, maximum); err == nil && t.After(bound) {
+		return false
+	}
+	return true
+}
+
+// beginDeviceAuth starts GitHub's device flow for user ("" unless
+// multi-tenant). The response's AuthURL is GitHub's verification page,
+// where the user enters UserCode; meanwhile jobs poll GitHub for the token
+// and complete the state with it, for polling as with Google.
+func (s *Server) beginDeviceAuth(ctx context.Context, user string) (api.AuthStartResponse, error) {
+	state, err := generateState()
+	if err != nil {
+		return api.AuthStartResponse{}, fmt.Errorf("generating state: %w", err)
+	}
+
+	github := s.config().GitHub
+	form := url.Values{}
+	form.Set("client_id", github.ClientID)
+	form.Set("scope", github.Scope)
+	resp, err := postGitHubOAuth(ctx, s.upstream.Do, githubDeviceCodeURL, form)
+	if err != nil {
+		return api.AuthStartResponse{}, fmt.Errorf("requesting a device code: %w", err)
+	}
+	if resp.status != http.StatusOK {
+		return api.AuthStartResponse{}, upstreamResponseError(resp.httpResponse(), "GitHub refused the device code request")
+	}
+	var device struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURI string `json:"verification_uri"`
+		ExpiresIn       int    `json:"expires_in"`
+		Interval        int    `json:"interval"`
+	}
+	if err := json.Unmarshal(resp.body, &device); err != nil {
+		return api.AuthStartResponse{}, fmt.Errorf("decoding device code response: %w", err)
+	}
+
+	expires := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	s.pollDeviceAuth(user, state, device.DeviceCode, time.Duration(max(device.Interval, 1))*time.Second, expires)
+	return api.AuthStartResponse{AuthURL: device.VerificationURI, State: state, UserCode: device.UserCode}, nil
+}
+
+// pollDeviceAuth asks GitHub for the token of a device flow after interval,
+// as a job, and again until the user has entered the code or it expired.
+// Waiting between attempts holds no worker.
+func (s *Server) pollDeviceAuth(user, state, deviceCode string, interval time.Duration, expires time.Time) {
+	time.AfterFunc(interval, func() {
+		s.jobs.Submit(Job{
+			Name:     "github-device-flow",
+			Priority: PriorityHigh,
+			Run: func(ctx context.Context) error {
+				form := url.Values{}
+				form.Set("client_id", s.config().GitHub.ClientID)
+				form.Set("device_code", deviceCode)
+				form.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+				resp, err := postGitHubOAuth(ctx, s.upstream.Do, githubTokenURL, form)
+				switch {
+				case err != nil:
+					log.Printf("Polling GitHub for the token of state %s: %v", state, err)
+				case resp.status == http.StatusOK:
+					return s.completeAuth(ctx, state, user, resp)
+				default:
+					var body struct {
+						Error    string `json:"error"`
+						Interval int    `json:"interval"`
+					}
+					json.Unmarshal(resp.body, &body)
+					switch body.Error {
+					case "authorization_pending":
+					case "slow_down":
+						interval = max(time.Duration(body.Interval)*time.Second, interval+5*time.Second)
+					default:
+						// access_denied or expired_token
+						return fmt.Errorf("device flow for state %s: %s", state, body.Error)
+					}
+				}
+				if time.Now().Add(interval).After(expires) {
+					return fmt.Errorf("device flow for state %s: the code expired", state)
+				}
+				s.pollDeviceAuth(user, state, deviceCode, interval, expires)
+				return nil
+			},
+		})
+	})
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	gmailBaseURL = "https://gmail.googleapis.com/gmail/v1/users/me"
+	// gmailScope must be in google.scope for creating tasks from email
+	gmailScope = "https://www.googleapis.com/auth/gmail.readonly"
+)
+
+// gmailMessage is a message fetched with format=metadata.
+type gmailMessage struct {
+	ID       string `json:"id"`
+	ThreadID string `json:"threadId"`
+	Payload  struct {
+		Headers []struct {
+			Name  string `json:"name"`
+			Value string `json:"value"`
+		} `json:"headers"`
+	} `json:"payload"`
+}
+
+func (m gmailMessage) header(name string) string {
+	for _, h := range m.Payload.Headers {
+		if strings.EqualFold(h.Name, name) {
+			return h.Value
+		}
+	}
+	return ""
+}
+
+// email is m as reported to clients, with a link to its thread.
+func (m gmailMessage) email() api.EmailMessage {
+	return api.EmailMessage{
+		ID:      m.ID,
+		Thread:  m.ThreadID,
+		Subject: m.header("Subject"),
+		From:    m.header("From"),
+		Date:    m.header("Date"),
+		Link:    "https://mail.google.com/mail/u/0/#all/" + m.ThreadID,
+	}
+}
+
+// emailTask is the task for a message: titled by its subject, with the
+// sender and a link back to the thread in its notes.
+func emailTask(email api.EmailMessage) api.Task {
+	title := cmp.Or(strings.TrimSpace(email.Subject), "(no subject)")
+	notes := []string{"From: " + email.From, "Subject: " + title}
+	if email.Date != "" {
+		notes = append(notes, "Date: "+email.Date)
+	}
+	notes = append(notes, email.Link)
+	return api.Task{Title: title, Notes: strings.Join(notes, "\n"), Status: "needsAction"}
+}
+
+// findMessage fetches the message req names, or the newest one matching its
+// query. found is false when the query matches nothing.
+func (s *Server) findMessage(ctx context.Context, token string, req api.FromEmailRequest) (msg gmailMessage, found bool, err error) {
+	id := req.Message
+	if id == "" {
+		var matches struct {
+			Messages []struct {
+				ID string `json:"id"`
+			} `json:"messages"`
+		}
+		query := url.Values{"q": {req.Query}, "maxResults": {"1"}}
+		if err := s.callGoogle(ctx, token, "GET", gmailBaseURL+"/messages?"+query.Encode(), nil, &matches, "Gmail", gmailScope); err != nil {
+			return msg, false, err
+		}
+		if len(matches.Messages) == 0 {
+			return msg, false, nil
+		}
+		id = matches.Messages[0].ID
+	}
+
+	query := url.Values{"format": {"metadata"}, "metadataHeaders": {"Subject", "From", "Date"}}
+	err = s.callGoogle(ctx, token, "GET", gmailBaseURL+"/messages/"+url.PathEscape(id)+"?"+query.Encode(), nil, &msg, "Gmail", gmailScope)
+	return msg, err == nil, err
+}
+
+// POST /api/tasks/from-email - Create a task from a Gmail message
+//
+// The message is given by ID, or as a Gmail search whose newest match is
+// used. The task is titled by the subject, and its notes hold the sender,
+// subject, date and a link to the thread. Needs the gmail.readonly scope in
+// google.scope. ?dry_run=1 returns the task without creating it.
+func (s *Server) handleFromEmail(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.FromEmailRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	req.Message, req.Query = strings.TrimSpace(req.Message), strings.TrimSpace(req.Query)
+	if (req.Message == "") == (req.Query == "") {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Give either message or query")
+		return
+	}
+
+	msg, found, err := s.findMessage(r.Context(), token, req)
+	if err != nil {
+		writeAPIError(w, asAPIError(err, "Gmail request failed"))
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "No message matches "+req.Query)
+		return
+	}
+
+	lists, err := s.tasks.ListTaskLists(r.Context(), token)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	list, ok := listByID(lists, req.List)
+	if !ok {
+		writeListNotFound(w, req.List)
+		return
+	}
+
+	resp := api.FromEmailResponse{Email: msg.email(), List: list, DryRun: dryRun(r)}
+	task := emailTask(resp.Email)
+	resp.Request = InsertWrite(list.ID, task)
+
+	if !resp.DryRun {
+		task, steps, err := s.writeUndoable(r, token, resp.Request)
+		if err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		resp.Task = task
+		s.recordUndo(r, token, "task from email "+task.Title, steps)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,46 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// callGoogle sends a JSON request to one of Google's APIs other than Tasks,
+// which goes through TasksClient, and decodes the response into out. body
+// is sent when not nil. A 403 is reported as scope, the OAuth scope the API
+// needs, missing from google.scope.
+func (s *Server) callGoogle(ctx context.Context, token, method, rawURL string, body, out any, service, scope string) error {
+	var payload io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		payload = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, payload)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := s.upstream.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := upstreamResponseError(resp, service+" request failed")
+		if apiErr.Status == http.StatusForbidden {
+			apiErr.Message = service + " refused the request; add " + scope + " to google.scope and authorize again"
+		}
+		return apiErr
+	}
+	return json.NewDecoder(io.LimitReader(resp.Body, maxCachedBody)).Decode(out)
+}
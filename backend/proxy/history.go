@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// maxTaskHistory is how many changes are kept for each task; older ones
+// are forgotten.
+const maxTaskHistory = 100
+
+// HistoryStore keeps the changes made to each task, by task ID, oldest
+// first, in a JSON file readable only by its owner.
+type HistoryStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewHistoryStore(path string) *HistoryStore {
+	return &HistoryStore{path: path}
+}
+
+// Get returns the changes to account's task taskID, newest first.
+func (s *HistoryStore) Get(account, taskID string) ([]api.HistoryEntry, error) {
+	history, err := s.All(account)
+	if err != nil {
+		return nil, err
+	}
+	entries := history[taskID]
+	slices.Reverse(entries)
+	return entries, nil
+}
+
+// All returns the changes to each of account's tasks, oldest first.
+func (s *HistoryStore) All(account string) (map[string][]api.HistoryEntry, error) {
+	s.mutex.Lock()
+	history, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	for id, entries := range history {
+		history[id] = slices.DeleteFunc(entries, func(entry api.HistoryEntry) bool { return entry.Account != account })
+		if len(history[id]) == 0 {
+			delete(history, id)
+		}
+	}
+	return history, nil
+}
+
+// Created returns when each of account's tasks whose creation was recorded
+// was created.
+func (s *HistoryStore) Created(account string) (map[string]time.Time, error) {
+	history, err := s.All(account)
+	if err != nil {
+		return nil, err
+	}
+	created := make(map[string]time.Time)
+	for id, entries := range history {
+		for _, entry := range entries {
+			if entry.Change != api.HistoryCreated {
+				continue
+			}
+			if at, err := time.Parse(time.RFC3339, entry.At); err == nil {
+				created[id] = at
+			}
+			break
+		}
+	}
+	return created, nil
+}
+
+// Record adds changes to account's tasks by task ID. An observed change
+// already recorded (the latest change of its field is to the same value) is
+// skipped: polling sees the proxy's own writes too, and each event stream
+// polls on its own.
+func (s *HistoryStore) Record(account string, changes map[string][]api.HistoryEntry) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	history, err := s.read()
+	if err != nil {
+		return err
+	}
+	for id, entries := range changes {
+		for _, entry := range entries {
+			entry.Account = account
+			if entry.Source == api.HistoryObserved && alreadyRecorded(history[id], entry) {
+				continue
+			}
+			history[id] = append(history[id], entry)
+		}
+		if n := len(history[id]); n > maxTaskHistory {
+			history[id] = slices.Clone(history[id][n-maxTaskHistory:])
+		}
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// alreadyRecorded reports whether the latest of entries changing what
+// entry changes made the same change.
+func alreadyRecorded(entries []api.HistoryEntry, entry api.HistoryEntry) bool {
+	for _, prev := range slices.Backward(entries) {
+		if prev.Change == entry.Change && prev.Field == entry.Field {
+			return prev.To == entry.To
+		}
+	}
+	return false
+}
+
+func (s *HistoryStore) read() (map[string][]api.HistoryEntry, error) {
+	history := make(map[string][]api.HistoryEntry)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return history, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return history, nil
+}
+
+// taskChanges returns the field-level changes from before to after.
+func taskChanges(before, after api.Task) []api.HistoryEntry {
+	var entries []api.HistoryEntry
+	field := func(change, name, from, to string) {
+		if from != to {
+			entries = append(entries, api.HistoryEntry{Change: change, Field: name, From: from, To: to})
+		}
+	}
+	field(api.HistoryUpdated, "title", before.Title, after.Title)
+	field(api.HistoryUpdated, "notes", before.Notes, after.Notes)
+	field(api.HistoryUpdated, "due", dueDate(before), dueDate(after))
+	field(api.HistoryUpdated, "status", before.Status, after.Status)
+	field(api.HistoryMoved, "parent", before.Parent, after.Parent)
+	return entries
+}
+
+// writeChanges returns what the write w changed, given the task before it
+// (nil for an insert) and the task Google returned (nil for a delete).
+func writeChanges(w api.TaskWrite, before, after *api.Task) []api.HistoryEntry {
+	switch {
+	case before == nil && after != nil:
+		return []api.HistoryEntry{{Change: api.HistoryCreated, To: after.Title}}
+	case before != nil && w.Method == "DELETE":
+		return []api.HistoryEntry{{Change: api.HistoryDeleted, From: before.Title}}
+	case before != nil && after != nil:
+		entries := taskChanges(*before, *after)
+		if len(entries) == 0 && w.Body == nil {
+			// A move among the same siblings
+			entries = append(entries, api.HistoryEntry{Change: api.HistoryMoved, Field: "position"})
+		}
+		return entries
+	}
+	return nil
+}
+
+// recordHistory records changes the proxy made for r with token, by task
+// ID.
+func (s *Server) recordHistory(r *http.Request, token string, changes map[string][]api.HistoryEntry) {
+	account, err := s.accountOf(r.Context(), token)
+	if err != nil {
+		slog.Warn("recording task history failed", "error", err)
+		return
+	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	user := tenantUser(r.Context())
+	for _, entries := range changes {
+		for i := range entries {
+			entries[i].At, entries[i].Source = now, api.HistoryProxy
+			entries[i].Via, entries[i].Client, entries[i].User = r.Pattern, r.UserAgent(), user
+		}
+	}
+	if err := s.historyStore(user).Record(account, changes); err != nil {
+		slog.Warn("recording task history failed", "error", err)
+	}
+}
+
+// observedChanges turns the events of a poll into history entries by task
+// ID. List events are not about one task and are left out.
+func observedChanges(events []api.TaskEvent) map[string][]api.HistoryEntry {
+	now := time.Now().UTC().Format(time.RFC3339)
+	changes := make(map[string][]api.HistoryEntry)
+	for _, event := range events {
+		if event.Task == nil {
+			continue
+		}
+		entry := api.HistoryEntry{At: now, Source: api.HistoryObserved, From: event.From, To: event.To}
+		switch event.Type {
+		case api.EventTaskCreated:
+			entry.Change, entry.To = api.HistoryCreated, event.Task.Title
+		case api.EventTaskDeleted:
+			entry.Change, entry.From = api.HistoryDeleted, event.Task.Title
+		case api.EventTaskCompleted:
+			entry.Change, entry.Field, entry.From, entry.To = api.HistoryUpdated, "status", "needsAction", "completed"
+		case api.EventTaskReopened:
+			entry.Change, entry.Field, entry.From, entry.To = api.HistoryUpdated, "status", "completed", "needsAction"
+		case api.EventTaskTitleChanged:
+			entry.Change, entry.Field = api.HistoryUpdated, "title"
+		case api.EventTaskNotesChanged:
+			entry.Change, entry.Field = api.HistoryUpdated, "notes"
+		case api.EventTaskDueChanged:
+			entry.Change, entry.Field = api.HistoryUpdated, "due"
+		case api.EventTaskMoved:
+			entry.Change, entry.Field = api.HistoryMoved, "list"
+		case api.EventTaskReparented:
+			entry.Change, entry.Field = api.HistoryMoved, "parent"
+		default:
+			continue
+		}
+		changes[event.Task.ID] = append(changes[event.Task.ID], entry)
+	}
+	return changes
+}
+
+// recordObserved is the change listener that adds changes made by other
+// clients to the history of the user they were found for.
+func (s *Server) recordObserved(_ context.Context, w *watch, _ []api.ListWithTasks, events []api.TaskEvent) {
+	user := ""
+	if w.sub != nil {
+		user = w.sub.user
+	}
+	w.mutex.Lock()
+	account := w.account
+	w.mutex.Unlock()
+	if err := s.historyStore(user).Record(account, observedChanges(events)); err != nil {
+		slog.Warn("recording task history failed", "error", err)
+	}
+}
+
+// GET /api/tasks/{task}/history - A task's recorded changes, newest first
+//
+// Changes made through the proxy are recorded with the endpoint, client and
+// user that made them; with changes.enabled, changes made by other clients
+// are recorded when polling finds them. The task need not exist any more.
+func (s *Server) handleTaskHistory(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	entries, err := s.historyStore(tenantUser(r.Context())).Get(account, r.PathValue("task"))
+	if err != nil {
+		log.Printf("Reading task history: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task history")
+		return
+	}
+	resp := api.TaskHistoryResponse{Task: r.PathValue("task"), Entries: entries}
+	if resp.Entries == nil {
+		resp.Entries = []api.HistoryEntry{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	hookCommandTimeout = 30 * time.Second // one run of hooks.command
+	nvimHookTimeout    = 5 * time.Second  // connecting and writing to hooks.nvim
+	nvimHookPattern    = "GtaskHook"      // of the User autocommand
+)
+
+// overdueHooks remembers the tasks task.overdue ran for, by user, task and
+// due date, so it runs once when a task becomes overdue rather than at
+// every scan and for every stream watching the account.
+type overdueHooks struct {
+	mutex sync.Mutex
+	seen  map[string]string // key -> due date
+}
+
+// first reports whether r is the first overdue reminder of its task and due
+// date for user.
+func (o *overdueHooks) first(user string, r api.Reminder) bool {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.seen == nil {
+		o.seen = make(map[string]string)
+	}
+	key := user + "\x00" + r.Task.ID + "\x00" + r.Due
+	if _, ok := o.seen[key]; ok {
+		return false
+	}
+	// Forget tasks long overdue, which no scan reports as new again
+	if len(o.seen) >= 1000 {
+		old := time.Now().AddDate(0, 0, -60).Format(time.DateOnly)
+		maps.DeleteFunc(o.seen, func(_, due string) bool { return due < old })
+	}
+	o.seen[key] = r.Due
+	return true
+}
+
+// fireHook runs the hooks for event in the background, if hooks.events
+// has it.
+func (s *Server) fireHook(event, user string, data any) {
+	if !s.config().hooksOn(event) {
+		return
+	}
+	s.jobs.Submit(Job{
+		Name:     "hook-" + event,
+		Priority: PriorityLow,
+		Run: func(ctx context.Context) error {
+			return s.runHooks(ctx, event, user, data)
+		},
+	})
+}
+
+// runHooks runs hooks.command and notifies hooks.nvim of event, if
+// hooks.events has it.
+func (s *Server) runHooks(ctx context.Context, event, user string, data any) error {
+	cfg := s.config()
+	if !cfg.hooksOn(event) {
+		return nil
+	}
+	hook := api.HookEvent{Event: event, At: time.Now().UTC().Format(time.RFC3339), User: user, Data: data}
+	var errs []error
+	if cfg.Hooks.Command != "" {
+		if err := runHookCommand(ctx, cfg.Hooks.Command, hook); err != nil {
+			errs = append(errs, fmt.Errorf("hooks.command: %w", err))
+		}
+	}
+	if cfg.Hooks.Nvim != "" {
+		if err := notifyNvim(ctx, cfg.Hooks.Nvim, hook); err != nil {
+			errs = append(errs, fmt.Errorf("hooks.nvim: %w", err))
+		}
+	}
+	slog.Debug("Ran hooks", "event", event, "user", user, "failed", len(errs))
+	return errors.Join(errs...)
+}
+
+// runHookCommand runs command through the shell with the event as JSON on
+// stdin and its main fields in GTASK_* environment variables.
+func runHookCommand(ctx context.Context, command string, hook api.HookEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, hookCommandTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(hook)
+	if err != nil {
+		return err
+	}
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(), "GTASK_EVENT="+hook.Event, "GTASK_EVENT_AT="+hook.At, "GTASK_USER="+hook.User)
+	switch data := hook.Data.(type) {
+	case api.Reminder:
+		cmd.Env = append(cmd.Env,
+			"GTASK_TASK_ID="+data.Task.ID,
+			"GTASK_TASK_TITLE="+data.Task.Title,
+			"GTASK_TASK_DUE="+data.Due,
+			"GTASK_LIST_ID="+data.List.ID,
+			"GTASK_LIST_TITLE="+data.List.Title,
+		)
+	case api.SyncFinishedHook:
+		cmd.Env = append(cmd.Env, "GTASK_LIST_ID="+data.List)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// notifyNvim sends the event to the Neovim listening at address (its
+// v:servername: a unix socket path, or host:port) as a User GtaskHook
+// autocommand with the event as its data.
+func notifyNvim(ctx context.Context, address string, hook api.HookEvent) error {
+	network := "tcp"
+	if strings.ContainsAny(address, `/\`) {
+		network = "unix"
+	}
+	data, err := msgpackValue(hook)
+	if err != nil {
+		return err
+	}
+	msg, err := msgpackNotification("nvim_exec_autocmds", "User", map[string]any{"pattern": nvimHookPattern, "data": data})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, nvimHookTimeout)
+	defer cancel()
+	conn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetWriteDeadline(deadline)
+	_, err = conn.Write(msg)
+	return err
+}
@@ -0,0 +1,248 @@
+package proxy
+
+import (
+	"cmp"
+	"fmt"
+	"html/template"
+	"log"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// messages are the strings of the pages a user sees in the browser, by
+// language. English has every key; other languages fall back to it per key.
+var messages = map[string]map[string]string{
+	"en": {
+		"success.title":       "Authentication Successful!",
+		"success.body":        "Authorization completed! Please return to your terminal/editor.",
+		"close":               "You can safely close this window.",
+		"error.title":         "Authentication Error",
+		"error.missing":       "Missing authorization code or state.",
+		"error.access_denied": "Access was not granted. Start the sign-in again from your editor and allow access to Google Tasks.",
+		"error.admin_policy":  "Your Google Workspace administrator does not allow this app. Ask them to allow it, or sign in with another account.",
+		"error.client":        "Google rejected this proxy's OAuth client. The proxy's administrator can run \"gtask-auth-proxy doctor\" to find the problem.",
+		"error.unavailable":   "Google is temporarily unavailable. Please try again in a few minutes.",
+		"error.other":         "Google reported an error: %s",
+		"share.snapshot":      "Snapshot taken %s; later changes are not shown.",
+		"share.expires":       "This link expires %s.",
+		"share.due":           "due %s",
+		"share.empty":         "This list has no tasks.",
+		"share.missing.title": "Link not found",
+		"share.missing.body":  "This link was revoked, has expired or never existed. Ask whoever sent it for a new one.",
+		"share.error.title":   "Something went wrong",
+		"share.error.body":    "The shared list could not be read. Please try again later.",
+	},
+	"de": {
+		"success.title":       "Anmeldung erfolgreich!",
+		"success.body":        "Die Autorisierung ist abgeschlossen. Bitte kehre zu deinem Terminal/Editor zurück.",
+		"close":               "Du kannst dieses Fenster jetzt schließen.",
+		"error.title":         "Anmeldefehler",
+		"error.missing":       "Autorisierungscode oder State fehlt.",
+		"error.access_denied": "Der Zugriff wurde nicht erlaubt. Starte die Anmeldung im Editor erneut und erlaube den Zugriff auf Google Tasks.",
+		"error.admin_policy":  "Dein Google-Workspace-Administrator lässt diese App nicht zu. Bitte ihn, sie freizugeben, oder melde dich mit einem anderen Konto an.",
+		"error.client":        "Google hat den OAuth-Client dieses Proxys abgelehnt. Der Administrator des Proxys kann mit \"gtask-auth-proxy doctor\" die Ursache finden.",
+		"error.unavailable":   "Google ist vorübergehend nicht erreichbar. Bitte versuche es in ein paar Minuten erneut.",
+		"error.other":         "Google hat einen Fehler gemeldet: %s",
+		"share.snapshot":      "Stand vom %s; spätere Änderungen werden nicht angezeigt.",
+		"share.expires":       "Dieser Link läuft am %s ab.",
+		"share.due":           "fällig %s",
+		"share.empty":         "Diese Liste hat keine Aufgaben.",
+		"share.missing.title": "Link nicht gefunden",
+		"share.missing.body":  "Dieser Link wurde widerrufen, ist abgelaufen oder hat nie existiert. Bitte die Person, die ihn geschickt hat, um einen neuen.",
+		"share.error.title":   "Etwas ist schiefgelaufen",
+		"share.error.body":    "Die geteilte Liste konnte nicht gelesen werden. Bitte versuche es später erneut.",
+	},
+	"es": {
+		"success.title":       "¡Autenticación correcta!",
+		"success.body":        "Autorización completada. Vuelve a tu terminal/editor.",
+		"close":               "Ya puedes cerrar esta ventana.",
+		"error.title":         "Error de autenticación",
+		"error.missing":       "Falta el código de autorización o el estado.",
+		"error.access_denied": "No se concedió el acceso. Vuelve a iniciar sesión desde tu editor y permite el acceso a Google Tasks.",
+		"error.admin_policy":  "El administrador de tu Google Workspace no permite esta aplicación. Pídele que la permita o inicia sesión con otra cuenta.",
+		"error.client":        "Google rechazó el cliente OAuth de este proxy. Su administrador puede ejecutar \"gtask-auth-proxy doctor\" para encontrar el problema.",
+		"error.unavailable":   "Google no está disponible temporalmente. Vuelve a intentarlo en unos minutos.",
+		"error.other":         "Google informó de un error: %s",
+		"share.snapshot":      "Copia tomada el %s; los cambios posteriores no se muestran.",
+		"share.expires":       "Este enlace caduca el %s.",
+		"share.due":           "vence %s",
+		"share.empty":         "Esta lista no tiene tareas.",
+		"share.missing.title": "Enlace no encontrado",
+		"share.missing.body":  "Este enlace se revocó, caducó o nunca existió. Pide uno nuevo a quien te lo envió.",
+		"share.error.title":   "Algo salió mal",
+		"share.error.body":    "No se pudo leer la lista compartida. Vuelve a intentarlo más tarde.",
+	},
+	"fr": {
+		"success.title":       "Authentification réussie !",
+		"success.body":        "Autorisation terminée ! Vous pouvez revenir à votre terminal/éditeur.",
+		"close":               "Vous pouvez fermer cette fenêtre.",
+		"error.title":         "Erreur d'authentification",
+		"error.missing":       "Code d'autorisation ou état manquant.",
+		"error.access_denied": "L'accès n'a pas été accordé. Relancez la connexion depuis votre éditeur et autorisez l'accès à Google Tasks.",
+		"error.admin_policy":  "L'administrateur de votre Google Workspace n'autorise pas cette application. Demandez-lui de l'autoriser ou connectez-vous avec un autre compte.",
+		"error.client":        "Google a refusé le client OAuth de ce proxy. Son administrateur peut lancer \"gtask-auth-proxy doctor\" pour trouver le problème.",
+		"error.unavailable":   "Google est momentanément indisponible. Réessayez dans quelques minutes.",
+		"error.other":         "Google a signalé une erreur : %s",
+		"share.snapshot":      "Instantané du %s ; les modifications ultérieures ne sont pas affichées.",
+		"share.expires":       "Ce lien expire le %s.",
+		"share.due":           "échéance %s",
+		"share.empty":         "Cette liste n'a aucune tâche.",
+		"share.missing.title": "Lien introuvable",
+		"share.missing.body":  "Ce lien a été révoqué, a expiré ou n'a jamais existé. Demandez-en un nouveau à la personne qui vous l'a envoyé.",
+		"share.error.title":   "Une erreur est survenue",
+		"share.error.body":    "La liste partagée n'a pas pu être lue. Réessayez plus tard.",
+	},
+	"pt": {
+		"success.title":       "Autenticação concluída!",
+		"success.body":        "Autorização concluída! Volte ao seu terminal/editor.",
+		"close":               "Você já pode fechar esta janela.",
+		"error.title":         "Erro de autenticação",
+		"error.missing":       "Código de autorização ou estado ausente.",
+		"error.access_denied": "O acesso não foi concedido. Inicie o login novamente pelo editor e permita o acesso ao Google Tasks.",
+		"error.admin_policy":  "O administrador do seu Google Workspace não permite este aplicativo. Peça a ele para liberá-lo ou entre com outra conta.",
+		"error.client":        "O Google recusou o cliente OAuth deste proxy. O administrador pode executar \"gtask-auth-proxy doctor\" para encontrar o problema.",
+		"error.unavailable":   "O Google está temporariamente indisponível. Tente novamente em alguns minutos.",
+		"error.other":         "O Google informou um erro: %s",
+		"share.snapshot":      "Retrato de %s; alterações posteriores não são mostradas.",
+		"share.expires":       "Este link expira em %s.",
+		"share.due":           "vence %s",
+		"share.empty":         "Esta lista não tem tarefas.",
+		"share.missing.title": "Link não encontrado",
+		"share.missing.body":  "Este link foi revogado, expirou ou nunca existiu. Peça um novo a quem o enviou.",
+		"share.error.title":   "Algo deu errado",
+		"share.error.body":    "Não foi possível ler a lista compartilhada. Tente novamente mais tarde.",
+	},
+	"ja": {
+		"success.title":       "認証に成功しました",
+		"success.body":        "認可が完了しました。ターミナル/エディタに戻ってください。",
+		"close":               "このウィンドウは閉じてかまいません。",
+		"error.title":         "認証エラー",
+		"error.missing":       "認可コードまたは state がありません。",
+		"error.access_denied": "アクセスが許可されませんでした。エディタからもう一度サインインし、Google Tasks へのアクセスを許可してください。",
+		"error.admin_policy":  "Google Workspace の管理者がこのアプリを許可していません。管理者に許可を依頼するか、別のアカウントでサインインしてください。",
+		"error.client":        "Google がこのプロキシの OAuth クライアントを拒否しました。プロキシの管理者は \"gtask-auth-proxy doctor\" で原因を調べられます。",
+		"error.unavailable":   "Google が一時的に利用できません。数分後にもう一度お試しください。",
+		"error.other":         "Google からエラーが返されました: %s",
+		"share.snapshot":      "%s 時点のスナップショットです。その後の変更は表示されません。",
+		"share.expires":       "このリンクの有効期限は %s です。",
+		"share.due":           "期限 %s",
+		"share.empty":         "このリストにはタスクがありません。",
+		"share.missing.title": "リンクが見つかりません",
+		"share.missing.body":  "このリンクは取り消されたか、期限切れか、存在しません。送った人に新しいリンクを頼んでください。",
+		"share.error.title":   "問題が発生しました",
+		"share.error.body":    "共有されたリストを読み込めませんでした。しばらくしてからもう一度お試しください。",
+	},
+	"zh": {
+		"success.title":       "认证成功！",
+		"success.body":        "授权已完成，请返回终端/编辑器。",
+		"close":               "现在可以关闭此窗口。",
+		"error.title":         "认证错误",
+		"error.missing":       "缺少授权码或 state。",
+		"error.access_denied": "未授予访问权限。请从编辑器重新登录，并允许访问 Google Tasks。",
+		"error.admin_policy":  "你的 Google Workspace 管理员不允许此应用。请联系管理员允许它，或使用其他账号登录。",
+		"error.client":        "Google 拒绝了此代理的 OAuth 客户端。代理管理员可以运行 \"gtask-auth-proxy doctor\" 查找问题。",
+		"error.unavailable":   "Google 暂时不可用，请几分钟后重试。",
+		"error.other":         "Google 返回了错误：%s",
+		"share.snapshot":      "快照时间：%s；之后的更改不会显示。",
+		"share.expires":       "此链接将于 %s 过期。",
+		"share.due":           "截止 %s",
+		"share.empty":         "此列表没有任务。",
+		"share.missing.title": "未找到链接",
+		"share.missing.body":  "此链接已被撤销、已过期或从未存在。请向发送者索取新链接。",
+		"share.error.title":   "出现问题",
+		"share.error.body":    "无法读取共享的列表，请稍后重试。",
+	},
+}
+
+// languages are the supported language tags, for validating ui.language.
+var languages = slices.Sorted(maps.Keys(messages))
+
+// oauthErrors maps the error parameter of Google's redirect to a message key.
+var oauthErrors = map[string]string{
+	"access_denied":           "error.access_denied",
+	"admin_policy_enforced":   "error.admin_policy",
+	"org_internal":            "error.admin_policy",
+	"invalid_client":          "error.client",
+	"unauthorized_client":     "error.client",
+	"invalid_request":         "error.client",
+	"invalid_scope":           "error.client",
+	"redirect_uri_mismatch":   "error.client",
+	"server_error":            "error.unavailable",
+	"temporarily_unavailable": "error.unavailable",
+}
+
+// translate returns the message for key in lang, formatted with args.
+func translate(lang, key string, args ...any) string {
+	text, ok := messages[lang][key]
+	if !ok {
+		text = messages["en"][key]
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(text, args...)
+	}
+	return text
+}
+
+// pageLanguage picks the language of a page for r: the most preferred
+// supported language in Accept-Language, or else ui.language. Region
+// subtags are ignored, so de-AT gets German.
+func (s *Server) pageLanguage(r *http.Request) string {
+	type preference struct {
+		lang    string
+		quality float64
+	}
+	var prefs []preference
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		if _, ok := messages[primary]; ok && quality > 0 {
+			prefs = append(prefs, preference{primary, quality})
+		}
+	}
+	slices.SortStableFunc(prefs, func(a, b preference) int {
+		return cmp.Compare(b.quality, a.quality)
+	})
+	if len(prefs) > 0 {
+		return prefs[0].lang
+	}
+	return s.config().Language
+}
+
+var messagePage = template.Must(template.New("message").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}"><head><meta charset="utf-8"><title>{{.Title}}</title></head><body>
+	<h1>{{.Title}}</h1>
+	{{range .Paragraphs}}<p>{{.}}</p>
+	{{end}}
+	{{- if .Close}}<script>
+		setTimeout(function() { window.close(); }, 2000);
+	</script>{{end}}
+</body></html>
+`))
+
+// writeMessagePage responds with a page of a title and paragraphs, already
+// translated into lang. closeWindow tries to close the tab after a moment
+// (which works if it was opened by script).
+func writeMessagePage(w http.ResponseWriter, lang string, status int, closeWindow bool, title string, paragraphs ...string) {
+	page := struct {
+		Lang, Title string
+		Paragraphs  []string
+		Close       bool
+	}{lang, title, paragraphs, closeWindow}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Vary", "Accept-Language")
+	w.WriteHeader(status)
+	if err := messagePage.Execute(w, page); err != nil {
+		log.Printf("Rendering page: %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package proxy
+
+import (
+	"container/heap"
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type Priority int
+
+const (
+	PriorityLow Priority = iota
+	PriorityNormal
+	PriorityHigh
+)
+
+// RetryPolicy retries a failed job up to MaxAttempts times in total, waiting
+// Backoff, then twice that, and so on between attempts.
+type RetryPolicy struct {
+	MaxAttempts int
+	Backoff     time.Duration
+}
+
+// Job is a unit of background work.
+type Job struct {
+	Name     string
+	Priority Priority
+	Retry    RetryPolicy
+	Run      func(ctx context.Context) error
+
+	attempt int
+	seq     uint64
+}
+
+var (
+	jobsQueued    = expvarInt("jobs_queued")
+	jobsCompleted = expvarInt("jobs_completed")
+	jobsFailed    = expvarInt("jobs_failed")
+	jobsRetried   = expvarInt("jobs_retried")
+)
+
+// Scheduler runs jobs on a bounded pool of workers, highest priority first
+// (FIFO within a priority). Periodic jobs are submitted by Every.
+type Scheduler struct {
+	workers int
+
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	queue   jobQueue
+	seq     uint64
+	stopped bool // draining the queue
+	closed  bool // drained; jobs are dropped
+	exited  int  // workers that have exited
+	ctx     context.Context
+
+	// drain is what queued jobs run with once stopped; Wait cancels it
+	drain       context.Context
+	cancelDrain context.CancelFunc
+
+	running sync.WaitGroup
+}
+
+func NewScheduler(workers int) *Scheduler {
+	s := &Scheduler{workers: workers}
+	s.cond = sync.NewCond(&s.mutex)
+	return s
+}
+
+// Start launches the workers. Once ctx is done, running jobs see their
+// context cancelled and the workers drain the queue, running what is left
+// (and what those jobs submit) until it is empty or Wait gives up.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mutex.Lock()
+	s.ctx = ctx
+	s.drain, s.cancelDrain = context.WithCancel(context.WithoutCancel(ctx))
+	s.mutex.Unlock()
+
+	for range s.workers {
+		s.running.Add(1)
+		go s.work(ctx)
+	}
+
+	go func() {
+		<-ctx.Done()
+		s.mutex.Lock()
+		s.stopped = true
+		s.mutex.Unlock()
+		s.cond.Broadcast()
+	}()
+}
+
+// Submit queues job. It is dropped once the scheduler has stopped and
+// drained its queue.
+func (s *Scheduler) Submit(job Job) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.seq++
+	job.seq = s.seq
+	heap.Push(&s.queue, &job)
+	jobsQueued.Add(1)
+	s.cond.Signal()
+}
+
+// Every submits job every interval() until the scheduler stops. interval is
+// re-read before each wait so configuration reloads take effect.
+func (s *Scheduler) Every(interval func() time.Duration, job Job) {
+	go func() {
+		s.mutex.Lock()
+		ctx := s.ctx
+		s.mutex.Unlock()
+
+		timer := time.NewTimer(interval())
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+				s.Submit(job)
+				timer.Reset(interval())
+			}
+		}
+	}()
+}
+
+// Wait blocks until every worker has drained the queue and exited after the
+// scheduler's context was cancelled, or until ctx expires; the jobs still
+// running then have their context cancelled.
+func (s *Scheduler) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mutex.Lock()
+		if s.cancelDrain != nil {
+			s.cancelDrain()
+		}
+		s.mutex.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *Scheduler) work(ctx context.Context) {
+	defer s.running.Done()
+
+	for {
+		s.mutex.Lock()
+		for s.queue.Len() == 0 && !s.stopped {
+			s.cond.Wait()
+		}
+		if s.queue.Len() == 0 {
+			s.exited++
+			s.closed = s.exited == s.workers
+			s.mutex.Unlock()
+			return
+		}
+		job := heap.Pop(&s.queue).(*Job)
+		jobsQueued.Add(-1)
+		runCtx := ctx
+		if ctx.Err() != nil {
+			runCtx = s.drain
+		}
+		s.mutex.Unlock()
+
+		s.run(runCtx, job)
+	}
+}
+
+func (s *Scheduler) run(ctx context.Context, job *Job) {
+	job.attempt++
+	err := job.Run(ctx)
+	if err == nil {
+		jobsCompleted.Add(1)
+		return
+	}
+
+	if job.attempt >= job.Retry.MaxAttempts || ctx.Err() != nil {
+		jobsFailed.Add(1)
+		log.Printf("Job %s failed after %d attempt(s): %v", job.Name, job.attempt, err)
+		return
+	}
+
+	delay := job.Retry.Backoff << (job.attempt - 1)
+	jobsRetried.Add(1)
+	log.Printf("Job %s failed (attempt %d), retrying in %s: %v", job.Name, job.attempt, delay, err)
+	retry := *job
+	time.AfterFunc(delay, func() { s.Submit(retry) })
+}
+
+// jobQueue is a max-heap on priority, then submission order.
+type jobQueue []*Job
+
+func (q jobQueue) Len() int { return len(q) }
+
+func (q jobQueue) Less(i, j int) bool {
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority > q[j].Priority
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q jobQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *jobQueue) Push(x any) { *q = append(*q, x.(*Job)) }
+
+func (q *jobQueue) Pop() any {
+	old := *q
+	job := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return job
+}
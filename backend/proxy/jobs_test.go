@@ -0,0 +1,174 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder collects the names of the jobs that ran, in order.
+type recorder struct {
+	mutex sync.Mutex
+	names []string
+}
+
+func (r *recorder) job(name string, priority Priority) Job {
+	return Job{Name: name, Priority: priority, Run: func(context.Context) error {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.names = append(r.names, name)
+		return nil
+	}}
+}
+
+func (r *recorder) ran() []string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return slices.Clone(r.names)
+}
+
+func stopScheduler(t *testing.T, s *Scheduler, cancel context.CancelFunc) {
+	t.Helper()
+	cancel()
+	ctx, done := context.WithTimeout(context.Background(), time.Second)
+	defer done()
+	if err := s.Wait(ctx); err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+}
+
+func TestSchedulerPriorityOrder(t *testing.T) {
+	var r recorder
+	s := NewScheduler(1)
+	// Queued before the worker starts, so the order is the queue's alone
+	s.Submit(r.job("low", PriorityLow))
+	s.Submit(r.job("normal 1", PriorityNormal))
+	s.Submit(r.job("high 1", PriorityHigh))
+	s.Submit(r.job("normal 2", PriorityNormal))
+	s.Submit(r.job("high 2", PriorityHigh))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+	stopScheduler(t, s, cancel)
+
+	want := []string{"high 1", "high 2", "normal 1", "normal 2", "low"}
+	if got := r.ran(); !slices.Equal(got, want) {
+		t.Errorf("ran %v, want %v", got, want)
+	}
+}
+
+func TestSchedulerRetry(t *testing.T) {
+	s := NewScheduler(2)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	var mutex sync.Mutex
+	attempts := map[string]int{}
+	done := make(chan string, 2)
+	failing := func(name string, failures int) Job {
+		return Job{
+			Name:  name,
+			Retry: RetryPolicy{MaxAttempts: 3, Backoff: time.Millisecond},
+			Run: func(context.Context) error {
+				mutex.Lock()
+				defer mutex.Unlock()
+				attempts[name]++
+				if attempts[name] <= failures {
+					if attempts[name] == 3 {
+						done <- name
+					}
+					return errors.New("not yet")
+				}
+				done <- name
+				return nil
+			},
+		}
+	}
+	s.Submit(failing("recovers", 2))
+	s.Submit(failing("gives up", 5))
+	for range 2 {
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("jobs were not retried")
+		}
+	}
+	// A retry the policy no longer allows would have been scheduled by now
+	time.Sleep(20 * time.Millisecond)
+	stopScheduler(t, s, cancel)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if attempts["recovers"] != 3 || attempts["gives up"] != 3 {
+		t.Errorf("attempts %v, want 3 each", attempts)
+	}
+}
+
+func TestSchedulerDrainsOnStop(t *testing.T) {
+	var r recorder
+	s := NewScheduler(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	started := make(chan struct{})
+	var runningCtx context.Context
+	s.Submit(Job{Name: "running", Run: func(ctx context.Context) error {
+		runningCtx = ctx
+		close(started)
+		<-ctx.Done()
+		return nil
+	}})
+	<-started
+	s.Submit(r.job("queued", PriorityLow))
+	s.Submit(Job{Name: "submits more", Run: func(ctx context.Context) error {
+		if ctx.Err() != nil {
+			t.Error("a queued job ran with a cancelled context while draining")
+		}
+		s.Submit(r.job("submitted while draining", PriorityLow))
+		return nil
+	}})
+
+	stopScheduler(t, s, cancel)
+	if runningCtx.Err() == nil {
+		t.Error("the running job's context was not cancelled")
+	}
+	want := []string{"queued", "submitted while draining"}
+	if got := r.ran(); !slices.Equal(got, want) {
+		t.Errorf("ran %v while draining, want %v", got, want)
+	}
+
+	// Once drained, the scheduler drops new work
+	s.Submit(r.job("too late", PriorityHigh))
+	time.Sleep(10 * time.Millisecond)
+	if got := r.ran(); len(got) != len(want) {
+		t.Errorf("a job submitted after the drain ran: %v", got)
+	}
+}
+
+func TestSchedulerWaitGivesUp(t *testing.T) {
+	s := NewScheduler(1)
+	ctx, cancel := context.WithCancel(context.Background())
+	s.Start(ctx)
+
+	cancelled := make(chan struct{})
+	cancel()
+	s.Submit(Job{Name: "slow", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		close(cancelled)
+		return ctx.Err()
+	}})
+
+	waitCtx, done := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer done()
+	if err := s.Wait(waitCtx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait = %v, want the deadline", err)
+	}
+	select {
+	case <-cancelled:
+	case <-time.After(time.Second):
+		t.Error("a draining job was not cancelled when Wait gave up")
+	}
+}
@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// decodeItems streams a Google collection page ({"items": [...],
+// "nextPageToken": "..."}), calling decodeItem with the decoder positioned at
+// each element of items. Other fields are skipped without being buffered as
+// a whole. It returns the page's nextPageToken.
+func decodeItems(r io.Reader, decodeItem func(*json.Decoder) error) (string, error) {
+	dec := json.NewDecoder(r)
+	if err := expectDelim(dec, '{'); err != nil {
+		return "", err
+	}
+
+	var nextPageToken string
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return "", err
+		}
+		key, _ := tok.(string)
+
+		switch key {
+		case "items":
+			if err := expectDelim(dec, '['); err != nil {
+				return "", err
+			}
+			for dec.More() {
+				if err := decodeItem(dec); err != nil {
+					return "", err
+				}
+			}
+			if err := expectDelim(dec, ']'); err != nil {
+				return "", err
+			}
+		case "nextPageToken":
+			if err := dec.Decode(&nextPageToken); err != nil {
+				return "", err
+			}
+		default:
+			if err := skipValue(dec); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return nextPageToken, expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, want json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != want {
+		return fmt.Errorf("expected %v in JSON, got %v", want, tok)
+	}
+	return nil
+}
+
+// skipValue consumes the next value token by token, so large unused fields
+// are never held in memory at once.
+func skipValue(dec *json.Decoder) error {
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
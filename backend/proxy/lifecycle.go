@@ -0,0 +1,92 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// Start launches the background subsystems: the job scheduler with its
+// periodic jobs, and the SIGHUP reload watcher. They stop when ctx is
+// cancelled.
+func (s *Server) Start(ctx context.Context) {
+	s.jobs.Start(ctx)
+	s.jobs.Every(func() time.Duration { return s.config().CleanupInterval }, Job{
+		Name:     "cleanup-auth-states",
+		Priority: PriorityLow,
+		Run: func(context.Context) error {
+			s.cleanupExpiredStates()
+			return nil
+		},
+	})
+
+	s.jobs.Every(func() time.Duration { return s.config().Reminders.Interval }, Job{
+		Name:     "reminders",
+		Priority: PriorityLow,
+		Run:      s.scanReminders,
+	})
+	s.jobs.Every(func() time.Duration { return s.config().Changes.Interval }, Job{
+		Name:     "changes",
+		Priority: PriorityLow,
+		Run:      s.pollChanges,
+	})
+	s.jobs.Every(s.backupCheckEvery, Job{
+		Name:     "backup",
+		Priority: PriorityLow,
+		Retry:    RetryPolicy{MaxAttempts: 3, Backoff: time.Minute},
+		Run:      s.runBackups,
+	})
+
+	go s.watchReload(ctx)
+}
+
+// watchReload reloads the configuration on SIGHUP until ctx is cancelled.
+func (s *Server) watchReload(ctx context.Context) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			if err := s.reload(); err != nil {
+				log.Printf("Config reload failed, keeping previous configuration: %v", err)
+			}
+		}
+	}
+}
+
+// OnShutdown registers fn to run during Shutdown, after in-flight background
+// work has finished. Hooks run in reverse registration order.
+func (s *Server) OnShutdown(fn func(context.Context) error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
+
+// Shutdown waits for running jobs (such as callback token exchanges) and then
+// runs the shutdown hooks, giving up when ctx expires. The context passed to
+// Start must already be cancelled.
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+	if err := s.jobs.Wait(ctx); err != nil {
+		errs = append(errs, errors.New("timed out waiting for running jobs"))
+	}
+
+	s.mutex.RLock()
+	hooks := append([]func(context.Context) error(nil), s.shutdownHooks...)
+	s.mutex.RUnlock()
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if err := hooks[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
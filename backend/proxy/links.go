@@ -0,0 +1,178 @@
+package proxy
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	maxLinkLength   = 2048
+	maxTaskLinks    = 100
+	attachmentIDLen = 6 // random bytes
+)
+
+// linkType reports whether link is a URL or a file path. Anything with a
+// scheme other than file: is a URL; a single letter before the colon is a
+// Windows drive, not a scheme.
+func linkType(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || len(u.Scheme) < 2 || u.Scheme == "file" {
+		return "file"
+	}
+	return "url"
+}
+
+// writeTaskLinks responds with the task's Google links and attachments.
+func writeTaskLinks(w http.ResponseWriter, task api.Task, meta api.TaskMetadata) {
+	resp := api.TaskLinksResponse{Task: task.ID, Google: task.Links, Attached: meta.Links}
+	if resp.Google == nil {
+		resp.Google = []api.TaskLink{}
+	}
+	if resp.Attached == nil {
+		resp.Attached = []api.Attachment{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// linkedTask finds the task a links request is for, writing the error when
+// there is none.
+func (s *Server) linkedTask(w http.ResponseWriter, r *http.Request, token, listID string) (api.Task, bool) {
+	_, task, found, err := s.findTask(r.Context(), token, listID, r.PathValue("task"))
+	if err != nil {
+		writeTasksError(w, err)
+		return api.Task{}, false
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Task not found")
+		return api.Task{}, false
+	}
+	return task, true
+}
+
+// GET /api/tasks/{task}/links - A task's links
+//
+// Both the links Google made and the URLs and files attached through the
+// proxy. ?list= skips looking the task up in every list.
+func (s *Server) handleTaskLinks(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, r.URL.Query().Get("list"))
+	if !ok {
+		return
+	}
+	meta, err := s.metadataStore(tenantUser(r.Context())).Get(task.ID)
+	if err != nil {
+		log.Printf("Reading task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task metadata")
+		return
+	}
+	writeTaskLinks(w, task, meta)
+}
+
+// POST /api/tasks/{task}/links - Attach a URL or file to a task
+//
+// Google Tasks' own links are read-only, so attachments are kept in the
+// task's metadata. Attaching a link the task already has only updates its
+// description.
+func (s *Server) handleAddTaskLink(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.AddLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	req.Link = strings.TrimSpace(req.Link)
+	if req.Link == "" || len(req.Link) > maxLinkLength {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "link must be a URL or file path of at most 2048 bytes")
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, req.List)
+	if !ok {
+		return
+	}
+	id, err := generateRandomString(attachmentIDLen)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to generate link ID")
+		return
+	}
+
+	full := false
+	meta, err := s.metadataStore(tenantUser(r.Context())).Update(task.ID, func(m *api.TaskMetadata) {
+		if i := slices.IndexFunc(m.Links, func(a api.Attachment) bool { return a.Link == req.Link }); i >= 0 {
+			m.Links[i].Description = req.Description
+			return
+		}
+		if full = len(m.Links) >= maxTaskLinks; full {
+			return
+		}
+		m.Links = append(m.Links, api.Attachment{
+			ID:          id,
+			Type:        linkType(req.Link),
+			Link:        req.Link,
+			Description: req.Description,
+			Added:       time.Now().UTC().Format(time.RFC3339),
+		})
+	})
+	if err != nil {
+		log.Printf("Recording task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to record the link")
+		return
+	}
+	if full {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "A task can have at most 100 links")
+		return
+	}
+	writeTaskLinks(w, task, meta)
+}
+
+// DELETE /api/tasks/{task}/links/{link} - Remove an attached link
+//
+// {link} is the attachment's ID. Google's own links cannot be removed.
+// ?list= works as for GET.
+func (s *Server) handleRemoveTaskLink(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, r.URL.Query().Get("list"))
+	if !ok {
+		return
+	}
+
+	removed := false
+	meta, err := s.metadataStore(tenantUser(r.Context())).Update(task.ID, func(m *api.TaskMetadata) {
+		n := len(m.Links)
+		m.Links = slices.DeleteFunc(m.Links, func(a api.Attachment) bool { return a.ID == r.PathValue("link") })
+		removed = len(m.Links) < n
+	})
+	if err != nil {
+		log.Printf("Recording task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to remove the link")
+		return
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Link not found")
+		return
+	}
+	writeTaskLinks(w, task, meta)
+}
@@ -0,0 +1,159 @@
+package proxy
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// listenAddr is one address the server listens on.
+type listenAddr struct {
+	network string // tcp or unix
+	address string
+}
+
+func (a listenAddr) String() string {
+	if a.network == "unix" {
+		return "unix:" + a.address
+	}
+	return a.address
+}
+
+// listenAddrs returns where to listen: each server.listen entry, with
+// server.port for entries that are only a host, or every interface on
+// server.port when there are none. Entries starting with unix: or / are
+// unix sockets.
+func (c *Config) listenAddrs() []listenAddr {
+	if len(c.Listen) == 0 {
+		return []listenAddr{{"tcp", ":" + c.Port}}
+	}
+	var addrs []listenAddr
+	for _, entry := range c.Listen {
+		if path, ok := strings.CutPrefix(entry, "unix:"); ok {
+			addrs = append(addrs, listenAddr{"unix", expandHome(path)})
+			continue
+		}
+		if strings.HasPrefix(entry, "/") || strings.HasPrefix(entry, "~/") {
+			addrs = append(addrs, listenAddr{"unix", expandHome(entry)})
+			continue
+		}
+		if _, _, err := net.SplitHostPort(entry); err != nil {
+			// A host alone, maybe a bracketed IPv6 address
+			entry = net.JoinHostPort(strings.Trim(entry, "[]"), c.Port)
+		}
+		addrs = append(addrs, listenAddr{"tcp", entry})
+	}
+	return addrs
+}
+
+// listen opens every address of addrs, or none if one fails. A unix socket
+// left behind by a proxy that did not shut down cleanly is replaced, and new
+// sockets are only accessible to their owner.
+func listen(addrs []listenAddr) ([]net.Listener, error) {
+	var listeners []net.Listener
+	fail := func(err error) ([]net.Listener, error) {
+		for _, l := range listeners {
+			l.Close()
+		}
+		return nil, err
+	}
+	for _, addr := range addrs {
+		var l net.Listener
+		var err error
+		if addr.network == "unix" {
+			l, err = listenUnix(addr.address)
+		} else {
+			l, err = net.Listen(addr.network, addr.address)
+		}
+		if err != nil {
+			return fail(err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// listenUnix listens on a unix socket at path that only its owner can
+// connect to. The socket is created in a directory only the owner can enter
+// and linked to path once it is restricted, so no one else can connect in
+// between.
+func listenUnix(path string) (net.Listener, error) {
+	if err := removeStaleSocket(path); err != nil {
+		return nil, err
+	}
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".gtask")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	private := filepath.Join(dir, "s")
+	l, err := net.Listen("unix", private)
+	if err != nil {
+		return nil, err
+	}
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(private, 0o600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	// Unlike a rename, a link does not replace whatever is at path
+	if err := os.Link(private, path); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return unixListener{l, path}, nil
+}
+
+// unixListener removes its socket when closed.
+type unixListener struct {
+	net.Listener
+	path string
+}
+
+func (l unixListener) Close() error {
+	err := l.Listener.Close()
+	os.Remove(l.path)
+	return err
+}
+
+// removeStaleSocket removes the socket at path unless something still
+// accepts connections on it. Anything other than a socket is left alone, so
+// listening on path fails.
+func removeStaleSocket(path string) error {
+	info, err := os.Lstat(path)
+	if errors.Is(err, fs.ErrNotExist) || err == nil && info.Mode()&fs.ModeSocket == 0 {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return fmt.Errorf("%s is in use by another process", path)
+	}
+	return os.Remove(path)
+}
+
+// localURL is the base URL of the server for the log: the first TCP
+// address, with localhost for every interface, or "" when it only listens
+// on unix sockets.
+func localURL(addrs []listenAddr) string {
+	for _, addr := range addrs {
+		if addr.network != "tcp" {
+			continue
+		}
+		host, port, err := net.SplitHostPort(addr.address)
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+			host = "localhost"
+		}
+		return "http://" + net.JoinHostPort(host, port)
+	}
+	return ""
+}
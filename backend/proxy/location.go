@@ -0,0 +1,115 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// cleanLocation trims loc and checks it names a file and a line.
+func cleanLocation(loc *api.CodeLocation) error {
+	loc.File, loc.Repo, loc.Commit = strings.TrimSpace(loc.File), strings.TrimSpace(loc.Repo), strings.TrimSpace(loc.Commit)
+	switch {
+	case loc.File == "" || len(loc.File) > maxLinkLength:
+		return errors.New("location needs a file path of at most 2048 bytes")
+	case loc.Line < 1:
+		return errors.New("location needs a line, counted from 1")
+	case len(loc.Repo) > maxLinkLength || len(loc.Commit) > maxLinkLength:
+		return errors.New("location repo and commit must be at most 2048 bytes")
+	}
+	return nil
+}
+
+// writeTaskLocation responds with the task's code location.
+func writeTaskLocation(w http.ResponseWriter, task api.Task, meta api.TaskMetadata) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.TaskLocationResponse{Task: task.ID, Location: meta.Location})
+}
+
+// GET /api/tasks/{task}/location - Where in the code a task was created
+//
+// The location is null for tasks not created from code. ?list= skips
+// looking the task up in every list.
+func (s *Server) handleTaskLocation(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, r.URL.Query().Get("list"))
+	if !ok {
+		return
+	}
+	meta, err := s.metadataStore(tenantUser(r.Context())).Get(task.ID)
+	if err != nil {
+		log.Printf("Reading task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task metadata")
+		return
+	}
+	writeTaskLocation(w, task, meta)
+}
+
+// PUT /api/tasks/{task}/location - Set where in the code a task is about
+//
+// Replaces the task's location, kept in its metadata like attached links.
+func (s *Server) handleSetTaskLocation(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.SetLocationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if err := cleanLocation(&req.CodeLocation); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, req.List)
+	if !ok {
+		return
+	}
+	meta, err := s.metadataStore(tenantUser(r.Context())).Update(task.ID, func(m *api.TaskMetadata) {
+		m.Location = &req.CodeLocation
+	})
+	if err != nil {
+		log.Printf("Recording task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to record the location")
+		return
+	}
+	writeTaskLocation(w, task, meta)
+}
+
+// DELETE /api/tasks/{task}/location - Forget a task's code location
+//
+// ?list= works as for GET.
+func (s *Server) handleRemoveTaskLocation(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	task, ok := s.linkedTask(w, r, token, r.URL.Query().Get("list"))
+	if !ok {
+		return
+	}
+	meta, err := s.metadataStore(tenantUser(r.Context())).Update(task.ID, func(m *api.TaskMetadata) {
+		m.Location = nil
+	})
+	if err != nil {
+		log.Printf("Recording task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to remove the location")
+		return
+	}
+	writeTaskLocation(w, task, meta)
+}
@@ -0,0 +1,136 @@
+package proxy
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// RotatingFile is a log file that is rotated once it would grow past
+// maxSize: backend.log becomes backend.log.1, backend.log.1 becomes
+// backend.log.2 and so on, keeping at most maxFiles old files.
+type RotatingFile struct {
+	path     string
+	maxSize  int64
+	maxFiles int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+// OpenRotatingFile opens path for appending, creating it and its directory
+// if needed.
+func OpenRotatingFile(path string, maxSize int64, maxFiles int) (*RotatingFile, error) {
+	f := &RotatingFile{path: path, maxSize: maxSize, maxFiles: maxFiles}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *RotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+// Write appends p, rotating first if p would take the file past maxSize. A
+// single write larger than maxSize still goes to a fresh file whole.
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			// Keep logging to the current file rather than losing output
+			fmt.Fprintf(os.Stderr, "log rotation failed: %v\n", err)
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// rotate keeps the current file open until the new one is: if that fails,
+// writes go on to the current file (under its rotated name) and rotation is
+// tried again after another maxSize.
+func (f *RotatingFile) rotate() error {
+	current := f.file
+	os.Remove(f.path + "." + strconv.Itoa(f.maxFiles))
+	for i := f.maxFiles - 1; i >= 1; i-- {
+		os.Rename(f.path+"."+strconv.Itoa(i), f.path+"."+strconv.Itoa(i+1))
+	}
+	renameErr := os.Rename(f.path, f.path+".1")
+	if err := f.open(); err != nil {
+		f.size = 0
+		return err
+	}
+	current.Close()
+	return renameErr
+}
+
+func (f *RotatingFile) Close() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.file.Close()
+}
+
+// bestEffort writes to w and ignores its errors.
+type bestEffort struct {
+	w io.Writer
+}
+
+func (b bestEffort) Write(p []byte) (int, error) {
+	b.w.Write(p)
+	return len(p), nil
+}
+
+// expandHome replaces a leading ~/ with the home directory, since LOG_FILE
+// and friends are often set without a shell to expand it.
+func expandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, rest)
+}
+
+// parseSize reads a byte count with an optional KB, MB or GB suffix.
+func parseSize(v string) (int64, error) {
+	v = strings.ToUpper(strings.TrimSpace(v))
+	multiplier := int64(1)
+	for suffix, m := range map[string]int64{"KB": 1 << 10, "MB": 1 << 20, "GB": 1 << 30} {
+		if number, ok := strings.CutSuffix(v, suffix); ok {
+			v, multiplier = strings.TrimSpace(number), m
+			break
+		}
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if n <= 0 {
+		return 0, fmt.Errorf("must be positive: %s", v)
+	}
+	return n * multiplier, nil
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"container/list"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded, TTL-aware map. When full, setting a new key evicts
+// the least recently used entry; expired entries are never returned and are
+// removed by Purge. It is safe for concurrent use.
+type LRU[V any] struct {
+	mutex    sync.Mutex
+	capacity int
+	order    *list.List // front = most recently used
+	entries  map[string]*list.Element
+
+	evicted *expvar.Int // removed to make room
+	expired *expvar.Int // removed after their TTL
+}
+
+type lruEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// NewLRU creates a cache holding at most capacity entries. Eviction counts
+// are published as expvar metrics named <name>_evicted and <name>_expired,
+// shared by every cache of that name in the process.
+func NewLRU[V any](name string, capacity int) *LRU[V] {
+	return &LRU[V]{
+		capacity: capacity,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		evicted:  expvarInt(name + "_evicted"),
+		expired:  expvarInt(name + "_expired"),
+	}
+}
+
+// Set stores value under key for ttl, evicting the least recently used
+// entry if the cache is full.
+func (c *LRU[V]) Set(key string, value V, ttl time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry := &lruEntry[V]{key: key, value: value, expiresAt: time.Now().Add(ttl)}
+	if el, ok := c.entries[key]; ok {
+		el.Value = entry
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(entry)
+	for c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.evicted.Add(1)
+	}
+}
+
+// Get returns the live value for key and marks it recently used.
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.lookup(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.order.MoveToFront(el)
+	return el.Value.(*lruEntry[V]).value, true
+}
+
+// Take returns the live value for key and removes it.
+func (c *LRU[V]) Take(key string) (V, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	el, ok := c.lookup(key)
+	if !ok {
+		var zero V
+		return zero, false
+	}
+	c.remove(el)
+	return el.Value.(*lruEntry[V]).value, true
+}
+
+// Delete removes key if present.
+func (c *LRU[V]) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+}
+
+// DeleteFunc removes every entry whose key matches.
+func (c *LRU[V]) DeleteFunc(match func(key string) bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for key, el := range c.entries {
+		if match(key) {
+			c.remove(el)
+		}
+	}
+}
+
+// Purge removes every expired entry.
+func (c *LRU[V]) Purge() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for el := c.order.Back(); el != nil; {
+		prev := el.Prev()
+		if now.After(el.Value.(*lruEntry[V]).expiresAt) {
+			c.remove(el)
+			c.expired.Add(1)
+		}
+		el = prev
+	}
+}
+
+// Len returns the number of entries, including expired ones not yet purged.
+func (c *LRU[V]) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.order.Len()
+}
+
+// lookup finds key, dropping it if it has expired. Callers hold the mutex.
+func (c *LRU[V]) lookup(key string) (*list.Element, bool) {
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(el.Value.(*lruEntry[V]).expiresAt) {
+		c.remove(el)
+		c.expired.Add(1)
+		return nil, false
+	}
+	return el, true
+}
+
+func (c *LRU[V]) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*lruEntry[V]).key)
+}
+
+var expvarMutex sync.Mutex
+
+// expvarInt returns the counter published as name, publishing it first if
+// needed. expvar names are process-wide and cannot be published twice, so
+// the servers of a process that embeds more than one share their counters.
+func expvarInt(name string) *expvar.Int {
+	expvarMutex.Lock()
+	defer expvarMutex.Unlock()
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v
+	}
+	return expvar.NewInt(name)
+}
@@ -0,0 +1,96 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// MetadataStore keeps what the proxy knows about tasks beyond their Google
+// fields, by task ID, in a JSON file readable only by its owner.
+type MetadataStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewMetadataStore(path string) *MetadataStore {
+	return &MetadataStore{path: path}
+}
+
+// Get returns taskID's metadata, empty when there is none.
+func (s *MetadataStore) Get(taskID string) (api.TaskMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	all, err := s.read()
+	if err != nil {
+		return api.TaskMetadata{}, err
+	}
+	return all[taskID], nil
+}
+
+// All returns the metadata of every task that has some.
+func (s *MetadataStore) All() (map[string]api.TaskMetadata, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.read()
+}
+
+// Update changes taskID's metadata with update and saves it. Metadata left
+// empty is forgotten.
+func (s *MetadataStore) Update(taskID string, update func(*api.TaskMetadata)) (api.TaskMetadata, error) {
+	var meta api.TaskMetadata
+	err := s.UpdateMany(map[string]func(*api.TaskMetadata){
+		taskID: func(m *api.TaskMetadata) {
+			update(m)
+			meta = *m
+		},
+	})
+	if err != nil {
+		return api.TaskMetadata{}, err
+	}
+	return meta, nil
+}
+
+// UpdateMany is Update for several tasks at once, saving them together.
+func (s *MetadataStore) UpdateMany(updates map[string]func(*api.TaskMetadata)) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	all, err := s.read()
+	if err != nil {
+		return err
+	}
+	for taskID, update := range updates {
+		meta := all[taskID]
+		update(&meta)
+		if meta.Empty() {
+			delete(all, taskID)
+		} else {
+			all[taskID] = meta
+		}
+	}
+	data, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func (s *MetadataStore) read() (map[string]api.TaskMetadata, error) {
+	all := make(map[string]api.TaskMetadata)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return all, nil
+}
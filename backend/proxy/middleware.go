@@ -0,0 +1,143 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	tenantKey
+)
+
+// requestID returns the ID assigned to r by the access log middleware.
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// statusRecorder captures the status code and body size written by a handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += n
+	return n, err
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer (Flush,
+// deadlines) through the recorder.
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// accessLog assigns every request an ID (reusing a client-supplied
+// X-Request-ID) and, unless disabled in config, logs one structured line per
+// request once it completes.
+func (s *Server) accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 128 {
+			id, _ = generateRandomString(12)
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(context.WithValue(r.Context(), requestIDKey, id))
+
+		if !s.config().AccessLog {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		if rec.status == 0 {
+			rec.status = http.StatusOK
+		}
+
+		slog.Info("request",
+			"method", r.Method,
+			"path", logPath(r),
+			"status", rec.status,
+			"duration_ms", float64(time.Since(start).Microseconds())/1000,
+			"bytes", rec.bytes,
+			"request_id", id,
+		)
+	})
+}
+
+// parseTimeout reads an X-Timeout or ?timeout= value: a Go duration like
+// "30s" or "1m30s", or a number of seconds.
+func parseTimeout(v string) (time.Duration, error) {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		seconds, numErr := strconv.ParseFloat(v, 64)
+		if numErr != nil {
+			return 0, errors.New("timeout must be a duration like 30s or a number of seconds")
+		}
+		d = time.Duration(seconds * float64(time.Second))
+	}
+	if d <= 0 {
+		return 0, errors.New("timeout must be positive")
+	}
+	return d, nil
+}
+
+// withTimeout gives /api requests that ask for one with an X-Timeout header
+// or ?timeout= a deadline, at most server.max_timeout away. Every Google
+// call a handler makes is bound to the request's context, which also ends
+// when the client disconnects, so either stops the remaining calls.
+func (s *Server) withTimeout(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		v := r.Header.Get("X-Timeout")
+		if v == "" {
+			v = r.URL.Query().Get("timeout")
+		}
+		if v == "" || !strings.HasPrefix(r.URL.Path, "/api/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		timeout, err := parseTimeout(v)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+			return
+		}
+		ctx, cancel := context.WithTimeout(r.Context(), min(timeout, s.config().MaxTimeout))
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// logPath hides the auth state in poll URLs, since it is enough to collect
+// the tokens, and the token in share links. Query strings are never logged
+// for the same reason.
+func logPath(r *http.Request) string {
+	if strings.Contains(r.Pattern, "{state}") || strings.Contains(r.Pattern, "{token}") {
+		_, path, _ := strings.Cut(r.Pattern, " ")
+		return path
+	}
+	return r.URL.Path
+}
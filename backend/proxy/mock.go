@@ -0,0 +1,620 @@
+package proxy
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// MockGoogle is an in-memory stand-in for Google's token endpoint, Tasks
+// API, the events of Calendar API and a read-only Gmail inbox, selected with provider.name = "mock". Any client ID and secret are
+// accepted and sign-in needs no consent screen, so the plugin and the CLI can
+// be run and tested without credentials or network access. Data lives until
+// the process exits and is shared by every caller.
+type MockGoogle struct {
+	mux *http.ServeMux
+
+	mutex  sync.Mutex
+	lists  []*mockList
+	events map[string]*googleEvent // by calendar and event ID
+	seq    int
+}
+
+type mockList struct {
+	api.TaskList
+	tasks []*api.Task // in display order; subtasks follow no particular order
+}
+
+func NewMockGoogle() *MockGoogle {
+	m := &MockGoogle{mux: http.NewServeMux(), events: make(map[string]*googleEvent)}
+
+	m.mux.HandleFunc("POST /token", m.handleToken)
+	m.mux.HandleFunc("POST /revoke", m.handleRevoke)
+	m.mux.HandleFunc("GET /tasks/v1/users/@me/lists", m.authorized(m.listLists))
+	m.mux.HandleFunc("POST /tasks/v1/users/@me/lists", m.authorized(m.insertList))
+	m.mux.HandleFunc("GET /tasks/v1/users/@me/lists/{list}", m.authorized(m.getList))
+	m.mux.HandleFunc("PATCH /tasks/v1/users/@me/lists/{list}", m.authorized(m.updateList))
+	m.mux.HandleFunc("PUT /tasks/v1/users/@me/lists/{list}", m.authorized(m.updateList))
+	m.mux.HandleFunc("DELETE /tasks/v1/users/@me/lists/{list}", m.authorized(m.deleteList))
+	m.mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks", m.authorized(m.listTasks))
+	m.mux.HandleFunc("POST /tasks/v1/lists/{list}/tasks", m.authorized(m.insertTask))
+	m.mux.HandleFunc("POST /tasks/v1/lists/{list}/clear", m.authorized(m.clearTasks))
+	m.mux.HandleFunc("GET /tasks/v1/lists/{list}/tasks/{task}", m.authorized(m.getTask))
+	m.mux.HandleFunc("PATCH /tasks/v1/lists/{list}/tasks/{task}", m.authorized(m.updateTask))
+	m.mux.HandleFunc("PUT /tasks/v1/lists/{list}/tasks/{task}", m.authorized(m.updateTask))
+	m.mux.HandleFunc("DELETE /tasks/v1/lists/{list}/tasks/{task}", m.authorized(m.deleteTask))
+	m.mux.HandleFunc("POST /tasks/v1/lists/{list}/tasks/{task}/move", m.authorized(m.moveTask))
+	m.mux.HandleFunc("POST /calendar/v3/calendars/{calendar}/events", m.authorized(m.insertEvent))
+	m.mux.HandleFunc("GET /calendar/v3/calendars/{calendar}/events/{event}", m.authorized(m.getEvent))
+	m.mux.HandleFunc("PATCH /calendar/v3/calendars/{calendar}/events/{event}", m.authorized(m.updateEvent))
+	m.mux.HandleFunc("DELETE /calendar/v3/calendars/{calendar}/events/{event}", m.authorized(m.deleteEvent))
+	m.mux.HandleFunc("GET /gmail/v1/users/me/messages", m.authorized(m.listMessages))
+	m.mux.HandleFunc("GET /gmail/v1/users/me/messages/{message}", m.authorized(m.getMessage))
+
+	// Something to look at on first sign-in
+	list := m.newList("My Tasks")
+	m.addTask(list, &api.Task{Title: "Try gtask.nvim against the mock provider", Status: "needsAction",
+		Due: googleDue(time.Now().AddDate(0, 0, 1))})
+	m.addTask(list, &api.Task{Title: "Read the README", Status: "completed", Completed: time.Now().UTC().Format(time.RFC3339)})
+
+	return m
+}
+
+// ServeHTTP serves the token endpoint at /token, token revocation at
+// /revoke, the Tasks API under /tasks/v1, the Calendar API under
+// /calendar/v3 and Gmail under /gmail/v1, the paths Google uses.
+func (m *MockGoogle) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mux.ServeHTTP(w, r)
+}
+
+// AuthURL skips Google's consent screen: it sends the browser straight back
+// to the callback with a code for state.
+func (m *MockGoogle) AuthURL(redirectURI, state string) string {
+	query := url.Values{}
+	query.Set("code", "mock-code-"+state)
+	query.Set("state", state)
+	if strings.Contains(redirectURI, "?") {
+		return redirectURI + "&" + query.Encode()
+	}
+	return redirectURI + "?" + query.Encode()
+}
+
+// RoundTrip answers requests for Google's hosts in memory, so the upstream
+// client can use MockGoogle as its transport. Anything else is refused.
+func (m *MockGoogle) RoundTrip(req *http.Request) (*http.Response, error) {
+	switch req.URL.Host {
+	case "oauth2.googleapis.com", "tasks.googleapis.com", "www.googleapis.com", "gmail.googleapis.com":
+	default:
+		return nil, fmt.Errorf("mock provider: no network access to %s", req.URL.Host)
+	}
+
+	return serveRoundTrip(m, req), nil
+}
+
+// serveRoundTrip answers req with h in memory, for the transports that
+// stand in for Google.
+func serveRoundTrip(h http.Handler, req *http.Request) *http.Response {
+	rec := &responseBuffer{header: make(http.Header)}
+	h.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return &http.Response{
+		Status:        strconv.Itoa(rec.status) + " " + http.StatusText(rec.status),
+		StatusCode:    rec.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        rec.header,
+		Body:          io.NopCloser(&rec.body),
+		ContentLength: int64(rec.body.Len()),
+		Request:       req,
+	}
+}
+
+// responseBuffer is the http.ResponseWriter behind RoundTrip.
+type responseBuffer struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *responseBuffer) Header() http.Header { return b.header }
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.WriteHeader(http.StatusOK)
+	return b.body.Write(p)
+}
+
+// handleRevoke accepts the revocation of any mock token. Revoked tokens
+// keep working, as every mock-access- token does.
+func (m *MockGoogle) handleRevoke(w http.ResponseWriter, r *http.Request) {
+	if !strings.HasPrefix(r.PostFormValue("token"), "mock-") {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_token", "error_description": "Token is not a mock token"})
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleToken issues tokens for mock codes and mock refresh tokens.
+func (m *MockGoogle) handleToken(w http.ResponseWriter, r *http.Request) {
+	var valid bool
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		valid = strings.HasPrefix(r.PostFormValue("code"), "mock-code-")
+	case "refresh_token":
+		valid = strings.HasPrefix(r.PostFormValue("refresh_token"), "mock-refresh-")
+	}
+	if !valid {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{
+			"error":             "invalid_grant",
+			"error_description": "Mock provider only accepts its own codes and refresh tokens",
+		})
+		return
+	}
+
+	access, _ := generateRandomString(16)
+	tokens := map[string]any{
+		"access_token": "mock-access-" + access,
+		"expires_in":   3599,
+		"token_type":   "Bearer",
+		"scope":        "https://www.googleapis.com/auth/tasks",
+	}
+	if r.PostFormValue("grant_type") == "authorization_code" {
+		refresh, _ := generateRandomString(16)
+		tokens["refresh_token"] = "mock-refresh-" + refresh
+	}
+	writeGoogleJSON(w, r, http.StatusOK, tokens)
+}
+
+// authorized rejects requests without a mock access token, the way Google
+// rejects expired ones, so the clients' refresh paths can be exercised.
+func (m *MockGoogle) authorized(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer mock-access-") {
+			writeGoogleError(w, http.StatusUnauthorized, "UNAUTHENTICATED", "Request had invalid authentication credentials.")
+			return
+		}
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		next(w, r)
+	}
+}
+
+func (m *MockGoogle) listLists(w http.ResponseWriter, r *http.Request) {
+	items := make([]api.TaskList, len(m.lists))
+	for i, list := range m.lists {
+		items[i] = list.TaskList
+	}
+	writePage(w, r, "tasks#taskLists", items)
+}
+
+func (m *MockGoogle) insertList(w http.ResponseWriter, r *http.Request) {
+	var in api.TaskList
+	if !m.decode(w, r, &in) {
+		return
+	}
+	if in.Title == "" {
+		writeGoogleError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Missing title.")
+		return
+	}
+	writeGoogleJSON(w, r, http.StatusOK, m.newList(in.Title).TaskList)
+}
+
+func (m *MockGoogle) getList(w http.ResponseWriter, r *http.Request) {
+	if list := m.findList(w, r); list != nil {
+		writeGoogleJSON(w, r, http.StatusOK, list.TaskList)
+	}
+}
+
+func (m *MockGoogle) updateList(w http.ResponseWriter, r *http.Request) {
+	list := m.findList(w, r)
+	if list == nil {
+		return
+	}
+	var in api.TaskList
+	if !m.decode(w, r, &in) {
+		return
+	}
+	if in.Title != "" {
+		list.Title = in.Title
+	}
+	m.touch(&list.Updated, &list.ETag)
+	writeGoogleJSON(w, r, http.StatusOK, list.TaskList)
+}
+
+func (m *MockGoogle) deleteList(w http.ResponseWriter, r *http.Request) {
+	if list := m.findList(w, r); list != nil {
+		m.lists = slices.DeleteFunc(m.lists, func(l *mockList) bool { return l == list })
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (m *MockGoogle) listTasks(w http.ResponseWriter, r *http.Request) {
+	list := m.findList(w, r)
+	if list == nil {
+		return
+	}
+	query := r.URL.Query()
+	showCompleted := query.Get("showCompleted") != "false"
+	showHidden := query.Get("showHidden") == "true"
+
+	items := []api.Task{}
+	for _, task := range list.tasks {
+		if task.Status == "completed" && !showCompleted || task.Hidden && !showHidden {
+			continue
+		}
+		items = append(items, *task)
+	}
+	writePage(w, r, "tasks#tasks", items)
+}
+
+func (m *MockGoogle) insertTask(w http.ResponseWriter, r *http.Request) {
+	list := m.findList(w, r)
+	if list == nil {
+		return
+	}
+	var in api.Task
+	if !m.decode(w, r, &in) {
+		return
+	}
+	task := &api.Task{
+		Title:  in.Title,
+		Notes:  in.Notes,
+		Status: cmp.Or(in.Status, "needsAction"),
+		Due:    in.Due,
+		Links:  in.Links,
+	}
+	if task.Status == "completed" {
+		task.Completed = cmp.Or(in.Completed, time.Now().UTC().Format(time.RFC3339))
+	}
+	m.addTask(list, task)
+	if !m.place(w, list, task, r.URL.Query().Get("parent"), r.URL.Query().Get("previous")) {
+		return
+	}
+	writeGoogleJSON(w, r, http.StatusOK, task)
+}
+
+func (m *MockGoogle) getTask(w http.ResponseWriter, r *http.Request) {
+	if _, task := m.findTask(w, r); task != nil {
+		writeGoogleJSON(w, r, http.StatusOK, task)
+	}
+}
+
+// updateTask applies the fields present in the body; PUT is treated like
+// PATCH.
+func (m *MockGoogle) updateTask(w http.ResponseWriter, r *http.Request) {
+	_, task := m.findTask(w, r)
+	if task == nil {
+		return
+	}
+	var fields map[string]json.RawMessage
+	if !m.decode(w, r, &fields) {
+		return
+	}
+
+	var in api.Task
+	data, _ := json.Marshal(fields)
+	json.Unmarshal(data, &in)
+	for name := range fields {
+		switch name {
+		case "title":
+			task.Title = in.Title
+		case "notes":
+			task.Notes = in.Notes
+		case "due":
+			task.Due = in.Due
+		case "links":
+			task.Links = in.Links
+		case "status":
+			task.Status = in.Status
+			task.Completed = ""
+			if in.Status == "completed" {
+				task.Completed = cmp.Or(in.Completed, time.Now().UTC().Format(time.RFC3339))
+			}
+		}
+	}
+	m.touch(&task.Updated, &task.ETag)
+	writeGoogleJSON(w, r, http.StatusOK, task)
+}
+
+func (m *MockGoogle) deleteTask(w http.ResponseWriter, r *http.Request) {
+	list, task := m.findTask(w, r)
+	if task == nil {
+		return
+	}
+	// Subtasks go with their parent
+	removed := map[string]bool{task.ID: true}
+	for changed := true; changed; {
+		changed = false
+		for _, t := range list.tasks {
+			if !removed[t.ID] && removed[t.Parent] {
+				removed[t.ID] = true
+				changed = true
+			}
+		}
+	}
+	list.tasks = slices.DeleteFunc(list.tasks, func(t *api.Task) bool { return removed[t.ID] })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MockGoogle) moveTask(w http.ResponseWriter, r *http.Request) {
+	list, task := m.findTask(w, r)
+	if task == nil {
+		return
+	}
+	if m.place(w, list, task, r.URL.Query().Get("parent"), r.URL.Query().Get("previous")) {
+		writeGoogleJSON(w, r, http.StatusOK, task)
+	}
+}
+
+// clearTasks hides completed tasks, as Google's "clear" does.
+func (m *MockGoogle) clearTasks(w http.ResponseWriter, r *http.Request) {
+	list := m.findList(w, r)
+	if list == nil {
+		return
+	}
+	for _, task := range list.tasks {
+		if task.Status == "completed" {
+			task.Hidden = true
+			m.touch(&task.Updated, &task.ETag)
+		}
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *MockGoogle) insertEvent(w http.ResponseWriter, r *http.Request) {
+	var event googleEvent
+	if !m.decode(w, r, &event) {
+		return
+	}
+	if event.Start == nil || event.End == nil {
+		writeGoogleError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Missing time range.")
+		return
+	}
+	m.seq++
+	event.ID = fmt.Sprintf("mockevent%d", m.seq)
+	event.HTMLLink = "https://calendar.google.com/calendar/event?eid=" + event.ID
+	m.events[r.PathValue("calendar")+"/"+event.ID] = &event
+	writeGoogleJSON(w, r, http.StatusOK, event)
+}
+
+func (m *MockGoogle) getEvent(w http.ResponseWriter, r *http.Request) {
+	if event := m.findEvent(w, r); event != nil {
+		writeGoogleJSON(w, r, http.StatusOK, event)
+	}
+}
+
+// updateEvent applies the fields present in the body.
+func (m *MockGoogle) updateEvent(w http.ResponseWriter, r *http.Request) {
+	event := m.findEvent(w, r)
+	if event == nil {
+		return
+	}
+	var in googleEvent
+	if !m.decode(w, r, &in) {
+		return
+	}
+	event.Summary = cmp.Or(in.Summary, event.Summary)
+	event.Description = cmp.Or(in.Description, event.Description)
+	event.Start = cmp.Or(in.Start, event.Start)
+	event.End = cmp.Or(in.End, event.End)
+	event.Source = cmp.Or(in.Source, event.Source)
+	writeGoogleJSON(w, r, http.StatusOK, event)
+}
+
+func (m *MockGoogle) deleteEvent(w http.ResponseWriter, r *http.Request) {
+	if event := m.findEvent(w, r); event != nil {
+		delete(m.events, r.PathValue("calendar")+"/"+event.ID)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func (m *MockGoogle) findEvent(w http.ResponseWriter, r *http.Request) *googleEvent {
+	event, ok := m.events[r.PathValue("calendar")+"/"+r.PathValue("event")]
+	if !ok {
+		writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Not Found")
+		return nil
+	}
+	return event
+}
+
+// mockMessages is the mock's inbox, newest first.
+var mockMessages = []struct{ ID, Thread, From, Subject string }{
+	{"mockmsg2", "mockthread2", "Alice <alice@example.com>", "Review the quarterly report"},
+	{"mockmsg1", "mockthread1", "Bob <bob@example.com>", "Lunch on Friday?"},
+}
+
+// listMessages matches q's words, ignoring any from: or subject: before
+// them, against each message's sender and subject.
+func (m *MockGoogle) listMessages(w http.ResponseWriter, r *http.Request) {
+	words := strings.Fields(strings.ToLower(r.URL.Query().Get("q")))
+	messages := []map[string]string{}
+	for _, msg := range mockMessages {
+		text := strings.ToLower(msg.From + " " + msg.Subject)
+		if !slices.ContainsFunc(words, func(word string) bool {
+			if _, value, ok := strings.Cut(word, ":"); ok {
+				word = value
+			}
+			return !strings.Contains(text, word)
+		}) {
+			messages = append(messages, map[string]string{"id": msg.ID, "threadId": msg.Thread})
+		}
+	}
+	writeGoogleJSON(w, r, http.StatusOK, map[string]any{"messages": messages, "resultSizeEstimate": len(messages)})
+}
+
+func (m *MockGoogle) getMessage(w http.ResponseWriter, r *http.Request) {
+	for _, msg := range mockMessages {
+		if msg.ID == r.PathValue("message") {
+			writeGoogleJSON(w, r, http.StatusOK, map[string]any{
+				"id":       msg.ID,
+				"threadId": msg.Thread,
+				"payload": map[string]any{"headers": []map[string]string{
+					{"name": "From", "value": msg.From},
+					{"name": "Subject", "value": msg.Subject},
+				}},
+			})
+			return
+		}
+	}
+	writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Requested entity was not found.")
+}
+
+func (m *MockGoogle) newList(title string) *mockList {
+	m.seq++
+	list := &mockList{TaskList: api.TaskList{ID: fmt.Sprintf("mock-list-%d", m.seq), Title: title}}
+	m.touch(&list.Updated, &list.ETag)
+	m.lists = append(m.lists, list)
+	return list
+}
+
+// addTask puts a new task first among the top-level tasks.
+func (m *MockGoogle) addTask(list *mockList, task *api.Task) {
+	m.seq++
+	task.ID = fmt.Sprintf("mock-task-%d", m.seq)
+	m.touch(&task.Updated, &task.ETag)
+	list.tasks = slices.Insert(list.tasks, 0, task)
+	m.renumber(list)
+}
+
+// place moves task under parent, directly after previous (or first).
+func (m *MockGoogle) place(w http.ResponseWriter, list *mockList, task *api.Task, parent, previous string) bool {
+	for _, id := range []string{parent, previous} {
+		if id != "" && !slices.ContainsFunc(list.tasks, func(t *api.Task) bool { return t.ID == id }) {
+			writeGoogleError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid parent or previous task ID.")
+			return false
+		}
+	}
+
+	list.tasks = slices.DeleteFunc(list.tasks, func(t *api.Task) bool { return t == task })
+	task.Parent = parent
+	at := 0
+	if previous != "" {
+		at = slices.IndexFunc(list.tasks, func(t *api.Task) bool { return t.ID == previous }) + 1
+	} else if parent != "" {
+		at = slices.IndexFunc(list.tasks, func(t *api.Task) bool { return t.ID == parent }) + 1
+	}
+	list.tasks = slices.Insert(list.tasks, at, task)
+	m.touch(&task.Updated, &task.ETag)
+	m.renumber(list)
+	return true
+}
+
+// renumber gives tasks positions in list order, as zero-padded strings like
+// Google's, so sorting siblings by position matches display order.
+func (m *MockGoogle) renumber(list *mockList) {
+	for i, task := range list.tasks {
+		task.Position = fmt.Sprintf("%020d", i)
+	}
+}
+
+func (m *MockGoogle) touch(updated, etag *string) {
+	now := time.Now().UTC()
+	*updated = now.Format(time.RFC3339Nano)
+	*etag = fmt.Sprintf(`"%d"`, now.UnixNano())
+}
+
+func (m *MockGoogle) findList(w http.ResponseWriter, r *http.Request) *mockList {
+	id := r.PathValue("list")
+	for _, list := range m.lists {
+		if list.ID == id || id == "@default" {
+			return list
+		}
+	}
+	writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task list not found.")
+	return nil
+}
+
+func (m *MockGoogle) findTask(w http.ResponseWriter, r *http.Request) (*mockList, *api.Task) {
+	list := m.findList(w, r)
+	if list == nil {
+		return nil, nil
+	}
+	id := r.PathValue("task")
+	for _, task := range list.tasks {
+		if task.ID == id {
+			return list, task
+		}
+	}
+	writeGoogleError(w, http.StatusNotFound, "NOT_FOUND", "Task not found.")
+	return nil, nil
+}
+
+func (m *MockGoogle) decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeGoogleError(w, http.StatusBadRequest, "INVALID_ARGUMENT", "Invalid JSON payload received.")
+		return false
+	}
+	return true
+}
+
+// writePage paginates items with maxResults and an offset pageToken.
+func writePage[T any](w http.ResponseWriter, r *http.Request, kind string, items []T) {
+	start, _ := strconv.Atoi(r.URL.Query().Get("pageToken"))
+	start = min(max(start, 0), len(items))
+	size, err := strconv.Atoi(r.URL.Query().Get("maxResults"))
+	if err != nil || size <= 0 {
+		size = 100
+	}
+	end := min(start+size, len(items))
+
+	page := map[string]any{"kind": kind, "items": items[start:end]}
+	if end < len(items) {
+		page["nextPageToken"] = strconv.Itoa(end)
+	}
+	writeGoogleJSON(w, r, http.StatusOK, page)
+}
+
+// writeJSON responds with v, with an ETag over the body so conditional
+// requests get 304 Not Modified like they do from Google.
+func writeGoogleJSON(w http.ResponseWriter, r *http.Request, status int, v any) {
+	body, err := json.Marshal(v)
+	if err != nil {
+		writeGoogleError(w, http.StatusInternalServerError, "INTERNAL", err.Error())
+		return
+	}
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+
+	w.Header().Set("ETag", etag)
+	if r.Method == "GET" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeError responds in the Google API error format.
+func writeGoogleError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"error": map[string]any{"code": status, "message": message, "status": code},
+	})
+}
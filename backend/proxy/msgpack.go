@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+)
+
+// Neovim speaks MessagePack-RPC. The proxy only sends notifications, so it
+// only needs to encode, and only the values JSON decodes to.
+
+// msgpackNotification encodes an RPC notification: [2, method, params].
+func msgpackNotification(method string, params ...any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := msgpackEncode(&buf, []any{2, method, params}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// msgpackValue turns v into the nil, bool, float64, string, []any and
+// map[string]any values msgpackEncode takes, by way of its JSON.
+func msgpackValue(v any) (any, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var value any
+	return value, json.Unmarshal(data, &value)
+}
+
+func msgpackEncode(buf *bytes.Buffer, v any) error {
+	switch v := v.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case int:
+		msgpackInt(buf, int64(v))
+	case float64:
+		// JSON numbers are floats; whole ones go as integers, which is what
+		// Lua on the other end expects of counts and lines
+		if v == math.Trunc(v) && math.Abs(v) < 1<<53 {
+			msgpackInt(buf, int64(v))
+			break
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, v)
+	case string:
+		msgpackHeader(buf, len(v), 0xa0, 31, 0xd9, 0xda, 0xdb)
+		buf.WriteString(v)
+	case []any:
+		msgpackHeader(buf, len(v), 0x90, 15, 0, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := msgpackEncode(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]any:
+		msgpackHeader(buf, len(v), 0x80, 15, 0, 0xde, 0xdf)
+		// Sorted, so the same value always encodes the same way
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, strings.Compare)
+		for _, key := range keys {
+			msgpackEncode(buf, key)
+			if err := msgpackEncode(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("msgpack: cannot encode %T", v)
+	}
+	return nil
+}
+
+func msgpackInt(buf *bytes.Buffer, n int64) {
+	switch {
+	case n >= 0 && n <= 127:
+		buf.WriteByte(byte(n))
+	case n < 0 && n >= -32:
+		buf.WriteByte(byte(int8(n)))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+// msgpackHeader writes the header of a string, array or map of n items:
+// fixed (fix|n) up to fixMax, else with an 8-bit (when the type has one),
+// 16-bit or 32-bit length.
+func msgpackHeader(buf *bytes.Buffer, n int, fix byte, fixMax int, len8, len16, len32 byte) {
+	switch {
+	case n <= fixMax:
+		buf.WriteByte(fix | byte(n))
+	case len8 != 0 && n <= math.MaxUint8:
+		buf.WriteByte(len8)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(len16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(len32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
@@ -0,0 +1,293 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// operation documents one endpoint. Request and Response are zero values of
+// the Go types the handler decodes and encodes, so the schemas in the spec
+// come from the same definitions as the wire format.
+type operation struct {
+	Method      string
+	Path        string
+	Summary     string
+	Auth        string // "", "bearer" (Google access token) or "admin"
+	Query       []string
+	Request     any
+	RequestType string // content type of Request, default application/json
+	Response    any    // ignored when ContentType is set
+	ContentType string // content type of Response, default application/json
+}
+
+// apiOperations lists every endpoint served by routes, except the mock
+// provider's copy of the Tasks API.
+var apiOperations = []operation{
+	{Method: "POST", Path: "/auth/start", Summary: "Start an authorization: returns the Google URL to open and the state to poll", Response: api.AuthStartResponse{}},
+	{Method: "POST", Path: "/auth/token", Summary: "Exchange an authorization code for tokens", Request: api.TokenRequest{}, Response: api.GoogleToken{}},
+	{Method: "POST", Path: "/auth/session", Summary: "Open a session as one of tenants.users (multi-tenant mode only)", Request: api.SessionRequest{}, Response: api.SessionResponse{}},
+	{Method: "POST", Path: "/auth/refresh", Summary: "Refresh an access token", Request: api.RefreshRequest{}, Response: api.GoogleToken{}},
+	{Method: "GET", Path: "/auth/callback", Summary: "OAuth redirect target; completes the authorization in the background", Query: []string{"code", "state", "error"}, ContentType: "text/html"},
+	{Method: "GET", Path: "/auth/poll/{state}", Summary: "Poll for the tokens of a completed authorization; they are returned once", Response: api.PollResponse{}},
+	{Method: "GET", Path: "/health", Summary: "Health check", Response: api.HealthResponse{}},
+	{Method: "GET", Path: "/openapi.json", Summary: "This specification", Response: map[string]any{}},
+	{Method: "GET", Path: "/", Summary: "Setup page, only while no OAuth client is configured", Query: []string{"code"}, ContentType: "text/html"},
+	{Method: "POST", Path: "/setup", Summary: "Save the OAuth client entered on the setup page", Request: url.Values{}, RequestType: "application/x-www-form-urlencoded", ContentType: "text/html"},
+	{Method: "GET", Path: "/ui", Summary: "Dashboard of lists, tasks, sign-in state and sync status, backed by the API", ContentType: "text/html"},
+	{Method: "GET", Path: "/share/{token}", Summary: "A shared snapshot of a list, for anyone with the link: a page, or this JSON with format=json", Query: []string{"format"}, Response: api.SharedList{}},
+	{Method: "GET", Path: "/api/lists", Summary: "Task lists of the authenticated user", Auth: "bearer", Query: []string{"maxResults", "pageToken"}, Response: api.TaskListsPage{}},
+	{Method: "GET", Path: "/api/lists/{list}/tasks", Summary: "Tasks in one list: Google's pages (maxResults, pageToken) or the proxy's cached ones (limit, cursor), sorted by due, alpha, position or updated", Auth: "bearer", Query: []string{"completedMax", "completedMin", "dueMax", "dueMin", "updatedMin", "maxResults", "pageToken", "limit", "cursor", "sort", "order", "showCompleted", "showDeleted", "showHidden"}, Response: api.TasksPage{}},
+	{Method: "POST", Path: "/api/lists/{list}/clear", Summary: "Archive a list's completed tasks locally, then clear them from the list; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Response: api.ClearResponse{}},
+	{Method: "POST", Path: "/api/tasklists/{list}/share", Summary: "Share a read-only snapshot of a list through a link that needs no Google access; expires is optional", Auth: "bearer", Request: api.ShareRequest{}, Response: api.ShareResponse{}},
+	{Method: "GET", Path: "/api/shares", Summary: "Shared snapshots whose links still work, newest first", Auth: "bearer", Response: api.SharesResponse{}},
+	{Method: "DELETE", Path: "/api/shares/{id}", Summary: "Revoke a shared snapshot's link", Auth: "bearer", Response: api.RevokeShareResponse{}},
+	{Method: "GET", Path: "/api/archive", Summary: "Completed tasks archived when their lists were cleared, by completion date", Auth: "bearer", Query: []string{"from", "to", "tz", "list", "limit"}, Response: api.ArchiveResponse{}},
+	{Method: "GET", Path: "/api/stats/trends", Summary: "Tasks completed per day, average task age, overdue rate and per-list breakdowns over a range of days", Auth: "bearer", Query: []string{"range", "tz"}, Response: api.TrendsResponse{}},
+	{Method: "GET", Path: "/api/usage", Summary: "Calls made to Google since the proxy started, by endpoint, hour and day, and the Tasks API calls left in today's quota", Auth: "bearer", Response: api.UsageResponse{}},
+	{Method: "GET", Path: "/api/backup/status", Summary: "Scheduled backup settings, the last attempt and the backups kept", Auth: "bearer", Response: api.BackupStatusResponse{}},
+	{Method: "GET", Path: "/api/backup/list", Summary: "Backups kept, newest first, with the lists each holds for the caller", Auth: "bearer", Response: api.BackupListResponse{}},
+	{Method: "POST", Path: "/api/backup/{id}/restore", Summary: "Re-create a backup's lists and tasks that no longer exist, optionally one list or task; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.RestoreRequest{}, Response: api.RestoreResponse{}},
+	{Method: "GET", Path: "/api/tasks", Summary: "Every list with its tasks, optionally sorted by due, alpha, position or updated", Auth: "bearer", Query: []string{"showCompleted", "showHidden", "dueMin", "dueMax", "updatedMin", "sort", "order"}, Response: api.AllTasksResponse{}},
+	{Method: "GET", Path: "/api/agenda", Summary: "Overdue, due today, due this week and recently completed tasks across lists", Auth: "bearer", Query: []string{"date", "tz"}, Response: api.AgendaResponse{}},
+	{Method: "GET", Path: "/api/smart", Summary: "The smart lists (today, upcoming, overdue, no-date) with their task counts", Auth: "bearer", Query: []string{"date", "tz"}, Response: api.SmartListsResponse{}},
+	{Method: "GET", Path: "/api/smart/{list}", Summary: "The open tasks of one smart list across every real list, each with the list it came from", Auth: "bearer", Query: []string{"date", "tz", "sort", "order"}, Response: api.SmartListResponse{}},
+	{Method: "GET", Path: "/api/tasks/quickfix", Summary: "Open tasks as Neovim quickfix entries, ready for setqflist()", Auth: "bearer", Query: []string{"filter", "date", "tz"}, Response: api.QuickfixResponse{}},
+	{Method: "GET", Path: "/api/search/fuzzy", Summary: "fzf-style fuzzy search of task titles across lists, best first, with matched positions", Auth: "bearer", Query: []string{"q", "limit", "cursor"}, Response: api.FuzzySearchResponse{}},
+	{Method: "GET", Path: "/api/duplicates", Summary: "Groups of likely duplicate tasks by normalized-title similarity, within and across lists, the one to keep first", Auth: "bearer", Query: []string{"threshold", "list", "across", "completed"}, Response: api.DuplicatesResponse{}},
+	{Method: "POST", Path: "/api/duplicates/merge", Summary: "Merge duplicates into one task, combining notes and due dates and moving subtasks, then delete them; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.MergeDuplicatesRequest{}, Response: api.MergeDuplicatesResponse{}},
+	{Method: "POST", Path: "/api/tasks/{task}/snooze", Summary: "Push a task's due date back and hold its reminders until the snooze ends; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.SnoozeRequest{}, Response: api.SnoozeResponse{}},
+	{Method: "POST", Path: "/api/quickadd", Summary: "Parse a line like \"pay rent tomorrow 9am #finance !p1 @personal\" into a task and create it; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.QuickAddRequest{}, Response: api.QuickAddResponse{}},
+	{Method: "POST", Path: "/api/tasks/{task}/schedule", Summary: "Time-block a task with a Google Calendar event, recorded in its metadata; needs the calendar.events scope; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.ScheduleRequest{}, Response: api.ScheduleResponse{}},
+	{Method: "POST", Path: "/api/tasks/from-email", Summary: "Create a task from a Gmail message, by ID or the newest match of a search, with the sender and a link to the thread in its notes; needs the gmail.readonly scope; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.FromEmailRequest{}, Response: api.FromEmailResponse{}},
+	{Method: "POST", Path: "/api/import/todos", Summary: "Create or update one task per scanned TODO/FIXME comment, found again on later scans by a fingerprint; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.ImportTodosRequest{}, Response: api.ImportTodosResponse{}},
+	{Method: "GET", Path: "/api/tasks/{task}/links", Summary: "A task's links: Google's read-only ones and the URLs and files attached through the proxy", Auth: "bearer", Query: []string{"list"}, Response: api.TaskLinksResponse{}},
+	{Method: "POST", Path: "/api/tasks/{task}/links", Summary: "Attach a URL or file path to a task, kept in the task's metadata", Auth: "bearer", Request: api.AddLinkRequest{}, Response: api.TaskLinksResponse{}},
+	{Method: "DELETE", Path: "/api/tasks/{task}/links/{link}", Summary: "Remove an attached link by its ID", Auth: "bearer", Query: []string{"list"}, Response: api.TaskLinksResponse{}},
+	{Method: "GET", Path: "/api/tasks/{task}/location", Summary: "Where in the code a task was created: file, line, repo and commit, or null", Auth: "bearer", Query: []string{"list"}, Response: api.TaskLocationResponse{}},
+	{Method: "PUT", Path: "/api/tasks/{task}/location", Summary: "Set a task's code location, kept in the task's metadata", Auth: "bearer", Request: api.SetLocationRequest{}, Response: api.TaskLocationResponse{}},
+	{Method: "DELETE", Path: "/api/tasks/{task}/location", Summary: "Forget a task's code location", Auth: "bearer", Query: []string{"list"}, Response: api.TaskLocationResponse{}},
+	{Method: "GET", Path: "/api/tasks/{task}/history", Summary: "A task's recorded field-level changes, made through the proxy or found by change polling, newest first", Auth: "bearer", Response: api.TaskHistoryResponse{}},
+	{Method: "POST", Path: "/api/batch", Summary: "Apply creates, updates, deletes and moves in order; dry_run previews them", Auth: "bearer", Query: []string{"dry_run"}, Request: api.BatchRequest{}, Response: api.BatchResponse{}},
+	{Method: "POST", Path: "/api/tasks/bulk-status", Summary: "Complete or reopen many tasks concurrently, with a result per task; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Request: api.BulkStatusRequest{}, Response: api.BulkStatusResponse{}},
+	{Method: "POST", Path: "/api/reconcile", Summary: "Three-way merge a buffer's edits of one list (against the base it was rendered from) with the list's current state; applies clean changes and returns conflicts", Auth: "bearer", Query: []string{"dry_run"}, Request: api.ReconcileRequest{}, Response: api.ReconcileResponse{}},
+	{Method: "GET", Path: "/api/undo", Summary: "The session's undoable mutations, newest first, with the changes that revert them", Auth: "bearer", Response: api.UndoHistoryResponse{}},
+	{Method: "POST", Path: "/api/undo", Summary: "Revert the session's latest mutation made through the proxy; dry_run previews it", Auth: "bearer", Query: []string{"dry_run"}, Response: api.UndoResponse{}},
+	{Method: "GET", Path: "/api/events", Summary: "Server-sent events for the authenticated user: \"reminder\" events carry a Reminder, \"change\" events a TaskEvent", Auth: "bearer", ContentType: "text/event-stream"},
+	{Method: "POST", Path: "/admin/reload", Summary: "Reload configuration without restarting", Auth: "admin", Response: api.ReloadResponse{}},
+	{Method: "GET", Path: "/admin/update-check", Summary: "Compare the running version with the latest release", Auth: "admin", Response: api.UpdateCheckResponse{}},
+	{Method: "GET", Path: "/admin/metrics", Summary: "Runtime and cache counters (expvar)", Auth: "admin", Response: map[string]any{}},
+	{Method: "GET", Path: "/admin/sessions", Summary: "Open sessions and the accounts with a stored Google token, without token material", Auth: "admin", Response: api.SessionsResponse{}},
+	{Method: "DELETE", Path: "/admin/sessions/{id}", Summary: "End one session and its event streams (multi-tenant mode only)", Auth: "admin", Response: api.ExpireSessionResponse{}},
+	{Method: "POST", Path: "/admin/accounts/{user}/reauth", Summary: "Revoke an account's Google grant, delete its stored token and end its sessions, so it must authorize again", Auth: "admin", Response: api.ReauthResponse{}},
+}
+
+var pathParam = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPISpec builds the OpenAPI 3.1 document once; it only depends on
+// types and the version.
+var openAPISpec = sync.OnceValues(func() ([]byte, error) {
+	g := schemaGenerator{schemas: make(map[string]any)}
+	errorResponse := map[string]any{
+		"description": "Error envelope",
+		"content":     map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeFor[api.ErrorResponse]())}},
+	}
+	g.schemas["APIError"].(map[string]any)["properties"].(map[string]any)["code"] = map[string]any{"type": "string", "enum": api.ErrorCodes}
+
+	// The data of /api/events, which has no JSON response to hang them on
+	g.schema(reflect.TypeFor[api.Reminder]())
+	g.schema(reflect.TypeFor[api.TaskEvent]())
+	g.schemas["TaskEvent"].(map[string]any)["properties"].(map[string]any)["type"] = map[string]any{"type": "string", "enum": api.EventTypes}
+
+	paths := make(map[string]map[string]any)
+	for _, op := range apiOperations {
+		var params []any
+		for _, m := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+			params = append(params, map[string]any{"name": m[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"}})
+		}
+		for _, name := range op.Query {
+			params = append(params, map[string]any{"name": name, "in": "query", "schema": map[string]any{"type": "string"}})
+		}
+		if strings.HasPrefix(op.Path, "/api/") {
+			params = append(params,
+				map[string]any{"$ref": "#/components/parameters/Timeout"},
+				map[string]any{"$ref": "#/components/parameters/TimeoutQuery"})
+		}
+
+		success := map[string]any{"description": "Success"}
+		if op.ContentType != "" {
+			success["content"] = map[string]any{op.ContentType: map[string]any{"schema": map[string]any{"type": "string"}}}
+		} else {
+			success["content"] = map[string]any{"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(op.Response))}}
+		}
+		o := map[string]any{
+			"summary":     op.Summary,
+			"operationId": operationID(op),
+			"responses":   map[string]any{"200": success, "default": map[string]any{"$ref": "#/components/responses/Error"}},
+		}
+		if params != nil {
+			o["parameters"] = params
+		}
+		if op.Request != nil {
+			o["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{cmp.Or(op.RequestType, "application/json"): map[string]any{"schema": g.schema(reflect.TypeOf(op.Request))}},
+			}
+		}
+		switch op.Auth {
+		case "":
+		case "bearer":
+			// A multi-tenant proxy also accepts a session with a stored token
+			o["security"] = []any{map[string]any{"bearer": []any{}}, map[string]any{"session": []any{}}}
+		default:
+			o["security"] = []any{map[string]any{op.Auth: []any{}}}
+		}
+
+		if paths[op.Path] == nil {
+			paths[op.Path] = make(map[string]any)
+		}
+		paths[op.Path][strings.ToLower(op.Method)] = o
+	}
+
+	spec := map[string]any{
+		"openapi": "3.1.0",
+		"info": map[string]any{
+			"title":       "gtask auth proxy",
+			"version":     version,
+			"description": "OAuth and Google Tasks proxy for gtask.nvim. Every error uses the Error response.",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas":   g.schemas,
+			"responses": map[string]any{"Error": errorResponse},
+			"parameters": map[string]any{
+				"Timeout": map[string]any{"name": "X-Timeout", "in": "header", "schema": map[string]any{"type": "string"},
+					"description": "Deadline for the request, like 30s or a number of seconds, at most server.max_timeout; Google calls still pending then fail with timeout"},
+				"TimeoutQuery": map[string]any{"name": "timeout", "in": "query", "schema": map[string]any{"type": "string"},
+					"description": "X-Timeout, for clients that cannot set headers"},
+			},
+			"securitySchemes": map[string]any{
+				"bearer": map[string]any{"type": "http", "scheme": "bearer", "description": "Google access token of the user"},
+				"admin":  map[string]any{"type": "http", "scheme": "bearer", "description": "admin.token from the configuration"},
+				"session": map[string]any{"type": "apiKey", "in": "header", "name": api.SessionHeader,
+					"description": "Session from /auth/session; required on /auth and /api requests in multi-tenant mode"},
+			},
+		},
+	}
+	return json.MarshalIndent(spec, "", "  ")
+})
+
+// operationID is the method and the path's fixed segments in camel case,
+// e.g. getApiListsTasks.
+func operationID(op operation) string {
+	id := strings.ToLower(op.Method)
+	parts := strings.FieldsFunc(pathParam.ReplaceAllString(op.Path, ""), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(parts) == 0 {
+		parts = []string{"root"}
+	}
+	for _, part := range parts {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// schemaGenerator converts Go types to JSON Schema the way encoding/json
+// marshals them. Named structs become components referenced by name.
+type schemaGenerator struct {
+	schemas map[string]any
+}
+
+func (g *schemaGenerator) schema(t reflect.Type) map[string]any {
+	if t == nil {
+		return map[string]any{}
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if _, ok := g.schemas[t.Name()]; !ok {
+			g.schemas[t.Name()] = nil // placeholder for recursive types
+			g.schemas[t.Name()] = g.object(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default: // interfaces
+		return map[string]any{}
+	}
+}
+
+// object describes a struct's JSON fields. Embedded structs are flattened,
+// and fields without omitempty are required.
+func (g *schemaGenerator) object(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+	var required []string
+	var add func(t reflect.Type)
+	add = func(t reflect.Type) {
+		for i := range t.NumField() {
+			field := t.Field(i)
+			tag := field.Tag.Get("json")
+			if tag == "-" {
+				continue
+			}
+			if field.Anonymous && tag == "" {
+				add(field.Type)
+				continue
+			}
+			if !field.IsExported() {
+				continue
+			}
+			name, options, _ := strings.Cut(tag, ",")
+			if name == "" {
+				name = field.Name
+			}
+			properties[name] = g.schema(field.Type)
+			if !strings.Contains(options, "omitempty") {
+				required = append(required, name)
+			}
+		}
+	}
+	add(t)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if required != nil {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// GET /openapi.json - OpenAPI description of this API
+func (s *Server) handleOpenAPI(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	spec, err := openAPISpec()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to build the specification")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(spec)
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+)
+
+// proxySchemes are the outbound proxies http.Transport can dial. socks5
+// resolves names on the proxy, like socks5h.
+var proxySchemes = []string{"http", "https", "socks5", "socks5h"}
+
+// parseProxyURL checks an upstream.proxy value.
+func parseProxyURL(v string) (*url.URL, error) {
+	u, err := url.Parse(v)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.Contains(proxySchemes, u.Scheme) || u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy %q, expected a URL like http://host:port or socks5://[user:password@]host:port", v)
+	}
+	return u, nil
+}
+
+// UseProxy sends every request through the proxy at proxyURL, except to
+// the hosts in NO_PROXY and to loopback addresses. An empty proxyURL keeps
+// HTTP_PROXY, HTTPS_PROXY and NO_PROXY. It must be called before the client
+// is used.
+func (c *UpstreamClient) UseProxy(proxyURL string) error {
+	if proxyURL == "" {
+		return nil
+	}
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		return err
+	}
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("upstream transport %T cannot use a proxy", c.client.Transport)
+	}
+	noProxy := firstEnv("NO_PROXY", "no_proxy")
+	transport.Proxy = func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL, noProxy) {
+			return nil, nil
+		}
+		return u, nil
+	}
+	return nil
+}
+
+// UseCA trusts the PEM certificates in caFile for TLS connections, on top
+// of the system's trusted roots unless systemRoots is false. An empty caFile
+// keeps the system roots alone. It must be called before the client is
+// used.
+func (c *UpstreamClient) UseCA(caFile string, systemRoots bool) error {
+	if caFile == "" {
+		if !systemRoots {
+			return fmt.Errorf("upstream.system_roots is false but upstream.ca_file is not set, so no server would be trusted")
+		}
+		return nil
+	}
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return err
+	}
+	pool := x509.NewCertPool()
+	if systemRoots {
+		if pool, err = x509.SystemCertPool(); err != nil {
+			return fmt.Errorf("reading the system's trusted roots: %w", err)
+		}
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return fmt.Errorf("%s: no PEM certificates found", caFile)
+	}
+	transport, ok := c.client.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("upstream transport %T cannot use a CA file", c.client.Transport)
+	}
+	transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	return nil
+}
+
+// firstEnv returns the first of the environment variables that is set.
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// bypassProxy reports whether u is reached directly: it is a loopback
+// address or matches noProxy, a NO_PROXY list. Entries are "*", host names
+// (matching their subdomains too, with or without a leading dot), IP
+// addresses and CIDR ranges, each optionally with a port.
+func bypassProxy(u *url.URL, noProxy string) bool {
+	host, port := u.Hostname(), u.Port()
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	if ip != nil && ip.IsLoopback() {
+		return true
+	}
+	for _, entry := range strings.Split(noProxy, ",") {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if entry == "*" {
+			return true
+		}
+		if _, network, err := net.ParseCIDR(entry); err == nil {
+			if ip != nil && network.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if h, p, err := net.SplitHostPort(entry); err == nil {
+			if p != port {
+				continue
+			}
+			entry = h
+		}
+		if entryIP := net.ParseIP(entry); entryIP != nil {
+			if ip != nil && entryIP.Equal(ip) {
+				return true
+			}
+			continue
+		}
+		entry = strings.TrimPrefix(entry, ".")
+		host := strings.ToLower(host)
+		if host == entry || strings.HasSuffix(host, "."+entry) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,274 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// quickAdd is a quick-add line taken apart.
+type quickAdd struct {
+	Title    string
+	Due      time.Time // zero when undated
+	Time     string    // HH:MM, or ""
+	Tags     []string
+	Priority int
+	List     string // the @name, or ""
+}
+
+// quickAddFillers are dropped when they come right before a date or time,
+// so "pay rent by friday at 9am" becomes "pay rent".
+var quickAddFillers = map[string]bool{"on": true, "by": true, "due": true, "at": true}
+
+// parseQuickTime reads 9am, 9:30pm, 12am or 21:00 as HH:MM.
+func parseQuickTime(word string) (string, bool) {
+	for _, layout := range []string{"3pm", "3:04pm", "15:04"} {
+		if t, err := time.Parse(layout, word); err == nil {
+			return t.Format("15:04"), true
+		}
+	}
+	return "", false
+}
+
+// parseQuickAdd takes apart a quick-add line. Words starting with # are
+// tags, !p1 to !p4 (or !1 to !4) the priority and @name the list. The first
+// word parseDate accepts is the due date and the first time (9am, 21:00) its
+// time, which without a date means today. A weekday's abbreviation is also
+// a word ("buy sun cream"), so it is only a date after "on" or "due" or at
+// the end of the title. Everything else is the title.
+func parseQuickAdd(text string, now time.Time) (quickAdd, error) {
+	var q quickAdd
+	var title []string
+	abbreviated := -1 // index in title of the last weekday abbreviation, the date if it ends the title
+	var abbreviatedDay time.Time
+	for _, word := range strings.Fields(text) {
+		lower := strings.ToLower(word)
+		switch {
+		case len(word) > 1 && word[0] == '#':
+			q.Tags = append(q.Tags, word[1:])
+			continue
+		case len(word) > 1 && word[0] == '@':
+			if q.List != "" {
+				return quickAdd{}, fmt.Errorf("more than one list: @%s and %s", q.List, word)
+			}
+			q.List = word[1:]
+			continue
+		case len(word) > 1 && word[0] == '!':
+			n, err := strconv.Atoi(strings.TrimPrefix(lower[1:], "p"))
+			if err != nil || n < 1 || n > 4 {
+				return quickAdd{}, fmt.Errorf("unrecognised priority %q, use !p1 to !p4", word)
+			}
+			q.Priority = n
+			continue
+		}
+
+		if q.Due.IsZero() {
+			if day, err := parseDate(lower, now); err == nil {
+				if weekdayAbbreviation(lower) && !afterDueWord(title) {
+					abbreviated, abbreviatedDay = len(title), day
+					title = append(title, word)
+					continue
+				}
+				q.Due = day
+				title = dropFiller(title)
+				continue
+			}
+		}
+		if q.Time == "" {
+			if hhmm, ok := parseQuickTime(lower); ok {
+				q.Time = hhmm
+				title = dropFiller(title)
+				continue
+			}
+		}
+		title = append(title, word)
+	}
+
+	if q.Due.IsZero() && abbreviated >= 0 && abbreviated == len(title)-1 {
+		q.Due = abbreviatedDay
+		title = dropFiller(title[:abbreviated])
+	}
+	q.Title = strings.Join(title, " ")
+	if q.Title == "" {
+		return quickAdd{}, errors.New("the text has no title left once dates, tags, priority and list are taken out")
+	}
+	if q.Time != "" && q.Due.IsZero() {
+		q.Due, _ = parseDate("today", now)
+	}
+	return q, nil
+}
+
+// weekdayAbbreviation reports whether word is the three-letter name of a
+// weekday, like "sun" or "wed".
+func weekdayAbbreviation(word string) bool {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if word == strings.ToLower(day.String()[:3]) {
+			return true
+		}
+	}
+	return false
+}
+
+// afterDueWord reports whether title ends with "on" or "due", after which
+// a weekday abbreviation is taken for a date.
+func afterDueWord(title []string) bool {
+	n := len(title)
+	return n > 0 && (strings.EqualFold(title[n-1], "on") || strings.EqualFold(title[n-1], "due"))
+}
+
+// dropFiller removes a trailing filler word ("on", "by", ...) from title.
+func dropFiller(title []string) []string {
+	if n := len(title); n > 0 && quickAddFillers[strings.ToLower(title[n-1])] {
+		return title[:n-1]
+	}
+	return title
+}
+
+// notes records what Google Tasks has no field for, or "".
+func (q quickAdd) notes() string {
+	var lines []string
+	if q.Time != "" {
+		lines = append(lines, "Time: "+q.Time)
+	}
+	if q.Priority > 0 {
+		lines = append(lines, "Priority: p"+strconv.Itoa(q.Priority))
+	}
+	if len(q.Tags) > 0 {
+		lines = append(lines, "Tags: #"+strings.Join(q.Tags, " #"))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// findList returns the list a quick-add line names: the one whose title
+// matches name ignoring case, with - and _ standing for spaces.
+func findList(lists []api.TaskList, name string) (api.TaskList, bool) {
+	name = strings.NewReplacer("-", " ", "_", " ").Replace(name)
+	for _, list := range lists {
+		if strings.EqualFold(list.Title, name) {
+			return list, true
+		}
+	}
+	return api.TaskList{}, false
+}
+
+// listByID returns the list with id, or the first list when id is empty.
+func listByID(lists []api.TaskList, id string) (api.TaskList, bool) {
+	for _, list := range lists {
+		if id == "" || list.ID == id {
+			return list, true
+		}
+	}
+	return api.TaskList{}, false
+}
+
+// writeListNotFound reports that listByID found nothing for id.
+func writeListNotFound(w http.ResponseWriter, id string) {
+	if id == "" {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "The account has no task lists")
+		return
+	}
+	writeError(w, http.StatusNotFound, api.CodeNotFound, "List not found")
+}
+
+// POST /api/quickadd - Parse one line into a task and create it
+//
+// "pay rent tomorrow 9am #finance !p1 @personal" creates "pay rent" due
+// tomorrow in the list titled Personal, with the time, priority and tags in
+// its notes. A code location sent along is kept in the new task's metadata.
+// ?dry_run=1 reports the parse and the insert without sending it.
+func (s *Server) handleQuickAdd(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.QuickAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	now := time.Now()
+	if req.TZ != "" {
+		loc, err := parseTimeZone(req.TZ)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+			return
+		}
+		now = now.In(loc)
+	}
+	if req.Location != nil {
+		if err := cleanLocation(req.Location); err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+			return
+		}
+	}
+	parsed, err := parseQuickAdd(req.Text, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.ListTaskLists(r.Context(), token)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	var list api.TaskList
+	switch {
+	case parsed.List != "":
+		if list, ok = findList(lists, parsed.List); !ok {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, "No list is titled "+parsed.List)
+			return
+		}
+	default:
+		if list, ok = listByID(lists, req.List); !ok {
+			writeListNotFound(w, req.List)
+			return
+		}
+	}
+
+	task := api.Task{Title: parsed.Title, Notes: parsed.notes(), Status: "needsAction"}
+	resp := api.QuickAddResponse{
+		Title:    parsed.Title,
+		Time:     parsed.Time,
+		Tags:     parsed.Tags,
+		Priority: parsed.Priority,
+		List:     list,
+		DryRun:   dryRun(r),
+	}
+	if resp.Tags == nil {
+		resp.Tags = []string{}
+	}
+	if !parsed.Due.IsZero() {
+		task.Due = googleDue(parsed.Due)
+		resp.Due = parsed.Due.Format(time.DateOnly)
+	}
+	resp.Request = InsertWrite(list.ID, task)
+
+	if !resp.DryRun {
+		task, steps, err := s.writeUndoable(r, token, resp.Request)
+		if err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		resp.Task = task
+		s.recordUndo(r, token, "quick add of "+parsed.Title, steps)
+		if req.Location != nil {
+			if _, err := s.metadataStore(tenantUser(r.Context())).Update(task.ID, func(m *api.TaskMetadata) { m.Location = req.Location }); err != nil {
+				log.Printf("Recording task location: %v", err)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
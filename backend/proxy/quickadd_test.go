@@ -0,0 +1,100 @@
+package proxy
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestParseQuickAdd(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC) // a Friday
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		text     string
+		title    string
+		due      time.Time
+		time     string
+		tags     []string
+		priority int
+		list     string
+	}{
+		{text: "pay rent", title: "pay rent"},
+		{text: "pay rent tomorrow 9am #finance !p1 @personal", title: "pay rent", due: day(15), time: "09:00", tags: []string{"finance"}, priority: 1, list: "personal"},
+		{text: "pay rent by friday at 9am", title: "pay rent", due: day(21), time: "09:00"},
+		{text: "call mom on 2025-03-20", title: "call mom", due: day(20)},
+		{text: "standup 9:30am", title: "standup", due: day(14), time: "09:30"},
+		{text: "review at 21:00 !2", title: "review", due: day(14), time: "21:00", priority: 2},
+		{text: "read Today", title: "read", due: day(14)},
+		{text: "buy sun cream", title: "buy sun cream"},
+		{text: "buy sun cream sat", title: "buy sun cream", due: day(15)},
+		{text: "call mom sat", title: "call mom", due: day(15)},
+		{text: "call mom sat 9am #family", title: "call mom", due: day(15), time: "09:00", tags: []string{"family"}},
+		{text: "call mom sat at 9am", title: "call mom", due: day(15), time: "09:00"},
+		{text: "dentist on mon", title: "dentist", due: day(17)},
+		{text: "report due wed", title: "report", due: day(19)},
+		{text: "get wed in june", title: "get wed in june"},
+		{text: "fri tomorrow", title: "fri", due: day(15)},
+		{text: "tomorrow or friday", title: "or friday", due: day(15)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			q, err := parseQuickAdd(tt.text, now)
+			if err != nil {
+				t.Fatalf("parseQuickAdd: %v", err)
+			}
+			if q.Title != tt.title || !q.Due.Equal(tt.due) || q.Time != tt.time || q.Priority != tt.priority || q.List != tt.list || !slices.Equal(q.Tags, tt.tags) {
+				t.Errorf("got %+v, want title %q due %v time %q tags %v priority %d list %q",
+					q, tt.title, tt.due, tt.time, tt.tags, tt.priority, tt.list)
+			}
+		})
+	}
+}
+
+func TestParseQuickAddErrors(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	for _, text := range []string{
+		"",
+		"tomorrow 9am #tag",
+		"sat",
+		"pay rent !p5",
+		"pay rent @home @work",
+	} {
+		t.Run(text, func(t *testing.T) {
+			if q, err := parseQuickAdd(text, now); err == nil {
+				t.Errorf("got %+v, want an error", q)
+			}
+		})
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	now := time.Date(2025, 3, 14, 22, 30, 0, 0, time.UTC) // a Friday
+	day := func(m time.Month, d int) time.Time { return time.Date(2025, m, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"today", day(3, 14)},
+		{" Tomorrow ", day(3, 15)},
+		{"saturday", day(3, 15)},
+		{"sat", day(3, 15)},
+		{"friday", day(3, 21)}, // never today
+		{"thu", day(3, 20)},
+		{"2025-04-01", day(4, 1)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseDate(tt.in, now)
+			if err != nil || !got.Equal(tt.want) {
+				t.Errorf("parseDate(%q) = %v, %v; want %v", tt.in, got, err, tt.want)
+			}
+		})
+	}
+	for _, in := range []string{"", "yesterday", "fr", "2025-13-01", "14/03/2025"} {
+		if got, err := parseDate(in, now); err == nil {
+			t.Errorf("parseDate(%q) = %v, want an error", in, got)
+		}
+	}
+}
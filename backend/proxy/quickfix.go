@@ -0,0 +1,103 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// quickfixFilters are the filters of GET /api/tasks/quickfix and the titles
+// of their lists.
+var quickfixFilters = map[string]string{
+	"overdue": "Overdue tasks",
+	"today":   "Tasks due today",
+	"week":    "Tasks due this week",
+	"open":    "Open tasks",
+}
+
+// buildQuickfix returns the open tasks in lists that pass filter as
+// quickfix entries, by due date with undated tasks last. date is midnight
+// in the user's time zone.
+func buildQuickfix(lists []api.ListWithTasks, filter string, date time.Time) api.QuickfixResponse {
+	day := date.Format(time.DateOnly)
+	weekEnd := date.AddDate(0, 0, agendaDays-1).Format(time.DateOnly)
+
+	keep := func(due string) bool {
+		switch filter {
+		case "overdue":
+			return due != "" && due < day
+		case "today":
+			return due == day
+		case "week":
+			return due >= day && due <= weekEnd
+		}
+		return true
+	}
+	items, failed := openTasks(lists, keep)
+
+	resp := api.QuickfixResponse{
+		Title:  "gtask: " + quickfixFilters[filter],
+		Items:  []api.QuickfixItem{},
+		Failed: failed,
+	}
+	for _, item := range items {
+		due := dueDate(item.Task)
+		entry := api.QuickfixItem{
+			Text:     item.Task.Title,
+			Type:     "I",
+			Module:   item.List.Title,
+			Valid:    true,
+			UserData: api.QuickfixUserData{Task: item.Task.ID, List: item.List.ID, Due: due},
+		}
+		switch {
+		case due == "":
+		case due < day:
+			entry.Type = "E"
+			entry.Text += " (overdue since " + due + ")"
+		case due == day:
+			entry.Type = "W"
+			entry.Text += " (due today)"
+		default:
+			entry.Text += " (due " + due + ")"
+		}
+		resp.Items = append(resp.Items, entry)
+	}
+	return resp
+}
+
+// GET /api/tasks/quickfix - Open tasks as Neovim quickfix entries
+//
+// ?filter= is overdue, today, week (today and the next six days) or open
+// (every open task, the default); ?date= and ?tz= are read like the
+// agenda's.
+func (s *Server) handleQuickfix(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	filter := cmp.Or(query.Get("filter"), "open")
+	if _, ok := quickfixFilters[filter]; !ok {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "filter must be overdue, today, week or open")
+		return
+	}
+	date, err := agendaDate(query.Get("date"), query.Get("tz"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildQuickfix(lists, filter, date))
+}
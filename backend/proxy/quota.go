@@ -0,0 +1,170 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxQuotaBackoff bounds the delay after repeated rate limiting without a
+// Retry-After.
+const maxQuotaBackoff = 2 * time.Minute
+
+// ErrRateLimited is returned without contacting Google while a quota it
+// exhausted is backing off.
+var ErrRateLimited = errors.New("rate limited")
+
+// RateLimitedError wraps ErrRateLimited with the time until requests are
+// sent again.
+type RateLimitedError struct {
+	Host    string
+	RetryIn time.Duration
+}
+
+func (e *RateLimitedError) Error() string {
+	return e.Host + ": " + ErrRateLimited.Error()
+}
+
+func (e *RateLimitedError) Unwrap() error {
+	return ErrRateLimited
+}
+
+// rateLimitReasons are the reasons Google gives in a 403 (and sometimes a
+// 429) for an exhausted quota rather than a lack of permission.
+var rateLimitReasons = map[string]bool{
+	"rateLimitExceeded":     true,
+	"userRateLimitExceeded": true,
+	"quotaExceeded":         true,
+	"dailyLimitExceeded":    true,
+	"RATE_LIMIT_EXCEEDED":   true,
+}
+
+// rateLimitReason reports whether a response with status and body means a
+// quota was exhausted, with Google's reason ("" for a bare 429).
+func rateLimitReason(status int, body []byte) (string, bool) {
+	if status != http.StatusTooManyRequests && status != http.StatusForbidden {
+		return "", false
+	}
+	var apiErr struct {
+		Error struct {
+			Errors []struct {
+				Reason string `json:"reason"`
+			} `json:"errors"`
+			Details []struct {
+				Reason string `json:"reason"`
+			} `json:"details"`
+		} `json:"error"`
+	}
+	if json.Unmarshal(body, &apiErr) == nil {
+		for _, e := range apiErr.Error.Errors {
+			if rateLimitReasons[e.Reason] {
+				return e.Reason, true
+			}
+		}
+		for _, d := range apiErr.Error.Details {
+			if rateLimitReasons[d.Reason] {
+				return d.Reason, true
+			}
+		}
+	}
+	return "", status == http.StatusTooManyRequests
+}
+
+// parseRetryAfter reads a Retry-After header, in seconds or as an HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		return max(at.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// retryAfterSeconds rounds d up to whole seconds for a Retry-After header.
+func retryAfterSeconds(d time.Duration) int {
+	return max(int(math.Ceil(d.Seconds())), 1)
+}
+
+// quotaBackoff paces requests to quotas Google reported as exhausted. Each
+// quota is a host, or a host and access token for per-user limits. After a
+// rate-limited response its requests wait until the Retry-After Google gave,
+// or when it gave none, a delay that doubles with each consecutive limit.
+// Any other response resets the doubling.
+type quotaBackoff struct {
+	mutex  sync.Mutex
+	quotas map[string]*quota
+}
+
+type quota struct {
+	until   time.Time
+	strikes int
+}
+
+func newQuotaBackoff() *quotaBackoff {
+	return &quotaBackoff{quotas: make(map[string]*quota)}
+}
+
+// quotaKeys are the quotas a request to host with authorization counts
+// against: the project's and the user's.
+func quotaKeys(host, authorization string) []string {
+	if authorization == "" {
+		return []string{host}
+	}
+	return []string{host, host + " " + sessionKey(authorization)}
+}
+
+// wait returns how long requests against keys must still wait.
+func (b *quotaBackoff) wait(keys []string) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var wait time.Duration
+	for _, key := range keys {
+		if q, ok := b.quotas[key]; ok {
+			wait = max(wait, time.Until(q.until))
+		}
+	}
+	return wait
+}
+
+// limited records a rate-limited response for key and returns how long its
+// requests now wait: retryAfter when Google gave one, else the adaptive delay.
+func (b *quotaBackoff) limited(key string, retryAfter time.Duration, given bool) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	q, ok := b.quotas[key]
+	if !ok {
+		q = &quota{}
+		b.quotas[key] = q
+	}
+	delay := retryAfter
+	if !given {
+		ceiling := min(time.Second<<q.strikes, maxQuotaBackoff)
+		// Half fixed, half jitter, so clients limited together spread out
+		delay = ceiling/2 + rand.N(ceiling/2+1)
+	}
+	q.strikes = min(q.strikes+1, 16)
+	q.until = time.Now().Add(delay)
+	return delay
+}
+
+// ok forgets the backoff of keys after a response that was not limited.
+func (b *quotaBackoff) ok(keys []string) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, key := range keys {
+		if q, found := b.quotas[key]; found && time.Now().After(q.until) {
+			delete(b.quotas, key)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// reconcileFields are the task fields a buffer edits, in the order they are
+// merged.
+var reconcileFields = []string{"title", "notes", "status", "due", "parent"}
+
+// maxReconcileTasks bounds each side of one POST /api/reconcile request.
+const maxReconcileTasks = 5000
+
+// taskField returns a merged field of t in a comparable form.
+func taskField(t api.Task, field string) string {
+	switch field {
+	case "title":
+		return t.Title
+	case "notes":
+		return t.Notes
+	case "status":
+		return cmp.Or(t.Status, "needsAction")
+	case "due":
+		return dueDate(t)
+	default:
+		return t.Parent
+	}
+}
+
+// changedFields lists the merged fields that differ between a and b.
+func changedFields(a, b api.Task) []string {
+	var changed []string
+	for _, field := range reconcileFields {
+		if taskField(a, field) != taskField(b, field) {
+			changed = append(changed, field)
+		}
+	}
+	return changed
+}
+
+// patchField adds the update that sets field to t's value.
+func patchField(fields map[string]any, t api.Task, field string) {
+	switch field {
+	case "title":
+		fields["title"] = t.Title
+	case "notes":
+		fields["notes"] = t.Notes
+	case "status":
+		fields["status"] = taskField(t, "status")
+		if taskField(t, "status") != "completed" {
+			fields["completed"] = nil
+		}
+	case "due":
+		fields["due"] = nil
+		if day, err := time.Parse(time.DateOnly, dueDate(t)); err == nil {
+			fields["due"] = googleDue(day)
+		}
+	}
+}
+
+// taskDepth returns how deep t is nested among the tasks byID, stopping at
+// parents that are not in it.
+func taskDepth(t api.Task, byID map[string]api.Task) int {
+	depth := 0
+	for t.Parent != "" && depth < len(byID) {
+		parent, ok := byID[t.Parent]
+		if !ok {
+			break
+		}
+		t = parent
+		depth++
+	}
+	return depth
+}
+
+// renderTask writes t as the plugin's markdown renders it, indented two
+// spaces per level of depth, without the plugin's own ID comment.
+func renderTask(b *strings.Builder, t api.Task, depth int) {
+	indent := strings.Repeat("  ", depth)
+	checkbox := " "
+	if taskField(t, "status") == "completed" {
+		checkbox = "x"
+	}
+	fmt.Fprintf(b, "%s- [%s] %s", indent, checkbox, t.Title)
+	if due := dueDate(t); due != "" {
+		fmt.Fprintf(b, " | %s", due)
+	}
+	b.WriteString("\n")
+	if t.Notes != "" {
+		b.WriteString("\n")
+		for line := range strings.Lines(t.Notes) {
+			if line = strings.TrimRight(line, "\r\n"); line != "" {
+				fmt.Fprintf(b, "%s  %s\n", indent, line)
+			}
+		}
+	}
+}
+
+// conflictMarkers renders both sides of a conflict between git-style
+// conflict markers. A side that deleted the task is empty.
+func conflictMarkers(local, remote *api.Task, localByID, remoteByID map[string]api.Task) string {
+	var b strings.Builder
+	b.WriteString("<<<<<<< local\n")
+	if local != nil {
+		renderTask(&b, *local, taskDepth(*local, localByID))
+	}
+	b.WriteString("=======\n")
+	if remote != nil {
+		renderTask(&b, *remote, taskDepth(*remote, remoteByID))
+	}
+	b.WriteString(">>>>>>> remote\n")
+	return b.String()
+}
+
+// mergeTasks three-way merges a buffer's edits of list (local, made
+// against base) with the list's current remote tasks. A field edited in
+// the buffer is updated unless it was also changed remotely to another
+// value, which is a conflict; deleting a task on one side conflicts with
+// editing it on the other. The changes are deletes, then updates and moves
+// in local order, then creates.
+func mergeTasks(list string, base, local, remote []api.Task) ([]api.Change, []api.ReconcileConflict, error) {
+	baseByID := make(map[string]api.Task, len(base))
+	for _, t := range base {
+		baseByID[t.ID] = t
+	}
+	remoteByID := make(map[string]api.Task, len(remote))
+	for _, t := range remote {
+		remoteByID[t.ID] = t
+	}
+	localByID := make(map[string]api.Task, len(local))
+	for i, t := range local {
+		if t.Due != "" {
+			if _, err := time.Parse(time.DateOnly, dueDate(t)); err != nil {
+				return nil, nil, fmt.Errorf("local[%d]: invalid due %q", i, t.Due)
+			}
+		}
+		if t.ID == "" {
+			if t.Title == "" {
+				return nil, nil, fmt.Errorf("local[%d]: a new task needs a title", i)
+			}
+			continue
+		}
+		if _, ok := baseByID[t.ID]; !ok {
+			return nil, nil, fmt.Errorf("local[%d]: task %s is not in base", i, t.ID)
+		}
+		if _, dup := localByID[t.ID]; dup {
+			return nil, nil, fmt.Errorf("local[%d]: task %s is listed twice", i, t.ID)
+		}
+		localByID[t.ID] = t
+	}
+
+	var deletes, edits, creates []api.Change
+	conflicts := []api.ReconcileConflict{}
+	for _, b := range base {
+		l, inLocal := localByID[b.ID]
+		r, inRemote := remoteByID[b.ID]
+		switch {
+		case inLocal && inRemote, !inLocal && !inRemote:
+		case !inLocal:
+			if len(changedFields(b, r)) > 0 {
+				conflicts = append(conflicts, api.ReconcileConflict{Task: b.ID, Fields: []string{"deleted"}, Base: b, Remote: &r})
+			} else {
+				deletes = append(deletes, api.Change{Op: "delete", List: list, Task: b.ID})
+			}
+		default:
+			if len(changedFields(b, l)) > 0 {
+				conflicts = append(conflicts, api.ReconcileConflict{Task: b.ID, Fields: []string{"deleted"}, Base: b, Local: &l})
+			}
+		}
+	}
+
+	for _, l := range local {
+		if l.ID == "" {
+			fields := map[string]any{"title": l.Title}
+			for _, field := range []string{"notes", "status", "due"} {
+				patchField(fields, l, field)
+			}
+			delete(fields, "completed")
+			creates = append(creates, api.Change{Op: "create", List: list, Parent: l.Parent, Fields: fields})
+			continue
+		}
+		r, inRemote := remoteByID[l.ID]
+		if !inRemote {
+			continue
+		}
+		b := baseByID[l.ID]
+
+		fields := make(map[string]any)
+		var conflicting []string
+		move := false
+		for _, field := range reconcileFields {
+			lv, bv, rv := taskField(l, field), taskField(b, field), taskField(r, field)
+			switch {
+			case lv == bv || lv == rv:
+				// Unchanged in the buffer, or changed the same way on both sides
+			case rv != bv:
+				conflicting = append(conflicting, field)
+			case field == "parent":
+				move = true
+			default:
+				patchField(fields, l, field)
+			}
+		}
+		if len(fields) > 0 {
+			edits = append(edits, api.Change{Op: "update", List: list, Task: l.ID, Fields: fields})
+		}
+		if move {
+			edits = append(edits, api.Change{Op: "move", List: list, Task: l.ID, Parent: l.Parent})
+		}
+		if len(conflicting) > 0 {
+			conflicts = append(conflicts, api.ReconcileConflict{Task: l.ID, Fields: conflicting, Base: b, Local: &l, Remote: &r})
+		}
+	}
+
+	for i, c := range conflicts {
+		conflicts[i].Markers = conflictMarkers(c.Local, c.Remote, localByID, remoteByID)
+	}
+	return append(append(deletes, edits...), creates...), conflicts, nil
+}
+
+// POST /api/reconcile - Three-way merge a buffer's edits of a list with its remote state
+//
+// The remote tasks are read fresh (revalidated past the cache), merged with
+// the buffer's edits against its base, and the changes that merge cleanly
+// are applied like a batch. Conflicting edits are returned instead. With
+// ?dry_run=1 nothing is sent.
+func (s *Server) handleReconcile(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.ReconcileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if req.List == "" {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Missing list")
+		return
+	}
+	if len(req.Base) > maxReconcileTasks || len(req.Local) > maxReconcileTasks {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest,
+			fmt.Sprintf("At most %d tasks in base and in local", maxReconcileTasks))
+		return
+	}
+
+	remote, err := s.tasks.ListTasks(withRevalidation(r.Context()), token, req.List, watchQuery)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	changes, conflicts, err := mergeTasks(req.List, req.Base, req.Local, remote)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	results := make([]api.ChangeResult, len(changes))
+	for i, change := range changes {
+		write, err := changeWrite(change)
+		if err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		results[i].Request = write
+	}
+
+	resp := api.ReconcileResponse{DryRun: dryRun(r), Results: results, Conflicts: conflicts, Remote: remote}
+	if !resp.DryRun && len(results) > 0 {
+		undo := make([][]undoStep, len(results))
+		for i := range results {
+			task, steps, err := s.writeUndoable(r, token, results[i].Request)
+			if err != nil {
+				results[i].Error = asAPIError(err, "Google Tasks request failed")
+				continue
+			}
+			results[i].Task = task
+			undo[i] = steps
+		}
+		s.recordUndo(r, token, changesSummary("reconcile", len(results)), undo...)
+		// The writes invalidated the cached list, so this reads it back
+		if resp.Remote, err = s.tasks.ListTasks(r.Context(), token, req.List, watchQuery); err != nil {
+			resp.RemoteError = asAPIError(err, "Failed to read the list back")
+		}
+	}
+	if !resp.DryRun {
+		s.fireHook(api.HookSyncFinished, tenantUser(r.Context()), syncFinished(req.List, results, conflicts))
+	}
+	if resp.Remote == nil {
+		resp.Remote = []api.Task{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// syncFinished is the data of the sync.finished hooks of a reconcile.
+func syncFinished(list string, results []api.ChangeResult, conflicts []api.ReconcileConflict) api.SyncFinishedHook {
+	hook := api.SyncFinishedHook{List: list, Changes: len(results), Conflicts: len(conflicts)}
+	for _, result := range results {
+		if result.Error != nil {
+			hook.Failed++
+		}
+	}
+	return hook
+}
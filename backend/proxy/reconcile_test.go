@@ -0,0 +1,194 @@
+package proxy
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// edited returns a copy of t changed by edit.
+func edited(t api.Task, edit func(*api.Task)) api.Task {
+	edit(&t)
+	return t
+}
+
+func mustMerge(t *testing.T, base, local, remote []api.Task) ([]api.Change, []api.ReconcileConflict) {
+	t.Helper()
+	changes, conflicts, err := mergeTasks("L", base, local, remote)
+	if err != nil {
+		t.Fatalf("mergeTasks: %v", err)
+	}
+	return changes, conflicts
+}
+
+var milk = api.Task{ID: "a", Title: "Buy milk", Status: "needsAction"}
+
+func TestMergeTasksUnchanged(t *testing.T) {
+	// A buffer that only spells the due date differently has no edits
+	base := []api.Task{edited(milk, func(t *api.Task) { t.Due = "2025-03-14T00:00:00.000Z" })}
+	local := []api.Task{edited(milk, func(t *api.Task) { t.Due = "2025-03-14"; t.Status = "" })}
+
+	changes, conflicts := mustMerge(t, base, local, base)
+	if len(changes) != 0 {
+		t.Errorf("changes = %+v", changes)
+	}
+	// An empty list, so the response has "conflicts": []
+	if conflicts == nil || len(conflicts) != 0 {
+		t.Errorf("conflicts = %#v", conflicts)
+	}
+}
+
+func TestMergeTasksFieldByField(t *testing.T) {
+	base := []api.Task{milk}
+	local := []api.Task{edited(milk, func(t *api.Task) { t.Title = "Buy oat milk"; t.Notes = "2 litres" })}
+	remote := []api.Task{edited(milk, func(t *api.Task) { t.Title = "Buy soy milk"; t.Status = "completed" })}
+
+	changes, conflicts := mustMerge(t, base, local, remote)
+
+	// The notes merge; the title changed both ways conflicts; the remote
+	// completion is left alone
+	want := []api.Change{{Op: "update", List: "L", Task: "a", Fields: map[string]any{"notes": "2 litres"}}}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes = %+v, want %+v", changes, want)
+	}
+	if len(conflicts) != 1 || !reflect.DeepEqual(conflicts[0].Fields, []string{"title"}) {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	if c := conflicts[0]; c.Base.Title != "Buy milk" || c.Local.Title != "Buy oat milk" || c.Remote.Title != "Buy soy milk" {
+		t.Errorf("conflict sides = %+v", c)
+	}
+	if !strings.Contains(conflicts[0].Markers, "  2 litres\n=======\n- [x] Buy soy milk\n") {
+		t.Errorf("markers = %q", conflicts[0].Markers)
+	}
+
+	// The same edit made on both sides is neither sent nor a conflict
+	changes, conflicts = mustMerge(t, base, remote, remote)
+	if len(changes) != 0 || len(conflicts) != 0 {
+		t.Errorf("edit made on both sides: %+v, %+v", changes, conflicts)
+	}
+}
+
+func TestMergeTasksStatusAndDue(t *testing.T) {
+	done := edited(milk, func(t *api.Task) { t.Status = "completed"; t.Due = "2025-03-14T00:00:00.000Z" })
+
+	// Reopening clears the completion time; a removed due date is cleared
+	reopened := edited(milk, func(t *api.Task) { t.Due = "" })
+	changes, _ := mustMerge(t, []api.Task{done}, []api.Task{reopened}, []api.Task{done})
+	want := map[string]any{"status": "needsAction", "completed": nil, "due": nil}
+	if len(changes) != 1 || !reflect.DeepEqual(changes[0].Fields, want) {
+		t.Errorf("changes = %+v, want fields %v", changes, want)
+	}
+
+	// A new due date is sent as Google's midnight UTC
+	moved := edited(done, func(t *api.Task) { t.Due = "2025-03-20" })
+	changes, _ = mustMerge(t, []api.Task{done}, []api.Task{moved}, []api.Task{done})
+	if len(changes) != 1 || changes[0].Fields["due"] != "2025-03-20T00:00:00Z" {
+		t.Errorf("changes = %+v", changes)
+	}
+}
+
+func TestMergeTasksDeletes(t *testing.T) {
+	renamed := edited(milk, func(t *api.Task) { t.Title = "Buy oat milk" })
+	for _, tt := range []struct {
+		name          string
+		local, remote []api.Task
+		changes       []api.Change
+		conflict      bool
+	}{
+		{name: "deleted in the buffer", remote: []api.Task{milk},
+			changes: []api.Change{{Op: "delete", List: "L", Task: "a"}}},
+		{name: "deleted in the buffer, edited remotely", remote: []api.Task{renamed}, conflict: true},
+		{name: "deleted remotely, edited in the buffer", local: []api.Task{renamed}, conflict: true},
+		{name: "deleted remotely", local: []api.Task{milk}},
+		{name: "deleted on both sides"},
+	} {
+		changes, conflicts := mustMerge(t, []api.Task{milk}, tt.local, tt.remote)
+		if !reflect.DeepEqual(changes, tt.changes) {
+			t.Errorf("%s: changes = %+v, want %+v", tt.name, changes, tt.changes)
+		}
+		if got := len(conflicts) == 1 && conflicts[0].Fields[0] == "deleted"; got != tt.conflict || len(conflicts) > 1 {
+			t.Errorf("%s: conflicts = %+v", tt.name, conflicts)
+		}
+	}
+}
+
+func TestMergeTasksOrder(t *testing.T) {
+	call := api.Task{ID: "b", Title: "Call mom"}
+	base := []api.Task{milk, call}
+	local := []api.Task{
+		{Title: "Buy eggs", Parent: "b", Status: "completed"},
+		edited(call, func(t *api.Task) { t.Title = "Call dad"; t.Parent = "x" }),
+	}
+
+	changes, _ := mustMerge(t, base, local, base)
+
+	want := []api.Change{
+		{Op: "delete", List: "L", Task: "a"},
+		{Op: "update", List: "L", Task: "b", Fields: map[string]any{"title": "Call dad"}},
+		{Op: "move", List: "L", Task: "b", Parent: "x"},
+		// A new task never clears a completion time it does not have
+		{Op: "create", List: "L", Parent: "b", Fields: map[string]any{"title": "Buy eggs", "notes": "", "status": "completed", "due": nil}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("changes =\n%+v\nwant\n%+v", changes, want)
+	}
+}
+
+func TestMergeTasksErrors(t *testing.T) {
+	base := []api.Task{milk}
+	for want, local := range map[string][]api.Task{
+		`local[0]: invalid due "next week"`:  {edited(milk, func(t *api.Task) { t.Due = "next week" })},
+		"local[1]: a new task needs a title": {milk, {Notes: "untitled"}},
+		"local[0]: task z is not in base":    {{ID: "z", Title: "Unknown"}},
+		"local[1]: task a is listed twice":   {milk, milk},
+	} {
+		if _, _, err := mergeTasks("L", base, local, base); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("error = %v, want %q", err, want)
+		}
+	}
+}
+
+func TestConflictMarkers(t *testing.T) {
+	parent := api.Task{ID: "p", Title: "Groceries"}
+	local := api.Task{ID: "a", Title: "Buy oat milk", Parent: "p", Due: "2025-03-14T00:00:00.000Z", Notes: "2 litres\r\n\r\nsemi-skimmed"}
+	remote := api.Task{ID: "a", Title: "Buy soy milk", Status: "completed"}
+
+	// Each side is indented by its own nesting: the task was moved out of
+	// Groceries remotely
+	got := conflictMarkers(&local, &remote, map[string]api.Task{"p": parent, "a": local}, map[string]api.Task{"a": remote})
+	want := "<<<<<<< local\n" +
+		"  - [ ] Buy oat milk | 2025-03-14\n" +
+		"\n" +
+		"    2 litres\n" +
+		"    semi-skimmed\n" +
+		"=======\n" +
+		"- [x] Buy soy milk\n" +
+		">>>>>>> remote\n"
+	if got != want {
+		t.Errorf("conflictMarkers =\n%s\nwant\n%s", got, want)
+	}
+
+	// A deleted side is empty
+	if got := conflictMarkers(nil, &remote, nil, nil); got != "<<<<<<< local\n=======\n- [x] Buy soy milk\n>>>>>>> remote\n" {
+		t.Errorf("deleted locally: %q", got)
+	}
+}
+
+func TestTaskDepth(t *testing.T) {
+	byID := map[string]api.Task{
+		"a": {ID: "a"},
+		"b": {ID: "b", Parent: "a"},
+		"c": {ID: "c", Parent: "b"},
+		"x": {ID: "x", Parent: "gone"},
+		// A corrupt buffer can nest tasks in a loop
+		"y": {ID: "y", Parent: "z"},
+		"z": {ID: "z", Parent: "y"},
+	}
+	for id, want := range map[string]int{"a": 0, "c": 2, "x": 0, "y": len(byID)} {
+		if got := taskDepth(byID[id], byID); got != want {
+			t.Errorf("taskDepth(%s) = %d, want %d", id, got, want)
+		}
+	}
+}
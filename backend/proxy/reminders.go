@@ -0,0 +1,275 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// reminderSinks are the values allowed in reminders.sinks.
+var reminderSinks = []string{"desktop", "sse", "command"}
+
+// reminderCommandTimeout bounds one run of reminders.command.
+const reminderCommandTimeout = 30 * time.Second
+
+// dueReminders returns the open tasks in lists that are overdue or due
+// within ahead of now. Google due dates carry no time, so a task is overdue
+// from the day after its due date, in now's time zone.
+func dueReminders(lists []api.ListWithTasks, now time.Time, ahead time.Duration) []api.Reminder {
+	today := now.Format(time.DateOnly)
+	var reminders []api.Reminder
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			if task.Status == "completed" || task.Deleted || task.Hidden || task.Due == "" {
+				continue
+			}
+			due := dueDate(task)
+			dueDay, err := time.ParseInLocation(time.DateOnly, due, now.Location())
+			if err != nil {
+				continue
+			}
+
+			kind := ""
+			switch {
+			case due < today:
+				kind = "overdue"
+			case dueDay.Sub(now) <= ahead:
+				kind = "due"
+			default:
+				continue
+			}
+			reminders = append(reminders, api.Reminder{Kind: kind, Due: due, List: list.TaskList, Task: task})
+		}
+	}
+	return reminders
+}
+
+// applySnoozes drops the reminders of tasks snoozed past now and marks
+// those whose snooze has ended.
+func applySnoozes(reminders []api.Reminder, snoozes map[string]time.Time, now time.Time) []api.Reminder {
+	kept := reminders[:0]
+	for _, r := range reminders {
+		if until, ok := snoozes[r.Task.ID]; ok {
+			if until.After(now) {
+				continue
+			}
+			r.SnoozedUntil = until.Format(time.RFC3339)
+		}
+		kept = append(kept, r)
+	}
+	return kept
+}
+
+// reminderKey identifies a reminder for deduplication. Overdue reminders
+// repeat daily, so today is part of their key, and a reminder comes back
+// once after each snooze.
+func reminderKey(r api.Reminder, today string) string {
+	key := r.Kind + " " + r.Task.ID + " " + r.Due + " " + r.SnoozedUntil
+	if r.Kind == "overdue" {
+		key += " " + today
+	}
+	return key
+}
+
+// unsent returns the reminders not in sent and replaces sent with the keys
+// of all current reminders, so tasks that are done or rescheduled are
+// forgotten.
+func unsent(reminders []api.Reminder, sent map[string]bool, today string) ([]api.Reminder, map[string]bool) {
+	current := make(map[string]bool, len(reminders))
+	var fresh []api.Reminder
+	for _, r := range reminders {
+		key := reminderKey(r, today)
+		current[key] = true
+		if !sent[key] {
+			fresh = append(fresh, r)
+		}
+	}
+	return fresh, current
+}
+
+// scanReminders is the periodic reminder job: the tasks of the account
+// logged in with `auth login` go to the desktop and command sinks, and each
+// event stream gets the reminders for its own token. Polling for reminders
+// also detects changes, like pollChanges.
+func (s *Server) scanReminders(ctx context.Context) error {
+	cfg := s.config()
+	if !cfg.Reminders.Enabled {
+		return nil
+	}
+
+	if cfg.scansLocally() {
+		lists, ok, err := s.observeLocal(ctx)
+		if err != nil {
+			return fmt.Errorf("reminders: %w", err)
+		}
+		if !ok {
+			slog.Debug("reminders: no stored login for the desktop and command sinks, run `auth login`")
+		} else {
+			s.remind(ctx, &s.local, lists)
+		}
+	}
+	if slices.Contains(cfg.Reminders.Sinks, "sse") {
+		for _, sub := range s.events.Subscribers() {
+			if lists, ok := s.observeSubscriber(ctx, sub); ok {
+				s.remind(ctx, &sub.watch, lists)
+			}
+		}
+	}
+	return nil
+}
+
+// remindOnChange is the change listener that checks for reminders right
+// away when a task is added, reopened or rescheduled, instead of at the
+// next scan.
+func (s *Server) remindOnChange(ctx context.Context, w *watch, lists []api.ListWithTasks, events []api.TaskEvent) {
+	for _, event := range events {
+		switch event.Type {
+		case api.EventTaskCreated, api.EventTaskReopened, api.EventTaskDueChanged:
+			s.remind(ctx, w, lists)
+			return
+		}
+	}
+}
+
+// remind sends the reminders in lists that w's user has not had yet: to
+// the event stream for a stream's watch, else to the local sinks. Tasks
+// that became overdue run the task.overdue hooks.
+func (s *Server) remind(ctx context.Context, w *watch, lists []api.ListWithTasks) {
+	cfg := s.config()
+	if !cfg.Reminders.Enabled {
+		return
+	}
+	if w.sub != nil && !slices.Contains(cfg.Reminders.Sinks, "sse") || w.sub == nil && !cfg.scansLocally() {
+		return
+	}
+
+	user := ""
+	if w.sub != nil {
+		user = w.sub.user
+	}
+	now := time.Now()
+	snoozes, err := s.snoozeStore(user).All()
+	if err != nil {
+		log.Printf("Reading snoozes: %v", err)
+	}
+	reminders := applySnoozes(dueReminders(lists, now, cfg.Reminders.Ahead), snoozes, now)
+
+	if cfg.hooksOn(api.HookTaskOverdue) {
+		for _, r := range reminders {
+			if r.Kind == "overdue" && s.overdueHooks.first(user, r) {
+				s.fireHook(api.HookTaskOverdue, user, r)
+			}
+		}
+	}
+	if w.sub == nil && !cfg.remindsLocally() {
+		return
+	}
+
+	w.mutex.Lock()
+	fresh, sent := unsent(reminders, w.reminded, now.Format(time.DateOnly))
+	w.reminded = sent
+	w.mutex.Unlock()
+
+	for _, r := range fresh {
+		if w.sub != nil {
+			s.events.Send(w.sub, "reminder", r)
+			continue
+		}
+		if slices.Contains(cfg.Reminders.Sinks, "desktop") {
+			if err := notifyDesktop(ctx, reminderTitle(r), reminderBody(r)); err != nil {
+				log.Printf("Desktop notification failed: %v", err)
+			}
+		}
+		if slices.Contains(cfg.Reminders.Sinks, "command") {
+			if err := runReminderCommand(ctx, cfg.Reminders.Command, r); err != nil {
+				log.Printf("Reminder command failed: %v", err)
+			}
+		}
+	}
+}
+
+func reminderTitle(r api.Reminder) string {
+	if r.Kind == "overdue" {
+		return "Task overdue since " + r.Due
+	}
+	if r.Due == time.Now().Format(time.DateOnly) {
+		return "Task due today"
+	}
+	return "Task due " + r.Due
+}
+
+func reminderBody(r api.Reminder) string {
+	return r.Task.Title + " (" + r.List.Title + ")"
+}
+
+// notifyDesktop shows a notification with notify-send on Linux and the BSDs
+// or osascript on macOS.
+func notifyDesktop(ctx context.Context, title, body string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		script := fmt.Sprintf("display notification %s with title %s", appleScriptString(body), appleScriptString(title))
+		cmd = exec.CommandContext(ctx, "osascript", "-e", script)
+	case "windows":
+		return errors.New("desktop notifications are not supported on Windows, use the command sink")
+	default:
+		// A title starting with "-" is not an option
+		cmd = exec.CommandContext(ctx, "notify-send", "--app-name=gtask", "--", title, body)
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%s: %w: %s", cmd.Path, err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+func appleScriptString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// runReminderCommand runs command through the shell with the reminder in
+// GTASK_* environment variables and as JSON on stdin.
+func runReminderCommand(ctx context.Context, command string, r api.Reminder) error {
+	ctx, cancel := context.WithTimeout(ctx, reminderCommandTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	cmd := shellCommand(ctx, command)
+	cmd.Stdin = bytes.NewReader(data)
+	cmd.Env = append(os.Environ(),
+		"GTASK_REMINDER_KIND="+r.Kind,
+		"GTASK_REMINDER_TITLE="+reminderTitle(r),
+		"GTASK_TASK_ID="+r.Task.ID,
+		"GTASK_TASK_TITLE="+r.Task.Title,
+		"GTASK_TASK_NOTES="+r.Task.Notes,
+		"GTASK_TASK_DUE="+r.Due,
+		"GTASK_LIST_ID="+r.List.ID,
+		"GTASK_LIST_TITLE="+r.List.Title,
+	)
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("%w: %s", err, bytes.TrimSpace(out))
+	}
+	return nil
+}
+
+// shellCommand runs command through the shell: sh, or cmd on Windows.
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
@@ -0,0 +1,323 @@
+package proxy
+
+import (
+	"cmp"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// errBackupNotFound is returned by readBackup for an ID with no backup.
+var errBackupNotFound = errors.New("backup not found")
+
+// readBackup reads the backup with id from dir. Only IDs shaped like the
+// time stamps of backup names are accepted, so id cannot name another file.
+func readBackup(dir, id string) (api.Backup, error) {
+	var backup api.Backup
+	if _, err := time.Parse(backupLayout, id); err != nil {
+		return backup, errBackupNotFound
+	}
+	f, err := os.Open(filepath.Join(dir, backupPrefix+id+backupSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return backup, errBackupNotFound
+	}
+	if err != nil {
+		return backup, err
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return backup, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	if err := json.NewDecoder(zr).Decode(&backup); err != nil {
+		return backup, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	if backup.Version > api.BackupVersion {
+		return backup, fmt.Errorf("%s: backup version %d is newer than this proxy supports", f.Name(), backup.Version)
+	}
+	return backup, nil
+}
+
+// backupAccountOf returns user's account in backup, if it is the Google
+// account with ID verified. Backups made before accounts were recorded have
+// none.
+func backupAccountOf(backup api.Backup, user, verified string) (api.BackupAccount, bool) {
+	for _, account := range backup.Accounts {
+		if account.User == user && account.Account == verified {
+			return account, true
+		}
+	}
+	return api.BackupAccount{}, false
+}
+
+// restoreStep creates one task again. change names backup IDs for its list,
+// parent and previous sibling when they are created again too.
+type restoreStep struct {
+	undoStep
+	list api.TaskList
+	from api.Task
+}
+
+// restoreTasks plans creating the tasks of list that do not exist any more,
+// parents before their children and siblings in order. With only, just that
+// task and its subtasks are considered. existing counts the considered
+// tasks that still exist.
+func restoreTasks(list api.ListWithTasks, exists map[string]bool, only string) (steps []restoreStep, existing int) {
+	byID := make(map[string]api.Task, len(list.Tasks))
+	for _, t := range list.Tasks {
+		byID[t.ID] = t
+	}
+	selected := func(t api.Task) bool {
+		for id := t.ID; only != "" && id != only; id = byID[id].Parent {
+			if id == "" {
+				return false
+			}
+		}
+		return true
+	}
+	restoring := make(map[string]bool)
+	for _, t := range list.Tasks {
+		switch {
+		case !selected(t):
+		case exists[t.ID]:
+			existing++
+		default:
+			restoring[t.ID] = true
+		}
+	}
+
+	tasks := slices.Clone(list.Tasks)
+	slices.SortFunc(tasks, func(a, b api.Task) int { return cmp.Compare(a.Position, b.Position) })
+	known := func(id string) bool { return exists[id] || restoring[id] }
+	var add func(t api.Task)
+	add = func(t api.Task) {
+		parent, previous := t.Parent, previousSibling(list.Tasks, t)
+		if parent != "" && !known(parent) {
+			// The parent is gone for good, so the task comes back at the top level
+			parent, previous = "", ""
+		}
+		if previous != "" && !known(previous) {
+			previous = ""
+		}
+		steps = append(steps, restoreStep{
+			undoStep: undoStep{change: api.Change{Op: "create", List: list.ID, Parent: parent, Previous: previous, Fields: restoreFields(t)}, restores: t.ID},
+			list:     list.TaskList,
+			from:     t,
+		})
+		for _, child := range tasks {
+			if child.Parent == t.ID && restoring[child.ID] {
+				add(child)
+			}
+		}
+	}
+	for _, t := range tasks {
+		if restoring[t.ID] && !restoring[t.Parent] {
+			add(t)
+		}
+	}
+	return steps, existing
+}
+
+// GET /api/backup/list - The backups kept and the lists each holds for the
+// caller
+func (s *Server) handleBackupList(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, verified, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	dir := s.config().Backup.Dir
+	backups, err := listBackups(dir)
+	if err != nil {
+		log.Printf("Reading backups: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the backup directory")
+		return
+	}
+	resp := api.BackupListResponse{Backups: []api.BackupSummary{}}
+	for _, file := range backups {
+		summary := api.BackupSummary{BackupFile: file, Lists: []api.BackupListSummary{}}
+		backup, err := readBackup(dir, file.ID)
+		if err != nil {
+			summary.Error = err.Error()
+		} else if account, ok := backupAccountOf(backup, tenantUser(r.Context()), verified); ok {
+			for _, list := range account.Lists {
+				summary.Lists = append(summary.Lists, api.BackupListSummary{List: api.TaskList{ID: list.ID, Title: list.Title}, Tasks: len(list.Tasks)})
+			}
+		}
+		resp.Backups = append(resp.Backups, summary)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/backup/{id}/restore - Create the lists and tasks of a backup
+// that no longer exist
+//
+// The body may name one list, or one task (with its subtasks), of the
+// backup; otherwise everything is restored. Tasks that still exist are
+// never changed. Restored tasks get new IDs and keep their metadata; they
+// can be undone together, but re-created lists are not removed by undo.
+// ?dry_run=1 previews what would be created.
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, verified, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	var req api.RestoreRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	user := tenantUser(r.Context())
+	backup, err := readBackup(s.config().Backup.Dir, r.PathValue("id"))
+	if errors.Is(err, errBackupNotFound) {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Backup not found")
+		return
+	}
+	if err != nil {
+		log.Printf("Reading backup: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the backup")
+		return
+	}
+	account, ok := backupAccountOf(backup, user, verified)
+	if !ok {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "The backup has nothing for this account")
+		return
+	}
+
+	var selected []api.ListWithTasks
+	for _, list := range account.Lists {
+		if req.List != "" && list.ID != req.List {
+			continue
+		}
+		if req.Task != "" && !slices.ContainsFunc(list.Tasks, func(t api.Task) bool { return t.ID == req.Task }) {
+			continue
+		}
+		selected = append(selected, list)
+	}
+	if len(selected) == 0 && req.Task != "" {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Task not found in the backup")
+		return
+	}
+	if len(selected) == 0 && req.List != "" {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "List not found in the backup")
+		return
+	}
+
+	current, err := s.tasks.FetchAll(withRevalidation(r.Context()), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	metadata, err := s.metadataStore(user).All()
+	if err != nil {
+		log.Printf("Reading task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task metadata")
+		return
+	}
+	exists := make(map[string]bool)
+	restored := make(map[string]string) // current task IDs by backup ID
+	titles := make(map[string]string)   // list IDs by title
+	for _, list := range current {
+		// Without every list, a task that was moved could be taken for lost
+		if list.Error != nil {
+			writeAPIError(w, list.Error)
+			return
+		}
+		exists[list.ID] = true
+		titles[list.Title] = cmp.Or(titles[list.Title], list.ID)
+		for _, t := range list.Tasks {
+			exists[t.ID] = true
+			// A task restored before stands for the one it was restored from
+			if from := metadata[t.ID].RestoredFrom; from != "" {
+				exists[from], restored[from] = true, t.ID
+			}
+		}
+	}
+
+	resp := api.RestoreResponse{Backup: r.PathValue("id"), Created: backup.Created, DryRun: dryRun(r), Lists: []api.RestoredList{}, Tasks: []api.RestoredTask{}}
+	var steps []restoreStep
+	lists := make(map[string]string) // current list IDs by backup ID
+	for _, list := range selected {
+		listSteps, existing := restoreTasks(list, exists, req.Task)
+		resp.Existing += existing
+		steps = append(steps, listSteps...)
+		if exists[list.ID] {
+			continue
+		}
+		// A deleted list's tasks go to a list with its title, which may be
+		// the list created by an earlier restore; otherwise it is created
+		// again, also when it had no tasks
+		if id, ok := titles[list.Title]; ok {
+			lists[list.ID] = id
+		} else if len(listSteps) > 0 || req.Task == "" && len(list.Tasks) == 0 {
+			resp.Lists = append(resp.Lists, api.RestoredList{From: api.TaskList{ID: list.ID, Title: list.Title}})
+		}
+	}
+
+	for i := range resp.Lists {
+		restored := &resp.Lists[i]
+		if resp.DryRun {
+			continue
+		}
+		created, err := s.tasks.Write(r.Context(), token, ListInsertWrite(restored.From.Title))
+		if err != nil {
+			restored.Error = asAPIError(err, "Google Tasks request failed")
+			continue
+		}
+		restored.List = &api.TaskList{ID: created.ID, Title: created.Title}
+		lists[restored.From.ID] = created.ID
+	}
+
+	var undo [][]undoStep
+	for _, step := range steps {
+		change := step.change
+		change.List = cmp.Or(lists[change.List], change.List)
+		for _, id := range []*string{&change.Parent, &change.Previous} {
+			*id = cmp.Or(restored[*id], *id)
+		}
+		write, err := changeWrite(change)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, fmt.Sprintf("Invalid restore step: %v", err))
+			return
+		}
+		result := api.RestoredTask{From: step.from, List: api.TaskList{ID: step.list.ID, Title: step.list.Title}, Request: write}
+		if !resp.DryRun {
+			task, steps, err := s.writeUndoable(r, token, write)
+			if err != nil {
+				result.Error = asAPIError(err, "Google Tasks request failed")
+			} else {
+				result.Task = task
+				restored[step.restores] = task.ID
+				undo = append(undo, steps)
+				meta := account.Metadata[step.restores]
+				meta.RestoredFrom = step.restores
+				if _, err := s.metadataStore(user).Update(task.ID, func(m *api.TaskMetadata) { *m = meta }); err != nil {
+					log.Printf("Restoring task metadata: %v", err)
+				}
+			}
+		}
+		resp.Tasks = append(resp.Tasks, result)
+	}
+	if len(undo) > 0 {
+		s.recordUndo(r, token, changesSummary("restore", len(undo)), undo...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Handler serves the proxy's endpoints with access logging and request
+// timeouts, for embedding the proxy in another program's HTTP server.
+func (s *Server) Handler() http.Handler {
+	return s.accessLog(s.withTimeout(s.routes()))
+}
+
+// routes registers every endpoint with a method-qualified pattern. The mux
+// answers unknown paths with 404 and known paths with the wrong method with
+// 405 (plus an Allow header); both are rewritten into the error envelope.
+func (s *Server) routes() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("POST /auth/start", s.handleAuthStart)
+	mux.HandleFunc("POST /auth/token", s.handleToken)
+	mux.HandleFunc("POST /auth/refresh", s.handleRefresh)
+	mux.HandleFunc("POST /auth/session", s.handleSession)
+	mux.HandleFunc("GET /auth/callback", s.handleCallback)
+	mux.HandleFunc("GET /auth/poll/{state}", s.handlePoll)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /openapi.json", s.handleOpenAPI)
+
+	mux.HandleFunc("GET /{$}", s.handleSetup)
+	mux.HandleFunc("POST /setup", s.handleSetupSave)
+	mux.HandleFunc("GET /ui", s.handleUI)
+	mux.HandleFunc("GET /share/{token}", s.handleSharedList)
+
+	mux.HandleFunc("GET /api/lists", s.handleListTaskLists)
+	mux.HandleFunc("GET /api/lists/{list}/tasks", s.handleListTasks)
+	mux.HandleFunc("POST /api/lists/{list}/clear", s.handleClearList)
+	mux.HandleFunc("POST /api/tasklists/{list}/share", s.handleShareList)
+	mux.HandleFunc("GET /api/shares", s.handleShares)
+	mux.HandleFunc("DELETE /api/shares/{id}", s.handleRevokeShare)
+	mux.HandleFunc("GET /api/archive", s.handleArchive)
+	mux.HandleFunc("GET /api/stats/trends", s.handleTrends)
+	mux.HandleFunc("GET /api/usage", s.handleUsage)
+	mux.HandleFunc("GET /api/backup/status", s.handleBackupStatus)
+	mux.HandleFunc("GET /api/backup/list", s.handleBackupList)
+	mux.HandleFunc("POST /api/backup/{id}/restore", s.handleRestore)
+	mux.HandleFunc("GET /api/tasks", s.handleAllTasks)
+	mux.HandleFunc("GET /api/agenda", s.handleAgenda)
+	mux.HandleFunc("GET /api/smart", s.handleSmartLists)
+	mux.HandleFunc("GET /api/smart/{list}", s.handleSmartList)
+	mux.HandleFunc("GET /api/tasks/quickfix", s.handleQuickfix)
+	mux.HandleFunc("GET /api/search/fuzzy", s.handleFuzzySearch)
+	mux.HandleFunc("GET /api/duplicates", s.handleDuplicates)
+	mux.HandleFunc("POST /api/duplicates/merge", s.handleMergeDuplicates)
+	mux.HandleFunc("POST /api/tasks/{task}/snooze", s.handleSnooze)
+	mux.HandleFunc("POST /api/quickadd", s.handleQuickAdd)
+	mux.HandleFunc("POST /api/tasks/{task}/schedule", s.handleSchedule)
+	mux.HandleFunc("POST /api/tasks/from-email", s.handleFromEmail)
+	mux.HandleFunc("POST /api/import/todos", s.handleImportTodos)
+	mux.HandleFunc("GET /api/tasks/{task}/links", s.handleTaskLinks)
+	mux.HandleFunc("POST /api/tasks/{task}/links", s.handleAddTaskLink)
+	mux.HandleFunc("DELETE /api/tasks/{task}/links/{link}", s.handleRemoveTaskLink)
+	mux.HandleFunc("GET /api/tasks/{task}/location", s.handleTaskLocation)
+	mux.HandleFunc("PUT /api/tasks/{task}/location", s.handleSetTaskLocation)
+	mux.HandleFunc("DELETE /api/tasks/{task}/location", s.handleRemoveTaskLocation)
+	mux.HandleFunc("GET /api/tasks/{task}/history", s.handleTaskHistory)
+	mux.HandleFunc("POST /api/batch", s.handleBatch)
+	mux.HandleFunc("POST /api/tasks/bulk-status", s.handleBulkStatus)
+	mux.HandleFunc("POST /api/reconcile", s.handleReconcile)
+	mux.HandleFunc("GET /api/undo", s.handleUndoHistory)
+	mux.HandleFunc("POST /api/undo", s.handleUndo)
+	mux.HandleFunc("GET /api/events", s.handleEvents)
+
+	mux.HandleFunc("POST /admin/reload", s.handleReload)
+	mux.HandleFunc("GET /admin/update-check", s.handleUpdateCheck)
+	mux.HandleFunc("GET /admin/metrics", s.handleMetrics)
+	mux.HandleFunc("GET /admin/sessions", s.handleSessions)
+	mux.HandleFunc("DELETE /admin/sessions/{id}", s.handleExpireSession)
+	mux.HandleFunc("POST /admin/accounts/{user}/reauth", s.handleReauth)
+
+	// With the mock provider, clients that call the Tasks API directly (the
+	// plugin) can be pointed at /mock/tasks/v1 instead of Google
+	if s.mock != nil {
+		mux.Handle("/mock/tasks/v1/", http.StripPrefix("/mock", s.mock))
+	}
+	// Likewise at /github/tasks/v1 with the GitHub provider
+	if s.github != nil {
+		mux.Handle("/github/tasks/v1/", http.StripPrefix("/github", s.github))
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// CORS preflight is answered the same way for every path
+		if r.Method == "OPTIONS" {
+			s.handleOptions(w, r)
+			return
+		}
+
+		// Until the setup page has saved an OAuth client, nothing that
+		// talks to Google can work
+		if !s.config().configured() && (strings.HasPrefix(r.URL.Path, "/auth/") || strings.HasPrefix(r.URL.Path, "/api/")) {
+			writeError(w, http.StatusServiceUnavailable, api.CodeNotConfigured, "No Google OAuth client is configured; finish setup at /")
+			return
+		}
+
+		// In multi-tenant mode, requests act for the user of their session
+		if s.tenants != nil && needsSession(r.URL.Path) {
+			var ok bool
+			if r, ok = s.authorizeTenant(w, r); !ok {
+				return
+			}
+		}
+
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		// Let the mux decide between 404 and 405, then replace its
+		// plain-text body with the envelope.
+		probe := &headerProbe{header: make(http.Header)}
+		mux.ServeHTTP(probe, r)
+		if allow := probe.header.Get("Allow"); allow != "" {
+			w.Header().Set("Allow", allow)
+		}
+		if probe.status == http.StatusMethodNotAllowed {
+			methodNotAllowed(w)
+			return
+		}
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Not found")
+	})
+}
+
+// headerProbe records the status and headers of a response and discards
+// its body.
+type headerProbe struct {
+	header http.Header
+	status int
+}
+
+func (p *headerProbe) Header() http.Header { return p.header }
+
+func (p *headerProbe) Write(b []byte) (int, error) { return len(b), nil }
+
+func (p *headerProbe) WriteHeader(status int) { p.status = status }
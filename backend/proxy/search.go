@@ -0,0 +1,245 @@
+package proxy
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Scores of fuzzy matching, after fzf's: every matched character scores,
+// more so at the start of a word or of the title and right after the
+// previous match, and gaps between matches cost.
+const (
+	fuzzyMatch       = 16
+	fuzzyGapStart    = -3
+	fuzzyGapExtend   = -1
+	fuzzyBoundary    = 8  // after punctuation
+	fuzzyWhite       = 10 // at the start or after a space
+	fuzzyCamel       = 7  // a capital after a lower case letter, or a digit after a non-digit
+	fuzzyConsecutive = 4
+	fuzzyFirstFactor = 2 // the bonus of the pattern's first character counts double
+)
+
+// Limits of GET /api/search/fuzzy.
+const (
+	defaultFuzzyLimit = 50
+	maxFuzzyLimit     = 500
+	maxFuzzyQuery     = 128 // bytes
+)
+
+// fuzzyBonus is the bonus for matching r when it follows prev.
+func fuzzyBonus(prev, r rune) int {
+	switch {
+	case unicode.IsSpace(prev):
+		return fuzzyWhite
+	case !unicode.IsLetter(prev) && !unicode.IsDigit(prev):
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return fuzzyBoundary
+		}
+	case unicode.IsLower(prev) && unicode.IsUpper(r), !unicode.IsDigit(prev) && unicode.IsDigit(r):
+		return fuzzyCamel
+	}
+	return 0
+}
+
+// fuzzyTerm finds the best-scoring match of pattern as a subsequence of
+// text. It returns the score and the byte offsets of the matched runes; ok
+// is false when pattern is not a subsequence. Matching ignores case unless
+// caseSensitive.
+func fuzzyTerm(text, pattern []rune, offsets []int, caseSensitive bool) (score int, positions []int, ok bool) {
+	n, m := len(text), len(pattern)
+	if m == 0 || m > n {
+		return 0, nil, m == 0
+	}
+	fold := func(r rune) rune {
+		if caseSensitive {
+			return r
+		}
+		return unicode.ToLower(r)
+	}
+
+	// scores[j][i] is the best score of pattern[:j+1] with pattern[j] at
+	// text[i], from[j][i] where pattern[j-1] was then
+	const none = -1 << 30
+	scores := make([][]int, m)
+	from := make([][]int, m)
+	for j := range m {
+		scores[j] = make([]int, n)
+		from[j] = make([]int, n)
+		// The best score of pattern[:j] ending two or more runes back, less
+		// the gap, and where it ended
+		gapped, gappedAt := none, -1
+		for i := range n {
+			if j > 0 && i >= 2 && scores[j-1][i-2] != none {
+				if extended := scores[j-1][i-2] + fuzzyGapStart; extended > gapped+fuzzyGapExtend {
+					gapped, gappedAt = extended, i-2
+				} else {
+					gapped += fuzzyGapExtend
+				}
+			} else if gapped != none {
+				gapped += fuzzyGapExtend
+			}
+
+			scores[j][i] = none
+			if fold(text[i]) != fold(pattern[j]) {
+				continue
+			}
+			prev := ' '
+			if i > 0 {
+				prev = text[i-1]
+			}
+			bonus := fuzzyBonus(prev, text[i])
+			if j == 0 {
+				scores[j][i] = fuzzyMatch + bonus*fuzzyFirstFactor
+				from[j][i] = -1
+				continue
+			}
+			if i > 0 && scores[j-1][i-1] != none {
+				scores[j][i] = scores[j-1][i-1] + fuzzyMatch + max(bonus, fuzzyConsecutive)
+				from[j][i] = i - 1
+			}
+			if gapped != none && gapped+fuzzyMatch+bonus > scores[j][i] {
+				scores[j][i] = gapped + fuzzyMatch + bonus
+				from[j][i] = gappedAt
+			}
+		}
+	}
+
+	best, end := none, -1
+	for i, s := range scores[m-1] {
+		if s > best {
+			best, end = s, i
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+	positions = make([]int, m)
+	for j := m - 1; j >= 0; j-- {
+		positions[j] = offsets[end]
+		end = from[j][end]
+	}
+	return best, positions, true
+}
+
+// fuzzyScore matches query against text: each space-separated term of the
+// query must match, and the scores add up. A query with a capital letter
+// is matched case-sensitively, like fzf's smart case.
+func fuzzyScore(text, query string) (int, []int, bool) {
+	caseSensitive := strings.IndexFunc(query, unicode.IsUpper) >= 0
+	runes := make([]rune, 0, utf8.RuneCountInString(text))
+	offsets := make([]int, 0, cap(runes))
+	for i, r := range text {
+		runes = append(runes, r)
+		offsets = append(offsets, i)
+	}
+
+	total := 0
+	positions := []int{}
+	for _, term := range strings.Fields(query) {
+		score, matched, ok := fuzzyTerm(runes, []rune(term), offsets, caseSensitive)
+		if !ok {
+			return 0, nil, false
+		}
+		total += score
+		positions = append(positions, matched...)
+	}
+	slices.Sort(positions)
+	return total, slices.Compact(positions), true
+}
+
+// fuzzySearch returns the tasks in lists whose title matches query, in
+// fuzzyKey order.
+func fuzzySearch(lists []api.ListWithTasks, query string) ([]api.FuzzyMatch, []api.ListWithTasks) {
+	matches := []api.FuzzyMatch{}
+	var failed []api.ListWithTasks
+	for _, list := range lists {
+		if list.Error != nil {
+			failed = append(failed, list)
+			continue
+		}
+		for _, task := range list.Tasks {
+			if task.Deleted {
+				continue
+			}
+			if score, positions, ok := fuzzyScore(task.Title, query); ok {
+				matches = append(matches, api.FuzzyMatch{List: list.TaskList, Task: task, Score: score, Positions: positions})
+			}
+		}
+	}
+
+	sortByKey(matches, fuzzyKey)
+	return matches, failed
+}
+
+// fuzzyKey orders matches best first: by score, then open tasks and shorter
+// titles first, then by ID so every match has its own place for cursors.
+func fuzzyKey(m api.FuzzyMatch) string {
+	completed := 0
+	if m.Task.Status == "completed" {
+		completed = 1
+	}
+	// Inverted so a higher score sorts first; scores stay far below 1<<40
+	return fmt.Sprintf("%013d\x00%d\x00%08d\x00%s\x00%s", 1<<40-m.Score, completed, len(m.Task.Title), m.List.ID, m.Task.ID)
+}
+
+// GET /api/search/fuzzy - fzf-style fuzzy search of task titles across lists
+//
+// ?q= is matched as a subsequence of each title, ignoring case unless it
+// has a capital; space-separated terms must all match. The best ?limit=
+// matches (50 by default) are returned with the matched positions, and
+// ?cursor= continues with the next ones. Titles are read through the cache,
+// so a picker can search on every keystroke.
+// Tasks created from code have their location, for the picker to jump to.
+func (s *Server) handleFuzzySearch(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	q := strings.TrimSpace(query.Get("q"))
+	if len(q) > maxFuzzyQuery {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "q is longer than "+strconv.Itoa(maxFuzzyQuery)+" bytes")
+		return
+	}
+	limit, err := pageLimit(query, defaultFuzzyLimit, maxFuzzyLimit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+
+	matches, failed := fuzzySearch(lists, q)
+	resp := api.FuzzySearchResponse{Query: q, Total: len(matches), Failed: failed}
+	resp.Matches, resp.NextCursor, err = cursorPage(matches, fuzzyKey, cursorScope(url.Values{"q": {q}}, "q"), query.Get("cursor"), limit)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	if metadata, err := s.metadataStore(tenantUser(r.Context())).All(); err != nil {
+		log.Printf("Reading task metadata: %v", err)
+	} else {
+		for i := range resp.Matches {
+			resp.Matches[i].Location = metadata[resp.Matches[i].Task.ID].Location
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
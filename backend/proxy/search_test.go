@@ -0,0 +1,126 @@
+package proxy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// fuzzyPositions returns where query matches text, or nil when it does not.
+func fuzzyPositions(text, query string) []int {
+	_, positions, ok := fuzzyScore(text, query)
+	if !ok {
+		return nil
+	}
+	return positions
+}
+
+func TestFuzzyScoreMatches(t *testing.T) {
+	// Byte offsets, so highlights land on multi-byte titles
+	if got := fuzzyPositions("café au lait", "éa"); !slices.Equal(got, []int{3, 6}) {
+		t.Errorf("positions past a multi-byte rune = %v", got)
+	}
+	// Terms match independently and in any order; their positions merge
+	if got := fuzzyPositions("buy milk", "milk buy"); !slices.Equal(got, []int{0, 1, 2, 4, 5, 6, 7}) {
+		t.Errorf("two terms = %v", got)
+	}
+	// and may share a rune
+	if got := fuzzyPositions("banana", "a a"); !slices.Equal(got, []int{1}) {
+		t.Errorf("terms sharing a rune = %v", got)
+	}
+	// An empty query matches everything, with nothing to highlight
+	if got := fuzzyPositions("buy milk", ""); got == nil || len(got) != 0 {
+		t.Errorf("empty query = %#v", got)
+	}
+
+	for _, query := range []string{"mb", "milk x", "abc"} {
+		if got := fuzzyPositions("buy milk", query); got != nil {
+			t.Errorf("%q matched at %v", query, got)
+		}
+	}
+	if got := fuzzyPositions("", "a"); got != nil {
+		t.Errorf("empty title matched at %v", got)
+	}
+}
+
+func TestFuzzyScoreSmartCase(t *testing.T) {
+	if fuzzyPositions("Buy Milk", "bm") == nil {
+		t.Error("a lower-case query should ignore case")
+	}
+	if fuzzyPositions("buy milk", "BM") != nil {
+		t.Error("a query with a capital should match case-sensitively")
+	}
+	// Each term decides for itself
+	if fuzzyPositions("Buy milk", "B milk") == nil || fuzzyPositions("buy milk", "B milk") != nil {
+		t.Error("smart case should apply per term")
+	}
+}
+
+func TestFuzzyScoreValues(t *testing.T) {
+	// b at the start (16 + 2×10), a gap of three (-3 -1 -1), m after a space (16 + 10)
+	if score, _, _ := fuzzyScore("buy milk", "bm"); score != 57 {
+		t.Errorf("score = %d, want 57", score)
+	}
+	milk, _, _ := fuzzyScore("buy milk", "milk")
+	buy, _, _ := fuzzyScore("buy milk", "buy")
+	if score, _, _ := fuzzyScore("buy milk", "milk buy"); score != milk+buy {
+		t.Errorf("score = %d, want the terms' %d + %d", score, milk, buy)
+	}
+	// The best alignment wins, not the first one found
+	if got := fuzzyPositions("smilk milk", "milk"); !slices.Equal(got, []int{6, 7, 8, 9}) {
+		t.Errorf("positions = %v, want the match at the start of a word", got)
+	}
+}
+
+func TestFuzzyScoreRanking(t *testing.T) {
+	// Each pair matches the query; the first should rank higher
+	for _, pair := range [][3]string{
+		{"mi", "milk", "swimming"},      // start of the title
+		{"mi", "buy milk", "swimming"},  // start of a word
+		{"ml", "ml notes", "milk"},      // consecutive
+		{"bc", "bake-cake", "backpack"}, // after punctuation
+		{"gt", "getTasks", "gutter"},    // camel case
+	} {
+		query, better, worse := pair[0], pair[1], pair[2]
+		b, _, ok1 := fuzzyScore(better, query)
+		w, _, ok2 := fuzzyScore(worse, query)
+		if !ok1 || !ok2 || b <= w {
+			t.Errorf("%q: %q scores %d and %q %d; want the first higher", query, better, b, worse, w)
+		}
+	}
+}
+
+func TestFuzzySearch(t *testing.T) {
+	lists := []api.ListWithTasks{
+		{TaskList: api.TaskList{ID: "home", Title: "Home"}, Tasks: []api.Task{
+			{ID: "1", Title: "milk the cow", Status: "completed"},
+			{ID: "2", Title: "milk", Status: "completed"},
+			{ID: "3", Title: "milk", Deleted: true},
+		}},
+		{TaskList: api.TaskList{ID: "work", Title: "Work"}, Error: &api.APIError{Message: "rate limited"}},
+		{TaskList: api.TaskList{ID: "shop", Title: "Shopping"}, Tasks: []api.Task{
+			{ID: "4", Title: "milk the cow", Status: "needsAction"},
+			{ID: "5", Title: "bread"},
+		}},
+	}
+
+	matches, failed := fuzzySearch(lists, "milk")
+
+	// Equal scores: open tasks, then shorter titles; deleted tasks never
+	var got []string
+	for _, m := range matches {
+		got = append(got, m.List.ID+"/"+m.Task.ID)
+	}
+	if want := []string{"shop/4", "home/2", "home/1"}; !slices.Equal(got, want) {
+		t.Errorf("matches = %v, want %v", got, want)
+	}
+	if len(failed) != 1 || failed[0].ID != "work" {
+		t.Errorf("failed = %+v", failed)
+	}
+
+	// No match is an empty list, not null
+	if matches, _ := fuzzySearch(lists, "xyz"); matches == nil || len(matches) != 0 {
+		t.Errorf("no matches = %#v", matches)
+	}
+}
@@ -0,0 +1,649 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+type PKCEState struct {
+	CodeVerifier string
+	User         string // tenant that started the flow, in multi-tenant mode
+	Timestamp    int64
+}
+
+type CompletedAuth struct {
+	Tokens    map[string]any
+	User      string
+	Timestamp int64
+}
+
+type Server struct {
+	states        *LRU[PKCEState]
+	completedAuth *LRU[CompletedAuth]
+	accounts      *LRU[string] // verified account IDs, by sessionKey of the access token
+	mutex         sync.RWMutex
+	current       atomic.Pointer[Config]
+	loadConfig    func() (*Config, error)
+	jobs          *Scheduler
+	upstream      *UpstreamClient
+	tasks         *TasksClient
+	mock          *MockGoogle  // set when provider.name is mock
+	github        *GitHubTasks // set when provider.name is github
+	events        *EventHub
+	local         watch // the `auth login` account, for reminders to local sinks
+	overdueHooks  overdueHooks
+	snoozes       *SnoozeStore
+	metadata      *MetadataStore
+	archive       *ArchiveStore
+	history       *HistoryStore
+	shares        *ShareStore
+	tenants       *Tenants // set in multi-tenant mode
+	undo          *UndoStore
+	backups       backupState
+	onChange      []changeListener
+	setupCode     string // guards the setup page while no OAuth client is configured
+	refreshes     flightGroup[*tokenResponse]
+	updates       updateChecker
+	shutdownHooks []func(context.Context) error
+}
+
+type GoogleConfig struct {
+	ClientID     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+	Scope        string `json:"-"`
+}
+
+// NewServer creates a server for cfg. loadConfig is called to reload the
+// configuration (SIGHUP, /admin/reload and the setup page).
+func NewServer(cfg *Config, loadConfig func() (*Config, error)) (*Server, error) {
+	s := &Server{
+		states:        NewLRU[PKCEState]("auth_states", cfg.MaxPendingAuth),
+		completedAuth: NewLRU[CompletedAuth]("auth_completed", cfg.MaxPendingAuth),
+		accounts:      NewLRU[string]("accounts", maxAccounts),
+		loadConfig:    loadConfig,
+		jobs:          NewScheduler(cfg.JobWorkers),
+		upstream:      NewUpstreamClient(),
+		events:        NewEventHub(),
+		snoozes:       NewSnoozeStore(cfg.Reminders.SnoozeFile),
+		metadata:      NewMetadataStore(cfg.MetadataFile),
+		archive:       NewArchiveStore(cfg.ArchiveFile),
+		history:       NewHistoryStore(cfg.HistoryFile),
+		shares:        NewShareStore(cfg.ShareFile),
+		undo:          NewUndoStore(),
+	}
+	s.current.Store(cfg)
+	if err := s.upstream.UseProxy(cfg.Upstream.Proxy); err != nil {
+		return nil, err
+	}
+	if err := s.upstream.UseCA(cfg.Upstream.CAFile, cfg.Upstream.SystemRoots); err != nil {
+		return nil, fmt.Errorf("upstream.ca_file: %w", err)
+	}
+	if cfg.Tenants.Enabled {
+		s.tenants = NewTenants(cfg.Tenants.Dir)
+	}
+	s.onChange = []changeListener{s.streamChanges, s.remindOnChange, s.recordObserved}
+	if !cfg.configured() {
+		code, err := generateRandomString(9)
+		if err != nil {
+			return nil, err
+		}
+		s.setupCode = code
+	}
+	if cfg.Provider == "mock" {
+		s.mock = NewMockGoogle()
+		s.upstream.client.Transport = s.mock
+	}
+	if cfg.Provider == "github" {
+		s.github = NewGitHubTasks(s.upstream.client.Transport, func() GitHubConfig { return s.config().GitHub })
+		s.upstream.client.Transport = s.github
+	}
+	if cfg.FixturesMode != "off" {
+		fixtures, err := NewFixtureTransport(cfg.FixturesMode, cfg.FixturesFile, s.upstream.client.Transport)
+		if err != nil {
+			return nil, err
+		}
+		s.upstream.client.Transport = fixtures
+	}
+	s.tasks = NewTasksClient(s.upstream, NewResponseCache(cfg.CacheMaxEntries, func() time.Duration {
+		return s.config().CacheTTL
+	}))
+	return s, nil
+}
+
+// config returns the active configuration. Handlers should call it once and
+// use the result so a concurrent reload cannot mix old and new values.
+func (s *Server) config() *Config {
+	return s.current.Load()
+}
+
+func generateRandomString(length int) (string, error) {
+	bytes := make([]byte, length)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(bytes), nil
+}
+
+func generatePKCE() (string, string, error) {
+	codeVerifier, err := generateRandomString(96)
+	if err != nil {
+		return "", "", err
+	}
+
+	hash := sha256.Sum256([]byte(codeVerifier))
+	codeChallenge := base64.RawURLEncoding.EncodeToString(hash[:])
+
+	return codeVerifier, codeChallenge, nil
+}
+
+func generateState() (string, error) {
+	return generateRandomString(32)
+}
+
+func (s *Server) cleanupExpiredStates() {
+	s.states.Purge()
+	s.completedAuth.Purge()
+	s.accounts.Purge()
+	s.undo.histories.Purge()
+}
+
+func (s *Server) enableCORS(w http.ResponseWriter) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Timeout, "+api.SessionHeader)
+}
+
+func (s *Server) handleOptions(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+	w.WriteHeader(http.StatusOK)
+}
+
+// POST /auth/start - Generate authorization URL
+func (s *Server) handleAuthStart(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	response, err := s.beginAuth(r.Context(), tenantUser(r.Context()))
+	if err != nil {
+		log.Printf("Error starting auth: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to start authorization")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// beginAuth records a new PKCE state for user ("" unless multi-tenant) and
+// builds the Google authorization URL for it. The callback completes the
+// state, which is then collected by polling. The GitHub provider starts its
+// device flow instead.
+func (s *Server) beginAuth(ctx context.Context, user string) (api.AuthStartResponse, error) {
+	if s.github != nil {
+		return s.beginDeviceAuth(ctx, user)
+	}
+
+	codeVerifier, codeChallenge, err := generatePKCE()
+	if err != nil {
+		return api.AuthStartResponse{}, fmt.Errorf("generating PKCE: %w", err)
+	}
+
+	state, err := generateState()
+	if err != nil {
+		return api.AuthStartResponse{}, fmt.Errorf("generating state: %w", err)
+	}
+
+	// Store PKCE state
+	s.states.Set(state, PKCEState{
+		CodeVerifier: codeVerifier,
+		User:         user,
+		Timestamp:    time.Now().Unix(),
+	}, s.config().StateTTL)
+
+	// Build authorization URL
+	google := s.config().Google
+	authURL := url.URL{
+		Scheme: "https",
+		Host:   "accounts.google.com",
+		Path:   "/o/oauth2/v2/auth",
+	}
+
+	params := authURL.Query()
+	params.Set("client_id", google.ClientID)
+	params.Set("redirect_uri", google.RedirectURI)
+	params.Set("response_type", "code")
+	params.Set("scope", google.Scope)
+	params.Set("access_type", "offline")
+	params.Set("prompt", "consent")
+	params.Set("code_challenge", codeChallenge)
+	params.Set("code_challenge_method", "S256")
+	params.Set("state", state)
+	authURL.RawQuery = params.Encode()
+
+	if s.mock != nil {
+		return api.AuthStartResponse{AuthURL: s.mock.AuthURL(google.RedirectURI, state), State: state}, nil
+	}
+
+	return api.AuthStartResponse{
+		AuthURL: authURL.String(),
+		State:   state,
+	}, nil
+}
+
+// POST /auth/token - Exchange authorization code for tokens
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	var req api.TokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if req.Code == "" || req.State == "" {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Missing code or state parameter")
+		return
+	}
+
+	// Retrieve and validate PKCE state
+	pkceData, exists := s.states.Take(req.State)
+
+	if !exists || pkceData.User != tenantUser(r.Context()) {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidState, "Invalid or expired state")
+		return
+	}
+
+	// Prepare token exchange request
+	google := s.config().Google
+	data := url.Values{}
+	data.Set("client_id", google.ClientID)
+	data.Set("client_secret", google.ClientSecret)
+	data.Set("code", req.Code)
+	data.Set("redirect_uri", google.RedirectURI)
+	data.Set("grant_type", "authorization_code")
+	data.Set("code_verifier", pkceData.CodeVerifier)
+
+	// Make request to Google
+	resp, err := s.upstream.PostForm(r.Context(), googleTokenURL, data)
+	var tokens *tokenResponse
+	if err == nil {
+		tokens, err = readTokenResponse(resp)
+	}
+	if err != nil {
+		log.Printf("Token exchange error: %v", err)
+		writeUpstreamError(w, err, "Token exchange failed")
+		return
+	}
+
+	keepTenantToken(tenantFrom(r.Context()), tokens)
+	forwardTokenResponse(w, tokens, "Token exchange failed")
+}
+
+// POST /auth/refresh - Refresh access token
+func (s *Server) handleRefresh(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	var req api.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+
+	if req.RefreshToken == "" {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Missing refresh_token parameter")
+		return
+	}
+
+	resp, err, shared := s.refreshToken(r.Context(), req.RefreshToken)
+	if err != nil {
+		log.Printf("Token refresh error: %v", err)
+		writeUpstreamError(w, err, "Token refresh failed")
+		return
+	}
+	if shared {
+		slog.Debug("coalesced concurrent token refresh", "request_id", requestID(r.Context()))
+	}
+
+	keepTenantToken(tenantFrom(r.Context()), resp)
+	forwardTokenResponse(w, resp, "Token refresh failed")
+}
+
+// refreshToken exchanges a refresh token at Google. Concurrent refreshes of
+// the same token share one upstream call, which is detached from any single
+// caller's request; a caller that gives up stops waiting for it.
+func (s *Server) refreshToken(ctx context.Context, refreshToken string) (*tokenResponse, error, bool) {
+	google := s.config().Google
+	data := url.Values{}
+	data.Set("client_id", google.ClientID)
+	data.Set("client_secret", google.ClientSecret)
+	data.Set("refresh_token", refreshToken)
+	data.Set("grant_type", "refresh_token")
+
+	hash := sha256.Sum256([]byte(refreshToken))
+	return s.refreshes.Do(ctx, hex.EncodeToString(hash[:]), func() (*tokenResponse, error) {
+		resp, err := s.upstream.PostForm(context.WithoutCancel(ctx), googleTokenURL, data)
+		if err != nil {
+			return nil, err
+		}
+		return readTokenResponse(resp)
+	})
+}
+
+// tokenResponse is a fully read Google token endpoint response, safe to
+// share between coalesced callers.
+type tokenResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// httpResponse rebuilds the response for upstreamResponseError.
+func (t *tokenResponse) httpResponse() *http.Response {
+	return &http.Response{
+		StatusCode: t.status,
+		Header:     t.header,
+		Body:       io.NopCloser(bytes.NewReader(t.body)),
+	}
+}
+
+func readTokenResponse(resp *http.Response) (*tokenResponse, error) {
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &tokenResponse{status: resp.StatusCode, header: resp.Header, body: body}, nil
+}
+
+// forwardTokenResponse relays a successful Google token response as-is and
+// wraps failures in the error envelope.
+func forwardTokenResponse(w http.ResponseWriter, resp *tokenResponse, failure string) {
+	if resp.status < 200 || resp.status > 299 {
+		writeAPIError(w, upstreamResponseError(resp.httpResponse(), failure))
+		return
+	}
+
+	var result map[string]any
+	if err := json.Unmarshal(resp.body, &result); err != nil {
+		log.Printf("Error decoding Google response: %v", err)
+		writeError(w, http.StatusBadGateway, api.CodeUpstreamError, "Failed to parse Google response")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// GET /auth/callback - OAuth callback handler
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	// Extract authorization code and state from query parameters
+	code := r.URL.Query().Get("code")
+	state := r.URL.Query().Get("state")
+	errorParam := r.URL.Query().Get("error")
+
+	lang := s.pageLanguage(r)
+	if errorParam != "" {
+		// OAuth error occurred
+		message := translate(lang, "error.other", errorParam)
+		if key, ok := oauthErrors[errorParam]; ok {
+			message = translate(lang, key)
+		}
+		writeMessagePage(w, lang, http.StatusOK, false, translate(lang, "error.title"), message, translate(lang, "close"))
+		return
+	}
+
+	if code == "" || state == "" {
+		writeMessagePage(w, lang, http.StatusOK, false, translate(lang, "error.title"),
+			translate(lang, "error.missing"), translate(lang, "close"))
+		return
+	}
+
+	// Exchange code for tokens in the background so the page renders
+	// immediately; the plugin picks the tokens up by polling.
+	google := s.config().Google
+	s.jobs.Submit(Job{
+		Name:     "oauth-code-exchange",
+		Priority: PriorityHigh,
+		Run: func(ctx context.Context) error {
+			return s.exchangeCode(ctx, google, state, code)
+		},
+	})
+
+	// Return success page with instructions
+	writeMessagePage(w, lang, http.StatusOK, true, translate(lang, "success.title"),
+		translate(lang, "success.body"), translate(lang, "close"))
+}
+
+// exchangeCode trades an authorization code for tokens and completes state,
+// for the poller to collect. Unknown or expired states are ignored.
+func (s *Server) exchangeCode(ctx context.Context, google GoogleConfig, state, code string) error {
+	// Get PKCE state
+	pkceData, exists := s.states.Take(state)
+
+	if !exists {
+		log.Printf("Invalid state in callback: %s", state)
+		return nil
+	}
+
+	// Exchange code for tokens. The code is single use, so let the
+	// exchange finish even if shutdown begins meanwhile.
+	data := url.Values{}
+	data.Set("client_id", google.ClientID)
+	data.Set("client_secret", google.ClientSecret)
+	data.Set("code", code)
+	data.Set("redirect_uri", google.RedirectURI)
+	data.Set("grant_type", "authorization_code")
+	data.Set("code_verifier", pkceData.CodeVerifier)
+
+	resp, err := s.upstream.PostForm(context.WithoutCancel(ctx), googleTokenURL, data)
+	var raw *tokenResponse
+	if err == nil {
+		raw, err = readTokenResponse(resp)
+	}
+	if err != nil {
+		return fmt.Errorf("token exchange: %w", err)
+	}
+	return s.completeAuth(ctx, state, pkceData.User, raw)
+}
+
+// completeAuth completes state with a token response, for the poller to
+// collect, and runs the auth.completed hooks.
+func (s *Server) completeAuth(ctx context.Context, state, user string, raw *tokenResponse) error {
+	var tokens map[string]any
+	if err := json.Unmarshal(raw.body, &tokens); err != nil {
+		return fmt.Errorf("decoding token response: %w", err)
+	}
+	keepTenantToken(s.tenant(user), raw)
+
+	// Store completed auth
+	s.completedAuth.Set(state, CompletedAuth{
+		Tokens:    tokens,
+		User:      user,
+		Timestamp: time.Now().Unix(),
+	}, s.config().StateTTL)
+
+	log.Printf("Successfully completed OAuth for state: %s", state)
+
+	// Run here rather than queued, so `auth login` can wait for the hooks
+	// before it exits
+	scope, _ := tokens["scope"].(string)
+	if err := s.runHooks(context.WithoutCancel(ctx), api.HookAuthCompleted, user, api.AuthCompletedHook{Scope: scope}); err != nil {
+		log.Printf("Hooks for %s failed: %v", api.HookAuthCompleted, err)
+	}
+	return nil
+}
+
+// GET /auth/poll/{state} - Poll for completion of OAuth flow
+func (s *Server) handlePoll(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	state := r.PathValue("state")
+
+	// Check if auth is completed, removing it so tokens are handed out once.
+	// In multi-tenant mode only the user who started the flow gets them.
+	authData, exists := s.completedAuth.Get(state)
+	if exists && authData.User == tenantUser(r.Context()) {
+		authData, exists = s.completedAuth.Take(state)
+	} else {
+		exists = false
+	}
+
+	if !exists {
+		// Not completed yet
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(api.PollResponse{Completed: false})
+		return
+	}
+
+	// Completed - return tokens
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.PollResponse{Completed: true, Tokens: authData.Tokens})
+}
+
+// GET /health - Health check
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	response := api.HealthResponse{
+		Status:    "ok",
+		Version:   version,
+		Timestamp: time.Now().Format(time.RFC3339),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// serve runs the auth proxy until SIGINT or SIGTERM and returns the exit code.
+func serve(args []string) int {
+	fs := flag.NewFlagSet("gtask-auth-proxy serve", flag.ContinueOnError)
+	flags := addConfigFlags(fs)
+	if _, err := parseArgs(fs, args); err != nil {
+		return usageError(err)
+	}
+	// Without an OAuth client, start anyway and serve the setup page
+	cfg, err := flags.Load()
+	if errors.Is(err, ErrMissingCredentials) {
+		cfg, err = flags.LoadIncomplete()
+	}
+	if err != nil {
+		log.Printf("Invalid configuration: %v", err)
+		return 1
+	}
+
+	slog.SetLogLoggerLevel(cfg.LogLevel)
+	if cfg.LogFile != "" {
+		// Keep stderr too; it is only lost when Neovim spawned the proxy
+		logFile, err := OpenRotatingFile(cfg.LogFile, cfg.LogMaxSize, cfg.LogMaxFiles)
+		if err != nil {
+			log.Printf("Opening log file: %v", err)
+			return 1
+		}
+		defer logFile.Close()
+		// The file comes first and stderr's errors are dropped, so a closed
+		// stderr neither keeps lines from the file nor, as a broken pipe,
+		// kills the process with SIGPIPE
+		signal.Ignore(syscall.SIGPIPE)
+		log.SetOutput(io.MultiWriter(logFile, bestEffort{os.Stderr}))
+		defer log.SetOutput(os.Stderr)
+	}
+
+	server, err := NewServer(cfg, flags.Load)
+	if err != nil {
+		log.Printf("Failed to start: %v", err)
+		return 1
+	}
+	addrs := cfg.listenAddrs()
+	listeners, err := listen(addrs)
+	if err != nil {
+		log.Printf("Server failed to start: %v", err)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	server.Start(ctx)
+
+	slog.Debug("configuration loaded", "config_file", cfg.Path, "credentials_file", cfg.CredentialsFile,
+		"state_ttl", cfg.StateTTL, "cleanup_interval", cfg.CleanupInterval)
+
+	httpServer := &http.Server{Handler: server.Handler()}
+	httpServer.RegisterOnShutdown(server.events.Close)
+	serveErr := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func() {
+			serveErr <- httpServer.Serve(l)
+		}()
+	}
+
+	var names []string
+	for _, addr := range addrs {
+		names = append(names, addr.String())
+	}
+	log.Printf("Gtask auth proxy listening on %s", strings.Join(names, ", "))
+	base := localURL(addrs)
+	if base == "" {
+		// Only unix sockets, which curl reaches with --unix-socket
+		base = "http://localhost"
+	}
+	log.Printf("Health check: %s/health", base)
+	log.Printf("Dashboard: %s/ui", base)
+	if cfg.FixturesMode != "off" {
+		log.Printf("Fixtures: %s Google interactions in %s", map[string]string{"record": "recording", "replay": "replaying"}[cfg.FixturesMode], cfg.FixturesFile)
+	}
+	if server.setupCode != "" {
+		log.Printf("No Google OAuth client is configured. Finish setup at %s/?code=%s", base, server.setupCode)
+	}
+	if server.mock != nil {
+		log.Printf("Using the mock provider: in-memory data at %s/mock/tasks/v1, Google is never contacted", base)
+	}
+	if server.github != nil {
+		log.Printf("Using the GitHub provider: assigned issues as tasks at %s/github/tasks/v1, Google is never contacted", base)
+	}
+
+	select {
+	case err := <-serveErr:
+		log.Printf("Server failed: %v", err)
+		return 1
+	case <-ctx.Done():
+	}
+	stop()
+
+	log.Printf("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), server.config().ShutdownTimeout)
+	defer cancel()
+
+	exitCode := 0
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		log.Printf("HTTP shutdown error: %v", err)
+		exitCode = 1
+	}
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Shutdown error: %v", err)
+		exitCode = 1
+	}
+
+	log.Printf("Shutdown complete")
+	return exitCode
+}
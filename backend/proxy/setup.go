@@ -0,0 +1,208 @@
+package proxy
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+type setupScope struct{ Value, Label string }
+
+// setupScopes are the scopes the setup page offers.
+var setupScopes = []setupScope{
+	{"https://www.googleapis.com/auth/tasks", "Read and edit tasks"},
+	{"https://www.googleapis.com/auth/tasks.readonly", "Read tasks only"},
+}
+
+// setupForm is what the setup page shows and submits.
+type setupForm struct {
+	Code         string
+	ClientID     string
+	ClientSecret string
+	Scope        string
+	RedirectURI  string
+	Scopes       []setupScope
+	Error        string
+	Warnings     []string
+	Path         string // config file written, after saving
+}
+
+var setupPage = template.Must(template.New("setup").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>gtask auth proxy setup</title>
+<style>
+	body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+	label { display: block; margin-top: 1em; }
+	input[type=text], input[type=password] { width: 100%; box-sizing: border-box; }
+	.error { color: #b00020; }
+	.warning { color: #8a6d00; }
+</style></head><body>
+<h1>gtask auth proxy setup</h1>
+{{if .Path}}
+	<p>Saved to <code>{{.Path}}</code>.</p>
+	{{range .Warnings}}<p class="warning">{{.}}</p>{{end}}
+	<p>Sign in once to check that Google accepts the client and redirect URI.</p>
+	<button id="test">Test sign-in</button>
+	<p id="result"></p>
+	<script>
+	document.getElementById("test").onclick = async function () {
+		const result = document.getElementById("result");
+		const show = function (text, cls) { result.textContent = text; result.className = cls || ""; };
+		try {
+			const start = await (await fetch("auth/start", { method: "POST" })).json();
+			if (!start.authUrl) { throw new Error(start.error ? start.error.message : "could not start sign-in"); }
+			window.open(start.authUrl, "_blank");
+			show("Waiting for you to finish signing in...");
+			for (let i = 0; i < 300; i++) {
+				await new Promise(function (r) { setTimeout(r, 2000); });
+				const poll = await (await fetch("auth/poll/" + encodeURIComponent(start.state))).json();
+				if (!poll.completed) { continue; }
+				if (poll.tokens && poll.tokens.access_token) {
+					show("Signed in. The proxy is ready; point gtask.nvim at it.");
+				} else {
+					show("Google refused the sign-in: " + ((poll.tokens && (poll.tokens.error_description || poll.tokens.error)) || "unknown error"), "error");
+				}
+				return;
+			}
+			show("Timed out waiting for sign-in.", "error");
+		} catch (e) {
+			show("Sign-in failed: " + e.message, "error");
+		}
+	};
+	</script>
+{{else}}
+	<p>No Google OAuth client is configured yet. Create a <em>Web application</em>
+	client in Google Cloud Console &gt; APIs &amp; Services &gt; Credentials, with
+	the redirect URI below as an authorized redirect URI, and enter it here.</p>
+	{{with .Error}}<p class="error">{{.}}</p>{{end}}
+	<form method="post" action="setup">
+		<input type="hidden" name="code" value="{{.Code}}">
+		<label>Client ID <input type="text" name="client_id" value="{{.ClientID}}" required></label>
+		<label>Client secret <input type="password" name="client_secret" value="{{.ClientSecret}}" required></label>
+		<fieldset style="margin-top: 1em"><legend>Access</legend>
+		{{range .Scopes}}<label><input type="radio" name="scope" value="{{.Value}}" {{if eq .Value $.Scope}}checked{{end}}> {{.Label}}</label>{{end}}
+		</fieldset>
+		<label>Redirect URI <input type="text" name="redirect_uri" value="{{.RedirectURI}}" required></label>
+		<p><button type="submit">Save</button></p>
+	</form>
+{{end}}
+</body></html>
+`))
+
+// GET / - Setup page, served only while no OAuth client is configured
+//
+// The page is guarded by the code logged at startup, since anyone who can
+// reach the proxy could otherwise configure it.
+func (s *Server) handleSetup(w http.ResponseWriter, r *http.Request) {
+	if !s.setupAllowed(w, r.URL.Query().Get("code")) {
+		return
+	}
+
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	renderSetup(w, http.StatusOK, setupForm{
+		Code:        s.setupCode,
+		Scope:       s.config().Google.Scope,
+		RedirectURI: scheme + "://" + r.Host + "/auth/callback",
+	})
+}
+
+// POST /setup - Save the OAuth client from the setup page to the config file
+func (s *Server) handleSetupSave(w http.ResponseWriter, r *http.Request) {
+	if !s.setupAllowed(w, r.PostFormValue("code")) {
+		return
+	}
+
+	form := setupForm{
+		Code:         s.setupCode,
+		ClientID:     strings.TrimSpace(r.PostFormValue("client_id")),
+		ClientSecret: strings.TrimSpace(r.PostFormValue("client_secret")),
+		Scope:        r.PostFormValue("scope"),
+		RedirectURI:  strings.TrimSpace(r.PostFormValue("redirect_uri")),
+	}
+	if form.Error = form.validate(); form.Error != "" {
+		renderSetup(w, http.StatusBadRequest, form)
+		return
+	}
+
+	cfg := s.config()
+	if cfg.Path == "" {
+		form.Error = "No config file location is known; start the proxy with -config or CONFIG_FILE."
+		renderSetup(w, http.StatusInternalServerError, form)
+		return
+	}
+	err := updateConfigFile(cfg.Path, map[string]string{
+		"google.client_id":     form.ClientID,
+		"google.client_secret": form.ClientSecret,
+		"google.scope":         form.Scope,
+		"google.redirect_uri":  form.RedirectURI,
+	})
+	if err == nil {
+		err = s.reload()
+	}
+	if err != nil {
+		log.Printf("Setup failed: %v", err)
+		form.Error = "Saving the configuration failed: " + err.Error()
+		renderSetup(w, http.StatusInternalServerError, form)
+		return
+	}
+
+	log.Printf("Setup: OAuth client saved to %s", cfg.Path)
+	if !clientSecretPattern.MatchString(form.ClientSecret) {
+		form.Warnings = append(form.Warnings, "The client secret does not start with GOCSPX-, which current Google client secrets do.")
+	}
+	if got := s.config().Google; got.ClientID != form.ClientID || got.RedirectURI != form.RedirectURI {
+		form.Warnings = append(form.Warnings, "An environment variable or flag overrides part of the saved configuration.")
+	}
+	form.Path = cfg.Path
+	renderSetup(w, http.StatusOK, form)
+}
+
+// setupAllowed answers 404 once the proxy is configured and 403 for a wrong
+// setup code.
+func (s *Server) setupAllowed(w http.ResponseWriter, code string) bool {
+	if s.setupCode == "" || s.config().configured() {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Not found")
+		return false
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(s.setupCode)) != 1 {
+		writeError(w, http.StatusForbidden, api.CodeUnauthorized, "Open the setup URL printed in the proxy's log")
+		return false
+	}
+	return true
+}
+
+// validate returns a message for the first problem with f, or "".
+func (f setupForm) validate() string {
+	if !clientIDPattern.MatchString(f.ClientID) {
+		return "The client ID should look like <number>-<id>.apps.googleusercontent.com."
+	}
+	if f.ClientSecret == "" {
+		return "The client secret is required."
+	}
+	if !slices.ContainsFunc(setupScopes, func(scope setupScope) bool { return scope.Value == f.Scope }) {
+		return "Choose the access to request."
+	}
+	u, err := url.Parse(f.RedirectURI)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "The redirect URI must be an absolute http or https URL."
+	}
+	return ""
+}
+
+func renderSetup(w http.ResponseWriter, status int, form setupForm) {
+	form.Scopes = setupScopes
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := setupPage.Execute(w, form); err != nil {
+		log.Printf("Rendering setup page: %v", err)
+	}
+}
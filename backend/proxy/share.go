@@ -0,0 +1,442 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Share is a snapshot of a list anyone with its link can read. User is the
+// tenant who shared it, "" outside multi-tenant mode, and Account the ID of
+// the Google account the list belongs to (see Server.accountOf).
+type Share struct {
+	User    string       `json:"user,omitempty"`
+	Account string       `json:"account"`
+	List    api.TaskList `json:"list"`
+	Tasks   []api.Task   `json:"tasks"`
+	Created time.Time    `json:"created"`
+	Expires time.Time    `json:"expires,omitzero"` // zero when it lasts until revoked
+}
+
+func (s Share) expired(now time.Time) bool {
+	return !s.Expires.IsZero() && now.After(s.Expires)
+}
+
+func (s Share) info(id string) api.ShareInfo {
+	info := api.ShareInfo{ID: id, List: s.List, Tasks: len(s.Tasks), CreatedAt: s.Created.Format(time.RFC3339)}
+	if !s.Expires.IsZero() {
+		info.ExpiresAt = s.Expires.Format(time.RFC3339)
+	}
+	return info
+}
+
+// ShareStore keeps shared snapshots in a JSON file readable only by its
+// owner, keyed by the SHA-256 of their link's token so the file holds no
+// usable link. Links are opened without a session, so in multi-tenant mode
+// every tenant's shares are kept here, each with its owner.
+type ShareStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewShareStore(path string) *ShareStore {
+	return &ShareStore{path: path}
+}
+
+// shareID is a share's name in /api/shares: a prefix of its key, which
+// cannot be turned back into the token.
+func shareID(key string) string {
+	return key[:16]
+}
+
+// Add stores share and returns the token of its link.
+func (s *ShareStore) Add(share Share) (string, error) {
+	token, err := generateRandomString(32)
+	if err != nil {
+		return "", err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	shares, err := s.read()
+	if err != nil {
+		return "", err
+	}
+	shares[sessionKey(token)] = share
+	return token, s.write(shares)
+}
+
+// Lookup returns the unexpired share for token.
+func (s *ShareStore) Lookup(token string) (Share, bool, error) {
+	s.mutex.Lock()
+	shares, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return Share{}, false, err
+	}
+	share, ok := shares[sessionKey(token)]
+	if !ok || share.expired(time.Now()) {
+		return Share{}, false, nil
+	}
+	return share, true, nil
+}
+
+// List returns the unexpired shares of user's account, newest first.
+func (s *ShareStore) List(user, account string) ([]api.ShareInfo, error) {
+	s.mutex.Lock()
+	shares, err := s.read()
+	s.mutex.Unlock()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	infos := []api.ShareInfo{}
+	for key, share := range shares {
+		if share.User == user && share.Account == account && !share.expired(now) {
+			infos = append(infos, share.info(shareID(key)))
+		}
+	}
+	slices.SortFunc(infos, func(a, b api.ShareInfo) int {
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(a.ID, b.ID))
+	})
+	return infos, nil
+}
+
+// Revoke deletes the share of user's account named id by List. ok is false
+// when there is none.
+func (s *ShareStore) Revoke(user, account, id string) (ok bool, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	shares, err := s.read()
+	if err != nil {
+		return false, err
+	}
+	for key, share := range shares {
+		if share.User == user && share.Account == account && shareID(key) == id {
+			delete(shares, key)
+			return true, s.write(shares)
+		}
+	}
+	return false, nil
+}
+
+func (s *ShareStore) read() (map[string]Share, error) {
+	shares := make(map[string]Share)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return shares, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &shares); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return shares, nil
+}
+
+// write saves shares, forgetting expired ones.
+func (s *ShareStore) write(shares map[string]Share) error {
+	now := time.Now()
+	for key, share := range shares {
+		if share.expired(now) {
+			delete(shares, key)
+		}
+	}
+	data, err := json.MarshalIndent(shares, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// parseShareExpiry reads ShareRequest.Expires: a duration, including days
+// and weeks, or an RFC 3339 time, which must be in the future.
+func parseShareExpiry(spec string, now time.Time) (time.Time, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return time.Time{}, nil
+	}
+	if days, ok := parseDays(strings.ToLower(spec)); ok {
+		return now.AddDate(0, 0, days), nil
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		if d <= 0 {
+			return time.Time{}, fmt.Errorf("expires must be positive: %s", spec)
+		}
+		return now.Add(d), nil
+	}
+	expires, err := time.Parse(time.RFC3339, spec)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expires must be a duration (30m, 2h, 3d, 1w) or an RFC 3339 time: %s", spec)
+	}
+	if !expires.After(now) {
+		return time.Time{}, fmt.Errorf("expires is in the past: %s", spec)
+	}
+	return expires, nil
+}
+
+// shareURL is the link to the share with token: under api.share_url, or
+// else the host the request came to.
+func (s *Server) shareURL(r *http.Request, token string) string {
+	base := s.config().ShareURL
+	if base == "" {
+		scheme := "http"
+		if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+			scheme = "https"
+		}
+		base = scheme + "://" + r.Host
+	}
+	return strings.TrimSuffix(base, "/") + "/share/" + token
+}
+
+// POST /api/tasklists/{list}/share - Share a read-only snapshot of a list
+//
+// The list's tasks (completed ones too, but not cleared ones) are copied
+// into api.share_file as they are now, without their links into Google; the
+// returned URL shows them to anyone who has it, as a page or as JSON, until
+// it expires or is revoked with DELETE /api/shares/{id}. Later changes to
+// the list are not shown; share it again for a new snapshot.
+func (s *Server) handleShareList(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.ShareRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+			return
+		}
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	expires, err := parseShareExpiry(req.Expires, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.ListTaskLists(r.Context(), token)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	list, ok := listByID(lists, r.PathValue("list"))
+	if !ok {
+		writeListNotFound(w, r.PathValue("list"))
+		return
+	}
+	// Google's default query leaves out cleared tasks
+	tasks, err := s.tasks.ListTasks(r.Context(), token, list.ID, nil)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	sortByKey(tasks, taskOrderKey(tasks))
+
+	share := Share{
+		User:    tenantUser(r.Context()),
+		Account: account,
+		List:    api.TaskList{ID: list.ID, Title: list.Title},
+		Tasks:   make([]api.Task, 0, len(tasks)),
+		Created: now,
+		Expires: expires.UTC(),
+	}
+	for _, task := range tasks {
+		if task.Deleted || task.Hidden {
+			continue
+		}
+		share.Tasks = append(share.Tasks, api.Task{
+			ID:        task.ID,
+			Title:     task.Title,
+			Notes:     task.Notes,
+			Status:    task.Status,
+			Due:       task.Due,
+			Completed: task.Completed,
+			Parent:    task.Parent,
+			Updated:   task.Updated,
+		})
+	}
+	shareToken, err := s.shares.Add(share)
+	if err != nil {
+		log.Printf("Sharing list: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to save the share")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.ShareResponse{
+		ShareInfo: share.info(shareID(sessionKey(shareToken))),
+		URL:       s.shareURL(r, shareToken),
+		Token:     shareToken,
+	})
+}
+
+// GET /api/shares - The shared snapshots that have not expired, newest first
+func (s *Server) handleShares(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+
+	shares, err := s.shares.List(tenantUser(r.Context()), account)
+	if err != nil {
+		log.Printf("Reading shares: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read shares")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.SharesResponse{Shares: shares})
+}
+
+// DELETE /api/shares/{id} - Revoke a shared snapshot, so its link stops working
+func (s *Server) handleRevokeShare(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := s.shares.Revoke(tenantUser(r.Context()), account, r.PathValue("id"))
+	if err != nil {
+		log.Printf("Revoking share: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to revoke share")
+		return
+	}
+	if !revoked {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Share not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.RevokeShareResponse{Revoked: true})
+}
+
+var sharePage = template.Must(template.New("share").Parse(`<!DOCTYPE html>
+<html lang="{{.Lang}}"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>{{.Title}}</title>
+<style>
+	body { font-family: sans-serif; max-width: 40em; margin: 2em auto; padding: 0 1em; }
+	ul { list-style: none; padding-left: 0; }
+	li { margin: 0.4em 0; }
+	li.subtask { padding-left: 1.5em; }
+	li.completed > span.title { text-decoration: line-through; color: #777; }
+	.due, .muted { color: #555; font-size: 0.9em; }
+	.notes { color: #555; font-size: 0.9em; white-space: pre-wrap; margin: 0.2em 0 0 1.5em; }
+</style></head><body>
+<h1>{{.Title}}</h1>
+<p class="muted">{{.Snapshot}}{{if .Expires}} {{.Expires}}{{end}}</p>
+{{if .Tasks}}<ul>
+{{range .Tasks}}	<li{{with .Class}} class="{{.}}"{{end}}>{{if .Completed}}&#x2611;{{else}}&#x2610;{{end}} <span class="title">{{.Title}}</span>{{if .Due}} <span class="due">{{.Due}}</span>{{end}}
+		{{- if .Notes}}<div class="notes">{{.Notes}}</div>{{end}}</li>
+{{end}}</ul>
+{{else}}<p>{{.Empty}}</p>
+{{end -}}
+</body></html>
+`))
+
+type sharedTask struct {
+	Title, Notes, Due, Class string
+	Completed                bool
+}
+
+// GET /share/{token} - A shared snapshot of a list, for anyone with the link
+//
+// It is a page in the reader's language, or the api.SharedList with
+// ?format=json. Expired and revoked links are not found.
+func (s *Server) handleSharedList(w http.ResponseWriter, r *http.Request) {
+	asJSON := r.URL.Query().Get("format") == "json"
+	lang := s.pageLanguage(r)
+
+	// Revoking a link must take effect everywhere, and the token in it must
+	// not leak to where the page's reader goes next
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Referrer-Policy", "no-referrer")
+	w.Header().Set("X-Robots-Tag", "noindex")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	share, ok, err := s.shares.Lookup(r.PathValue("token"))
+	if err != nil {
+		log.Printf("Reading shares: %v", err)
+		if asJSON {
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the share")
+		} else {
+			writeMessagePage(w, lang, http.StatusInternalServerError, false, translate(lang, "share.error.title"), translate(lang, "share.error.body"))
+		}
+		return
+	}
+	if !ok {
+		if asJSON {
+			writeError(w, http.StatusNotFound, api.CodeNotFound, "Share not found")
+		} else {
+			writeMessagePage(w, lang, http.StatusNotFound, false, translate(lang, "share.missing.title"), translate(lang, "share.missing.body"))
+		}
+		return
+	}
+
+	if asJSON {
+		resp := api.SharedList{List: share.List, Tasks: share.Tasks, CreatedAt: share.Created.Format(time.RFC3339)}
+		if !share.Expires.IsZero() {
+			resp.ExpiresAt = share.Expires.Format(time.RFC3339)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	const shown = "2006-01-02 15:04 MST"
+	page := struct {
+		Lang, Title, Snapshot, Expires, Empty string
+		Tasks                                 []sharedTask
+	}{
+		Lang:     lang,
+		Title:    share.List.Title,
+		Snapshot: translate(lang, "share.snapshot", share.Created.UTC().Format(shown)),
+		Empty:    translate(lang, "share.empty"),
+	}
+	if !share.Expires.IsZero() {
+		page.Expires = translate(lang, "share.expires", share.Expires.UTC().Format(shown))
+	}
+	for _, task := range share.Tasks {
+		shared := sharedTask{Title: task.Title, Notes: task.Notes, Completed: task.Status == "completed"}
+		var classes []string
+		if task.Parent != "" {
+			classes = append(classes, "subtask")
+		}
+		if shared.Completed {
+			classes = append(classes, "completed")
+		}
+		shared.Class = strings.Join(classes, " ")
+		if due := dueDate(task); due != "" {
+			shared.Due = translate(lang, "share.due", due)
+		}
+		page.Tasks = append(page.Tasks, shared)
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Language", lang)
+	w.Header().Set("Vary", "Accept-Language")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	if err := sharePage.Execute(w, page); err != nil {
+		log.Printf("Rendering shared list: %v", err)
+	}
+}
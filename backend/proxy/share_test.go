@@ -0,0 +1,128 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// shareList shares list through POST /api/tasklists/{list}/share.
+func shareList(t *testing.T, s *Server, list string) api.ShareResponse {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/tasklists/"+list+"/share", nil)
+	r.Header.Set("Authorization", "Bearer "+mockToken)
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("sharing %s: %d %s", list, w.Code, w.Body)
+	}
+	var resp api.ShareResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+// openShare opens a share's link as its reader would, without credentials.
+func openShare(s *Server, method, token string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/share/"+token+"?format=json", strings.NewReader(`{"title": "changed"}`))
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, r)
+	return w
+}
+
+func TestShareHoldsOnlyItsList(t *testing.T) {
+	s := newMockServer(t)
+	shared := s.mock.lists[0]
+	other := s.mock.newList("Private")
+	s.mock.addTask(other, &api.Task{Title: "surprise party", Status: "needsAction"})
+
+	resp := shareList(t, s, shared.ID)
+	w := openShare(s, "GET", resp.Token)
+	if w.Code != http.StatusOK {
+		t.Fatalf("opening the share: %d %s", w.Code, w.Body)
+	}
+	var got api.SharedList
+	if err := json.NewDecoder(w.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.List.ID != shared.ID || resp.Tasks != len(shared.tasks) {
+		t.Errorf("shared list %+v with %d tasks, want %s with %d", got.List, resp.Tasks, shared.ID, len(shared.tasks))
+	}
+	var titles []string
+	for _, task := range got.Tasks {
+		titles = append(titles, task.Title)
+	}
+	if len(titles) != len(shared.tasks) || slices.Contains(titles, "surprise party") {
+		t.Errorf("shared tasks = %q", titles)
+	}
+
+	// A snapshot: later changes to the list are not shown
+	shared.tasks[0].Title = "renamed after sharing"
+	if body := openShare(s, "GET", resp.Token).Body.String(); strings.Contains(body, "renamed after sharing") {
+		t.Errorf("share shows a later change: %s", body)
+	}
+}
+
+func TestShareIsReadOnly(t *testing.T) {
+	s := newMockServer(t)
+	list := s.mock.lists[0]
+	title := list.tasks[0].Title
+	resp := shareList(t, s, list.ID)
+
+	// The link only reads
+	for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+		if w := openShare(s, method, resp.Token); w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("%s on the link: %d %s", method, w.Code, w.Body)
+		}
+	}
+	if w := openShare(s, "GET", resp.Token); w.Code != http.StatusOK || list.tasks[0].Title != title {
+		t.Errorf("after writes to the link: %d, title %q", w.Code, list.tasks[0].Title)
+	}
+
+	// and its token is not a way into the API
+	for _, path := range []string{"/api/lists", "/api/lists/" + list.ID + "/tasks"} {
+		r := httptest.NewRequest("GET", path, nil)
+		r.Header.Set("Authorization", "Bearer "+resp.Token)
+		w := httptest.NewRecorder()
+		s.routes().ServeHTTP(w, r)
+		if w.Code == http.StatusOK {
+			t.Errorf("GET %s with the share's token: %d %s", path, w.Code, w.Body)
+		}
+	}
+}
+
+func TestShareStoreScopesToAccount(t *testing.T) {
+	store := NewShareStore(t.TempDir() + "/shares.json")
+	token, err := store.Add(Share{Account: "acct-a", List: api.TaskList{ID: "L1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := shareID(sessionKey(token))
+
+	if shares, _ := store.List("", "acct-b"); len(shares) != 0 {
+		t.Errorf("another account lists %+v", shares)
+	}
+	if ok, err := store.Revoke("", "acct-b", id); ok || err != nil {
+		t.Errorf("another account revoked the share: %t, %v", ok, err)
+	}
+	// Nor does the same account under another tenant
+	if ok, _ := store.Revoke("bob", "acct-a", id); ok {
+		t.Error("another tenant revoked the share")
+	}
+	if shares, _ := store.List("", "acct-a"); len(shares) != 1 || shares[0].ID != id {
+		t.Errorf("owner lists %+v", shares)
+	}
+	if ok, err := store.Revoke("", "acct-a", id); !ok || err != nil {
+		t.Errorf("owner could not revoke: %t, %v", ok, err)
+	}
+	if _, ok, _ := store.Lookup(token); ok {
+		t.Error("revoked link still opens")
+	}
+}
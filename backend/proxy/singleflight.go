@@ -0,0 +1,64 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+)
+
+// flightGroup coalesces concurrent calls with the same key into one
+// execution whose result every caller receives.
+type flightGroup[T any] struct {
+	mutex sync.Mutex
+	calls map[string]*flightCall[T]
+}
+
+type flightCall[T any] struct {
+	done  chan struct{}
+	value T
+	err   error
+	dups  int
+}
+
+// Do runs fn unless a call for key is already in flight, in which case it
+// waits for that call's result or for ctx to be done, whichever comes first.
+// fn itself runs to completion for the caller that started it. shared
+// reports whether the result went to more than one caller. A panic in fn is
+// logged and returned to every caller as an error.
+func (g *flightGroup[T]) Do(ctx context.Context, key string, fn func() (T, error)) (value T, err error, shared bool) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*flightCall[T])
+	}
+	if call, ok := g.calls[key]; ok {
+		call.dups++
+		g.mutex.Unlock()
+		select {
+		case <-call.done:
+			return call.value, call.err, true
+		case <-ctx.Done():
+			return value, ctx.Err(), true
+		}
+	}
+	call := &flightCall[T]{done: make(chan struct{})}
+	g.calls[key] = call
+	g.mutex.Unlock()
+
+	defer func() {
+		if r := recover(); r != nil {
+			slog.Error("coalesced call panicked", "panic", r, "stack", string(debug.Stack()))
+			call.err = fmt.Errorf("coalesced call panicked: %v", r)
+			value, err = call.value, call.err
+		}
+		g.mutex.Lock()
+		delete(g.calls, key)
+		shared = call.dups > 0
+		g.mutex.Unlock()
+		close(call.done)
+	}()
+
+	call.value, call.err = fn()
+	return call.value, call.err, false
+}
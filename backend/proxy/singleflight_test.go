@@ -0,0 +1,117 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// startLeader begins a call for key that blocks until release is closed and
+// waits until it is in flight.
+func startLeader(g *flightGroup[int], key string, release chan struct{}, fn func() (int, error)) <-chan error {
+	started := make(chan struct{})
+	result := make(chan error, 1)
+	go func() {
+		_, err, _ := g.Do(context.Background(), key, func() (int, error) {
+			close(started)
+			<-release
+			return fn()
+		})
+		result <- err
+	}()
+	<-started
+	return result
+}
+
+func TestFlightGroupCoalesces(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	calls := 0
+	leader := startLeader(&g, "k", release, func() (int, error) {
+		calls++
+		return 42, nil
+	})
+
+	var wg sync.WaitGroup
+	for range 3 {
+		wg.Go(func() {
+			v, err, shared := g.Do(context.Background(), "k", func() (int, error) {
+				t.Error("a second call for the same key ran")
+				return 0, nil
+			})
+			if v != 42 || err != nil || !shared {
+				t.Errorf("waiter got %d, %v, shared %t", v, err, shared)
+			}
+		})
+	}
+	// Let the waiters join before the leader finishes
+	for {
+		g.mutex.Lock()
+		dups := g.calls["k"].dups
+		g.mutex.Unlock()
+		if dups == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	close(release)
+	wg.Wait()
+	if err := <-leader; err != nil || calls != 1 {
+		t.Errorf("leader: %v after %d calls", err, calls)
+	}
+
+	// The key is free again once the call is done
+	v, _, shared := g.Do(context.Background(), "k", func() (int, error) { return 7, nil })
+	if v != 7 || shared {
+		t.Errorf("a later call got %d, shared %t", v, shared)
+	}
+}
+
+func TestFlightGroupWaiterGivesUp(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	defer close(release)
+	startLeader(&g, "k", release, func() (int, error) { return 1, nil })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err, _ := g.Do(ctx, "k", func() (int, error) { return 0, nil })
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("waiter past its deadline got %v", err)
+	}
+}
+
+func TestFlightGroupPanic(t *testing.T) {
+	var g flightGroup[int]
+	release := make(chan struct{})
+	leader := startLeader(&g, "k", release, func() (int, error) { panic("boom") })
+
+	waiter := make(chan error, 1)
+	go func() {
+		_, err, _ := g.Do(context.Background(), "k", func() (int, error) { return 0, nil })
+		waiter <- err
+	}()
+	close(release)
+
+	if err := <-leader; err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("leader got %v, want the panic as an error", err)
+	}
+	select {
+	case err := <-waiter:
+		// The waiter may have arrived after the call ended and run its own
+		// fn, which succeeds
+		if err != nil && !strings.Contains(err.Error(), "boom") {
+			t.Errorf("waiter got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the waiter is still blocked after the panic")
+	}
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if len(g.calls) != 0 {
+		t.Error("the panicked call was not removed")
+	}
+}
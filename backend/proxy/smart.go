@@ -0,0 +1,146 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// smartList is a virtual list of the open tasks of every real list whose
+// due date (YYYY-MM-DD, or "") passes match. day is the user's today and
+// weekEnd the last day of the week starting today.
+type smartList struct {
+	ID    string
+	Title string
+	match func(due, day, weekEnd string) bool
+}
+
+// smartLists are served under /api/smart, in this order.
+var smartLists = []smartList{
+	{"today", "Today", func(due, day, _ string) bool { return due == day }},
+	{"upcoming", "Upcoming", func(due, day, weekEnd string) bool { return due > day && due <= weekEnd }},
+	{"overdue", "Overdue", func(due, day, _ string) bool { return due != "" && due < day }},
+	{"no-date", "No date", func(due, _, _ string) bool { return due == "" }},
+}
+
+func findSmartList(id string) (smartList, bool) {
+	for _, list := range smartLists {
+		if list.ID == id {
+			return list, true
+		}
+	}
+	return smartList{}, false
+}
+
+// openTasks returns the open tasks in lists that keep accepts by due date,
+// sorted by due date with undated tasks last, then by list title and
+// position. Lists that could not be fetched are returned apart.
+func openTasks(lists []api.ListWithTasks, keep func(due string) bool) (items []api.AgendaItem, failed []api.ListWithTasks) {
+	items = []api.AgendaItem{}
+	for _, list := range lists {
+		if list.Error != nil {
+			failed = append(failed, list)
+			continue
+		}
+		for _, task := range list.Tasks {
+			if !task.Deleted && task.Status != "completed" && keep(dueDate(task)) {
+				items = append(items, api.AgendaItem{List: list.TaskList, Task: task})
+			}
+		}
+	}
+
+	slices.SortFunc(items, func(a, b api.AgendaItem) int {
+		// "~" sorts after any date
+		da, db := cmp.Or(dueDate(a.Task), "~"), cmp.Or(dueDate(b.Task), "~")
+		return cmp.Or(cmp.Compare(da, db),
+			cmp.Compare(a.List.Title, b.List.Title), cmp.Compare(a.Task.Position, b.Task.Position))
+	})
+	return items, failed
+}
+
+// smartRequest reads the date and tz parameters of a smart list request
+// and fetches every list.
+func (s *Server) smartRequest(w http.ResponseWriter, r *http.Request) (lists []api.ListWithTasks, date time.Time, ok bool) {
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+	date, err := agendaDate(r.URL.Query().Get("date"), r.URL.Query().Get("tz"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return nil, time.Time{}, false
+	}
+	lists, err = s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return nil, time.Time{}, false
+	}
+	return lists, date, true
+}
+
+// GET /api/smart - The smart lists with how many tasks each holds
+func (s *Server) handleSmartLists(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	lists, date, ok := s.smartRequest(w, r)
+	if !ok {
+		return
+	}
+	day := date.Format(time.DateOnly)
+	weekEnd := date.AddDate(0, 0, agendaDays-1).Format(time.DateOnly)
+
+	resp := api.SmartListsResponse{Date: day, TimeZone: date.Location().String()}
+	for _, list := range smartLists {
+		items, failed := openTasks(lists, func(due string) bool { return list.match(due, day, weekEnd) })
+		resp.Lists = append(resp.Lists, api.SmartListInfo{ID: list.ID, Title: list.Title, Count: len(items)})
+		resp.Failed = failed
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GET /api/smart/{list} - The tasks of one smart list, across every real list
+//
+// ?sort= and ?order= replace the order of due date, list and position;
+// ties are broken by list and position still.
+func (s *Server) handleSmartList(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	list, found := findSmartList(r.PathValue("list"))
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "No such smart list; use today, upcoming, overdue or no-date")
+		return
+	}
+	order, sorted, err := parseTaskSort(r.URL.Query(), "due")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	lists, date, ok := s.smartRequest(w, r)
+	if !ok {
+		return
+	}
+	day := date.Format(time.DateOnly)
+	weekEnd := date.AddDate(0, 0, agendaDays-1).Format(time.DateOnly)
+
+	items, failed := openTasks(lists, func(due string) bool { return list.match(due, day, weekEnd) })
+	if sorted {
+		sortByKey(items, func(item api.AgendaItem) string {
+			return order.key(item.Task, item.List.Title+"\x00"+item.List.ID+"\x00"+item.Task.Position+"\x00"+item.Task.ID)
+		})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.SmartListResponse{
+		ID:       list.ID,
+		Title:    list.Title,
+		Date:     day,
+		TimeZone: date.Location().String(),
+		Items:    items,
+		Failed:   failed,
+	})
+}
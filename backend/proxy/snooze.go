@@ -0,0 +1,246 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Times of day that named snoozes end at, since Google due dates have no
+// time: tonight is the evening, other days start in the morning.
+const (
+	snoozeEvening = 20
+	snoozeMorning = 9
+)
+
+// snoozeRetention is how long an expired snooze is remembered, so the
+// reminder that comes back after it can say it was snoozed.
+const snoozeRetention = 7 * 24 * time.Hour
+
+// SnoozeStore keeps when each snoozed task's reminders resume, by task ID,
+// in a JSON file readable only by its owner.
+type SnoozeStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewSnoozeStore(path string) *SnoozeStore {
+	return &SnoozeStore{path: path}
+}
+
+// All returns every remembered snooze.
+func (s *SnoozeStore) All() (map[string]time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.read()
+}
+
+// Save records that taskID is snoozed until until, and forgets snoozes that
+// ended more than snoozeRetention ago.
+func (s *SnoozeStore) Save(taskID string, until time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snoozes, err := s.read()
+	if err != nil {
+		return err
+	}
+	for id, t := range snoozes {
+		if time.Since(t) > snoozeRetention {
+			delete(snoozes, id)
+		}
+	}
+	snoozes[taskID] = until
+	data, err := json.MarshalIndent(snoozes, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func (s *SnoozeStore) read() (map[string]time.Time, error) {
+	snoozes := make(map[string]time.Time)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return snoozes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &snoozes); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return snoozes, nil
+}
+
+// parseSnooze returns when a snooze of spec starting at now ends. spec is a
+// duration (Go syntax, or whole days and weeks like 3d and 1w), "tonight"
+// (the evening, or an hour from now once it is later), "next-week" (next
+// Monday morning) or a date for parseDate (that day's morning).
+func parseSnooze(spec string, now time.Time) (time.Time, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	at := func(day time.Time, hour int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, now.Location())
+	}
+
+	var until time.Time
+	switch {
+	case spec == "":
+		return time.Time{}, errors.New("duration is required")
+	case spec == "tonight":
+		until = at(now, snoozeEvening)
+		if !until.After(now) {
+			until = now.Add(time.Hour)
+		}
+	case spec == "next-week":
+		ahead := (int(time.Monday) - int(now.Weekday()) + 6) % 7
+		until = at(now.AddDate(0, 0, ahead+1), snoozeMorning)
+	default:
+		if days, ok := parseDays(spec); ok {
+			until = now.AddDate(0, 0, days)
+			break
+		}
+		if d, err := time.ParseDuration(spec); err == nil {
+			if d <= 0 {
+				return time.Time{}, fmt.Errorf("duration must be positive: %s", spec)
+			}
+			until = now.Add(d)
+			break
+		}
+		day, err := parseDate(spec, now)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("unrecognised duration %q, use e.g. 1h, 3d, tonight, tomorrow, next-week, a weekday or YYYY-MM-DD", spec)
+		}
+		until = at(day, snoozeMorning)
+	}
+
+	if !until.After(now) {
+		return time.Time{}, fmt.Errorf("%q is not in the future", spec)
+	}
+	return until, nil
+}
+
+// parseDays reads a positive number of days (3d) or weeks (1w) as days.
+func parseDays(spec string) (int, bool) {
+	multiplier := 1
+	number, ok := strings.CutSuffix(spec, "d")
+	if !ok {
+		if number, ok = strings.CutSuffix(spec, "w"); !ok {
+			return 0, false
+		}
+		multiplier = 7
+	}
+	n, err := strconv.Atoi(number)
+	if err != nil || n <= 0 {
+		return 0, false
+	}
+	return n * multiplier, true
+}
+
+// findTask looks taskID up in the user's lists (only listID when set),
+// reading through the cache. ok is false when there is no such task.
+func (s *Server) findTask(ctx context.Context, token, listID, taskID string) (list api.TaskList, task api.Task, ok bool, err error) {
+	lists, err := s.tasks.FetchAll(ctx, token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		return api.TaskList{}, api.Task{}, false, err
+	}
+	for _, list := range lists {
+		if listID != "" && list.ID != listID {
+			continue
+		}
+		for _, task := range list.Tasks {
+			if task.ID == taskID {
+				return list.TaskList, task, true, nil
+			}
+		}
+	}
+	return api.TaskList{}, api.Task{}, false, nil
+}
+
+// POST /api/tasks/{task}/snooze - Push a task's due date back and hold its reminders
+//
+// The due date moves to the day the snooze ends, unless the task is already
+// due later. The snooze itself is remembered by the proxy, so reminders stay
+// quiet until it ends even when the due date is unchanged (a snooze of an
+// hour). ?dry_run=1 reports the update without sending or recording it.
+func (s *Server) handleSnooze(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.SnoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	now := time.Now()
+	if req.TZ != "" {
+		loc, err := parseTimeZone(req.TZ)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+			return
+		}
+		now = now.In(loc)
+	}
+	until, err := parseSnooze(req.Duration, now)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	list, task, found, err := s.findTask(r.Context(), token, req.List, r.PathValue("task"))
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Task not found")
+		return
+	}
+
+	resp := api.SnoozeResponse{
+		Until:  until.Format(time.RFC3339),
+		Due:    until.Format(time.DateOnly),
+		DryRun: dryRun(r),
+		Task:   &task,
+	}
+	if due := dueDate(task); due != "" && due >= resp.Due {
+		resp.Due = due
+	} else {
+		write := PatchWrite(list.ID, task.ID, map[string]any{"due": googleDue(until)})
+		resp.Request = &write
+	}
+
+	if !resp.DryRun {
+		if resp.Request != nil {
+			updated, steps, err := s.writeUndoable(r, token, *resp.Request)
+			if err != nil {
+				writeTasksError(w, err)
+				return
+			}
+			resp.Task = updated
+			s.recordUndo(r, token, "snooze of "+task.Title, steps)
+		}
+		if err := s.snoozeStore(tenantUser(r.Context())).Save(task.ID, until); err != nil {
+			log.Printf("Recording snooze: %v", err)
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to record the snooze")
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
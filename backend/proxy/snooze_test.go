@@ -0,0 +1,142 @@
+package proxy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustSnooze(t *testing.T, spec string, now time.Time) time.Time {
+	t.Helper()
+	until, err := parseSnooze(spec, now)
+	if err != nil {
+		t.Fatalf("parseSnooze(%q, %v): %v", spec, now, err)
+	}
+	return until
+}
+
+func TestSnoozeTonight(t *testing.T) {
+	day := func(hour, minute int) time.Time { return time.Date(2025, 3, 14, hour, minute, 0, 0, time.UTC) }
+
+	if got := mustSnooze(t, "tonight", day(19, 59)); !got.Equal(day(snoozeEvening, 0)) {
+		t.Errorf("before the evening: %v", got)
+	}
+	// From the evening on, "tonight" is an hour away, even past midnight
+	if got := mustSnooze(t, "tonight", day(20, 0)); !got.Equal(day(21, 0)) {
+		t.Errorf("at the evening: %v", got)
+	}
+	if got := mustSnooze(t, "Tonight ", day(23, 30)); !got.Equal(day(23, 30).Add(time.Hour)) {
+		t.Errorf("late at night: %v", got)
+	}
+}
+
+func TestSnoozeNextWeek(t *testing.T) {
+	monday := time.Date(2025, 3, 17, 8, 0, 0, 0, time.UTC)
+	for offset := range 7 {
+		now := monday.AddDate(0, 0, offset)
+		got := mustSnooze(t, "next-week", now)
+		days := got.YearDay() - now.YearDay()
+		if got.Weekday() != time.Monday || got.Hour() != snoozeMorning || days < 1 || days > 7 {
+			t.Errorf("next-week on %s is %v", now.Weekday(), got)
+		}
+	}
+	// Early on a Monday it is still the following Monday, not this morning
+	if got := mustSnooze(t, "next-week", monday); got.Day() != 24 {
+		t.Errorf("next-week early on Monday is %v", got)
+	}
+}
+
+func TestSnoozeDays(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no time zone database:", err)
+	}
+	// Clocks go forward overnight; a day's snooze keeps the time of day
+	now := time.Date(2025, 3, 8, 10, 30, 0, 0, ny)
+	got := mustSnooze(t, "1d", now)
+	if want := time.Date(2025, 3, 9, 10, 30, 0, 0, ny); !got.Equal(want) || got.Sub(now) != 23*time.Hour {
+		t.Errorf("1d across DST = %v (%v later)", got, got.Sub(now))
+	}
+	if got := mustSnooze(t, "2w", now); !got.Equal(time.Date(2025, 3, 22, 10, 30, 0, 0, ny)) {
+		t.Errorf("2w = %v", got)
+	}
+	if got := mustSnooze(t, "1h30m", now); got.Sub(now) != 90*time.Minute {
+		t.Errorf("1h30m = %v", got)
+	}
+}
+
+func TestSnoozeDates(t *testing.T) {
+	friday := time.Date(2025, 3, 14, 8, 0, 0, 0, time.UTC)
+	morning := func(day int) time.Time { return time.Date(2025, 3, day, snoozeMorning, 0, 0, 0, time.UTC) }
+
+	// This morning has not started yet at 08:00
+	if got := mustSnooze(t, "today", friday); !got.Equal(morning(14)) {
+		t.Errorf("today = %v", got)
+	}
+	// A weekday is never today
+	if got := mustSnooze(t, "fri", friday); !got.Equal(morning(21)) {
+		t.Errorf("fri on a Friday = %v", got)
+	}
+	if got := mustSnooze(t, "2025-03-20", friday); !got.Equal(morning(20)) {
+		t.Errorf("a date = %v", got)
+	}
+}
+
+func TestSnoozeErrors(t *testing.T) {
+	now := time.Date(2025, 3, 14, 10, 0, 0, 0, time.UTC)
+	for spec, want := range map[string]string{
+		"":           "duration is required",
+		"-1h":        "duration must be positive",
+		"0s":         "duration must be positive",
+		"0d":         "unrecognised duration",
+		"soon":       "unrecognised duration",
+		"today":      "not in the future", // its morning has passed
+		"2025-03-01": "not in the future",
+	} {
+		if _, err := parseSnooze(spec, now); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parseSnooze(%q) error = %v, want %q", spec, err, want)
+		}
+	}
+}
+
+func TestSnoozeStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snoozes.json")
+	store := NewSnoozeStore(path)
+
+	if all, err := store.All(); err != nil || len(all) != 0 {
+		t.Fatalf("a store without a file: %v, %v", all, err)
+	}
+
+	old := time.Now().Add(-snoozeRetention - time.Hour).Round(time.Second)
+	recent := time.Now().Add(-time.Hour).Round(time.Second)
+	later := time.Now().Add(time.Hour).Round(time.Second)
+	for id, until := range map[string]time.Time{"old": old, "recent": recent} {
+		if err := store.Save(id, until); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Save("later", later); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := store.All()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := all["old"]; ok {
+		t.Error("a snooze that ended long ago was kept")
+	}
+	if !all["recent"].Equal(recent) || !all["later"].Equal(later) {
+		t.Errorf("snoozes = %v", all)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("snooze file mode %v, want 0600", info.Mode().Perm())
+	}
+}
@@ -0,0 +1,85 @@
+package proxy
+
+import (
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// taskSorts are the values of ?sort=.
+var taskSorts = []string{"due", "alpha", "position", "updated"}
+
+// taskSort is how ?sort= and ?order= order tasks.
+type taskSort struct {
+	by   string
+	desc bool
+}
+
+// parseTaskSort reads ?sort= and ?order=. ok is false when neither is
+// given, for endpoints that keep an order of their own then; by is
+// fallback when only ?order= is.
+func parseTaskSort(query url.Values, fallback string) (s taskSort, ok bool, err error) {
+	s.by = query.Get("sort")
+	if s.by == "" && !query.Has("order") {
+		return taskSort{}, false, nil
+	}
+	if s.by == "" {
+		s.by = fallback
+	}
+	if !slices.Contains(taskSorts, s.by) {
+		return taskSort{}, false, errors.New("sort must be due, alpha, position or updated")
+	}
+	switch query.Get("order") {
+	case "", "asc":
+	case "desc":
+		s.desc = true
+	default:
+		return taskSort{}, false, errors.New("order must be asc or desc")
+	}
+	return s, true, nil
+}
+
+// key is t's sort key; position is its place in the manual order (unique
+// per task), which breaks ties. Tasks without a due date come last when
+// sorting by it, in either order.
+func (s taskSort) key(t api.Task, position string) string {
+	var field string
+	switch s.by {
+	case "due":
+		if t.Due == "" {
+			return "1\x00" + s.direct(position)
+		}
+		field = t.Due
+	case "alpha":
+		field = strings.ToLower(t.Title)
+	case "updated":
+		field = t.Updated
+	}
+	return "0\x00" + s.direct(field+"\x00"+position)
+}
+
+// direct turns key around for a descending order: every byte is inverted,
+// and a final 0xff puts a key after the longer keys it is a prefix of.
+func (s taskSort) direct(key string) string {
+	if !s.desc {
+		return key
+	}
+	b := make([]byte, len(key)+1)
+	for i := range len(key) {
+		b[i] = ^key[i]
+	}
+	b[len(key)] = 0xff
+	return string(b)
+}
+
+// sortTasks orders a list's tasks by s, ties in their manual order, and
+// returns the key it sorted by.
+func (s taskSort) sortTasks(tasks []api.Task) func(api.Task) string {
+	position := taskOrderKey(tasks)
+	key := func(t api.Task) string { return s.key(t, position(t)) }
+	sortByKey(tasks, key)
+	return key
+}
@@ -0,0 +1,216 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	defaultTrendDays = 30
+	maxTrendDays     = 366
+)
+
+// parseTrendRange reads a range of days like 30d, or weeks like 12w.
+func parseTrendRange(s string) (int, error) {
+	if s == "" {
+		return defaultTrendDays, nil
+	}
+	unit := 1
+	switch {
+	case strings.HasSuffix(s, "d"):
+		s = strings.TrimSuffix(s, "d")
+	case strings.HasSuffix(s, "w"):
+		s, unit = strings.TrimSuffix(s, "w"), 7
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 || n*unit > maxTrendDays {
+		return 0, fmt.Errorf("range must be a number of days (30d) or weeks (12w), at most %d days", maxTrendDays)
+	}
+	return n * unit, nil
+}
+
+// trendTask is a task counted by buildTrends and the list it is in (or was
+// archived from).
+type trendTask struct {
+	list api.TaskList
+	task api.Task
+}
+
+// trendTasks merges the current tasks of lists with the archive, keyed by
+// task ID: a cleared task may still be returned by Google as hidden.
+// Deleted tasks are left out.
+func trendTasks(lists []api.ListWithTasks, archived []api.ArchivedTask) []trendTask {
+	seen := make(map[string]bool)
+	var tasks []trendTask
+	for _, list := range lists {
+		for _, task := range list.Tasks {
+			if !task.Deleted {
+				seen[task.ID] = true
+				tasks = append(tasks, trendTask{list.TaskList, task})
+			}
+		}
+	}
+	for _, a := range archived {
+		if !seen[a.Task.ID] && !a.Task.Deleted {
+			tasks = append(tasks, trendTask{a.List, a.Task})
+		}
+	}
+	return tasks
+}
+
+// buildTrends computes the statistics of the days days through today,
+// which is midnight in the user's time zone. created holds the creation
+// times known from the task history.
+func buildTrends(lists []api.ListWithTasks, archived []api.ArchivedTask, created map[string]time.Time, today time.Time, days int) api.TrendsResponse {
+	from := today.AddDate(0, 0, -(days - 1))
+	fromDay, toDay := from.Format(time.DateOnly), today.Format(time.DateOnly)
+	resp := api.TrendsResponse{From: fromDay, To: toDay, Days: days, Daily: make([]api.TrendDay, days), Lists: []api.TrendList{}}
+	for i := range resp.Daily {
+		resp.Daily[i].Date = from.AddDate(0, 0, i).Format(time.DateOnly)
+	}
+
+	var current []api.ListWithTasks
+	for _, list := range lists {
+		if list.Error != nil {
+			resp.Failed = append(resp.Failed, list)
+		} else {
+			current = append(current, list)
+		}
+	}
+
+	type listStats struct {
+		api.TrendList
+		due, late int
+	}
+	byList := make(map[string]*listStats)
+	var order []string
+	var ageSum float64
+	var due, late int
+	for _, t := range trendTasks(current, archived) {
+		stats, ok := byList[t.list.ID]
+		if !ok {
+			stats = &listStats{TrendList: api.TrendList{List: api.TaskList{ID: t.list.ID, Title: t.list.Title}}}
+			byList[t.list.ID] = stats
+			order = append(order, t.list.ID)
+		}
+
+		var completedDay string
+		completedAt, err := time.Parse(time.RFC3339, t.task.Completed)
+		if t.task.Status == "completed" && err == nil {
+			completedDay = completedAt.In(today.Location()).Format(time.DateOnly)
+		}
+		dueDay := dueDate(t.task)
+
+		if t.task.Status != "completed" {
+			resp.Open++
+			stats.Open++
+			if dueDay != "" && dueDay < toDay {
+				resp.Overdue++
+				stats.Overdue++
+			}
+		}
+		if completedDay >= fromDay && completedDay <= toDay {
+			resp.Daily[int(completedAt.In(today.Location()).Sub(from).Hours()/24)].Completed++
+			resp.Completed++
+			stats.Completed++
+			if at, ok := created[t.task.ID]; ok && at.Before(completedAt) {
+				ageSum += completedAt.Sub(at).Hours() / 24
+				resp.AgeSample++
+			}
+		}
+		// Only due dates that have passed can have been missed
+		if dueDay >= fromDay && dueDay < toDay {
+			missed := completedDay == "" || completedDay > dueDay
+			due++
+			stats.due++
+			if missed {
+				late++
+				stats.late++
+			}
+		}
+	}
+
+	if resp.AgeSample > 0 {
+		resp.AverageAgeDays = round2(ageSum / float64(resp.AgeSample))
+	}
+	resp.OverdueRate, resp.DueSample = rate(late, due), due
+	for _, id := range order {
+		stats := byList[id]
+		stats.OverdueRate = rate(stats.late, stats.due)
+		resp.Lists = append(resp.Lists, stats.TrendList)
+	}
+	slices.SortStableFunc(resp.Lists, func(a, b api.TrendList) int {
+		return cmp.Compare(b.Completed, a.Completed)
+	})
+	return resp
+}
+
+// rate is n/of rounded to two places, 0 when of is 0.
+func rate(n, of int) float64 {
+	if of == 0 {
+		return 0
+	}
+	return round2(float64(n) / float64(of))
+}
+
+func round2(x float64) float64 {
+	return math.Round(x*100) / 100
+}
+
+// GET /api/stats/trends - Completion counts per day and other trends
+//
+// Tasks are read from every list, with completed and hidden ones, and from
+// the archive of cleared lists; task ages come from the task history, so
+// only tasks created through the proxy (or seen created by change polling)
+// have one. ?range= is 30d by default; ?tz= sets what today is.
+func (s *Server) handleTrends(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	days, err := parseTrendRange(query.Get("range"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+	today, err := agendaDate("today", query.Get("tz"), time.Now())
+	if err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, err.Error())
+		return
+	}
+
+	lists, err := s.tasks.FetchAll(r.Context(), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	user := tenantUser(r.Context())
+	archived, err := s.archiveStore(user).Query(account, time.Time{}, time.Time{}, "")
+	if err != nil {
+		log.Printf("Reading the archive: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read the archive")
+		return
+	}
+	created, err := s.historyStore(user).Created(account)
+	if err != nil {
+		log.Printf("Reading task history: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task history")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildTrends(lists, archived, created, today, days))
+}
@@ -0,0 +1,384 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const googleTasksBaseURL = "https://tasks.googleapis.com/tasks/v1"
+
+// TasksClient calls the Google Tasks API with the caller's access token,
+// serving repeated reads from the response cache.
+type TasksClient struct {
+	upstream *UpstreamClient
+	cache    *ResponseCache
+}
+
+func NewTasksClient(upstream *UpstreamClient, cache *ResponseCache) *TasksClient {
+	return &TasksClient{upstream: upstream, cache: cache}
+}
+
+// maxCachedBody bounds how much of a response is buffered for the cache.
+// Larger responses are streamed through without being cached.
+const maxCachedBody = 1 << 20
+
+// maxUpstreamBody bounds how much of any single response is read.
+const maxUpstreamBody = 32 << 20
+
+// Body is an open Tasks API response. CacheStatus is "HIT" when served from
+// cache, "REVALIDATED" after a 304 and "MISS" otherwise. Close must be called;
+// on a miss it caches the body if it was read completely and is small enough.
+type Body struct {
+	io.Reader
+	ContentType string
+	ETag        string
+	CacheStatus string
+	close       func() error
+}
+
+func (b *Body) Close() error {
+	return b.close()
+}
+
+type revalidateKey struct{}
+
+// withRevalidation returns a context whose Tasks API reads check with Google
+// even when cached within cache.ttl, for callers that must not act on stale
+// data. An unchanged response still costs only a 304.
+func withRevalidation(ctx context.Context) context.Context {
+	return context.WithValue(ctx, revalidateKey{}, true)
+}
+
+// Open fetches path (relative to the Tasks API base URL) for streaming.
+// Non-2xx upstream responses are returned as *APIError.
+func (c *TasksClient) Open(ctx context.Context, accessToken, path string, query url.Values) (*Body, error) {
+	target := googleTasksBaseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	key := cacheKey(accessToken, target)
+
+	cached, fresh := c.cache.Lookup(key)
+	if fresh && ctx.Value(revalidateKey{}) == nil {
+		return cachedBody(cached, "HIT"), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if cached != nil && cached.ETag != "" {
+		req.Header.Set("If-None-Match", cached.ETag)
+	}
+
+	resp, err := c.upstream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		resp.Body.Close()
+		c.cache.Revalidated(key, cached)
+		return cachedBody(cached, "REVALIDATED"), nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		return nil, upstreamResponseError(resp, "Google Tasks request failed")
+	}
+
+	capture := &boundedBuffer{limit: maxCachedBody}
+	reader := &eofReader{r: io.TeeReader(io.LimitReader(resp.Body, maxUpstreamBody), capture)}
+	fetched := &CachedResponse{
+		ContentType: resp.Header.Get("Content-Type"),
+		ETag:        resp.Header.Get("ETag"),
+		FetchedAt:   time.Now(),
+	}
+
+	return &Body{
+		Reader:      reader,
+		ContentType: fetched.ContentType,
+		ETag:        fetched.ETag,
+		CacheStatus: "MISS",
+		close: func() error {
+			if reader.eof && !capture.overflow {
+				fetched.Body = capture.buf.Bytes()
+				c.cache.Store(key, fetched)
+			}
+			return resp.Body.Close()
+		},
+	}, nil
+}
+
+func cachedBody(cached *CachedResponse, status string) *Body {
+	return &Body{
+		Reader:      bytes.NewReader(cached.Body),
+		ContentType: cached.ContentType,
+		ETag:        cached.ETag,
+		CacheStatus: status,
+		close:       func() error { return nil },
+	}
+}
+
+// boundedBuffer collects writes until limit, then gives up and only records
+// that it overflowed.
+type boundedBuffer struct {
+	buf      bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (b *boundedBuffer) Write(p []byte) (int, error) {
+	if !b.overflow && b.buf.Len()+len(p) <= b.limit {
+		b.buf.Write(p)
+	} else {
+		b.overflow = true
+		b.buf = bytes.Buffer{}
+	}
+	return len(p), nil
+}
+
+// eofReader records whether its reader was consumed to the end.
+type eofReader struct {
+	r   io.Reader
+	eof bool
+}
+
+func (e *eofReader) Read(p []byte) (int, error) {
+	n, err := e.r.Read(p)
+	if err == io.EOF {
+		e.eof = true
+	}
+	return n, err
+}
+
+// ListTaskLists returns every task list, following pagination.
+func (c *TasksClient) ListTaskLists(ctx context.Context, accessToken string) ([]api.TaskList, error) {
+	var lists []api.TaskList
+	err := c.eachPage(ctx, accessToken, "/users/@me/lists", url.Values{}, func(dec *json.Decoder) error {
+		var list api.TaskList
+		if err := dec.Decode(&list); err != nil {
+			return err
+		}
+		lists = append(lists, list)
+		return nil
+	})
+	return lists, err
+}
+
+// ListTasks returns every task in listID, following pagination. query holds
+// extra filters such as showCompleted.
+func (c *TasksClient) ListTasks(ctx context.Context, accessToken, listID string, query url.Values) ([]api.Task, error) {
+	var tasks []api.Task
+	err := c.eachPage(ctx, accessToken, tasksPath(listID), maps.Clone(query), func(dec *json.Decoder) error {
+		var task api.Task
+		if err := dec.Decode(&task); err != nil {
+			return err
+		}
+		tasks = append(tasks, task)
+		return nil
+	})
+	return tasks, err
+}
+
+// GetTaskList returns the task list listID, which may be "@default".
+func (c *TasksClient) GetTaskList(ctx context.Context, accessToken, listID string) (api.TaskList, error) {
+	body, err := c.Open(ctx, accessToken, "/users/@me/lists/"+url.PathEscape(listID), nil)
+	if err != nil {
+		return api.TaskList{}, err
+	}
+	defer body.Close()
+	var list api.TaskList
+	if err := json.NewDecoder(body).Decode(&list); err != nil {
+		return api.TaskList{}, err
+	}
+	// Read to the end so the response is cached
+	io.Copy(io.Discard, body)
+	return list, nil
+}
+
+// GetTask returns one task of listID.
+func (c *TasksClient) GetTask(ctx context.Context, accessToken, listID, taskID string) (api.Task, error) {
+	body, err := c.Open(ctx, accessToken, taskPath(listID, taskID), nil)
+	if err != nil {
+		return api.Task{}, err
+	}
+	defer body.Close()
+	var task api.Task
+	if err := json.NewDecoder(body).Decode(&task); err != nil {
+		return api.Task{}, err
+	}
+	// Read to the end so the response is cached
+	io.Copy(io.Discard, body)
+	return task, nil
+}
+
+func tasksPath(listID string) string {
+	return "/lists/" + url.PathEscape(listID) + "/tasks"
+}
+
+func taskPath(listID, taskID string) string {
+	return tasksPath(listID) + "/" + url.PathEscape(taskID)
+}
+
+// InsertWrite creates task at the top of listID.
+func InsertWrite(listID string, task api.Task) api.TaskWrite {
+	return api.TaskWrite{Method: "POST", Path: tasksPath(listID), Body: task, List: listID}
+}
+
+// ListInsertWrite creates a task list titled title. Its response is read
+// as a task, which has the list's ID and title.
+func ListInsertWrite(title string) api.TaskWrite {
+	return api.TaskWrite{Method: "POST", Path: "/users/@me/lists", Body: api.TaskList{Title: title}}
+}
+
+// PatchWrite updates only the given fields, keyed by their JSON names.
+func PatchWrite(listID, taskID string, fields map[string]any) api.TaskWrite {
+	return api.TaskWrite{Method: "PATCH", Path: taskPath(listID, taskID), Body: fields, List: listID, Task: taskID}
+}
+
+func DeleteWrite(listID, taskID string) api.TaskWrite {
+	return api.TaskWrite{Method: "DELETE", Path: taskPath(listID, taskID), List: listID, Task: taskID}
+}
+
+// MoveWrite moves a task under parent (top level when empty), after previous
+// (first when empty).
+func MoveWrite(listID, taskID, parent, previous string) api.TaskWrite {
+	query := url.Values{}
+	if parent != "" {
+		query.Set("parent", parent)
+	}
+	if previous != "" {
+		query.Set("previous", previous)
+	}
+	return api.TaskWrite{Method: "POST", Path: taskPath(listID, taskID) + "/move", Query: query, List: listID, Task: taskID}
+}
+
+// InsertTask creates task at the top of listID and returns it as stored by
+// Google.
+func (c *TasksClient) InsertTask(ctx context.Context, accessToken, listID string, task api.Task) (api.Task, error) {
+	created, err := c.Write(ctx, accessToken, InsertWrite(listID, task))
+	if err != nil {
+		return api.Task{}, err
+	}
+	return *created, nil
+}
+
+// Write sends w and returns the task Google responds with, or nil for a
+// delete. The caller's cached reads of the list are invalidated.
+func (c *TasksClient) Write(ctx context.Context, accessToken string, w api.TaskWrite) (*api.Task, error) {
+	target := googleTasksBaseURL + w.Path
+	if len(w.Query) > 0 {
+		target += "?" + w.Query.Encode()
+	}
+	var body io.Reader
+	if w.Body != nil {
+		payload, err := json.Marshal(w.Body)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, w.Method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if w.Body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.upstream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, upstreamResponseError(resp, "Google Tasks request failed")
+	}
+
+	if w.List == "" {
+		// A write to the lists themselves
+		c.cache.Invalidate(accessToken, googleTasksBaseURL+"/users/@me/lists")
+	} else {
+		c.cache.Invalidate(accessToken, googleTasksBaseURL+tasksPath(w.List))
+	}
+	if resp.StatusCode == http.StatusNoContent {
+		return nil, nil
+	}
+	var task api.Task
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxCachedBody)).Decode(&task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// eachPage walks every page of a collection, streaming each element of its
+// "items" array to decodeItem so a page is never held as a generic value.
+func (c *TasksClient) eachPage(ctx context.Context, accessToken, path string, query url.Values, decodeItem func(*json.Decoder) error) error {
+	if query == nil {
+		query = url.Values{}
+	}
+	query.Set("maxResults", "100")
+	for {
+		body, err := c.Open(ctx, accessToken, path, query)
+		if err != nil {
+			return err
+		}
+		next, err := decodeItems(body, decodeItem)
+		body.Close()
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		query.Set("pageToken", next)
+	}
+}
+
+// FetchAll fetches every list and then each list's tasks concurrently, with at
+// most workers requests in flight. Results keep Google's list order.
+func (c *TasksClient) FetchAll(ctx context.Context, accessToken string, query url.Values, workers int) ([]api.ListWithTasks, error) {
+	lists, err := c.ListTaskLists(ctx, accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]api.ListWithTasks, len(lists))
+	sem := make(chan struct{}, workers)
+	var wg sync.WaitGroup
+	for i, list := range lists {
+		results[i].TaskList = list
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case sem <- struct{}{}:
+				defer func() { <-sem }()
+			case <-ctx.Done():
+				results[i].Error = upstreamFailure(ctx.Err(), "Cancelled")
+				return
+			}
+
+			tasks, err := c.ListTasks(ctx, accessToken, list.ID, query)
+			if err != nil {
+				results[i].Error = asAPIError(err, "Failed to fetch tasks")
+				return
+			}
+			results[i].Tasks = tasks
+		}()
+	}
+	wg.Wait()
+
+	return results, nil
+}
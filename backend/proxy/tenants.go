@@ -0,0 +1,393 @@
+package proxy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// tenantName restricts user names, which name their data directories.
+var tenantName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// Tenant is one user of a multi-tenant proxy. Their Google token,
+// snoozes, task metadata, archive and task history are kept apart from everyone else's under tenants.dir/users/<name>;
+// cached Tasks API reads are already keyed by access token.
+type Tenant struct {
+	User     string
+	Tokens   *TokenStore
+	Snoozes  *SnoozeStore
+	Metadata *MetadataStore
+	Archive  *ArchiveStore
+	History  *HistoryStore
+}
+
+// Tenants holds the users and sessions of a multi-tenant proxy.
+type Tenants struct {
+	dir      string
+	sessions *SessionStore
+	mutex    sync.Mutex
+	tenants  map[string]*Tenant
+}
+
+func NewTenants(dir string) *Tenants {
+	return &Tenants{
+		dir:      dir,
+		sessions: NewSessionStore(filepath.Join(dir, "sessions.json")),
+		tenants:  make(map[string]*Tenant),
+	}
+}
+
+// Get returns user's tenant. Each user has one, so their stores' locks are
+// shared by all their requests.
+func (t *Tenants) Get(user string) *Tenant {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	tenant, ok := t.tenants[user]
+	if !ok {
+		dir := filepath.Join(t.dir, "users", user)
+		tenant = &Tenant{
+			User:     user,
+			Tokens:   NewTokenStore(filepath.Join(dir, "tokens.json")),
+			Snoozes:  NewSnoozeStore(filepath.Join(dir, "snoozes.json")),
+			Metadata: NewMetadataStore(filepath.Join(dir, "metadata.json")),
+			Archive:  NewArchiveStore(filepath.Join(dir, "archive.json")),
+			History:  NewHistoryStore(filepath.Join(dir, "history.json")),
+		}
+		t.tenants[user] = tenant
+	}
+	return tenant
+}
+
+// Session is an open session of a tenant.
+type Session struct {
+	User    string    `json:"user"`
+	Client  string    `json:"client,omitempty"` // User-Agent the session was opened with
+	Created time.Time `json:"created"`
+	Expires time.Time `json:"expires"`
+}
+
+// SessionStore keeps open sessions in a JSON file readable only by its
+// owner, keyed by the SHA-256 of their token so the file holds no usable
+// session. Every tenant request looks a session up, so the file is read
+// once and kept in memory.
+type SessionStore struct {
+	path     string
+	mutex    sync.Mutex
+	sessions map[string]Session // nil until read
+}
+
+func NewSessionStore(path string) *SessionStore {
+	return &SessionStore{path: path}
+}
+
+func sessionKey(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Open starts a session for user lasting ttl and returns its token.
+func (s *SessionStore) Open(user, client string, ttl time.Duration) (string, Session, error) {
+	token, err := generateRandomString(32)
+	if err != nil {
+		return "", Session{}, err
+	}
+	now := time.Now()
+	session := Session{User: user, Client: client, Created: now, Expires: now.Add(ttl)}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return "", Session{}, err
+	}
+	s.sessions[sessionKey(token)] = session
+	if err := s.write(); err != nil {
+		delete(s.sessions, sessionKey(token))
+		return "", Session{}, err
+	}
+	return token, session, nil
+}
+
+// Lookup returns the unexpired session for token.
+func (s *SessionStore) Lookup(token string) (Session, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return Session{}, false, err
+	}
+	session, ok := s.sessions[sessionKey(token)]
+	if !ok || time.Now().After(session.Expires) {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// sessionID is a session's name in /admin/sessions: a prefix of its key,
+// which cannot be turned back into the token.
+func sessionID(key string) string {
+	return key[:16]
+}
+
+// List returns the unexpired sessions by ID.
+func (s *SessionStore) List() (map[string]Session, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sessions := make(map[string]Session, len(s.sessions))
+	for key, session := range s.sessions {
+		if !now.After(session.Expires) {
+			sessions[sessionID(key)] = session
+		}
+	}
+	return sessions, nil
+}
+
+// Expire ends the session named id by List. ok is false when there is none.
+func (s *SessionStore) Expire(id string) (ok bool, err error) {
+	n, err := s.remove(func(key string, _ Session) bool { return sessionID(key) == id })
+	return n > 0, err
+}
+
+// ExpireUser ends every session of user and returns how many there were.
+func (s *SessionStore) ExpireUser(user string) (int, error) {
+	return s.remove(func(_ string, session Session) bool { return session.User == user })
+}
+
+func (s *SessionStore) remove(match func(key string, session Session) bool) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	removed := make(map[string]Session)
+	for key, session := range s.sessions {
+		if match(key, session) {
+			removed[key] = session
+			delete(s.sessions, key)
+		}
+	}
+	if len(removed) == 0 {
+		return 0, nil
+	}
+	if err := s.write(); err != nil {
+		maps.Copy(s.sessions, removed)
+		return 0, err
+	}
+	return len(removed), nil
+}
+
+func (s *SessionStore) load() error {
+	if s.sessions != nil {
+		return nil
+	}
+	sessions := make(map[string]Session)
+	data, err := os.ReadFile(s.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &sessions); err != nil {
+			return fmt.Errorf("%s: %w", s.path, err)
+		}
+	}
+	s.sessions = sessions
+	return nil
+}
+
+// write saves the sessions, forgetting expired ones.
+func (s *SessionStore) write() error {
+	now := time.Now()
+	for key, session := range s.sessions {
+		if now.After(session.Expires) {
+			delete(s.sessions, key)
+		}
+	}
+	data, err := json.MarshalIndent(s.sessions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// tenantFrom returns the tenant a request was authorized for, or nil
+// outside multi-tenant mode.
+func tenantFrom(ctx context.Context) *Tenant {
+	tenant, _ := ctx.Value(tenantKey).(*Tenant)
+	return tenant
+}
+
+// tenantUser returns the name of the request's tenant, or "".
+func tenantUser(ctx context.Context) string {
+	if tenant := tenantFrom(ctx); tenant != nil {
+		return tenant.User
+	}
+	return ""
+}
+
+// tenant returns user's tenant, or nil outside multi-tenant mode.
+func (s *Server) tenant(user string) *Tenant {
+	if s.tenants == nil || user == "" {
+		return nil
+	}
+	return s.tenants.Get(user)
+}
+
+// snoozeStore returns where user's snoozes are kept; "" is the single
+// user of a proxy that is not multi-tenant.
+func (s *Server) snoozeStore(user string) *SnoozeStore {
+	if tenant := s.tenant(user); tenant != nil {
+		return tenant.Snoozes
+	}
+	return s.snoozes
+}
+
+// metadataStore returns where user's task metadata is kept; "" is the
+// single user of a proxy that is not multi-tenant.
+func (s *Server) metadataStore(user string) *MetadataStore {
+	if tenant := s.tenant(user); tenant != nil {
+		return tenant.Metadata
+	}
+	return s.metadata
+}
+
+// archiveStore returns where user's cleared tasks are archived; "" is the
+// single user of a proxy that is not multi-tenant.
+func (s *Server) archiveStore(user string) *ArchiveStore {
+	if tenant := s.tenant(user); tenant != nil {
+		return tenant.Archive
+	}
+	return s.archive
+}
+
+// historyStore returns where the history of user's tasks is kept; "" is
+// the single user of a proxy that is not multi-tenant.
+func (s *Server) historyStore(user string) *HistoryStore {
+	if tenant := s.tenant(user); tenant != nil {
+		return tenant.History
+	}
+	return s.history
+}
+
+// needsSession reports whether a path is only served to a session in
+// multi-tenant mode: everything that acts for a user, but not the browser's
+// OAuth callback or opening the session itself.
+func needsSession(path string) bool {
+	if path == "/auth/callback" || path == "/auth/session" {
+		return false
+	}
+	return strings.HasPrefix(path, "/auth/") || strings.HasPrefix(path, "/api/")
+}
+
+// authorizeTenant resolves the request's session to its tenant and returns
+// the request carrying it. /api requests without an Authorization header
+// are given the tenant's stored Google token, refreshed when needed.
+func (s *Server) authorizeTenant(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	token := r.Header.Get(api.SessionHeader)
+	if token == "" {
+		writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "Missing "+api.SessionHeader+"; open a session with POST /auth/session")
+		return nil, false
+	}
+	session, ok, err := s.tenants.sessions.Lookup(token)
+	if err != nil {
+		log.Printf("Reading sessions: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read sessions")
+		return nil, false
+	}
+	// Removing a user from tenants.users ends their sessions
+	if _, configured := s.config().Tenants.Users[session.User]; !ok || !configured {
+		writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "Unknown or expired session; open a new one with POST /auth/session")
+		return nil, false
+	}
+
+	tenant := s.tenants.Get(session.User)
+	r = r.WithContext(context.WithValue(r.Context(), tenantKey, tenant))
+	if strings.HasPrefix(r.URL.Path, "/api/") && r.Header.Get("Authorization") == "" {
+		access, err := s.AccessToken(r.Context(), tenant.Tokens, defaultAccount)
+		if errors.Is(err, ErrNotLoggedIn) {
+			writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "No Google token is stored for "+tenant.User+"; authorize with /auth/start first")
+			return nil, false
+		}
+		if err != nil {
+			writeTasksError(w, err)
+			return nil, false
+		}
+		r.Header.Set("Authorization", "Bearer "+access)
+	}
+	return r, true
+}
+
+// keepTenantToken stores a successful token response as tenant's Google
+// token, keeping the previous refresh token when Google sent none. It does
+// nothing for a nil tenant (not multi-tenant).
+func keepTenantToken(tenant *Tenant, resp *tokenResponse) {
+	if tenant == nil || resp.status < 200 || resp.status > 299 {
+		return
+	}
+	token, err := parseToken(resp.body)
+	if err != nil {
+		log.Printf("Storing token for %s: %v", tenant.User, err)
+		return
+	}
+	if token.RefreshToken == "" {
+		if prev, err := tenant.Tokens.Load(defaultAccount); err == nil {
+			token.RefreshToken = prev.RefreshToken
+		}
+	}
+	if err := tenant.Tokens.Save(defaultAccount, token); err != nil {
+		log.Printf("Storing token for %s: %v", tenant.User, err)
+	}
+}
+
+// POST /auth/session - Open a session on a multi-tenant proxy
+func (s *Server) handleSession(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	if s.tenants == nil {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Multi-tenant mode is disabled")
+		return
+	}
+
+	var req api.SessionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	cfg := s.config()
+	secret, ok := cfg.Tenants.Users[req.User]
+	if !ok || subtle.ConstantTimeCompare([]byte(req.Secret), []byte(secret)) != 1 {
+		writeError(w, http.StatusUnauthorized, api.CodeUnauthorized, "Unknown user or wrong secret")
+		return
+	}
+
+	token, session, err := s.tenants.sessions.Open(req.User, r.UserAgent(), cfg.Tenants.SessionTTL)
+	if err != nil {
+		log.Printf("Opening session: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to open session")
+		return
+	}
+	log.Printf("Opened session for %s", req.User)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.SessionResponse{
+		Session:   token,
+		User:      session.User,
+		ExpiresAt: session.Expires.Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,132 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// newTenantServer returns a multi-tenant proxy for alice and bob, backed by
+// the mock provider.
+func newTenantServer(t *testing.T) *Server {
+	t.Helper()
+	cfg := DefaultConfig()
+	cfg.Google.ClientID = "id"
+	cfg.Google.ClientSecret = "secret"
+	cfg.Provider = "mock"
+	cfg.Reminders.SnoozeFile = t.TempDir() + "/snoozes.json"
+	cfg.Tenants = TenantsConfig{
+		Enabled:    true,
+		Users:      map[string]string{"alice": "alice-secret", "bob": "bob-secret"},
+		Dir:        t.TempDir(),
+		SessionTTL: time.Hour,
+	}
+	s, err := NewServer(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// openSession opens a session for user as POST /auth/session would.
+func openSession(t *testing.T, s *Server, user string, ttl time.Duration) string {
+	t.Helper()
+	token, _, err := s.tenants.sessions.Open(user, "test", ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// request sends a request with session (none when "") through the routes.
+func request(s *Server, method, path, session string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader("{}"))
+	if session != "" {
+		r.Header.Set(api.SessionHeader, session)
+	}
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, r)
+	return w
+}
+
+func TestTenantMissingSession(t *testing.T) {
+	s := newTenantServer(t)
+
+	for _, path := range []string{"/api/lists", "/auth/poll/some-state"} {
+		w := request(s, "GET", path, "")
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Missing "+api.SessionHeader) {
+			t.Errorf("GET %s without a session: %d %s", path, w.Code, w.Body)
+		}
+	}
+	// Google's redirect cannot carry a session, and health checks need none
+	if w := request(s, "GET", "/auth/callback?state=unknown&code=x", ""); w.Code == http.StatusUnauthorized {
+		t.Errorf("callback asked for a session: %d", w.Code)
+	}
+	if w := request(s, "GET", "/health", ""); w.Code != http.StatusOK {
+		t.Errorf("health without a session: %d", w.Code)
+	}
+}
+
+func TestTenantWrongSession(t *testing.T) {
+	s := newTenantServer(t)
+	expired := openSession(t, s, "alice", -time.Minute)
+	// A user removed from the configuration keeps no access
+	removed := openSession(t, s, "carol", time.Hour)
+
+	for name, session := range map[string]string{
+		"unknown": "not-a-session",
+		"expired": expired,
+		"removed": removed,
+	} {
+		w := request(s, "GET", "/api/lists", session)
+		if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "Unknown or expired session") {
+			t.Errorf("%s session: %d %s", name, w.Code, w.Body)
+		}
+	}
+}
+
+func TestTenantSessionOfAnotherUser(t *testing.T) {
+	s := newTenantServer(t)
+	alice := openSession(t, s, "alice", time.Hour)
+	bob := openSession(t, s, "bob", time.Hour)
+	err := s.tenants.Get("alice").Tokens.Save(defaultAccount, Token{
+		AccessToken: "mock-access-alice",
+		Expiry:      time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if w := request(s, "GET", "/api/lists", alice); w.Code != http.StatusOK {
+		t.Fatalf("alice's lists: %d %s", w.Code, w.Body)
+	}
+	// Bob's session never gets alice's Google token
+	w := request(s, "GET", "/api/lists", bob)
+	if w.Code != http.StatusUnauthorized || !strings.Contains(w.Body.String(), "No Google token is stored for bob") {
+		t.Errorf("bob's lists: %d %s", w.Code, w.Body)
+	}
+
+	// Nor the tokens of a sign-in alice started
+	s.completedAuth.Set("alice-state", CompletedAuth{
+		Tokens: map[string]any{"access_token": "mock-access-alice"},
+		User:   "alice",
+	}, time.Minute)
+	poll := func(session string) api.PollResponse {
+		var resp api.PollResponse
+		if err := json.NewDecoder(request(s, "GET", "/auth/poll/alice-state", session).Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+	if resp := poll(bob); resp.Completed || resp.Tokens != nil {
+		t.Errorf("bob collected alice's sign-in: %+v", resp)
+	}
+	if resp := poll(alice); !resp.Completed {
+		t.Error("bob's poll used up alice's sign-in")
+	}
+}
@@ -0,0 +1,226 @@
+package proxy
+
+import (
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// todoFingerprint identifies a comment across scans: by repository, file,
+// kind and text, but not line, so it survives code moving around it. n
+// tells apart identical comments in one file, counted from the top.
+func todoFingerprint(repo string, todo api.TodoComment, n int) string {
+	text := strings.ToLower(strings.Join(strings.Fields(todo.Text), " "))
+	hash := sha256.Sum256(fmt.Appendf(nil, "%s\x00%s\x00%s\x00%s\x00%d", repo, todo.File, todo.Kind, text, n))
+	return hex.EncodeToString(hash[:8])
+}
+
+// todoFingerprints returns the fingerprint of each of todos.
+func todoFingerprints(repo string, todos []api.TodoComment) []string {
+	order := make([]int, len(todos))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(todos[a].Line, todos[b].Line) })
+
+	fingerprints := make([]string, len(todos))
+	seen := make(map[string]int)
+	for _, i := range order {
+		base := todoFingerprint(repo, todos[i], 0)
+		fingerprints[i] = todoFingerprint(repo, todos[i], seen[base])
+		seen[base]++
+	}
+	return fingerprints
+}
+
+// todoTask is the task for a comment: titled by its text, with where it is
+// and who wrote it in the notes.
+func todoTask(repo string, todo api.TodoComment) api.Task {
+	notes := []string{fmt.Sprintf("%s at %s:%d", todo.Kind, todo.File, todo.Line)}
+	if repo != "" {
+		notes = append(notes, "Repo: "+repo)
+	}
+	if todo.Author != "" {
+		notes = append(notes, "Author: "+todo.Author)
+	}
+	return api.Task{Title: todo.Text, Notes: strings.Join(notes, "\n"), Status: "needsAction"}
+}
+
+// todoList finds the list req names among lists; create is set when it is
+// titled and does not exist yet.
+func todoList(lists []api.ListWithTasks, req api.ImportTodosRequest) (list api.TaskList, create, ok bool) {
+	for _, l := range lists {
+		switch {
+		case req.List != "":
+			ok = l.ID == req.List
+		case req.ListTitle != "":
+			ok = strings.EqualFold(l.Title, req.ListTitle)
+		default:
+			ok = true
+		}
+		if ok {
+			return l.TaskList, false, true
+		}
+	}
+	if req.List == "" && req.ListTitle != "" {
+		return api.TaskList{Title: req.ListTitle}, true, true
+	}
+	return api.TaskList{}, false, false
+}
+
+// POST /api/import/todos - Create or update tasks from TODO comments
+//
+// The plugin scans a repository for TODO, FIXME and similar comments and
+// sends them all. Each comment is one task, found again on later scans by
+// a fingerprint kept in the task's metadata: a comment whose text, line or
+// author changed updates its task, and one seen before is never added
+// twice. The metadata also keeps the comment's code location, for jumping
+// to it from the task. Completed tasks are left alone even while their
+// comment remains; deleted ones are created again. ?dry_run=1 reports the
+// writes without sending them.
+func (s *Server) handleImportTodos(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, ok := bearerToken(w, r)
+	if !ok {
+		return
+	}
+
+	var req api.ImportTodosRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "Invalid JSON")
+		return
+	}
+	if len(req.Todos) == 0 {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, "No todos to import")
+		return
+	}
+	if len(req.Todos) > maxBatchChanges {
+		writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("At most %d todos per import", maxBatchChanges))
+		return
+	}
+	req.ListTitle = strings.TrimSpace(req.ListTitle)
+	for i := range req.Todos {
+		todo := &req.Todos[i]
+		todo.File, todo.Text = strings.TrimSpace(todo.File), strings.TrimSpace(todo.Text)
+		todo.Kind = strings.ToUpper(cmp.Or(strings.TrimSpace(todo.Kind), "TODO"))
+		if todo.File == "" || todo.Text == "" {
+			writeError(w, http.StatusBadRequest, api.CodeInvalidRequest, fmt.Sprintf("todos[%d] needs a file and text", i))
+			return
+		}
+	}
+
+	current, err := s.tasks.FetchAll(withRevalidation(r.Context()), token, watchQuery, s.config().FanoutWorkers)
+	if err != nil {
+		writeTasksError(w, err)
+		return
+	}
+	list, create, ok := todoList(current, req)
+	if !ok {
+		writeListNotFound(w, req.List)
+		return
+	}
+	user := tenantUser(r.Context())
+	metadata, err := s.metadataStore(user).All()
+	if err != nil {
+		log.Printf("Reading task metadata: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to read task metadata")
+		return
+	}
+	type importedTask struct {
+		list string
+		task api.Task
+	}
+	imported := make(map[string]importedTask) // by fingerprint
+	for _, l := range current {
+		// Without every list, a task that was moved could be imported twice
+		if l.Error != nil {
+			writeAPIError(w, l.Error)
+			return
+		}
+		for _, t := range l.Tasks {
+			if fingerprint := metadata[t.ID].Fingerprint; fingerprint != "" {
+				imported[fingerprint] = importedTask{list: l.ID, task: t}
+			}
+		}
+	}
+
+	resp := api.ImportTodosResponse{DryRun: dryRun(r), List: list, Results: []api.ImportedTodo{}}
+	if create && !resp.DryRun {
+		created, err := s.tasks.Write(r.Context(), token, ListInsertWrite(list.Title))
+		if err != nil {
+			writeTasksError(w, err)
+			return
+		}
+		resp.List = api.TaskList{ID: created.ID, Title: created.Title}
+	}
+
+	var undo [][]undoStep
+	located := make(map[string]func(*api.TaskMetadata)) // metadata to save, by task ID
+	locate := func(taskID, fingerprint string, location api.CodeLocation) {
+		if meta := metadata[taskID]; meta.Fingerprint == fingerprint && meta.Location != nil && *meta.Location == location {
+			return
+		}
+		located[taskID] = func(m *api.TaskMetadata) { m.Fingerprint, m.Location = fingerprint, &location }
+	}
+	for i, fingerprint := range todoFingerprints(req.Repo, req.Todos) {
+		todo := req.Todos[i]
+		result := api.ImportedTodo{Todo: todo, Fingerprint: fingerprint, Action: "unchanged"}
+		task := todoTask(req.Repo, todo)
+		location := api.CodeLocation{File: todo.File, Line: todo.Line, Repo: req.Repo, Commit: req.Commit}
+		var write api.TaskWrite
+		if found, ok := imported[fingerprint]; ok {
+			existing := found.task
+			result.Task = &existing
+			if existing.Status == "completed" || existing.Title == task.Title && existing.Notes == task.Notes {
+				if existing.Status != "completed" && !resp.DryRun {
+					locate(existing.ID, fingerprint, location)
+				}
+				resp.Unchanged++
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+			result.Action, write = "updated", PatchWrite(found.list, existing.ID, map[string]any{"title": task.Title, "notes": task.Notes})
+		} else {
+			result.Action, write = "created", InsertWrite(resp.List.ID, task)
+		}
+		result.Request = &write
+
+		if !resp.DryRun {
+			written, steps, err := s.writeUndoable(r, token, write)
+			if err != nil {
+				result.Error = asAPIError(err, "Google Tasks request failed")
+				resp.Results = append(resp.Results, result)
+				continue
+			}
+			result.Task = written
+			undo = append(undo, steps)
+			locate(written.ID, fingerprint, location)
+		}
+		if result.Action == "created" {
+			resp.Created++
+		} else {
+			resp.Updated++
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	if len(located) > 0 {
+		if err := s.metadataStore(user).UpdateMany(located); err != nil {
+			log.Printf("Recording todo locations: %v", err)
+		}
+	}
+	if len(undo) > 0 {
+		s.recordUndo(r, token, changesSummary("todo import", len(undo)), undo...)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,205 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrNotLoggedIn is returned when the token store has no token for an account.
+var ErrNotLoggedIn = errors.New("not logged in, run `auth login` first")
+
+// Token is a Google OAuth token as kept by the token store.
+type Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	TokenType    string    `json:"token_type,omitempty"`
+	Scope        string    `json:"scope,omitempty"`
+	Expiry       time.Time `json:"expiry"`
+}
+
+// expiring reports whether the access token is missing or about to expire.
+// Tokens without an expiry (GitHub's) never do.
+func (t Token) expiring() bool {
+	return t.AccessToken == "" || !t.Expiry.IsZero() && time.Until(t.Expiry) < time.Minute
+}
+
+// parseToken reads a successful Google token endpoint response.
+func parseToken(body []byte) (Token, error) {
+	var resp struct {
+		AccessToken      string `json:"access_token"`
+		RefreshToken     string `json:"refresh_token"`
+		TokenType        string `json:"token_type"`
+		Scope            string `json:"scope"`
+		ExpiresIn        int    `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return Token{}, fmt.Errorf("decoding token response: %w", err)
+	}
+	if resp.AccessToken == "" {
+		if resp.Error != "" {
+			return Token{}, fmt.Errorf("google: %s: %s", resp.Error, resp.ErrorDescription)
+		}
+		return Token{}, errors.New("google returned no access token")
+	}
+	token := Token{
+		AccessToken:  resp.AccessToken,
+		RefreshToken: resp.RefreshToken,
+		TokenType:    resp.TokenType,
+		Scope:        resp.Scope,
+	}
+	if resp.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
+
+// TokenStore keeps one token per account in a JSON file readable only by
+// its owner.
+type TokenStore struct {
+	path  string
+	mutex sync.Mutex
+}
+
+func NewTokenStore(path string) *TokenStore {
+	return &TokenStore{path: path}
+}
+
+// Load returns the token for account, or ErrNotLoggedIn.
+func (s *TokenStore) Load(account string) (Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return Token{}, err
+	}
+	token, ok := tokens[account]
+	if !ok {
+		return Token{}, ErrNotLoggedIn
+	}
+	return token, nil
+}
+
+// All returns every stored token by account.
+func (s *TokenStore) All() (map[string]Token, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.read()
+}
+
+// Save stores token for account, replacing any previous one.
+func (s *TokenStore) Save(account string, token Token) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	tokens[account] = token
+	return s.write(tokens)
+}
+
+// Delete removes account's token. Deleting a missing account is not an error.
+func (s *TokenStore) Delete(account string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	tokens, err := s.read()
+	if err != nil {
+		return err
+	}
+	if _, ok := tokens[account]; !ok {
+		return nil
+	}
+	delete(tokens, account)
+	return s.write(tokens)
+}
+
+func (s *TokenStore) read() (map[string]Token, error) {
+	tokens := make(map[string]Token)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return tokens, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return tokens, nil
+}
+
+func (s *TokenStore) write(tokens map[string]Token) error {
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+// writeFileAtomic replaces path with data, readable only by its owner, so a
+// crash never leaves it truncated.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// AccessToken returns a usable access token for account, refreshing and
+// saving it first when it is about to expire.
+func (s *Server) AccessToken(ctx context.Context, store *TokenStore, account string) (string, error) {
+	token, err := store.Load(account)
+	if err != nil {
+		return "", err
+	}
+	if !token.expiring() {
+		return token.AccessToken, nil
+	}
+	if token.RefreshToken == "" {
+		return "", errors.New("access token expired and no refresh token is stored, run `auth login` again")
+	}
+
+	resp, err, _ := s.refreshToken(ctx, token.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("refreshing token: %w", err)
+	}
+	if resp.status != 200 {
+		return "", fmt.Errorf("refreshing token: %w", upstreamResponseError(resp.httpResponse(), "Token refresh failed"))
+	}
+	refreshed, err := parseToken(resp.body)
+	if err != nil {
+		return "", err
+	}
+	// Google only returns a new refresh token when it rotates it
+	if refreshed.RefreshToken == "" {
+		refreshed.RefreshToken = token.RefreshToken
+	}
+	if err := store.Save(account, refreshed); err != nil {
+		return "", err
+	}
+	return refreshed.AccessToken, nil
+}
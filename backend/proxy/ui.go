@@ -0,0 +1,455 @@
+package proxy
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// uiData is what the dashboard page is rendered with; everything else it
+// shows it fetches from the API.
+type uiData struct {
+	Nonce   string
+	Version string
+	Tenants bool // sign in with a session before using Google
+}
+
+var uiPage = template.Must(template.New("ui").Parse(`<!DOCTYPE html>
+<html><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1">
+<title>gtask</title>
+<style>
+	body { font-family: sans-serif; max-width: 50em; margin: 1em auto; padding: 0 1em; }
+	header { display: flex; justify-content: space-between; align-items: baseline; flex-wrap: wrap; gap: 1em; }
+	h1 { margin: 0; }
+	section { margin-top: 1.5em; }
+	dl { display: grid; grid-template-columns: max-content auto; gap: 0.2em 1em; margin: 0; }
+	dt { color: #555; }
+	dd { margin: 0; }
+	ul.tasks { list-style: none; padding-left: 0; }
+	ul.tasks ul.tasks { padding-left: 1.5em; }
+	li.completed > label > span.title { text-decoration: line-through; color: #777; }
+	.due, .muted { color: #555; font-size: 0.9em; }
+	.overdue { color: #b00020; }
+	.error { color: #b00020; }
+	.ok { color: #1b7e3a; }
+	form.inline { display: flex; gap: 0.5em; margin: 0.5em 0; }
+	form.inline input[type=text] { flex: 1; }
+	#events { max-height: 12em; overflow-y: auto; font-family: monospace; font-size: 0.85em; padding-left: 1.2em; }
+	[hidden] { display: none !important; }
+</style></head><body>
+<header>
+	<h1>gtask</h1>
+	<span class="muted">proxy {{.Version}}</span>
+</header>
+
+<section id="signin" hidden>
+	<h2>Sign in</h2>
+	{{if .Tenants}}
+	<form id="session" class="inline">
+		<input type="text" name="user" placeholder="User" required autocomplete="username">
+		<input type="password" name="secret" placeholder="Secret" required autocomplete="current-password">
+		<button type="submit">Open session</button>
+	</form>
+	{{else}}
+	<p><button id="google">Sign in with Google</button></p>
+	<details><summary>Use an existing access token</summary>
+		<p class="muted">To see what the plugin sees, paste the access token it is using.</p>
+		<form id="paste" class="inline">
+			<input type="text" name="token" placeholder="Access token" required autocomplete="off">
+			<button type="submit">Use</button>
+		</form>
+	</details>
+	{{end}}
+	<p id="signin-status"></p>
+</section>
+
+<section id="status">
+	<h2>Status</h2>
+	<dl>
+		<dt>Proxy</dt><dd id="health">checking...</dd>
+		<dt>Signed in</dt><dd id="auth">no</dd>
+		<dt>Loaded</dt><dd id="loaded">never</dd>
+		<dt>Live updates</dt><dd id="stream">off</dd>
+	</dl>
+	<p><button id="reload" hidden>Reload</button> <button id="signout" hidden>Sign out</button></p>
+</section>
+
+<section id="dashboard" hidden>
+	<h2>Lists</h2>
+	<form id="add" class="inline">
+		<select name="list"></select>
+		<input type="text" name="text" placeholder="pay rent tomorrow 9am #finance !p1" required>
+		<button type="submit">Add</button>
+	</form>
+	<label><input type="checkbox" id="show-completed"> Show completed</label>
+	<div id="lists"></div>
+
+	<h2>Recent changes</h2>
+	<p class="muted">Changes made through the proxy with this sign-in, and changes found by change polling while this page is open.</p>
+	<ul id="events"></ul>
+</section>
+
+<script nonce="{{.Nonce}}">
+"use strict";
+const tenants = {{.Tenants}};
+const store = window.localStorage;
+const $ = function (id) { return document.getElementById(id); };
+let lists = [];
+let stream = null;
+
+function text(el, value, cls) { el.textContent = value; el.className = cls || ""; }
+
+function headers() {
+	const h = { "Content-Type": "application/json" };
+	if (store.getItem("gtask.session")) { h["X-Gtask-Session"] = store.getItem("gtask.session"); }
+	if (store.getItem("gtask.access_token")) { h["Authorization"] = "Bearer " + store.getItem("gtask.access_token"); }
+	return h;
+}
+
+function signedIn() {
+	return tenants ? !!store.getItem("gtask.session") : !!store.getItem("gtask.access_token");
+}
+
+function saveTokens(tokens) {
+	store.setItem("gtask.access_token", tokens.access_token);
+	if (tokens.refresh_token) { store.setItem("gtask.refresh_token", tokens.refresh_token); }
+	if (tokens.expires_in) {
+		store.setItem("gtask.expires_at", new Date(Date.now() + tokens.expires_in * 1000).toISOString());
+	} else {
+		store.removeItem("gtask.expires_at");
+	}
+}
+
+// refresh swaps the refresh token for a new access token, if there is one
+async function refresh() {
+	const refreshToken = store.getItem("gtask.refresh_token");
+	if (tenants || !refreshToken) { return false; }
+	const resp = await fetch("auth/refresh", { method: "POST", headers: headers(), body: JSON.stringify({ refresh_token: refreshToken }) });
+	if (!resp.ok) { return false; }
+	saveTokens(await resp.json());
+	return true;
+}
+
+// call sends an API request, refreshing the access token once when it was
+// rejected, and returns the decoded body or throws the API error
+async function call(method, path, body) {
+	const send = function () {
+		return fetch(path, { method: method, headers: headers(), body: body === undefined ? undefined : JSON.stringify(body) });
+	};
+	let resp = await send();
+	if (resp.status === 401 && await refresh()) { resp = await send(); }
+	const data = await resp.json().catch(function () { return {}; });
+	if (!resp.ok) {
+		const err = new Error(data.error ? data.error.message : resp.status + " " + resp.statusText);
+		err.status = resp.status;
+		throw err;
+	}
+	return data;
+}
+
+function showAuth() {
+	const auth = $("auth");
+	if (!signedIn()) {
+		text(auth, "no");
+	} else if (tenants) {
+		const expires = store.getItem("gtask.session_expires_at");
+		text(auth, "as " + store.getItem("gtask.user") + (expires ? ", session until " + new Date(expires).toLocaleString() : ""), "ok");
+	} else {
+		const expires = store.getItem("gtask.expires_at");
+		const refreshable = store.getItem("gtask.refresh_token") ? ", refreshed automatically" : ", cannot be refreshed";
+		text(auth, (expires ? "access token until " + new Date(expires).toLocaleTimeString() : "with an access token") + refreshable, "ok");
+	}
+	$("signin").hidden = signedIn();
+	$("dashboard").hidden = !signedIn();
+	$("reload").hidden = !signedIn();
+	$("signout").hidden = !signedIn();
+}
+
+async function checkHealth() {
+	try {
+		const health = await (await fetch("health")).json();
+		text($("health"), health.status + ", version " + health.version, health.status === "ok" ? "ok" : "error");
+	} catch (e) {
+		text($("health"), "unreachable: " + e.message, "error");
+	}
+}
+
+function dueLabel(task) {
+	if (!task.due) { return ""; }
+	const due = task.due.slice(0, 10);
+	const today = new Date().toISOString().slice(0, 10);
+	const span = document.createElement("span");
+	span.className = "due" + (task.status !== "completed" && due < today ? " overdue" : "");
+	span.textContent = " due " + due;
+	return span;
+}
+
+function renderTasks(list, tasks, parent) {
+	const ul = document.createElement("ul");
+	ul.className = "tasks";
+	const children = tasks.filter(function (t) { return (t.parent || "") === parent; });
+	children.sort(function (a, b) { return (a.position || "").localeCompare(b.position || ""); });
+	for (const task of children) {
+		const li = document.createElement("li");
+		if (task.status === "completed") { li.className = "completed"; }
+		const label = document.createElement("label");
+		const box = document.createElement("input");
+		box.type = "checkbox";
+		box.checked = task.status === "completed";
+		box.onchange = function () { setCompleted(list, task, box.checked); };
+		const title = document.createElement("span");
+		title.className = "title";
+		title.textContent = " " + (task.title || "(untitled)");
+		label.append(box, title);
+		const due = dueLabel(task);
+		if (due) { label.append(due); }
+		li.append(label);
+		if (task.notes) {
+			const notes = document.createElement("div");
+			notes.className = "muted";
+			notes.textContent = task.notes;
+			li.append(notes);
+		}
+		const sub = renderTasks(list, tasks, task.id);
+		if (sub.childElementCount > 0) { li.append(sub); }
+		ul.append(li);
+	}
+	return ul;
+}
+
+function render() {
+	const container = $("lists");
+	container.replaceChildren();
+	const select = document.forms.add.elements.list;
+	const selected = select.value;
+	select.replaceChildren();
+	const showCompleted = $("show-completed").checked;
+	for (const list of lists) {
+		const option = document.createElement("option");
+		option.value = list.id;
+		option.textContent = list.title;
+		select.append(option);
+
+		const tasks = (list.tasks || []).filter(function (t) { return showCompleted || t.status !== "completed"; });
+		const open = (list.tasks || []).filter(function (t) { return t.status !== "completed"; }).length;
+		const details = document.createElement("details");
+		details.open = true;
+		const summary = document.createElement("summary");
+		summary.textContent = list.title + " (" + open + " open)";
+		details.append(summary);
+		if (list.error) {
+			const p = document.createElement("p");
+			p.className = "error";
+			p.textContent = "Could not load: " + list.error.message;
+			details.append(p);
+		}
+		details.append(renderTasks(list, tasks, ""));
+		container.append(details);
+	}
+	if (selected) { select.value = selected; }
+}
+
+async function load() {
+	try {
+		const data = await call("GET", "api/tasks?showCompleted=true&showHidden=true");
+		lists = data.lists || [];
+		render();
+		const failed = lists.filter(function (l) { return l.error; }).length;
+		text($("loaded"), new Date().toLocaleTimeString() + ", " + lists.length + " lists" + (failed ? ", " + failed + " failed" : ""), failed ? "error" : "ok");
+		await loadUndo();
+	} catch (e) {
+		text($("loaded"), "failed: " + e.message, "error");
+		if (e.status === 401) { signOut(); }
+	}
+	showAuth();
+}
+
+async function loadUndo() {
+	const data = await call("GET", "api/undo");
+	const events = $("events");
+	events.replaceChildren();
+	for (const entry of data.entries || []) {
+		logEvent(new Date(entry.at), entry.summary, true);
+	}
+}
+
+function logEvent(at, description, append) {
+	const li = document.createElement("li");
+	li.textContent = at.toLocaleTimeString() + " " + description;
+	if (append) { $("events").append(li); } else { $("events").prepend(li); }
+}
+
+async function setCompleted(list, task, done) {
+	const fields = done ? { status: "completed" } : { status: "needsAction", completed: null };
+	try {
+		const resp = await call("POST", "api/batch", { changes: [{ op: "update", list: list.id, task: task.id, fields: fields }] });
+		const result = resp.results[0];
+		if (result.error) { throw new Error(result.error.message); }
+	} catch (e) {
+		alert("Updating " + task.title + " failed: " + e.message);
+	}
+	await load();
+}
+
+// listen reads the event stream; EventSource cannot send the Authorization
+// header, so it is read with fetch
+async function listen() {
+	if (stream || !signedIn()) { return; }
+	stream = new AbortController();
+	const controller = stream;
+	text($("stream"), "connecting...");
+	try {
+		const resp = await fetch("api/events", { headers: headers(), signal: controller.signal });
+		if (!resp.ok) { throw new Error(resp.status + " " + resp.statusText); }
+		text($("stream"), "connected", "ok");
+		const reader = resp.body.pipeThrough(new TextDecoderStream()).getReader();
+		let buffer = "";
+		for (;;) {
+			const chunk = await reader.read();
+			if (chunk.done) { break; }
+			buffer += chunk.value;
+			let end;
+			while ((end = buffer.indexOf("\n\n")) >= 0) {
+				handleEvent(buffer.slice(0, end));
+				buffer = buffer.slice(end + 2);
+			}
+		}
+		text($("stream"), "disconnected", "error");
+	} catch (e) {
+		if (controller.signal.aborted) { return; }
+		text($("stream"), "disconnected: " + e.message, "error");
+	}
+	stream = null;
+	setTimeout(listen, 30000);
+}
+
+function handleEvent(block) {
+	let name = "message";
+	let data = "";
+	for (const line of block.split("\n")) {
+		if (line.startsWith("event: ")) { name = line.slice(7); }
+		if (line.startsWith("data: ")) { data += line.slice(6); }
+	}
+	if (!data) { return; }
+	const event = JSON.parse(data);
+	if (name === "change") {
+		logEvent(new Date(), event.type + (event.task ? " " + event.task.title : " " + event.list.title), false);
+		load();
+	} else if (name === "reminder") {
+		logEvent(new Date(), event.kind + " reminder: " + event.task.title, false);
+	} else if (name === "error") {
+		logEvent(new Date(), "error: " + (event.message || data), false);
+	}
+}
+
+function signOut() {
+	for (const key of ["access_token", "refresh_token", "expires_at", "session", "session_expires_at", "user"]) {
+		store.removeItem("gtask." + key);
+	}
+	if (stream) { stream.abort(); stream = null; }
+	text($("stream"), "off");
+	lists = [];
+	render();
+	showAuth();
+}
+
+function start() {
+	showAuth();
+	if (signedIn()) {
+		load();
+		listen();
+	}
+}
+
+if (tenants) {
+	$("session").onsubmit = async function (e) {
+		e.preventDefault();
+		const form = e.target.elements;
+		try {
+			const session = await call("POST", "auth/session", { user: form.user.value, secret: form.secret.value });
+			store.setItem("gtask.session", session.session);
+			store.setItem("gtask.session_expires_at", session.expires_at);
+			store.setItem("gtask.user", session.user);
+			text($("signin-status"), "");
+			start();
+		} catch (err) {
+			text($("signin-status"), "Could not open a session: " + err.message, "error");
+		}
+	};
+} else {
+	$("google").onclick = async function () {
+		const status = $("signin-status");
+		try {
+			const begin = await call("POST", "auth/start");
+			window.open(begin.authUrl, "_blank");
+			text(status, "Waiting for you to finish signing in...");
+			for (let i = 0; i < 300; i++) {
+				await new Promise(function (r) { setTimeout(r, 2000); });
+				const poll = await call("GET", "auth/poll/" + encodeURIComponent(begin.state));
+				if (!poll.completed) { continue; }
+				if (!poll.tokens || !poll.tokens.access_token) {
+					throw new Error((poll.tokens && (poll.tokens.error_description || poll.tokens.error)) || "Google refused the sign-in");
+				}
+				saveTokens(poll.tokens);
+				text(status, "");
+				start();
+				return;
+			}
+			text(status, "Timed out waiting for sign-in.", "error");
+		} catch (err) {
+			text(status, "Sign-in failed: " + err.message, "error");
+		}
+	};
+	$("paste").onsubmit = function (e) {
+		e.preventDefault();
+		saveTokens({ access_token: e.target.elements.token.value.trim() });
+		store.removeItem("gtask.refresh_token");
+		e.target.reset();
+		start();
+	};
+}
+
+$("add").onsubmit = async function (e) {
+	e.preventDefault();
+	const form = e.target.elements;
+	try {
+		await call("POST", "api/quickadd", { text: form.text.value, list: form.list.value, tz: Intl.DateTimeFormat().resolvedOptions().timeZone });
+		e.target.elements.text.value = "";
+	} catch (err) {
+		alert("Adding the task failed: " + err.message);
+	}
+	await load();
+};
+$("show-completed").onchange = render;
+$("reload").onclick = load;
+$("signout").onclick = signOut;
+
+checkHealth();
+start();
+</script>
+</body></html>
+`))
+
+// GET /ui - Dashboard of lists, tasks, sign-in and sync status
+//
+// A page for checking the proxy's view from a browser, or using tasks where
+// there is no Neovim. It signs in like the plugin (or opens a session on a
+// multi-tenant proxy), keeps its tokens in the browser's local storage and
+// uses the same API.
+func (s *Server) handleUI(w http.ResponseWriter, r *http.Request) {
+	nonce, err := generateRandomString(16)
+	if err != nil {
+		log.Printf("Generating script nonce: %v", err)
+		writeError(w, http.StatusInternalServerError, api.CodeInternal, "Failed to render the page")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", "default-src 'self'; script-src 'nonce-"+nonce+"'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if err := uiPage.Execute(w, uiData{Nonce: nonce, Version: version, Tenants: s.tenants != nil}); err != nil {
+		log.Printf("Rendering dashboard: %v", err)
+	}
+}
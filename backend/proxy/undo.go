@@ -0,0 +1,381 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// Bounds of the undo histories besides api.undo_depth: how many sessions
+// have one and how long one is kept after its last mutation.
+const (
+	maxUndoHistories = 1000
+	undoTTL          = 24 * time.Hour
+)
+
+// undoStep is one change that reverts part of a mutation. restores is set
+// on a create that brings back a deleted task: the ID it had, which later
+// steps may name as their task, parent or previous sibling.
+type undoStep struct {
+	change   api.Change
+	restores string
+}
+
+type undoEntry struct {
+	id      int
+	summary string
+	at      time.Time
+	steps   []undoStep
+}
+
+func (e undoEntry) api() api.UndoEntry {
+	entry := api.UndoEntry{ID: e.id, Summary: e.summary, At: e.at.Format(time.RFC3339), Changes: []api.Change{}}
+	for _, step := range e.steps {
+		entry.Changes = append(entry.Changes, step.change)
+	}
+	return entry
+}
+
+// undoHistory is one session's undoable mutations, oldest first.
+type undoHistory struct {
+	mutex   sync.Mutex
+	entries []undoEntry
+	next    int
+}
+
+// UndoStore keeps an undo history per session in memory: per tenant
+// session in multi-tenant mode, otherwise per access token.
+type UndoStore struct {
+	mutex     sync.Mutex
+	histories *LRU[*undoHistory]
+}
+
+func NewUndoStore() *UndoStore {
+	return &UndoStore{histories: NewLRU[*undoHistory]("undo_histories", maxUndoHistories)}
+}
+
+func (u *UndoStore) history(key string) *undoHistory {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	h, ok := u.histories.Get(key)
+	if !ok {
+		h = &undoHistory{}
+	}
+	u.histories.Set(key, h, undoTTL)
+	return h
+}
+
+// Record adds an entry that applies steps, keeping the newest depth.
+func (u *UndoStore) Record(key, summary string, steps []undoStep, depth int) {
+	if len(steps) == 0 {
+		return
+	}
+	h := u.history(key)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.next++
+	h.entries = append(h.entries, undoEntry{id: h.next, summary: summary, at: time.Now(), steps: steps})
+	if len(h.entries) > depth {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-depth)
+	}
+}
+
+// Entries returns the history, newest first.
+func (u *UndoStore) Entries(key string) []undoEntry {
+	h := u.history(key)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	entries := slices.Clone(h.entries)
+	slices.Reverse(entries)
+	return entries
+}
+
+// Pop removes and returns the newest entry and how many are left; ok is
+// false when there is none. With peek, the entry is kept.
+func (u *UndoStore) Pop(key string, peek bool) (entry undoEntry, remaining int, ok bool) {
+	h := u.history(key)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if len(h.entries) == 0 {
+		return undoEntry{}, 0, false
+	}
+	remaining = len(h.entries) - 1
+	entry = h.entries[remaining]
+	if !peek {
+		h.entries = h.entries[:remaining]
+	}
+	return entry, remaining, true
+}
+
+// Restore puts back an entry taken by Pop, in its place by age, so undoing
+// it can be tried again. An entry older than the history's depth is dropped.
+func (u *UndoStore) Restore(key string, entry undoEntry, depth int) {
+	h := u.history(key)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	i, _ := slices.BinarySearchFunc(h.entries, entry.id, func(e undoEntry, id int) int { return cmp.Compare(e.id, id) })
+	h.entries = slices.Insert(h.entries, i, entry)
+	if len(h.entries) > depth {
+		h.entries = slices.Delete(h.entries, 0, len(h.entries)-depth)
+	}
+}
+
+// Rename replaces task IDs in the remaining entries of key's history, after
+// undoing brought deleted tasks back under new IDs.
+func (u *UndoStore) Rename(key string, ids map[string]string) {
+	if len(ids) == 0 {
+		return
+	}
+	h := u.history(key)
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	for _, entry := range h.entries {
+		for i := range entry.steps {
+			step := &entry.steps[i]
+			for _, id := range []*string{&step.change.Task, &step.change.Parent, &step.change.Previous, &step.restores} {
+				*id = cmp.Or(ids[*id], *id)
+			}
+		}
+	}
+}
+
+// undoKey names the history of r's session: its X-Gtask-Session in
+// multi-tenant mode, otherwise the Google account of its token (see
+// accountOf), which outlives the token.
+func undoKey(r *http.Request, account string) string {
+	if tenantFrom(r.Context()) != nil {
+		return "session:" + sessionKey(r.Header.Get(api.SessionHeader))
+	}
+	return "account:" + account
+}
+
+// previousSibling returns the ID of the task right before t under the same
+// parent in tasks, or "" when t comes first.
+func previousSibling(tasks []api.Task, t api.Task) string {
+	var previous *api.Task
+	for i, other := range tasks {
+		if other.Parent == t.Parent && other.Position < t.Position && (previous == nil || other.Position > previous.Position) {
+			previous = &tasks[i]
+		}
+	}
+	if previous == nil {
+		return ""
+	}
+	return previous.ID
+}
+
+// restoreFields are the fields a create needs to bring t back.
+func restoreFields(t api.Task) map[string]any {
+	fields := map[string]any{"title": t.Title, "notes": t.Notes, "status": cmp.Or(t.Status, "needsAction")}
+	if t.Due != "" {
+		fields["due"] = t.Due
+	}
+	if t.Completed != "" {
+		fields["completed"] = t.Completed
+	}
+	return fields
+}
+
+// restoreSteps creates t again with its subtasks, parents before their
+// children and siblings in order, where they were in tasks.
+func restoreSteps(list string, tasks []api.Task, t api.Task) []undoStep {
+	steps := []undoStep{{
+		change:   api.Change{Op: "create", List: list, Parent: t.Parent, Previous: previousSibling(tasks, t), Fields: restoreFields(t)},
+		restores: t.ID,
+	}}
+
+	var children []api.Task
+	for _, child := range tasks {
+		if child.Parent == t.ID {
+			children = append(children, child)
+		}
+	}
+	slices.SortFunc(children, func(a, b api.Task) int { return cmp.Compare(a.Position, b.Position) })
+	for _, child := range children {
+		steps = append(steps, restoreSteps(list, tasks, child)...)
+	}
+	return steps
+}
+
+// writeUndoable sends w for r like TasksClient.Write and returns the steps
+// that revert it. The task's state before the write is read first
+// (revalidated past the cache); when it cannot be, nothing is sent. What
+// the write changed is added to the task's history.
+func (s *Server) writeUndoable(r *http.Request, token string, w api.TaskWrite) (*api.Task, []undoStep, error) {
+	ctx := r.Context()
+	var steps []undoStep
+	var before *api.Task
+	switch {
+	case w.Method == "PATCH":
+		task, err := s.tasks.GetTask(withRevalidation(ctx), token, w.List, w.Task)
+		if err != nil {
+			return nil, nil, err
+		}
+		before = &task
+		var previous map[string]any
+		if err := remarshal(before, &previous); err != nil {
+			return nil, nil, err
+		}
+		fields, _ := w.Body.(map[string]any)
+		restore := make(map[string]any, len(fields))
+		for field := range fields {
+			restore[field] = previous[field]
+		}
+		// Reopening a task needs its completion time back too, and vice versa
+		if _, ok := fields["status"]; ok {
+			restore["completed"] = previous["completed"]
+		}
+		steps = []undoStep{{change: api.Change{Op: "update", List: w.List, Task: w.Task, Fields: restore}}}
+	case w.Task != "":
+		// A delete or a move, which need the task's place among its siblings
+		tasks, err := s.tasks.ListTasks(withRevalidation(ctx), token, w.List, watchQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+		i := slices.IndexFunc(tasks, func(t api.Task) bool { return t.ID == w.Task })
+		if i < 0 {
+			return nil, nil, &api.APIError{Status: http.StatusNotFound, Code: api.CodeNotFound, Message: "Task not found"}
+		}
+		before = &tasks[i]
+		if w.Method == "DELETE" {
+			steps = restoreSteps(w.List, tasks, tasks[i])
+		} else {
+			steps = []undoStep{{change: api.Change{Op: "move", List: w.List, Task: w.Task, Parent: tasks[i].Parent, Previous: previousSibling(tasks, tasks[i])}}}
+		}
+	}
+
+	task, err := s.tasks.Write(ctx, token, w)
+	if err != nil {
+		return nil, nil, err
+	}
+	if w.Task == "" && task != nil {
+		steps = []undoStep{{change: api.Change{Op: "delete", List: w.List, Task: task.ID}}}
+	}
+	id := w.Task
+	if id == "" && task != nil {
+		id = task.ID
+	}
+	if id != "" {
+		s.recordHistory(r, token, map[string][]api.HistoryEntry{id: writeChanges(w, before, task)})
+	}
+	return task, steps, nil
+}
+
+// recordUndo adds a request's reverting steps to its session's history.
+// Each write's steps are given in the order the writes were sent, and are
+// undone newest first.
+func (s *Server) recordUndo(r *http.Request, token, summary string, steps ...[]undoStep) {
+	account, err := s.accountOf(r.Context(), token)
+	if err != nil {
+		slog.Warn("recording undo history failed", "error", err)
+		return
+	}
+	var all []undoStep
+	for _, write := range slices.Backward(steps) {
+		all = append(all, write...)
+	}
+	s.undo.Record(undoKey(r, account), summary, all, s.config().UndoDepth)
+}
+
+// changesSummary describes a request that sent n changes, for its entry.
+func changesSummary(kind string, n int) string {
+	if n == 1 {
+		return kind + " of 1 change"
+	}
+	return fmt.Sprintf("%s of %d changes", kind, n)
+}
+
+// GET /api/undo - The session's undoable mutations, newest first
+func (s *Server) handleUndoHistory(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	_, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	resp := api.UndoHistoryResponse{Entries: []api.UndoEntry{}}
+	for _, entry := range s.undo.Entries(undoKey(r, account)) {
+		resp.Entries = append(resp.Entries, entry.api())
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// POST /api/undo - Revert the session's latest mutation
+//
+// Mutations made through /api/batch, /api/reconcile, /api/quickadd and
+// snoozes are recorded with the changes that revert them. The latest is
+// reverted and forgotten; reverting cannot itself be undone. A change that
+// fails has Error set in its result and the rest are still applied; the
+// failed changes are kept as the latest entry, so undoing again retries
+// them. With ?dry_run=1 the entry is reported and kept.
+func (s *Server) handleUndo(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	token, account, ok := s.verifiedToken(w, r)
+	if !ok {
+		return
+	}
+	key := undoKey(r, account)
+	// The entry is taken out while it is undone, so a concurrent undo
+	// cannot apply it twice
+	entry, remaining, found := s.undo.Pop(key, dryRun(r))
+	if !found {
+		writeError(w, http.StatusNotFound, api.CodeNotFound, "Nothing to undo")
+		return
+	}
+
+	resp := api.UndoResponse{DryRun: dryRun(r), Undone: entry.api(), Results: []api.ChangeResult{}, Remaining: remaining}
+	restored := make(map[string]string)
+	var failed []undoStep
+	// keep puts back what was not undone and renames the restored tasks
+	keep := func(steps []undoStep) {
+		if len(steps) > 0 {
+			entry.steps = steps
+			s.undo.Restore(key, entry, s.config().UndoDepth)
+			resp.Remaining++
+		}
+		s.undo.Rename(key, restored)
+	}
+	for i, step := range entry.steps {
+		change := step.change
+		for _, id := range []*string{&change.Task, &change.Parent, &change.Previous} {
+			*id = cmp.Or(restored[*id], *id)
+		}
+		write, err := changeWrite(change)
+		if err != nil {
+			if !resp.DryRun {
+				keep(append(failed, entry.steps[i:]...))
+			}
+			writeError(w, http.StatusInternalServerError, api.CodeInternal, fmt.Sprintf("Invalid undo step: %v", err))
+			return
+		}
+		result := api.ChangeResult{Request: write}
+		if !resp.DryRun {
+			task, _, err := s.writeUndoable(r, token, write)
+			if err != nil {
+				result.Error = asAPIError(err, "Google Tasks request failed")
+				failed = append(failed, step)
+			}
+			result.Task = task
+			if task != nil && step.restores != "" {
+				restored[step.restores] = task.ID
+			}
+		}
+		resp.Results = append(resp.Results, result)
+	}
+	if !resp.DryRun {
+		keep(failed)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
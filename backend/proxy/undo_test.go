@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const mockToken = "mock-access-test"
+
+// newMockServer returns a single-user proxy backed by the mock provider.
+func newMockServer(t *testing.T) *Server {
+	t.Helper()
+	// Keep the history, shares and snoozes it writes out of the real data directory
+	t.Setenv("XDG_DATA_HOME", t.TempDir())
+	cfg := DefaultConfig()
+	cfg.Google.ClientID = "id"
+	cfg.Google.ClientSecret = "secret"
+	cfg.Provider = "mock"
+	s, err := NewServer(cfg, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// undoFor records steps as one entry of mockToken's history.
+func undoFor(t *testing.T, s *Server, summary string, steps ...undoStep) {
+	t.Helper()
+	account, err := s.accountOf(t.Context(), mockToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.undo.Record("account:"+account, summary, steps, s.config().UndoDepth)
+}
+
+func postUndo(t *testing.T, s *Server) api.UndoResponse {
+	t.Helper()
+	r := httptest.NewRequest("POST", "/api/undo", nil)
+	r.Header.Set("Authorization", "Bearer "+mockToken)
+	w := httptest.NewRecorder()
+	s.routes().ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST /api/undo: %d %s", w.Code, w.Body)
+	}
+	var resp api.UndoResponse
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp
+}
+
+func renameStep(list, task, title string) undoStep {
+	return undoStep{change: api.Change{Op: "update", List: list, Task: task, Fields: map[string]any{"title": title}}}
+}
+
+func TestUndoForgetsEntryAfterSuccess(t *testing.T) {
+	s := newMockServer(t)
+	list := s.mock.lists[0]
+	task := list.tasks[0]
+	undoFor(t, s, "older", renameStep(list.ID, task.ID, "Older"))
+	undoFor(t, s, "newer", renameStep(list.ID, task.ID, "Newer"))
+
+	resp := postUndo(t, s)
+
+	if resp.Undone.Summary != "newer" || resp.Remaining != 1 || resp.Results[0].Error != nil {
+		t.Errorf("undo = %+v", resp)
+	}
+	if task.Title != "Newer" {
+		t.Errorf("title = %q", task.Title)
+	}
+	if resp := postUndo(t, s); resp.Undone.Summary != "older" || resp.Remaining != 0 {
+		t.Errorf("second undo = %+v", resp)
+	}
+}
+
+func TestUndoKeepsFailedChanges(t *testing.T) {
+	s := newMockServer(t)
+	list := s.mock.lists[0]
+	task := list.tasks[0]
+	undoFor(t, s, "batch of 2 changes", renameStep(list.ID, "gone", "Lost"), renameStep(list.ID, task.ID, "Renamed back"))
+
+	resp := postUndo(t, s)
+
+	// The change that could be made is, and the one that failed stays
+	if resp.Results[0].Error == nil || resp.Results[1].Error != nil || task.Title != "Renamed back" {
+		t.Fatalf("undo = %+v", resp)
+	}
+	if resp.Remaining != 1 {
+		t.Errorf("remaining = %d, want the failed change", resp.Remaining)
+	}
+	account, _ := s.accountOf(t.Context(), mockToken)
+	entries := s.undo.Entries("account:" + account)
+	if len(entries) != 1 || len(entries[0].steps) != 1 || entries[0].steps[0].change.Task != "gone" {
+		t.Errorf("history = %+v", entries)
+	}
+}
+
+func TestUndoStoreRestore(t *testing.T) {
+	store := NewUndoStore()
+	step := renameStep("L", "a", "A")
+	store.Record("k", "first", []undoStep{step}, 3)
+	store.Record("k", "second", []undoStep{step}, 3)
+
+	taken, _, _ := store.Pop("k", false)
+	store.Record("k", "third", []undoStep{step}, 3)
+	// Put back among the others by age, not on top of what came after it
+	store.Restore("k", taken, 3)
+
+	var got []string
+	for _, entry := range store.Entries("k") {
+		got = append(got, entry.summary)
+	}
+	if want := []string{"third", "second", "first"}; !slices.Equal(got, want) {
+		t.Errorf("entries = %v, want %v", got, want)
+	}
+
+	// The history keeps its depth, dropping the oldest
+	taken, _, _ = store.Pop("k", false)
+	store.Record("k", "fourth", []undoStep{step}, 2)
+	store.Restore("k", taken, 2)
+	if entries := store.Entries("k"); len(entries) != 2 || entries[0].summary != "fourth" || entries[1].summary != "third" {
+		t.Errorf("entries past the depth = %+v", entries)
+	}
+}
@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+// version is the running release, set at build time with
+// -ldflags "-X github.com/p-tupe/gtask.nvim/backend/proxy.version=v1.2.3".
+var version = "dev"
+
+const releasesURL = "https://api.github.com/repos/p-tupe/gtask.nvim/releases/latest"
+
+type Release struct {
+	TagName string         `json:"tag_name"`
+	HTMLURL string         `json:"html_url"`
+	Assets  []ReleaseAsset `json:"assets"`
+}
+
+type ReleaseAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// updateChecker caches the latest release so repeated checks stay well under
+// GitHub's unauthenticated rate limit.
+type updateChecker struct {
+	mutex     sync.Mutex
+	release   *Release
+	checkedAt time.Time
+}
+
+const updateCheckTTL = time.Hour
+
+func (c *updateChecker) latest(ctx context.Context, upstream *UpstreamClient) (*Release, time.Time, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.release != nil && time.Since(c.checkedAt) < updateCheckTTL {
+		return c.release, c.checkedAt, nil
+	}
+
+	release, err := fetchLatestRelease(ctx, upstream)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	c.release = release
+	c.checkedAt = time.Now()
+	return c.release, c.checkedAt, nil
+}
+
+func fetchLatestRelease(ctx context.Context, upstream *UpstreamClient) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", releasesURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+
+	resp, err := upstream.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GitHub releases returned %s", resp.Status)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// newerVersion reports whether latest is a higher vMAJOR.MINOR.PATCH than
+// current. Development builds are always considered out of date.
+func newerVersion(current, latest string) bool {
+	if current == "dev" {
+		return true
+	}
+
+	cur, okCur := parseVersion(current)
+	lat, okLat := parseVersion(latest)
+	if !okCur || !okLat {
+		return current != latest
+	}
+	for i := range cur {
+		if lat[i] != cur[i] {
+			return lat[i] > cur[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(v string) ([3]int, bool) {
+	var parts [3]int
+	v = strings.TrimPrefix(v, "v")
+	v, _, _ = strings.Cut(v, "-") // ignore pre-release suffix
+	fields := strings.Split(v, ".")
+	if len(fields) == 0 || len(fields) > 3 {
+		return parts, false
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil {
+			return parts, false
+		}
+		parts[i] = n
+	}
+	return parts, true
+}
+
+// GET /admin/update-check - Compare the running version with the latest release
+func (s *Server) handleUpdateCheck(w http.ResponseWriter, r *http.Request) {
+	if !s.authorizeAdmin(w, r) {
+		return
+	}
+
+	release, checkedAt, err := s.updates.latest(r.Context(), s.upstream)
+	if err != nil {
+		log.Printf("Update check failed: %v", err)
+		writeUpstreamError(w, err, "Update check failed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(api.UpdateCheckResponse{
+		Current:         version,
+		Latest:          release.TagName,
+		UpdateAvailable: newerVersion(version, release.TagName),
+		URL:             release.HTMLURL,
+		CheckedAt:       checkedAt.Format(time.RFC3339),
+	})
+}
+
+// selfUpdate downloads the release binary for this platform and atomically
+// replaces the running executable. The new binary must match the release's
+// checksums.txt; a release without one is not installed.
+func selfUpdate(ctx context.Context, upstream *UpstreamClient) error {
+	release, err := fetchLatestRelease(ctx, upstream)
+	if err != nil {
+		return err
+	}
+	if !newerVersion(version, release.TagName) {
+		fmt.Printf("Already up to date (%s)\n", version)
+		return nil
+	}
+
+	assetName := fmt.Sprintf("gtask-auth-proxy-%s-%s", runtime.GOOS, runtime.GOARCH)
+	if runtime.GOOS == "windows" {
+		assetName += ".exe"
+	}
+	var binaryURL, checksumsURL string
+	for _, asset := range release.Assets {
+		switch asset.Name {
+		case assetName:
+			binaryURL = asset.BrowserDownloadURL
+		case "checksums.txt":
+			checksumsURL = asset.BrowserDownloadURL
+		}
+	}
+	if binaryURL == "" {
+		return fmt.Errorf("release %s has no %s asset", release.TagName, assetName)
+	}
+	if checksumsURL == "" {
+		return fmt.Errorf("release %s has no checksums.txt to verify %s against; not installing it", release.TagName, assetName)
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	executable, err = filepath.EvalSymlinks(executable)
+	if err != nil {
+		return err
+	}
+
+	// Download next to the executable so the final rename stays on one filesystem
+	tmp, err := os.CreateTemp(filepath.Dir(executable), ".gtask-update-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	fmt.Printf("Downloading %s %s...\n", assetName, release.TagName)
+	hash := sha256.New()
+	if err := download(ctx, upstream, binaryURL, io.MultiWriter(tmp, hash)); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	want, err := releaseChecksum(ctx, upstream, checksumsURL, assetName)
+	if err != nil {
+		return err
+	}
+	if got := hex.EncodeToString(hash.Sum(nil)); got != want {
+		return fmt.Errorf("checksum mismatch for %s: got %s, want %s", assetName, got, want)
+	}
+
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), executable); err != nil {
+		return err
+	}
+
+	fmt.Printf("Updated %s from %s to %s. Restart the server to use it.\n", executable, version, release.TagName)
+	return nil
+}
+
+func download(ctx context.Context, upstream *UpstreamClient, url string, dst io.Writer) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	// Binaries can take longer than the API timeout, so reuse the pooled
+	// transport with a download-sized deadline.
+	client := &http.Client{Transport: upstream.client.Transport, Timeout: 5 * time.Minute}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("download %s: %s", url, resp.Status)
+	}
+	_, err = io.Copy(dst, resp.Body)
+	return err
+}
+
+// releaseChecksum finds name in a sha256sum-style checksums file.
+func releaseChecksum(ctx context.Context, upstream *UpstreamClient, url, name string) (string, error) {
+	var buf strings.Builder
+	if err := download(ctx, upstream, url, &buf); err != nil {
+		return "", err
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(buf.String()))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return fields[0], nil
+		}
+	}
+	return "", errors.New("no checksum published for " + name)
+}
@@ -0,0 +1,277 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	googleTokenURL  = "https://oauth2.googleapis.com/token"
+	googleRevokeURL = "https://oauth2.googleapis.com/revoke"
+)
+
+// UpstreamClient is the single outbound HTTP client for calls to Google. It
+// pools connections, bounds every attempt with a timeout and retries network
+// errors and 5xx responses with exponential backoff and full jitter: those of
+// requests that are safe to repeat, and of others only when they were never
+// sent, so an insert or a one-time code exchange is not repeated. A
+// per-host circuit breaker fails fast while Google is unreachable, and
+// rate-limited responses (429, or 403 rateLimitExceeded) back off their
+// quota: briefly by waiting, longer by failing fast with RateLimitedError.
+type UpstreamClient struct {
+	client     *http.Client
+	maxRetries int
+	baseDelay  time.Duration
+	maxDelay   time.Duration // also the longest quota backoff waited out
+	quota      *quotaBackoff
+	usage      *usageCounter
+
+	breakersMutex sync.Mutex
+	breakers      map[string]*CircuitBreaker
+}
+
+func NewUpstreamClient() *UpstreamClient {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   5 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		ForceAttemptHTTP2:     true,
+	}
+
+	return &UpstreamClient{
+		client: &http.Client{
+			Transport: transport,
+			Timeout:   10 * time.Second,
+		},
+		maxRetries: 3,
+		baseDelay:  200 * time.Millisecond,
+		maxDelay:   5 * time.Second,
+		quota:      newQuotaBackoff(),
+		usage:      newUsageCounter(),
+		breakers:   make(map[string]*CircuitBreaker),
+	}
+}
+
+func (c *UpstreamClient) breaker(host string) *CircuitBreaker {
+	c.breakersMutex.Lock()
+	defer c.breakersMutex.Unlock()
+
+	b, ok := c.breakers[host]
+	if !ok {
+		b = NewCircuitBreaker(5, 30*time.Second)
+		c.breakers[host] = b
+	}
+	return b
+}
+
+// Do sends req, retrying transient failures. Requests with a body must be
+// replayable (req.GetBody set), which http.NewRequest does for common body
+// types.
+func (c *UpstreamClient) Do(req *http.Request) (*http.Response, error) {
+	// A request whose caller gave up is neither sent nor counted
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	// Before the breaker, so a request that never gets past its quota does
+	// not take the half-open probe and leave the breaker waiting for it
+	keys := quotaKeys(req.URL.Host, req.Header.Get("Authorization"))
+	if err := c.waitQuota(req.Context(), req.URL.Host, keys); err != nil {
+		return nil, err
+	}
+
+	breaker := c.breaker(req.URL.Host)
+	if ok, retryIn := breaker.Allow(); !ok {
+		return nil, &UnavailableError{Host: req.URL.Host, RetryIn: retryIn}
+	}
+
+	resp, err := c.doWithRetry(req, keys)
+	if err != nil && req.Context().Err() != nil {
+		breaker.Abandon()
+	} else {
+		breaker.Record(!shouldRetry(resp, err))
+	}
+	return resp, err
+}
+
+func (c *UpstreamClient) doWithRetry(req *http.Request, keys []string) (*http.Response, error) {
+	ctx := req.Context()
+	safe := replayable(req)
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.Body != nil {
+			if req.GetBody == nil {
+				return nil, errors.New("upstream: request body cannot be replayed")
+			}
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+
+		resp, err := c.client.Do(req)
+		var wait time.Duration
+		limited := false
+		if err == nil {
+			wait, limited = c.checkQuota(req.URL.Host, resp, keys)
+		}
+		c.usage.record(req, resp, err, limited)
+
+		delay, retry := c.backoff(attempt), shouldRetry(resp, err) && (safe || notSent(err))
+		if limited {
+			// Wait out a short backoff; a longer one is the caller's.
+			// Google did not act on a rate-limited request, whatever it was
+			delay, retry = wait, wait <= c.maxDelay
+		}
+		if !retry || attempt >= c.maxRetries || ctx.Err() != nil {
+			return resp, err
+		}
+
+		// Drain so the connection can be reused
+		if resp != nil {
+			io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// waitQuota waits out a short backoff of the quotas keys and fails fast
+// with a RateLimitedError during a longer one.
+func (c *UpstreamClient) waitQuota(ctx context.Context, host string, keys []string) error {
+	wait := c.quota.wait(keys)
+	if wait <= 0 {
+		return nil
+	}
+	if wait > c.maxDelay {
+		return &RateLimitedError{Host: host, RetryIn: wait}
+	}
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(wait):
+		return nil
+	}
+}
+
+// checkQuota reports whether resp says a quota is exhausted, backs the quota
+// off and returns how long to wait. A rate-limited response without a
+// Retry-After is given one with that wait, so callers see the same delay.
+func (c *UpstreamClient) checkQuota(host string, resp *http.Response, keys []string) (time.Duration, bool) {
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode != http.StatusForbidden {
+		c.quota.ok(keys)
+		return 0, false
+	}
+	// The body says why; keep it readable for the caller
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	reason, limited := rateLimitReason(resp.StatusCode, body)
+	if !limited {
+		c.quota.ok(keys)
+		return 0, false
+	}
+	key := keys[0]
+	if reason == "userRateLimitExceeded" && len(keys) > 1 {
+		key = keys[1]
+	}
+	retryAfter, given := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	wait := c.quota.limited(key, retryAfter, given)
+	if !given {
+		resp.Header.Set("Retry-After", strconv.Itoa(retryAfterSeconds(wait)))
+	}
+	slog.Warn("Google rate limited a request", "host", host, "status", resp.StatusCode, "reason", reason, "retry_in", wait)
+	return wait, true
+}
+
+// PostForm is the retrying equivalent of http.PostForm.
+func (c *UpstreamClient) PostForm(ctx context.Context, url string, data url.Values) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.Do(req)
+}
+
+// backoff returns a random delay in [0, min(maxDelay, baseDelay*2^attempt)).
+func (c *UpstreamClient) backoff(attempt int) time.Duration {
+	ceiling := c.baseDelay << attempt
+	if ceiling <= 0 || ceiling > c.maxDelay {
+		ceiling = c.maxDelay
+	}
+	return rand.N(ceiling)
+}
+
+func shouldRetry(resp *http.Response, err error) bool {
+	// Network errors, including per-attempt timeouts, are transient. Do
+	// stops retrying once the caller's own context is done.
+	if err != nil {
+		return true
+	}
+	return resp.StatusCode >= 500
+}
+
+// idempotent reports whether sending a request with method twice has the
+// same effect as sending it once.
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "OPTIONS", "PUT", "DELETE":
+		return true
+	}
+	return false
+}
+
+// replayable reports whether req may be sent again after Google may have
+// acted on it: idempotent methods, and refresh token grants, which only mint
+// another access token.
+func replayable(req *http.Request) bool {
+	if idempotent(req.Method) {
+		return true
+	}
+	if req.GetBody == nil || req.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+		return false
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return false
+	}
+	defer body.Close()
+	data, err := io.ReadAll(io.LimitReader(body, 64<<10))
+	if err != nil {
+		return false
+	}
+	form, err := url.ParseQuery(string(data))
+	return err == nil && form.Get("grant_type") == "refresh_token"
+}
+
+// notSent reports whether err means the request never reached Google: no
+// connection could be opened for it, so resending cannot repeat it.
+func notSent(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
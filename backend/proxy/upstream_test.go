@@ -0,0 +1,153 @@
+package proxy
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// failingServer answers every request with status and counts them.
+func failingServer(t *testing.T, status int) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &calls
+}
+
+func testUpstream() *UpstreamClient {
+	c := NewUpstreamClient()
+	c.baseDelay = time.Millisecond
+	c.maxDelay = time.Millisecond
+	return c
+}
+
+func TestUpstreamRetries(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("GET is retried on 5xx", func(t *testing.T) {
+		srv, calls := failingServer(t, http.StatusBadGateway)
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := testUpstream().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := calls.Load(); got != 4 {
+			t.Errorf("sent %d times, want 1 + 3 retries", got)
+		}
+	})
+
+	t.Run("an insert is sent once", func(t *testing.T) {
+		srv, calls := failingServer(t, http.StatusInternalServerError)
+		req, _ := http.NewRequestWithContext(ctx, "POST", srv.URL, strings.NewReader(`{"title":"x"}`))
+		resp, err := testUpstream().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := calls.Load(); got != 1 {
+			t.Errorf("sent %d times, want 1", got)
+		}
+	})
+
+	t.Run("a code exchange is sent once", func(t *testing.T) {
+		srv, calls := failingServer(t, http.StatusServiceUnavailable)
+		resp, err := testUpstream().PostForm(ctx, srv.URL, url.Values{"grant_type": {"authorization_code"}, "code": {"c"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := calls.Load(); got != 1 {
+			t.Errorf("sent %d times, want 1", got)
+		}
+	})
+
+	t.Run("a refresh grant is retried", func(t *testing.T) {
+		srv, calls := failingServer(t, http.StatusServiceUnavailable)
+		resp, err := testUpstream().PostForm(ctx, srv.URL, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {"r"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := calls.Load(); got != 4 {
+			t.Errorf("sent %d times, want 1 + 3 retries", got)
+		}
+	})
+
+	t.Run("4xx is not retried", func(t *testing.T) {
+		srv, calls := failingServer(t, http.StatusNotFound)
+		req, _ := http.NewRequestWithContext(ctx, "GET", srv.URL, nil)
+		resp, err := testUpstream().Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := calls.Load(); got != 1 {
+			t.Errorf("sent %d times, want 1", got)
+		}
+	})
+}
+
+func TestUpstreamRetriesUnsentPost(t *testing.T) {
+	// Nothing listens on a closed server's address, so no attempt reaches it
+	srv, _ := failingServer(t, http.StatusOK)
+	srv.Close()
+
+	c := testUpstream()
+	var dials atomic.Int32
+	transport := c.client.Transport.(*http.Transport)
+	dial := transport.DialContext
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return dial(ctx, network, addr)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL, strings.NewReader(`{"title":"x"}`))
+	if _, err := c.Do(req); err == nil {
+		t.Fatal("expected a connection error")
+	}
+	if got := dials.Load(); got != 4 {
+		t.Errorf("dialled %d times, want 1 + 3 retries", got)
+	}
+}
+
+func TestUpstreamQuotaKeepsBreakerProbe(t *testing.T) {
+	srv, calls := failingServer(t, http.StatusOK)
+	c := testUpstream()
+	host := strings.TrimPrefix(srv.URL, "http://")
+	b := c.breaker(host)
+	for range 5 {
+		b.Record(false)
+	}
+	b.expire()
+	c.quota.limited(host, time.Minute, true)
+
+	req, _ := http.NewRequest("GET", srv.URL, nil)
+	var limited *RateLimitedError
+	if _, err := c.Do(req); !errors.As(err, &limited) {
+		t.Fatalf("err = %v, want a RateLimitedError", err)
+	}
+
+	// The half-open probe is still there for the first request past the quota
+	c.quota = newQuotaBackoff()
+	req, _ = http.NewRequest("GET", srv.URL, nil)
+	resp, err := c.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls.Load() != 1 || b.state != breakerClosed {
+		t.Errorf("sent %d times, breaker state %d; want the probe sent and the breaker closed", calls.Load(), b.state)
+	}
+}
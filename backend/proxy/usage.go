@@ -0,0 +1,204 @@
+package proxy
+
+import (
+	"cmp"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/p-tupe/gtask.nvim/backend/api"
+)
+
+const (
+	usageHours = 48 // hourly counts kept
+	usageDays  = 30 // daily counts kept
+)
+
+// quotaZone is where Google's daily quotas reset at midnight.
+var quotaZone = func() *time.Location {
+	if loc, err := time.LoadLocation("America/Los_Angeles"); err == nil {
+		return loc
+	}
+	return time.FixedZone("PST", -8*60*60)
+}()
+
+// usageIDParents are the collections whose next path segment is an ID.
+var usageIDParents = map[string]bool{"lists": true, "tasks": true, "messages": true, "calendars": true, "events": true}
+
+// apiVersion matches the version segment of a Google API path (/tasks/v1).
+var apiVersion = regexp.MustCompile(`^v[0-9]+$`)
+
+// usageEndpoint names the endpoint u is a call to, with IDs replaced so
+// calls for different lists and tasks count together.
+func usageEndpoint(method string, u *url.URL) string {
+	segments := strings.Split(strings.Trim(u.EscapedPath(), "/"), "/")
+	for i := 1; i < len(segments); i++ {
+		id := !usageIDParents[segments[i]] && !apiVersion.MatchString(segments[i]) && segments[i] != "@me"
+		if id && usageIDParents[segments[i-1]] {
+			segments[i] = "{id}"
+		}
+	}
+	return method + " " + u.Host + "/" + strings.Join(segments, "/")
+}
+
+// usageCount is the calls to one endpoint in one period.
+type usageCount struct {
+	calls, errors, rateLimited int
+}
+
+func (c *usageCount) add(o usageCount) {
+	c.calls += o.calls
+	c.errors += o.errors
+	c.rateLimited += o.rateLimited
+}
+
+// usageCounter counts every call to Google, by endpoint, hour and quota day.
+// The counts are kept in memory and start over with the proxy.
+type usageCounter struct {
+	mutex   sync.Mutex
+	started time.Time
+	total   map[string]*usageCount
+	hours   map[time.Time]map[string]*usageCount
+	days    map[string]map[string]*usageCount
+}
+
+func newUsageCounter() *usageCounter {
+	return &usageCounter{
+		started: time.Now(),
+		total:   make(map[string]*usageCount),
+		hours:   make(map[time.Time]map[string]*usageCount),
+		days:    make(map[string]map[string]*usageCount),
+	}
+}
+
+// record counts one attempt at req, which failed with err or got resp.
+func (u *usageCounter) record(req *http.Request, resp *http.Response, err error, rateLimited bool) {
+	count := usageCount{calls: 1}
+	if err != nil || resp.StatusCode >= 400 {
+		count.errors = 1
+	}
+	if rateLimited {
+		count.rateLimited = 1
+	}
+	endpoint := usageEndpoint(req.Method, req.URL)
+	now := time.Now()
+	hour := now.UTC().Truncate(time.Hour)
+	day := now.In(quotaZone).Format(time.DateOnly)
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	bucket := func(counts map[string]*usageCount) {
+		c, ok := counts[endpoint]
+		if !ok {
+			c = &usageCount{}
+			counts[endpoint] = c
+		}
+		c.add(count)
+	}
+	bucket(u.total)
+	if u.hours[hour] == nil {
+		u.hours[hour] = make(map[string]*usageCount)
+		for start := range u.hours {
+			if start.Before(hour.Add(-usageHours * time.Hour)) {
+				delete(u.hours, start)
+			}
+		}
+	}
+	bucket(u.hours[hour])
+	if u.days[day] == nil {
+		u.days[day] = make(map[string]*usageCount)
+		oldest := now.In(quotaZone).AddDate(0, 0, -usageDays).Format(time.DateOnly)
+		for start := range u.days {
+			if start <= oldest {
+				delete(u.days, start)
+			}
+		}
+	}
+	bucket(u.days[day])
+}
+
+// report returns the counts, with the Tasks API calls of the current quota
+// day measured against dailyQuota.
+func (u *usageCounter) report(now time.Time, dailyQuota int) api.UsageResponse {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	resp := api.UsageResponse{
+		Since:      u.started.UTC().Format(time.RFC3339),
+		QuotaDay:   now.In(quotaZone).Format(time.DateOnly),
+		DailyQuota: dailyQuota,
+		Hours:      []api.UsagePeriod{},
+		Days:       []api.UsagePeriod{},
+		Endpoints:  usageEndpoints(u.total),
+	}
+	tasks, _ := url.Parse(googleTasksBaseURL)
+	for endpoint, count := range u.days[resp.QuotaDay] {
+		if strings.Contains(endpoint, " "+tasks.Host+"/") {
+			resp.TasksToday += count.calls
+		}
+	}
+	resp.Remaining = max(dailyQuota-resp.TasksToday, 0)
+
+	oldestHour := now.UTC().Truncate(time.Hour).Add(-usageHours * time.Hour)
+	for start, counts := range u.hours {
+		if start.After(oldestHour) {
+			resp.Hours = append(resp.Hours, usagePeriod(start.Format(time.RFC3339), counts))
+		}
+	}
+	for start, counts := range u.days {
+		resp.Days = append(resp.Days, usagePeriod(start, counts))
+	}
+	// Both formats sort by time as strings
+	for _, periods := range [][]api.UsagePeriod{resp.Hours, resp.Days} {
+		slices.SortFunc(periods, func(a, b api.UsagePeriod) int { return strings.Compare(b.Start, a.Start) })
+	}
+	return resp
+}
+
+func usagePeriod(start string, counts map[string]*usageCount) api.UsagePeriod {
+	period := api.UsagePeriod{Start: start, Endpoints: usageEndpoints(counts)}
+	for _, count := range counts {
+		period.Calls += count.calls
+	}
+	return period
+}
+
+// usageEndpoints lists counts, most called first.
+func usageEndpoints(counts map[string]*usageCount) []api.UsageEndpoint {
+	endpoints := make([]api.UsageEndpoint, 0, len(counts))
+	for endpoint, count := range counts {
+		endpoints = append(endpoints, api.UsageEndpoint{
+			Endpoint:    endpoint,
+			Calls:       count.calls,
+			Errors:      count.errors,
+			RateLimited: count.rateLimited,
+		})
+	}
+	slices.SortFunc(endpoints, func(a, b api.UsageEndpoint) int {
+		return cmp.Or(b.Calls-a.Calls, strings.Compare(a.Endpoint, b.Endpoint))
+	})
+	return endpoints
+}
+
+// GET /api/usage - Calls made to Google by endpoint, hour and day
+//
+// Every call the proxy makes counts, for every user and including retries
+// and background polling, since Google's quota is the OAuth client's; only
+// Tasks API calls count toward tasks_today. The counts start over when the
+// proxy restarts.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	s.enableCORS(w)
+
+	if _, _, ok := s.verifiedToken(w, r); !ok {
+		return
+	}
+	resp := s.upstream.usage.report(time.Now(), s.config().Upstream.DailyQuota)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
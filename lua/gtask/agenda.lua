@@ -0,0 +1,94 @@
+---@class GtaskAgenda
+---Agenda dashboard: overdue, due today, due this week and recently completed tasks
+local M = {}
+
+local api = require("gtask.api")
+local utils = require("gtask.utils")
+
+--- Render an agenda into lines for the dashboard buffer
+---@param agenda table Agenda from the proxy's /api/agenda
+---@return string[] lines
+function M.render(agenda)
+	local lines = { "# Agenda for " .. agenda.date }
+
+	local sections = {
+		{ title = "Overdue", items = agenda.overdue, show_due = true },
+		{ title = "Due today", items = agenda.due_today, show_due = false },
+		{ title = "Due this week", items = agenda.due_this_week, show_due = true },
+		{ title = "Recently completed", items = agenda.recently_completed, show_due = false },
+	}
+
+	local empty = true
+	for _, section in ipairs(sections) do
+		local items = section.items or {}
+		if #items > 0 then
+			empty = false
+			table.insert(lines, "")
+			table.insert(lines, string.format("## %s (%d)", section.title, #items))
+			table.insert(lines, "")
+			for _, item in ipairs(items) do
+				local task = item.task or {}
+				local check = task.status == "completed" and "x" or " "
+				local line = string.format("- [%s] %s _%s_", check, task.title or "", (item.list or {}).title or "")
+				if section.show_due and task.due then
+					line = line .. " (due " .. task.due:sub(1, 10) .. ")"
+				end
+				table.insert(lines, line)
+			end
+		end
+	end
+
+	if empty then
+		table.insert(lines, "")
+		table.insert(lines, "Nothing due.")
+	end
+
+	for _, list in ipairs(agenda.failed or {}) do
+		table.insert(lines, "")
+		table.insert(lines, string.format("(failed to fetch %s: %s)", list.title or "", (list.error or {}).message or ""))
+	end
+
+	return lines
+end
+
+--- Show the agenda in a scratch buffer
+---@param date string|nil today, tomorrow, a weekday or YYYY-MM-DD (default: today)
+function M.open(date)
+	api.get_agenda(date, function(agenda, err)
+		if err then
+			utils.notify("Failed to load agenda: " .. err, vim.log.levels.ERROR)
+			return
+		end
+
+		local buf = vim.api.nvim_create_buf(false, true)
+		vim.api.nvim_buf_set_lines(buf, 0, -1, false, M.render(agenda))
+		vim.bo[buf].modifiable = false
+		vim.bo[buf].bufhidden = "wipe"
+		vim.bo[buf].filetype = "markdown"
+		vim.cmd("botright split")
+		vim.api.nvim_win_set_buf(0, buf)
+		vim.keymap.set("n", "q", "<cmd>close<cr>", { buffer = buf, nowait = true, desc = "Close the gtask agenda" })
+	end)
+end
+
+--- Load open tasks into the quickfix list and open it
+---@param filter string|nil overdue, today, week or open (default: open)
+function M.quickfix(filter)
+	api.get_quickfix(filter, function(what, err)
+		if err then
+			utils.notify("Failed to load tasks: " .. err, vim.log.levels.ERROR)
+			return
+		end
+
+		vim.fn.setqflist({}, " ", what)
+		vim.cmd("botright copen")
+		for _, list in ipairs(what.failed or {}) do
+			utils.notify(
+				string.format("Failed to fetch %s: %s", list.title or "", (list.error or {}).message or ""),
+				vim.log.levels.WARN
+			)
+		end
+	end)
+end
+
+return M
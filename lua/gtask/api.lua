@@ -6,12 +6,23 @@ local config = require("gtask.config")
 local store = require("gtask.store")
 local utils = require("gtask.utils")
 
+-- Retry a request the proxy reports as rate limited this many times, when it
+-- asks to wait no longer than this many seconds
+local MAX_RATE_LIMIT_RETRIES = 2
+local MAX_RATE_LIMIT_WAIT = 60
+
 --- Get proxy backend URL from config (dynamically to respect setup() changes)
 ---@return string The proxy base URL
 local function get_proxy_url()
 	return config.proxy.base_url
 end
 
+--- Get Google Tasks API base URL from config (overridable to use a mock backend)
+---@return string The Tasks API base URL
+local function get_api_url()
+	return config.api.base_url
+end
+
 --- Refresh OAuth access token using refresh token
 --- Called automatically when API returns 401 unauthorized
 ---@param refresh_token string The refresh token to use
@@ -32,7 +43,7 @@ local function refresh_tokens(refresh_token, callback)
 		refresh_token = refresh_token,
 	})
 
-	vim.system({
+	vim.system(vim.list_extend({
 		"curl",
 		"-s",
 		"-X",
@@ -42,11 +53,27 @@ local function refresh_tokens(refresh_token, callback)
 		"-d",
 		request_body,
 		get_proxy_url() .. "/auth/refresh",
-	}, { text = true }, function(obj)
+	}, config.proxy_curl_args()), { text = true }, function(obj)
 		vim.schedule(function()
 			if obj.code == 0 then
 				local success, new_tokens = pcall(vim.fn.json_decode, obj.stdout)
 
+				-- Proxy errors use the envelope {error = {code, message, retryable, upstream}}
+				if success and type(new_tokens) == "table" and type(new_tokens.error) == "table" then
+					local err = new_tokens.error
+					local error_msg = err.message or "Token refresh failed"
+					if err.code == "upstream_unavailable" then
+						error_msg = "Google unreachable, try again shortly"
+					elseif err.upstream and err.upstream.error then
+						error_msg = error_msg .. " (" .. err.upstream.error .. ")"
+					end
+					utils.notify(error_msg, vim.log.levels.ERROR)
+					if callback then
+						callback(nil, error_msg)
+					end
+					return
+				end
+
 				if not success or not new_tokens or not new_tokens.access_token then
 					utils.notify("Invalid response from token refresh", vim.log.levels.ERROR)
 					if callback then
@@ -77,7 +104,8 @@ local function refresh_tokens(refresh_token, callback)
 end
 
 --- Make an authenticated request to the Google Tasks API
---- Handles token refresh automatically on 401 responses
+--- Handles token refresh automatically on 401 responses, and retries when the
+--- proxy reports Google's quota exhausted for a short while
 ---@param opts table Request options (url, method, body)
 ---@param callback function Callback called with response data or error
 local function request(opts, callback)
@@ -100,6 +128,8 @@ local function request(opts, callback)
 		return
 	end
 
+	local rate_limit_retries = 0
+
 	local function make_request(access_token)
 		if not access_token or access_token == "" then
 			if callback then
@@ -131,6 +161,13 @@ local function request(opts, callback)
 		end
 
 		table.insert(curl_args, opts.url)
+		-- Only the proxy gets the session, never Google
+		if vim.startswith(opts.url, get_proxy_url()) then
+			vim.list_extend(curl_args, config.proxy_curl_args())
+			-- The proxy gives up on Google when curl gives up on it
+			local timeout = tostring(config.get().proxy.timeout)
+			vim.list_extend(curl_args, { "--max-time", timeout, "-H", "X-Timeout: " .. timeout })
+		end
 
 		vim.system(vim.list_extend({ "curl" }, curl_args), { text = true }, function(obj)
 			vim.schedule(function()
@@ -146,7 +183,12 @@ local function request(opts, callback)
 
 					-- Check for API errors
 					if decoded_result and decoded_result.error then
-						if decoded_result.error.code == 401 then
+						-- Google reports {code = 401}; the proxy's /api endpoints pass
+						-- Google's status on as error.upstream.status
+						local upstream = decoded_result.error.upstream
+						local unauthorized = decoded_result.error.code == 401
+							or (type(upstream) == "table" and upstream.status == 401)
+						if unauthorized then
 							-- Token expired, try to refresh
 							if tokens.refresh_token then
 								refresh_tokens(tokens.refresh_token, function(new_tokens)
@@ -160,16 +202,34 @@ local function request(opts, callback)
 								callback(nil, "Unauthorized: No refresh token available")
 							end
 							return
-						else
-							callback(nil, "API Error: " .. (decoded_result.error.message or "Unknown error"))
+						end
+
+						-- The proxy's rate_limited errors say when Google will take requests again
+						local retry_after = tonumber(decoded_result.error.retry_after)
+						if
+							decoded_result.error.code == "rate_limited"
+							and retry_after
+							and retry_after <= MAX_RATE_LIMIT_WAIT
+							and rate_limit_retries < MAX_RATE_LIMIT_RETRIES
+						then
+							rate_limit_retries = rate_limit_retries + 1
+							utils.notify(string.format("Google is rate limiting requests, retrying in %ds", retry_after))
+							vim.defer_fn(function()
+								make_request(access_token)
+							end, retry_after * 1000)
 							return
 						end
+
+						callback(nil, "API Error: " .. (decoded_result.error.message or "Unknown error"))
+						return
 					end
 
 					callback(decoded_result)
 				elseif obj.code == 0 then
 					-- Empty response but successful
 					callback({})
+				elseif obj.code == 28 then
+					callback(nil, "Request timed out")
 				else
 					local error_msg = obj.stderr or ""
 					callback(nil, "Request failed: " .. (error_msg ~= "" and error_msg or result))
@@ -181,6 +241,324 @@ local function request(opts, callback)
 	make_request(tokens.access_token)
 end
 
+--- Get the agenda (overdue, due today, due this week, recently completed) from the proxy
+---@param date string|nil today, tomorrow, a weekday or YYYY-MM-DD (default: today)
+---@param callback function Callback called with the agenda or error
+function M.get_agenda(date, callback)
+	-- The local UTC offset (+0530), so "today" is the user's today
+	local tz = os.date("%z"):gsub("%+", "%%2B")
+	local url = string.format("%s/api/agenda?date=%s&tz=%s", get_proxy_url(), date or "today", tz)
+	request({ url = url }, callback)
+end
+
+--- Query parameters asking the proxy to sort tasks, "" for its default order
+---@param sort table|nil { by = "due"|"alpha"|"position"|"updated", order = "asc"|"desc" }
+---@return string
+local function sort_query(sort)
+	if not sort then
+		return ""
+	end
+	local query = ""
+	if sort.by then
+		query = query .. "&sort=" .. sort.by
+	end
+	if sort.order then
+		query = query .. "&order=" .. sort.order
+	end
+	return query
+end
+
+--- Get one of the proxy's smart lists (open tasks gathered from every list)
+---@param id string today, upcoming, overdue or no-date
+---@param callback function Callback called with { items = { { list, task } } } or error
+---@param sort table|nil { by, order } replacing the default order (due date, list, position)
+function M.get_smart_list(id, callback, sort)
+	local tz = os.date("%z"):gsub("%+", "%%2B")
+	local url = string.format("%s/api/smart/%s?tz=%s", get_proxy_url(), id, tz) .. sort_query(sort)
+	request({ url = url }, callback)
+end
+
+--- Create a task from one line of text on the proxy
+---@param text string e.g. "pay rent tomorrow 9am #finance !p1 @personal"
+---@param callback function Callback called with the parsed task and the created task, or error
+---@param location table|nil { file, line, repo, commit } the task is about, kept by the proxy
+function M.quick_add(text, callback, location)
+	request({
+		url = get_proxy_url() .. "/api/quickadd",
+		method = "POST",
+		body = { text = text, tz = os.date("%z"), location = location },
+	}, callback)
+end
+
+--- Revert the latest change made through the proxy (e.g. by :GtaskAdd)
+---@param callback function Callback called with { undone, results, remaining } or error
+function M.undo(callback)
+	request({ url = get_proxy_url() .. "/api/undo", method = "POST" }, callback)
+end
+
+--- Create a task from a Gmail message through the proxy
+---@param query string Gmail search (e.g. "from:boss is:unread"); its newest match is used
+---@param callback function Callback called with { email = { subject, from, link }, task } or error
+function M.from_email(query, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasks/from-email",
+		method = "POST",
+		body = { query = query },
+	}, callback)
+end
+
+--- Create or update one task per TODO comment through the proxy; comments
+--- imported before are found again, so re-importing adds no duplicates
+---@param list_title string Title of the list for the tasks, created if missing
+---@param repo string Name of the repository scanned
+---@param commit string|nil Commit scanned, kept with each task's location
+---@param todos table[] { { file, line, kind, text, author } }
+---@param callback function Callback called with { list, created, updated, unchanged, results } or error
+function M.import_todos(list_title, repo, commit, todos, callback)
+	request({
+		url = get_proxy_url() .. "/api/import/todos",
+		method = "POST",
+		body = { list_title = list_title, repo = repo, commit = commit, todos = todos },
+	}, callback)
+end
+
+--- Get where in the code a task was created, through the proxy
+---@param task_id string Google task ID
+---@param callback function Callback called with { task, location = { file, line, repo, commit } | nil } or error
+function M.get_location(task_id, callback)
+	request({ url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/location" }, function(result, err)
+		if result and result.location == vim.NIL then
+			result.location = nil
+		end
+		callback(result, err)
+	end)
+end
+
+--- Set the code location of a task through the proxy
+---@param task_id string Google task ID
+---@param location table { file, line, repo, commit }
+---@param callback function Callback called with { task, location } or error
+function M.set_location(task_id, location, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/location",
+		method = "PUT",
+		body = location,
+	}, callback)
+end
+
+--- Get a task's links through the proxy: Google's own and those attached with add_link
+---@param task_id string Google task ID
+---@param callback function Callback called with { google, attached = { { id, type, link, description } } } or error
+function M.get_links(task_id, callback)
+	request({ url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/links" }, callback)
+end
+
+--- Attach a URL or file path to a task through the proxy
+---@param task_id string Google task ID
+---@param link string e.g. a pull request URL or a file path
+---@param description string|nil
+---@param callback function Callback called with the task's links or error
+function M.add_link(task_id, link, description, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/links",
+		method = "POST",
+		body = { link = link, description = description },
+	}, callback)
+end
+
+--- Remove a link attached with add_link
+---@param task_id string Google task ID
+---@param link_id string The attachment's id
+---@param callback function Callback called with the task's remaining links or error
+function M.remove_link(task_id, link_id, callback)
+	request({ url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/links/" .. link_id, method = "DELETE" }, callback)
+end
+
+--- Get a task's recorded changes from the proxy, newest first
+---@param task_id string Google task ID
+---@param callback function Callback called with { entries = { { at, source, change, field, from, to } } } or error
+function M.get_history(task_id, callback)
+	request({ url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/history" }, callback)
+end
+
+--- Clear a list's completed tasks, archiving them on the proxy first
+---@param task_list_id string Google task list ID
+---@param callback function Callback called with { list, archived } or error
+function M.clear_completed(task_list_id, callback)
+	request({ url = get_proxy_url() .. "/api/lists/" .. task_list_id .. "/clear", method = "POST" }, callback)
+end
+
+--- Share a read-only snapshot of a list through a link that needs no Google access
+---@param task_list_id string Google task list ID
+---@param expires string|nil How long the link works, e.g. "3d" or "12h" (default: until revoked)
+---@param callback function Callback called with { id, list, tasks, created_at, expires_at, url, token } or error
+function M.share_list(task_list_id, expires, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasklists/" .. task_list_id .. "/share",
+		method = "POST",
+		-- An empty table would be encoded as a JSON array
+		body = expires and { expires = expires } or nil,
+	}, callback)
+end
+
+--- Get the shared snapshots whose links still work, newest first
+---@param callback function Callback called with { shares = { { id, list, tasks, created_at, expires_at } } } or error
+function M.list_shares(callback)
+	request({ url = get_proxy_url() .. "/api/shares" }, callback)
+end
+
+--- Revoke a shared snapshot, so its link stops working
+---@param share_id string id of the share from list_shares
+---@param callback function Callback called with { revoked } or error
+function M.revoke_share(share_id, callback)
+	request({ url = get_proxy_url() .. "/api/shares/" .. share_id, method = "DELETE" }, callback)
+end
+
+--- Get tasks archived on the proxy when their lists were cleared
+---@param from string|nil YYYY-MM-DD, first completion date (default: no bound)
+---@param to string|nil YYYY-MM-DD, last completion date (default: no bound)
+---@param callback function Callback called with { tasks = { { list, task, completed } }, total } or error
+function M.get_archive(from, to, callback)
+	local tz = os.date("%z"):gsub("%+", "%%2B")
+	local url = string.format("%s/api/archive?from=%s&to=%s&tz=%s", get_proxy_url(), from or "", to or "", tz)
+	request({ url = url }, callback)
+end
+
+--- Get completion statistics: tasks completed per day, average age, overdue rate and per-list counts
+---@param range string|nil e.g. "30d" or "12w" (default: 30d)
+---@param callback function Callback called with { daily = { { date, completed } }, average_age_days, overdue_rate, lists } or error
+function M.get_trends(range, callback)
+	local tz = os.date("%z"):gsub("%+", "%%2B")
+	local url = string.format("%s/api/stats/trends?range=%s&tz=%s", get_proxy_url(), range or "", tz)
+	request({ url = url }, callback)
+end
+
+--- Get the calls the proxy made to Google, by endpoint, hour and day
+---@param callback function Callback called with { tasks_today, daily_quota, remaining, hours, days, endpoints = { { endpoint, calls, errors, rate_limited } } } or error
+function M.get_usage(callback)
+	request({ url = get_proxy_url() .. "/api/usage" }, callback)
+end
+
+--- Get the proxy's backup settings and the backups it keeps
+---@param callback function Callback called with { enabled, last_attempt, last_error, next, backups = { { name, created, size } } } or error
+function M.get_backup_status(callback)
+	request({ url = get_proxy_url() .. "/api/backup/status" }, callback)
+end
+
+--- Get the backups the proxy keeps, with the lists each holds
+---@param callback function Callback called with { backups = { { id, created, lists = { { list, tasks } } } } } or error
+function M.list_backups(callback)
+	request({ url = get_proxy_url() .. "/api/backup/list" }, callback)
+end
+
+--- Re-create the lists and tasks of a backup that no longer exist
+---@param backup_id string Backup ID from list_backups
+---@param opts table|nil { list = backup list ID, task = backup task ID, dry_run = true to preview }
+---@param callback function Callback called with { lists, tasks, existing } or error
+function M.restore_backup(backup_id, opts, callback)
+	opts = opts or {}
+	local url = get_proxy_url() .. "/api/backup/" .. backup_id .. "/restore"
+	if opts.dry_run then
+		url = url .. "?dry_run=1"
+	end
+	local body = nil
+	if opts.list or opts.task then
+		body = { list = opts.list, task = opts.task }
+	end
+	request({ url = url, method = "POST", body = body }, callback)
+end
+
+--- Time-block a task on Google Calendar through the proxy
+---@param task_id string Google task ID
+---@param start string e.g. "15:00", "3pm" (on the task's due date) or "2025-03-14 15:00"
+---@param duration string|nil e.g. "45m" (default: the proxy's calendar.duration)
+---@param callback function Callback called with { created, event = { html_link, start, end } } or error
+function M.schedule(task_id, start, duration, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasks/" .. task_id .. "/schedule",
+		method = "POST",
+		body = { start = start, duration = duration, tz = os.date("%z") },
+	}, callback)
+end
+
+--- Get open tasks as quickfix entries from the proxy
+---@param filter string|nil overdue, today, week or open (default: open)
+---@param callback function Callback called with the setqflist() {what} table or error
+function M.get_quickfix(filter, callback)
+	local tz = os.date("%z"):gsub("%+", "%%2B")
+	local url = string.format("%s/api/tasks/quickfix?filter=%s&tz=%s", get_proxy_url(), filter or "open", tz)
+	request({ url = url }, callback)
+end
+
+--- Fuzzy-search task titles across lists on the proxy, for pickers
+---@param query string fzf-style query; space-separated terms must all match
+---@param limit number|nil Maximum number of matches (default: 50)
+---@param callback function Callback called with { matches = { { list, task, score, positions } }, total, next_cursor } or error
+---@param cursor string|nil next_cursor of the previous page, for the matches after it
+function M.fuzzy_search(query, limit, callback, cursor)
+	local url = string.format(
+		"%s/api/search/fuzzy?q=%s&limit=%d",
+		get_proxy_url(),
+		vim.uri_encode(query, "rfc3986"),
+		limit or 50
+	)
+	if cursor then
+		url = url .. "&cursor=" .. cursor
+	end
+	request({ url = url }, callback)
+end
+
+--- Complete or reopen many tasks in one request through the proxy
+---@param tasks table[] { { list, task } } IDs of the tasks; list may be nil for the proxy to look the task up
+---@param status string "completed" or "needsAction"
+---@param callback function Callback called with { updated, failed, results = { { task, error } } } (in the order of tasks) or error
+function M.bulk_status(tasks, status, callback)
+	request({
+		url = get_proxy_url() .. "/api/tasks/bulk-status",
+		method = "POST",
+		body = { status = status, tasks = tasks },
+	}, callback)
+end
+
+--- Find likely duplicate tasks across lists through the proxy
+---@param threshold number|nil How alike titles must be, above 0 and at most 1 (default: 0.8)
+---@param callback function Callback called with { groups = { { title, similarity, tasks = { { list, task } } } } } or error
+function M.find_duplicates(threshold, callback)
+	local url = get_proxy_url() .. "/api/duplicates"
+	if threshold then
+		url = url .. "?threshold=" .. threshold
+	end
+	request({ url = url }, callback)
+end
+
+--- Merge duplicate tasks into one through the proxy: the kept task gets their notes and due date, and they are deleted
+---@param keep table { list, task } IDs of the task to keep
+---@param duplicates table[] { { list, task } } IDs of the tasks merged into it
+---@param callback function Callback called with { task, results } or error
+function M.merge_duplicates(keep, duplicates, callback)
+	request({
+		url = get_proxy_url() .. "/api/duplicates/merge",
+		method = "POST",
+		body = { keep = keep, duplicates = duplicates },
+	}, callback)
+end
+
+--- Get one page of a list's tasks through the proxy's cache, for loading a
+--- long list as it is scrolled
+---@param list_id string Google task list ID
+---@param cursor string|nil nextCursor of the previous page (nil for the first)
+---@param limit number|nil Tasks per page (default: 100)
+---@param callback function Callback called with { items, nextCursor } (no nextCursor on the last page) or error
+---@param sort table|nil { by, order } to sort by before paging (default: Google's order); keep it for every page
+function M.get_tasks_page(list_id, cursor, limit, callback, sort)
+	local url = string.format("%s/api/lists/%s/tasks?showCompleted=true&limit=%d", get_proxy_url(), list_id, limit or 100)
+		.. sort_query(sort)
+	if cursor then
+		url = url .. "&cursor=" .. cursor
+	end
+	request({ url = url }, callback)
+end
+
 --- Get all task lists for the authenticated user (with pagination)
 --- Retrieves all task lists from Google Tasks API, automatically handling pagination
 ---@param callback function Callback called with task lists array or error
@@ -189,7 +567,7 @@ function M.get_task_lists(callback)
 
 	-- Recursive function to fetch all pages
 	local function fetch_page(page_token)
-		local url = "https://tasks.googleapis.com/tasks/v1/users/@me/lists?maxResults=100"
+		local url = get_api_url() .. "/users/@me/lists?maxResults=100"
 
 		if page_token then
 			url = url .. "&pageToken=" .. page_token
@@ -245,7 +623,8 @@ function M.get_tasks(task_list_id, callback)
 	-- Recursive function to fetch all pages
 	local function fetch_page(page_token)
 		local url = string.format(
-			"https://tasks.googleapis.com/tasks/v1/lists/%s/tasks?showCompleted=true&showHidden=true&maxResults=100",
+			"%s/lists/%s/tasks?showCompleted=true&showHidden=true&maxResults=100",
+			get_api_url(),
 			task_list_id
 		)
 
@@ -309,7 +688,7 @@ function M.create_task(task_list_id, task_data, callback)
 
 	request({
 		method = "POST",
-		url = string.format("https://tasks.googleapis.com/tasks/v1/lists/%s/tasks", task_list_id),
+		url = string.format("%s/lists/%s/tasks", get_api_url(), task_list_id),
 		body = task_data,
 	}, callback)
 end
@@ -347,7 +726,7 @@ function M.update_task(task_list_id, task_id, task_data, callback)
 
 	request({
 		method = "PATCH",
-		url = string.format("https://tasks.googleapis.com/tasks/v1/lists/%s/tasks/%s", task_list_id, task_id),
+		url = string.format("%s/lists/%s/tasks/%s", get_api_url(), task_list_id, task_id),
 		body = task_data,
 	}, callback)
 end
@@ -398,7 +777,7 @@ function M.create_task_list(list_name, callback)
 
 	request({
 		method = "POST",
-		url = "https://tasks.googleapis.com/tasks/v1/users/@me/lists",
+		url = get_api_url() .. "/users/@me/lists",
 		body = { title = list_name },
 	}, function(response, err)
 		if err then
@@ -427,7 +806,7 @@ function M.delete_task_list(list_id, callback)
 
 	request({
 		method = "DELETE",
-		url = string.format("https://tasks.googleapis.com/tasks/v1/users/@me/lists/%s", list_id),
+		url = string.format("%s/users/@me/lists/%s", get_api_url(), list_id),
 	}, function(response, err)
 		if err then
 			callback(false, err)
@@ -492,7 +871,7 @@ function M.create_task_with_parent(task_list_id, task_data, parent_id, previous_
 		return
 	end
 
-	local url = string.format("https://tasks.googleapis.com/tasks/v1/lists/%s/tasks", task_list_id)
+	local url = string.format("%s/lists/%s/tasks", get_api_url(), task_list_id)
 
 	-- Build query parameters for parent and ordering
 	local query_params = {}
@@ -538,7 +917,7 @@ function M.delete_task(task_list_id, task_id, callback)
 
 	request({
 		method = "DELETE",
-		url = string.format("https://tasks.googleapis.com/tasks/v1/lists/%s/tasks/%s", task_list_id, task_id),
+		url = string.format("%s/lists/%s/tasks/%s", get_api_url(), task_list_id, task_id),
 	}, callback)
 end
 
@@ -565,7 +944,7 @@ function M.move_task(task_list_id, task_id, parent_id, callback)
 	end
 
 	-- Build URL with parent parameter
-	local url = string.format("https://tasks.googleapis.com/tasks/v1/lists/%s/tasks/%s/move", task_list_id, task_id)
+	local url = string.format("%s/lists/%s/tasks/%s/move", get_api_url(), task_list_id, task_id)
 
 	if parent_id and parent_id ~= "" then
 		url = url .. "?parent=" .. parent_id
@@ -39,11 +39,11 @@ local function poll_for_completion(state, callback)
 			return
 		end
 
-		vim.system({
+		vim.system(vim.list_extend({
 			"curl",
 			"-s",
 			get_proxy_url() .. "/auth/poll/" .. state,
-		}, { text = true }, function(obj)
+		}, config.proxy_curl_args()), { text = true }, function(obj)
 			vim.schedule(function()
 				if obj.code == 0 then
 					local response = obj.stdout or ""
@@ -81,10 +81,11 @@ end
 
 --- Generate the OAuth 2.0 authorization URL via proxy backend
 --- Calls the proxy backend to get a secure authorization URL
----@param callback function Optional callback called with auth URL or error
+---@param callback function Optional callback called with auth URL or error, and the code to enter at the URL
+---                         when the proxy signs in with a device flow (GitHub provider)
 function M.get_authorization_url(callback)
 	-- Call proxy backend to generate auth URL
-	vim.system({
+	vim.system(vim.list_extend({
 		"curl",
 		"-s",
 		"-X",
@@ -94,7 +95,7 @@ function M.get_authorization_url(callback)
 		"-d",
 		"{}",
 		get_proxy_url() .. "/auth/start",
-	}, { text = true }, function(obj)
+	}, config.proxy_curl_args()), { text = true }, function(obj)
 		vim.schedule(function()
 			if obj.code == 0 then
 				local response = obj.stdout or ""
@@ -106,7 +107,13 @@ function M.get_authorization_url(callback)
 					utils.notify("DEBUG: Generated auth URL via proxy", vim.log.levels.DEBUG)
 
 					if callback then
-						callback(data.authUrl, nil)
+						callback(data.authUrl, nil, data.userCode)
+					end
+				elseif success and type(data) == "table" and type(data.error) == "table" then
+					local error_msg = "Auth proxy error: " .. (data.error.message or data.error.code or "unknown")
+					utils.notify(error_msg, vim.log.levels.ERROR)
+					if callback then
+						callback(nil, error_msg)
 					end
 				else
 					local error_msg = "Invalid response from auth proxy: " .. response
@@ -137,21 +144,25 @@ function M.authenticate(callback)
 	end
 
 	-- Get authorization URL from proxy backend
-	M.get_authorization_url(function(auth_url, err)
+	M.get_authorization_url(function(auth_url, err, user_code)
 		if err then
 			utils.notify("Failed to get authorization URL: " .. err, vim.log.levels.ERROR)
 			return
 		end
 
-		-- Copy to clipboard if available
-		local clipboard_success = pcall(vim.fn.setreg, "+", auth_url)
+		-- Copy to clipboard if available; with a device flow, the code is what gets pasted
+		local clipboard_success = pcall(vim.fn.setreg, "+", user_code or auth_url)
 
 		-- Display the URL prominently
-		utils.notify("Please visit this URL to authorize:", vim.log.levels.INFO)
+		if user_code then
+			utils.notify("Please visit this URL and enter the code " .. user_code .. " to authorize:", vim.log.levels.INFO)
+		else
+			utils.notify("Please visit this URL to authorize:", vim.log.levels.INFO)
+		end
 		utils.notify(auth_url, vim.log.levels.WARN)
 
 		if clipboard_success then
-			utils.notify("(URL copied to clipboard)", vim.log.levels.INFO)
+			utils.notify(user_code and "(Code copied to clipboard)" or "(URL copied to clipboard)", vim.log.levels.INFO)
 		end
 
 		-- Also echo the URL to command line to ensure it's visible
@@ -19,6 +19,30 @@ local defaults = {
 		--- Can be overridden via setup() function
 		---@type string
 		base_url = "https://app.priteshtupe.com/gtask",
+
+		--- Session for a proxy shared by several users (multi-tenant mode)
+		--- Open one with POST /auth/session on the proxy
+		---@type string|nil
+		session = nil,
+
+		--- Unix socket the proxy listens on (its server.listen); requests to
+		--- base_url are sent through it
+		---@type string|nil
+		socket = nil,
+
+		--- Seconds a request to the proxy may take; the proxy stops calling
+		--- Google for it then too
+		---@type number
+		timeout = 120,
+	},
+
+	--- Google Tasks API configuration
+	api = {
+		--- Base URL for Tasks API requests
+		--- Point at a backend running with PROVIDER=mock (e.g. http://localhost:3000/mock/tasks/v1)
+		--- to develop against in-memory data instead of Google
+		---@type string
+		base_url = "https://tasks.googleapis.com/tasks/v1",
 	},
 
 	--- Token storage configuration
@@ -51,6 +75,23 @@ local defaults = {
 		keep_completed_in_markdown = true,
 	},
 
+	--- Reminders configuration
+	reminders = {
+		--- Listen to the proxy's event stream and show due/overdue task reminders
+		--- Requires a proxy started with reminders enabled and the sse sink
+		---@type boolean
+		enabled = false,
+	},
+
+	--- Change notifications configuration
+	changes = {
+		--- Listen to the proxy's event stream and show changes made by other clients
+		--- (completed on the phone, renamed on the web, ...)
+		--- Requires a proxy started with changes enabled
+		---@type boolean
+		notify = false,
+	},
+
 	--- Logging verbosity level
 	--- Controls which log messages are shown
 	--- "error" (default): Only show errors
@@ -73,6 +114,31 @@ function M.setup(opts)
 		config.proxy.base_url = opts.proxy_url
 	end
 
+	if opts.proxy_session ~= nil then
+		if type(opts.proxy_session) ~= "string" then
+			error("proxy_session must be a string")
+		end
+		config.proxy.session = opts.proxy_session
+	end
+
+	if opts.proxy_socket ~= nil then
+		if type(opts.proxy_socket) ~= "string" then
+			error("proxy_socket must be a string")
+		end
+		config.proxy.socket = vim.fn.expand(opts.proxy_socket)
+	end
+
+	if opts.proxy_timeout ~= nil then
+		if type(opts.proxy_timeout) ~= "number" or opts.proxy_timeout <= 0 then
+			error("proxy_timeout must be a positive number of seconds")
+		end
+		config.proxy.timeout = opts.proxy_timeout
+	end
+
+	if opts.api_url then
+		config.api.base_url = opts.api_url:gsub("/$", "")
+	end
+
 	if opts.markdown_dir then
 		local path = opts.markdown_dir
 
@@ -107,6 +173,20 @@ function M.setup(opts)
 		config.sync.keep_completed_in_markdown = opts.keep_completed_in_markdown
 	end
 
+	if opts.reminders ~= nil then
+		if type(opts.reminders) ~= "boolean" then
+			error("reminders must be a boolean")
+		end
+		config.reminders.enabled = opts.reminders
+	end
+
+	if opts.notify_changes ~= nil then
+		if type(opts.notify_changes) ~= "boolean" then
+			error("notify_changes must be a boolean")
+		end
+		config.changes.notify = opts.notify_changes
+	end
+
 	if opts.verbosity then
 		if type(opts.verbosity) ~= "string" then
 			error("verbosity must be a string")
@@ -119,6 +199,19 @@ function M.setup(opts)
 	end
 end
 
+--- Extra curl arguments for requests to the proxy (its session and unix socket, if any)
+---@return string[]
+function M.proxy_curl_args()
+	local args = {}
+	if config.proxy.session and config.proxy.session ~= "" then
+		vim.list_extend(args, { "-H", "X-Gtask-Session: " .. config.proxy.session })
+	end
+	if config.proxy.socket and config.proxy.socket ~= "" then
+		vim.list_extend(args, { "--unix-socket", config.proxy.socket })
+	end
+	return args
+end
+
 --- Get current configuration
 ---@return table Current configuration
 function M.get()
@@ -133,9 +226,12 @@ end
 -- Expose configuration fields for backward compatibility
 M.scopes = config.scopes
 M.proxy = config.proxy
+M.api = config.api
 M.storage = config.storage
 M.markdown = config.markdown
 M.sync = config.sync
+M.reminders = config.reminders
+M.changes = config.changes
 M.verbosity = config.verbosity
 
 return M
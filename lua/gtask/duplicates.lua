@@ -0,0 +1,88 @@
+---@class GtaskDuplicates
+---Find tasks with alike titles and merge them into one
+local M = {}
+
+local api = require("gtask.api")
+local utils = require("gtask.utils")
+
+--- A task of a duplicate group, as shown when picking the one to keep
+---@param entry table { list, task }
+---@return string
+local function describe(entry)
+	local due = entry.task.due and (", due " .. entry.task.due:sub(1, 10)) or ""
+	local done = entry.task.status == "completed" and ", completed" or ""
+	return string.format("%s (%s%s%s)", entry.task.title, entry.list.title, due, done)
+end
+
+--- Merge the tasks of a group into the one at keep_index
+---@param group table { title, similarity, tasks }
+---@param keep_index number
+local function merge(group, keep_index)
+	local keep = group.tasks[keep_index]
+	local duplicates = {}
+	for i, entry in ipairs(group.tasks) do
+		if i ~= keep_index then
+			table.insert(duplicates, { list = entry.list.id, task = entry.task.id })
+		end
+	end
+	api.merge_duplicates({ list = keep.list.id, task = keep.task.id }, duplicates, function(result, err)
+		if err then
+			utils.notify("Failed to merge duplicates: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		local failed = 0
+		for _, r in ipairs(result.results) do
+			if r.error then
+				failed = failed + 1
+			end
+		end
+		if failed > 0 then
+			utils.notify(
+				string.format("%d of %d changes to merge into %s failed", failed, #result.results, keep.task.title),
+				vim.log.levels.WARN
+			)
+			return
+		end
+		utils.notify(string.format("Merged %d duplicate(s) into %s", #duplicates, keep.task.title))
+	end)
+end
+
+--- Pick a group of likely duplicates, then the task to keep, and merge the others into it
+---@param threshold number|nil How alike titles must be, above 0 and at most 1 (default: the proxy's 0.8)
+function M.review(threshold)
+	api.find_duplicates(threshold, function(result, err)
+		if err then
+			utils.notify("Failed to find duplicates: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		if #result.groups == 0 then
+			utils.notify("No duplicate tasks found")
+			return
+		end
+		vim.ui.select(result.groups, {
+			prompt = "Duplicates: ",
+			format_item = function(group)
+				return string.format(
+					"%s (%d tasks, %d%% alike)",
+					group.title,
+					#group.tasks,
+					math.floor(group.similarity * 100 + 0.5)
+				)
+			end,
+		}, function(group)
+			if not group then
+				return
+			end
+			vim.ui.select(group.tasks, {
+				prompt = "Keep which? The others are merged into it and deleted: ",
+				format_item = describe,
+			}, function(_, index)
+				if index then
+					merge(group, index)
+				end
+			end)
+		end)
+	end)
+end
+
+return M
@@ -0,0 +1,216 @@
+---@class GtaskEvents
+---Listens to the proxy's event stream (GET /api/events) and shows reminders
+---and changes made by other clients
+local M = {}
+
+local config = require("gtask.config")
+local store = require("gtask.store")
+local utils = require("gtask.utils")
+
+--- Seconds to wait before reconnecting after the stream ends
+local RECONNECT_DELAY = 30
+
+--- Running curl process, if any
+local job = nil
+
+--- Whether stop() was called, so the stream is not reopened
+local stopped = true
+
+--- Show a reminder event
+---@param reminder table Reminder with kind, due, list and task fields
+local function show_reminder(reminder)
+	local task = reminder.task or {}
+	local list = reminder.list or {}
+	local msg, level
+	if reminder.kind == "overdue" then
+		msg = string.format("Overdue since %s: %s (%s)", reminder.due, task.title or "", list.title or "")
+		level = vim.log.levels.WARN
+	else
+		msg = string.format("Due %s: %s (%s)", reminder.due, task.title or "", list.title or "")
+		level = vim.log.levels.INFO
+	end
+	-- Reminders were asked for, so they are shown regardless of verbosity
+	vim.notify(msg, level, { title = "gtask" })
+end
+
+--- Describe a change event for a notification
+---@param change table TaskEvent with type, list, task, from and to fields
+---@return string
+local function describe_change(change)
+	local list = (change.list or {}).title or ""
+	local task = (change.task or {}).title or ""
+	local descriptions = {
+		["list.created"] = "List created: " .. list,
+		["list.deleted"] = "List deleted: " .. list,
+		["list.renamed"] = string.format("List renamed: %s -> %s", change.from or "", change.to or ""),
+		["task.created"] = string.format("Task added: %s (%s)", task, list),
+		["task.deleted"] = string.format("Task deleted: %s (%s)", task, list),
+		["task.completed"] = string.format("Task completed: %s (%s)", task, list),
+		["task.reopened"] = string.format("Task reopened: %s (%s)", task, list),
+		["task.title_changed"] = string.format("Task renamed: %s -> %s", change.from or "", change.to or ""),
+		["task.notes_changed"] = string.format("Task notes changed: %s (%s)", task, list),
+		["task.due_changed"] = string.format("Task due date: %s (%s -> %s)", task, change.from or "none", change.to or "none"),
+		["task.moved"] = string.format("Task moved to %s: %s", list, task),
+		["task.reparented"] = string.format("Subtask moved: %s (%s)", task, list),
+	}
+	return descriptions[change.type] or ("Task changed: " .. task)
+end
+
+--- Handle a change made by another client
+---@param change table TaskEvent
+local function show_change(change)
+	if config.get().changes.notify then
+		vim.notify(describe_change(change), vim.log.levels.INFO, { title = "gtask" })
+	end
+	-- Lets users react to remote changes, e.g. by running :GtaskSync
+	vim.api.nvim_exec_autocmds("User", { pattern = "GtaskChange", data = change })
+end
+
+--- Handle one complete server-sent event
+---@param name string Event name
+---@param data string Event data (JSON)
+local function dispatch(name, data)
+	local ok, decoded = pcall(vim.fn.json_decode, data)
+	if not ok or type(decoded) ~= "table" then
+		utils.notify("Invalid event from proxy: " .. data, vim.log.levels.WARN)
+		return
+	end
+
+	if name == "reminder" then
+		if config.get().reminders.enabled then
+			show_reminder(decoded)
+		end
+	elseif name == "change" then
+		show_change(decoded)
+	elseif name == "error" then
+		utils.notify("Event stream: " .. (decoded.message or "error"), vim.log.levels.WARN)
+	end
+end
+
+--- Create a parser that turns stream chunks into events
+---@param on_event? function Called with each event's name and data; shows it by default
+---@return function Function taking each stdout chunk
+function M.event_parser(on_event)
+	on_event = on_event or dispatch
+	local buffer = ""
+	local name, data = "message", nil
+
+	return function(chunk)
+		-- A CRLF may be split across chunks, so normalise the joined buffer
+		buffer = (buffer .. chunk):gsub("\r\n", "\n")
+		while true do
+			local newline = buffer:find("\n", 1, true)
+			if not newline then
+				return
+			end
+			local line = buffer:sub(1, newline - 1)
+			buffer = buffer:sub(newline + 1)
+
+			if line == "" then
+				if data then
+					on_event(name, data)
+				end
+				name, data = "message", nil
+			elseif line:match("^event:") then
+				name = vim.trim(line:sub(7))
+			elseif line:match("^data:") then
+				local value = line:sub(6):gsub("^ ", "")
+				data = data and (data .. "\n" .. value) or value
+			end
+		end
+	end
+end
+
+local connect
+
+--- Reconnect after a delay, refreshing the access token first
+local function schedule_reconnect()
+	if stopped then
+		return
+	end
+	vim.defer_fn(function()
+		if stopped or job then
+			return
+		end
+		if not store.has_tokens() then
+			schedule_reconnect()
+			return
+		end
+		-- Any authenticated request refreshes an expired access token
+		require("gtask.api").get_task_lists(function()
+			connect()
+		end)
+	end, RECONNECT_DELAY * 1000)
+end
+
+--- Open the event stream with the stored access token
+connect = function()
+	if stopped or job then
+		return
+	end
+	local tokens = store.load_tokens()
+	if not tokens or not tokens.access_token then
+		schedule_reconnect()
+		return
+	end
+
+	local parse = M.event_parser()
+	local handle
+	handle = vim.system(vim.list_extend({
+		"curl",
+		"-sN",
+		"--fail",
+		"-H",
+		"Authorization: Bearer " .. tokens.access_token,
+		"-H",
+		"Accept: text/event-stream",
+		config.get().proxy.base_url .. "/api/events",
+	}, config.proxy_curl_args()), {
+		text = true,
+		stdout = function(_, chunk)
+			if chunk then
+				vim.schedule(function()
+					parse(chunk)
+				end)
+			end
+		end,
+	}, function(obj)
+		vim.schedule(function()
+			if job ~= handle then
+				return -- stopped, and possibly restarted since
+			end
+			job = nil
+			if not stopped then
+				utils.notify(string.format("Event stream closed (curl exit %d), reconnecting", obj.code))
+				schedule_reconnect()
+			end
+		end)
+	end)
+	job = handle
+end
+
+--- Start listening for reminders. Does nothing if already listening.
+function M.start()
+	if not stopped then
+		return
+	end
+	stopped = false
+	connect()
+end
+
+--- Stop listening for reminders
+function M.stop()
+	stopped = true
+	if job then
+		job:kill("sigterm")
+		job = nil
+	end
+end
+
+--- Whether the event stream is currently open
+---@return boolean
+function M.is_running()
+	return job ~= nil
+end
+
+return M
@@ -4,12 +4,16 @@ local M = {}
 --- Call this in your Neovim config to customize the plugin behavior
 ---@param opts table|nil Configuration options
 ---   - proxy_url: string|nil - Custom URL for the OAuth proxy backend (default: "https://app.priteshtupe.com/gtask")
+---   - proxy_socket: string|nil - Unix socket the proxy listens on; requests to proxy_url go through it
+---   - proxy_timeout: number|nil - Seconds a request to the proxy may take (default: 120)
 ---   - markdown_dir: string|nil - Absolute path to markdown directory (default: "~/gtask.nvim")
 ---                                Must start with / or ~ (no relative paths)
 ---   - ignore_patterns: string[]|nil - List of directory names or .md file names to ignore
 ---                                     Directory names will skip entire subdirectories
 ---                                     File names will skip specific markdown files
 ---                                     (default: {})
+---   - reminders: boolean|nil - Show due/overdue task reminders sent by the proxy (default: false)
+---   - notify_changes: boolean|nil - Show changes made by other clients, as reported by the proxy (default: false)
 ---
 --- Example:
 ---   require('gtask').setup({
@@ -18,7 +22,15 @@ local M = {}
 ---     ignore_patterns = { "archive", "draft.md" },
 ---   })
 function M.setup(opts)
-	require("gtask.config").setup(opts)
+	local config = require("gtask.config")
+	config.setup(opts)
+
+	local events = require("gtask.events")
+	if config.get().reminders.enabled or config.get().changes.notify then
+		events.start()
+	else
+		events.stop()
+	end
 end
 
 return M
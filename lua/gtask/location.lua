@@ -0,0 +1,110 @@
+---@class GtaskLocation
+---Code locations of tasks: where a task was created, and jumping back there
+local M = {}
+
+local api = require("gtask.api")
+local utils = require("gtask.utils")
+
+--- The git repository around dir
+---@param dir string
+---@return string|nil root
+---@return string|nil commit HEAD, if the repository has one
+local function git_repo(dir)
+	local root = vim.fn.systemlist({ "git", "-C", dir, "rev-parse", "--show-toplevel" })[1]
+	if vim.v.shell_error ~= 0 or not root then
+		return nil, nil
+	end
+	local commit = vim.fn.systemlist({ "git", "-C", root, "rev-parse", "HEAD" })[1]
+	return root, vim.v.shell_error == 0 and commit or nil
+end
+
+--- The location of the cursor, for tasks created from code
+---@return table|nil { file, line, repo, commit }; nil outside a file
+function M.current()
+	local path = vim.api.nvim_buf_get_name(0)
+	if path == "" or vim.bo.buftype ~= "" then
+		return nil
+	end
+	path = vim.fn.fnamemodify(path, ":p")
+	local location = { file = path, line = vim.api.nvim_win_get_cursor(0)[1] }
+	local root, commit = git_repo(vim.fn.fnamemodify(path, ":h"))
+	if root and path:sub(1, #root + 1) == root .. "/" then
+		location.file = path:sub(#root + 2)
+		location.repo = vim.fn.fnamemodify(root, ":t")
+		location.commit = commit
+	end
+	return location
+end
+
+--- Open the file of a location at its line. Repository-relative files are
+--- found in the repository around the current directory, which must be the
+--- one the location names.
+---@param location table { file, line, repo, commit }
+function M.jump(location)
+	local path = location.file
+	if location.repo and location.repo ~= vim.NIL then
+		local root = git_repo(vim.fn.getcwd())
+		if not root or vim.fn.fnamemodify(root, ":t") ~= location.repo then
+			utils.notify(
+				string.format("%s:%d is in %s, not the current repository", path, location.line, location.repo),
+				vim.log.levels.ERROR
+			)
+			return
+		end
+		path = root .. "/" .. path
+	end
+	if vim.fn.filereadable(path) == 0 then
+		utils.notify(path .. " no longer exists", vim.log.levels.ERROR)
+		return
+	end
+	vim.cmd.edit(vim.fn.fnameescape(path))
+	-- The code may have changed since; stay within the file
+	local line = math.min(math.max(location.line, 1), vim.api.nvim_buf_line_count(0))
+	vim.api.nvim_win_set_cursor(0, { line, 0 })
+end
+
+--- Jump to where a task was created
+---@param task_id string Google task ID
+function M.jump_to_task(task_id)
+	api.get_location(task_id, function(result, err)
+		if err then
+			utils.notify("Failed to get the task's location: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		if not result.location then
+			utils.notify("The task was not created from code")
+			return
+		end
+		M.jump(result.location)
+	end)
+end
+
+--- Pick a task created from code by fuzzy search and jump to its location
+---@param query string|nil Search of task titles (default: every task)
+function M.pick(query)
+	api.fuzzy_search(query or "", 500, function(result, err)
+		if err then
+			utils.notify("Failed to search tasks: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		local matches = vim.tbl_filter(function(match)
+			return match.location ~= nil and match.location ~= vim.NIL
+		end, result.matches)
+		if #matches == 0 then
+			utils.notify("No tasks created from code match")
+			return
+		end
+		vim.ui.select(matches, {
+			prompt = "Jump to task: ",
+			format_item = function(match)
+				return string.format("%s (%s:%d)", match.task.title, match.location.file, match.location.line)
+			end,
+		}, function(match)
+			if match then
+				M.jump(match.location)
+			end
+		end)
+	end)
+end
+
+return M
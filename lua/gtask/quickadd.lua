@@ -0,0 +1,32 @@
+---@class GtaskQuickAdd
+---One-line task capture through the proxy's /api/quickadd
+local M = {}
+
+local api = require("gtask.api")
+
+--- Create a task from one line of text, asking for the line when none is given
+---@param text string|nil e.g. "pay rent tomorrow 9am #finance !p1 @personal"
+---@param location table|nil { file, line, repo, commit } to keep with the task
+function M.add(text, location)
+	local function create(line)
+		if not line or vim.trim(line) == "" then
+			return
+		end
+		api.quick_add(line, function(result, err)
+			if err then
+				vim.notify("Failed to add task: " .. err, vim.log.levels.ERROR)
+				return
+			end
+			local due = result.due and (" (due " .. result.due .. ")") or ""
+			vim.notify(string.format("Added %s to %s%s", result.title, result.list.title, due))
+		end, location)
+	end
+
+	if text then
+		create(text)
+	else
+		vim.ui.input({ prompt = "Add task: " }, create)
+	end
+end
+
+return M
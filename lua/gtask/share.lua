@@ -0,0 +1,73 @@
+---@class GtaskShare
+---Share read-only snapshots of task lists through links that need no Google access
+local M = {}
+
+local api = require("gtask.api")
+local utils = require("gtask.utils")
+
+--- Pick a list and share a snapshot of it, copying the link to the clipboard
+---@param expires string|nil How long the link works, e.g. "3d" or "12h" (default: until revoked)
+function M.share(expires)
+	api.get_task_lists(function(response, err)
+		if err then
+			utils.notify("Failed to get task lists: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		vim.ui.select(response.items, {
+			prompt = "Share which list? ",
+			format_item = function(list)
+				return list.title
+			end,
+		}, function(list)
+			if not list then
+				return
+			end
+			api.share_list(list.id, expires, function(result, share_err)
+				if share_err then
+					utils.notify("Failed to share " .. list.title .. ": " .. share_err, vim.log.levels.ERROR)
+					return
+				end
+				local expiry = result.expires_at and (" until " .. result.expires_at) or ""
+				utils.notify(string.format("Shared %d task(s) of %s%s:", result.tasks, list.title, expiry))
+				utils.notify(result.url, vim.log.levels.WARN)
+				if pcall(vim.fn.setreg, "+", result.url) then
+					utils.notify("(URL copied to clipboard)")
+				end
+			end)
+		end)
+	end)
+end
+
+--- Pick a shared snapshot and revoke it, so its link stops working
+function M.revoke()
+	api.list_shares(function(result, err)
+		if err then
+			utils.notify("Failed to get shares: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		if #result.shares == 0 then
+			utils.notify("No shared lists")
+			return
+		end
+		vim.ui.select(result.shares, {
+			prompt = "Revoke which share? ",
+			format_item = function(share)
+				local expires = share.expires_at and (", expires " .. share.expires_at) or ""
+				return string.format("%s (%d tasks, shared %s%s)", share.list.title, share.tasks, share.created_at, expires)
+			end,
+		}, function(share)
+			if not share then
+				return
+			end
+			api.revoke_share(share.id, function(_, revoke_err)
+				if revoke_err then
+					utils.notify("Failed to revoke share: " .. revoke_err, vim.log.levels.ERROR)
+					return
+				end
+				utils.notify("Revoked the share of " .. share.list.title)
+			end)
+		end)
+	end)
+end
+
+return M
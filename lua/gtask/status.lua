@@ -0,0 +1,75 @@
+---@class GtaskStatus
+---Complete or reopen the synced tasks of buffer lines in one request
+local M = {}
+
+local api = require("gtask.api")
+local mapping = require("gtask.mapping")
+local parser = require("gtask.parser")
+local utils = require("gtask.utils")
+
+--- Set the checkbox of a task line
+---@param line string
+---@param completed boolean
+---@return string
+local function tick(line, completed)
+	return (line:gsub("^(%s*%-%s*%[)[%sx]?(%])", "%1" .. (completed and "x" or " ") .. "%2", 1))
+end
+
+--- Complete or reopen the tasks on lines first to last of the current buffer, then tick their checkboxes
+---@param first number First line (1-based)
+---@param last number Last line
+---@param completed boolean true to complete, false to reopen
+function M.set(first, last, completed)
+	local buf = vim.api.nvim_get_current_buf()
+	local lines = vim.api.nvim_buf_get_lines(buf, 0, -1, false)
+	local map = mapping.load()
+
+	local tasks, rows, unsynced = {}, {}, 0
+	for row = first, last do
+		local _, _, title = parser.parse_task_line(lines[row])
+		if title then
+			-- Synced tasks have their UUID on the next line
+			local uuid = lines[row + 1] and parser.extract_task_uuid(lines[row + 1])
+			local data = uuid and map.tasks[uuid]
+			if data and data.google_id then
+				table.insert(tasks, { list = mapping.get_list_id(map, data.list_name), task = data.google_id })
+				table.insert(rows, row)
+			else
+				unsynced = unsynced + 1
+			end
+		end
+	end
+	if #tasks == 0 then
+		utils.notify("No synced tasks on these lines, run :GtaskSync first", vim.log.levels.WARN)
+		return
+	end
+
+	api.bulk_status(tasks, completed and "completed" or "needsAction", function(result, err)
+		if err then
+			utils.notify("Failed to update tasks: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		if vim.api.nvim_buf_is_valid(buf) then
+			for i, r in ipairs(result.results) do
+				if not r.error then
+					local row = rows[i]
+					local line = vim.api.nvim_buf_get_lines(buf, row - 1, row, false)[1]
+					if line then
+						vim.api.nvim_buf_set_lines(buf, row - 1, row, false, { tick(line, completed) })
+					end
+				end
+			end
+		end
+
+		local message = string.format("%s %d task(s)", completed and "Completed" or "Reopened", result.updated)
+		if result.failed > 0 then
+			message = message .. string.format(", %d failed", result.failed)
+		end
+		if unsynced > 0 then
+			message = message .. string.format(", %d not synced yet", unsynced)
+		end
+		utils.notify(message, result.failed > 0 and vim.log.levels.WARN or vim.log.levels.INFO)
+	end)
+end
+
+return M
@@ -0,0 +1,108 @@
+---@class GtaskTodos
+---Import TODO/FIXME comments of a git repository as tasks
+local M = {}
+
+local api = require("gtask.api")
+local utils = require("gtask.utils")
+
+--- Comment keywords that become tasks
+M.kinds = { "TODO", "FIXME", "HACK", "XXX" }
+
+--- Most comments the proxy imports at once
+M.max_todos = 500
+
+--- Take the kind, text and author of a TODO comment out of a line of code
+---@param line string
+---@return string|nil kind
+---@return string|nil text
+---@return string|nil author The name in TODO(name), if any
+function M.parse(line)
+	for _, kind in ipairs(M.kinds) do
+		local _, finish = line:find("%f[%w]" .. kind .. "%f[%W]")
+		if finish then
+			local text = line:sub(finish + 1)
+			-- TODO(name): text, TODO: text and TODO - text
+			local author = text:match("^%((.-)%)")
+			text = text:gsub("^%b()", ""):gsub("^%s*[:%-]?%s*", "")
+			-- Closers of block comments
+			text = vim.trim(text:gsub("%s*%*/%s*$", ""):gsub("%s*%-%->%s*$", ""))
+			if text ~= "" then
+				return kind, text, author ~= "" and author or nil
+			end
+		end
+	end
+	return nil, nil, nil
+end
+
+--- Find the TODO comments of the git repository around dir
+---@param dir string
+---@param callback function Called with (repo name, { { file, line, kind, text, author } }, commit) or (nil, error)
+function M.scan(dir, callback)
+	local root = vim.fn.systemlist({ "git", "-C", dir, "rev-parse", "--show-toplevel" })[1]
+	if vim.v.shell_error ~= 0 or not root then
+		callback(nil, "not in a git repository")
+		return
+	end
+	local commit = vim.fn.systemlist({ "git", "-C", root, "rev-parse", "HEAD" })[1]
+	if vim.v.shell_error ~= 0 then
+		commit = nil -- no commits yet
+	end
+	local pattern = "\\b(" .. table.concat(M.kinds, "|") .. ")\\b"
+	vim.system({ "git", "-C", root, "grep", "-n", "-I", "-E", pattern }, { text = true }, function(obj)
+		vim.schedule(function()
+			-- git grep exits 1 when nothing matches
+			if obj.code > 1 then
+				callback(nil, obj.stderr)
+				return
+			end
+			local todos = {}
+			for _, match in ipairs(vim.split(obj.stdout or "", "\n", { trimempty = true })) do
+				local file, line, code = match:match("^(.-):(%d+):(.*)$")
+				local kind, text, author = M.parse(code or "")
+				if kind then
+					table.insert(todos, { file = file, line = tonumber(line), kind = kind, text = text, author = author })
+				end
+			end
+			callback(vim.fn.fnamemodify(root, ":t"), todos, commit)
+		end)
+	end)
+end
+
+--- Import the TODO comments of the current repository into a list
+---@param list_title string|nil Title of the list, created if missing (default: "TODO: <repo>")
+function M.import(list_title)
+	M.scan(vim.fn.getcwd(), function(repo, todos, commit)
+		if not repo then
+			utils.notify("Failed to scan for TODOs: " .. todos, vim.log.levels.ERROR)
+			return
+		end
+		if #todos == 0 then
+			utils.notify("No TODO comments found in " .. repo)
+			return
+		end
+		if #todos > M.max_todos then
+			utils.notify(
+				string.format("%s has %d TODO comments, more than the %d one import takes", repo, #todos, M.max_todos),
+				vim.log.levels.ERROR
+			)
+			return
+		end
+		api.import_todos(list_title or ("TODO: " .. repo), repo, commit, todos, function(result, err)
+			if err then
+				utils.notify("Failed to import TODOs: " .. err, vim.log.levels.ERROR)
+				return
+			end
+			utils.notify(
+				string.format(
+					"%s: %d created, %d updated, %d unchanged",
+					result.list.title,
+					result.created,
+					result.updated,
+					result.unchanged
+				)
+			)
+		end)
+	end)
+end
+
+return M
@@ -12,5 +12,74 @@ local function cmd_sync()
 	end)
 end
 
+local function cmd_agenda(opts)
+	require("gtask.agenda").open(opts.args ~= "" and opts.args or nil)
+end
+
+local function cmd_quickfix(opts)
+	require("gtask.agenda").quickfix(opts.args ~= "" and opts.args or nil)
+end
+
+local function cmd_add(opts)
+	-- :GtaskAdd! keeps where the cursor is, to jump back to with :GtaskJump
+	local location = opts.bang and require("gtask.location").current() or nil
+	require("gtask.quickadd").add(opts.args ~= "" and opts.args or nil, location)
+end
+
+local function cmd_undo()
+	require("gtask.api").undo(function(result, err)
+		if err then
+			vim.notify("Failed to undo: " .. err, vim.log.levels.ERROR)
+			return
+		end
+		vim.notify(string.format("Undid %s (%d more to undo)", result.undone.summary, result.remaining))
+	end)
+end
+
+local function cmd_jump(opts)
+	require("gtask.location").pick(opts.args ~= "" and opts.args or nil)
+end
+
+local function cmd_done(opts)
+	-- :GtaskDone! reopens the tasks instead
+	require("gtask.status").set(opts.line1, opts.line2, not opts.bang)
+end
+
+local function cmd_duplicates(opts)
+	local threshold = tonumber(opts.args)
+	if opts.args ~= "" and not threshold then
+		vim.notify("GtaskDuplicates takes a similarity between 0 and 1", vim.log.levels.ERROR)
+		return
+	end
+	require("gtask.duplicates").review(threshold)
+end
+
+local function cmd_share(opts)
+	-- :GtaskShare! revokes a share instead
+	if opts.bang then
+		require("gtask.share").revoke()
+	else
+		require("gtask.share").share(opts.args ~= "" and opts.args or nil)
+	end
+end
+
+local function cmd_import_todos(opts)
+	require("gtask.todos").import(opts.args ~= "" and opts.args or nil)
+end
+
 vim.api.nvim_create_user_command("GtaskAuth", cmd_auth, {})
 vim.api.nvim_create_user_command("GtaskSync", cmd_sync, {})
+vim.api.nvim_create_user_command("GtaskAgenda", cmd_agenda, { nargs = "?" })
+vim.api.nvim_create_user_command("GtaskAdd", cmd_add, { nargs = "*", bang = true })
+vim.api.nvim_create_user_command("GtaskUndo", cmd_undo, {})
+vim.api.nvim_create_user_command("GtaskImportTodos", cmd_import_todos, { nargs = "?" })
+vim.api.nvim_create_user_command("GtaskJump", cmd_jump, { nargs = "*" })
+vim.api.nvim_create_user_command("GtaskDone", cmd_done, { range = true, bang = true })
+vim.api.nvim_create_user_command("GtaskDuplicates", cmd_duplicates, { nargs = "?" })
+vim.api.nvim_create_user_command("GtaskShare", cmd_share, { nargs = "?", bang = true })
+vim.api.nvim_create_user_command("GtaskQuickfix", cmd_quickfix, {
+	nargs = "?",
+	complete = function()
+		return { "overdue", "today", "week", "open" }
+	end,
+})
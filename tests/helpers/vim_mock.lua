@@ -20,6 +20,9 @@ end
 -- Mock vim global
 _G.vim = {
 	deepcopy = deepcopy,
+	trim = function(s)
+		return (s:gsub("^%s+", ""):gsub("%s+$", ""))
+	end,
 	fn = {
 		stdpath = function(what)
 			if what == "data" then
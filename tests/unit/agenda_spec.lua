@@ -0,0 +1,142 @@
+---Unit tests for agenda rendering
+describe("agenda module", function()
+	local agenda
+
+	before_each(function()
+		require("tests.helpers.vim_mock").reset()
+		agenda = require("gtask.agenda")
+	end)
+
+	local function item(title, list, extra)
+		local task = { title = title, status = "needsAction" }
+		for k, v in pairs(extra or {}) do
+			task[k] = v
+		end
+		return { task = task, list = { title = list } }
+	end
+
+	describe("render", function()
+		it("should list non-empty sections in order with their counts", function()
+			local lines = agenda.render({
+				date = "2025-01-15",
+				overdue = { item("File taxes", "Admin", { due = "2025-01-10T00:00:00.000Z" }) },
+				due_today = {
+					item("Pay rent", "Home", { due = "2025-01-15T00:00:00.000Z" }),
+					item("Call mum", "Home"),
+				},
+				due_this_week = {},
+				recently_completed = { item("Buy milk", "Shopping", { status = "completed" }) },
+			})
+
+			assert.same({
+				"# Agenda for 2025-01-15",
+				"",
+				"## Overdue (1)",
+				"",
+				"- [ ] File taxes _Admin_ (due 2025-01-10)",
+				"",
+				"## Due today (2)",
+				"",
+				"- [ ] Pay rent _Home_",
+				"- [ ] Call mum _Home_",
+				"",
+				"## Recently completed (1)",
+				"",
+				"- [x] Buy milk _Shopping_",
+			}, lines)
+		end)
+
+		it("should show due dates in this week's section only when the task has one", function()
+			local lines = agenda.render({
+				date = "2025-01-15",
+				due_this_week = {
+					item("Dentist", "Health", { due = "2025-01-17T00:00:00.000Z" }),
+					item("Undated", "Health"),
+				},
+			})
+
+			assert.equals("- [ ] Dentist _Health_ (due 2025-01-17)", lines[5])
+			assert.equals("- [ ] Undated _Health_", lines[6])
+		end)
+
+		it("should say when nothing is due", function()
+			local lines = agenda.render({ date = "2025-01-15", overdue = {}, due_today = {} })
+
+			assert.same({ "# Agenda for 2025-01-15", "", "Nothing due." }, lines)
+		end)
+
+		it("should report lists that could not be fetched", function()
+			local lines = agenda.render({
+				date = "2025-01-15",
+				failed = { { title = "Work", error = { message = "rate limited" } } },
+			})
+
+			assert.equals("(failed to fetch Work: rate limited)", lines[#lines])
+		end)
+	end)
+
+	describe("quickfix", function()
+		local api
+		local original_get_quickfix
+		local qflist
+		local commands
+
+		before_each(function()
+			api = require("gtask.api")
+			original_get_quickfix = api.get_quickfix
+			qflist = nil
+			commands = {}
+			vim.fn.setqflist = function(list, action, what)
+				qflist = { list = list, action = action, what = what }
+			end
+			vim.cmd = function(command)
+				table.insert(commands, command)
+			end
+			require("gtask.config").setup({ verbosity = "warn" })
+		end)
+
+		after_each(function()
+			api.get_quickfix = original_get_quickfix
+			vim.fn.setqflist = nil
+			vim.cmd = nil
+			require("gtask.config").reset()
+		end)
+
+		it("should replace the quickfix list with the proxy's entries and open it", function()
+			local requested
+			local what = { title = "Overdue tasks", items = { { text = "File taxes", type = "E" } } }
+			api.get_quickfix = function(filter, callback)
+				requested = filter
+				callback(what, nil)
+			end
+
+			agenda.quickfix("overdue")
+
+			assert.equals("overdue", requested)
+			assert.same({ list = {}, action = " ", what = what }, qflist)
+			assert.same({ "botright copen" }, commands)
+		end)
+
+		it("should warn about lists that could not be fetched", function()
+			api.get_quickfix = function(_, callback)
+				callback({ items = {}, failed = { { title = "Work", error = { message = "rate limited" } } } }, nil)
+			end
+
+			agenda.quickfix()
+
+			assert.is_not_nil(require("tests.helpers.vim_mock").find_notification("^Failed to fetch Work: rate limited$"))
+		end)
+
+		it("should leave the quickfix list alone when the request fails", function()
+			api.get_quickfix = function(_, callback)
+				callback(nil, "connection refused")
+			end
+
+			agenda.quickfix("today")
+
+			assert.is_nil(qflist)
+			assert.same({}, commands)
+			assert.is_not_nil(require("tests.helpers.vim_mock").find_notification("^Failed to load tasks: connection refused$"))
+		end)
+	end)
+end)
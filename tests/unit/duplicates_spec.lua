@@ -0,0 +1,133 @@
+---Unit tests for :GtaskDuplicates
+describe("duplicates module", function()
+	local duplicates
+	local api
+	local vim_mock
+	local original_find
+	local original_merge
+	local groups
+	local thresholds
+	local merges
+	local merge_result
+	local choices
+	local shown
+
+	local function entry(list, id, title, extra)
+		local task = { id = id, title = title, status = "needsAction" }
+		for k, v in pairs(extra or {}) do
+			task[k] = v
+		end
+		return { list = { id = list:lower(), title = list }, task = task }
+	end
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		api = require("gtask.api")
+		original_find = api.find_duplicates
+		original_merge = api.merge_duplicates
+		groups = {
+			{
+				title = "buy milk",
+				similarity = 0.915,
+				tasks = {
+					entry("Home", "t1", "buy milk"),
+					entry("Shopping", "t2", "Buy milk", { due = "2025-03-14T00:00:00.000Z" }),
+					entry("Home", "t3", "buy milk!", { status = "completed" }),
+				},
+			},
+		}
+		thresholds = {}
+		merges = {}
+		merge_result = { results = { {}, {} } }
+		api.find_duplicates = function(threshold, callback)
+			table.insert(thresholds, threshold or false)
+			callback({ groups = groups }, nil)
+		end
+		api.merge_duplicates = function(keep, dups, callback)
+			table.insert(merges, { keep = keep, duplicates = dups })
+			callback(merge_result, nil)
+		end
+
+		-- Each select picks the next of choices (an index, or nil to cancel)
+		-- and records how its items were shown
+		choices = {}
+		shown = {}
+		vim.ui = {
+			select = function(items, opts, on_choice)
+				local lines = {}
+				for _, item in ipairs(items) do
+					table.insert(lines, opts.format_item(item))
+				end
+				table.insert(shown, lines)
+				local index = table.remove(choices, 1)
+				on_choice(index and items[index], index)
+			end,
+		}
+
+		duplicates = require("gtask.duplicates")
+	end)
+
+	after_each(function()
+		api.find_duplicates = original_find
+		api.merge_duplicates = original_merge
+		vim.ui = nil
+	end)
+
+	it("should merge the others into the task picked to keep", function()
+		choices = { 1, 2 }
+
+		duplicates.review(0.9)
+
+		assert.same({ 0.9 }, thresholds)
+		assert.same({
+			{
+				keep = { list = "shopping", task = "t2" },
+				duplicates = { { list = "home", task = "t1" }, { list = "home", task = "t3" } },
+			},
+		}, merges)
+		assert.is_not_nil(vim_mock.find_notification("^Merged 2 duplicate%(s%) into Buy milk$"))
+	end)
+
+	it("should show groups and tasks with what tells them apart", function()
+		choices = { 1 }
+
+		duplicates.review(nil)
+
+		assert.same({ "buy milk (3 tasks, 92% alike)" }, shown[1])
+		assert.same({
+			"buy milk (Home)",
+			"Buy milk (Shopping, due 2025-03-14)",
+			"buy milk! (Home, completed)",
+		}, shown[2])
+	end)
+
+	it("should merge nothing when either pick is cancelled", function()
+		duplicates.review(nil)
+		choices = { 1 }
+		duplicates.review(nil)
+
+		assert.equals(0, #merges)
+	end)
+
+	it("should say so when there are no duplicates", function()
+		groups = {}
+
+		duplicates.review(nil)
+
+		assert.equals(0, #shown)
+		assert.is_not_nil(vim_mock.find_notification("^No duplicate tasks found$"))
+	end)
+
+	it("should warn when some changes of a merge failed", function()
+		choices = { 1, 1 }
+		merge_result = { results = { {}, { error = { message = "Task not found" } } } }
+
+		duplicates.review(nil)
+
+		local warning = vim_mock.find_notification("^1 of 2 changes to merge into buy milk failed$")
+		assert.is_not_nil(warning)
+		assert.equals(vim.log.levels.WARN, warning.level)
+	end)
+end)
@@ -0,0 +1,181 @@
+---Unit tests for the proxy event stream parser
+describe("events module", function()
+	local events
+	local config
+	local vim_mock
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		config = require("gtask.config")
+		config.reset()
+		events = require("gtask.events")
+	end)
+
+	--- Parser that collects events instead of showing them
+	local function collecting_parser()
+		local received = {}
+		local parse = events.event_parser(function(name, data)
+			table.insert(received, { name = name, data = data })
+		end)
+		return parse, received
+	end
+
+	describe("event_parser", function()
+		it("should emit an event at each blank line", function()
+			local parse, received = collecting_parser()
+
+			parse('event: reminder\ndata: {"a":1}\n\nevent: error\ndata: {"b":2}\n\n')
+
+			assert.equals(2, #received)
+			assert.same({ name = "reminder", data = '{"a":1}' }, received[1])
+			assert.same({ name = "error", data = '{"b":2}' }, received[2])
+		end)
+
+		it("should keep partial lines until the rest arrives", function()
+			local parse, received = collecting_parser()
+
+			parse("event: remin")
+			parse("der\nda")
+			parse("ta: {}\n")
+			assert.equals(0, #received)
+
+			parse("\n")
+			assert.same({ { name = "reminder", data = "{}" } }, received)
+		end)
+
+		it("should handle CRLF line endings, even split across chunks", function()
+			local parse, received = collecting_parser()
+
+			parse("event: reminder\r")
+			parse("\ndata: {}\r\n\r")
+			parse("\n")
+
+			assert.same({ { name = "reminder", data = "{}" } }, received)
+		end)
+
+		it("should join multi-line data with newlines", function()
+			local parse, received = collecting_parser()
+
+			parse("data: first\ndata:second\ndata:  indented\n\n")
+
+			assert.equals("first\nsecond\n indented", received[1].data)
+		end)
+
+		it("should default the name to message and reset it after each event", function()
+			local parse, received = collecting_parser()
+
+			parse("event: reminder\ndata: 1\n\ndata: 2\n\n")
+
+			assert.equals("reminder", received[1].name)
+			assert.equals("message", received[2].name)
+		end)
+
+		it("should ignore comments, unknown fields and events without data", function()
+			local parse, received = collecting_parser()
+
+			parse(": keepalive\n\nevent: reminder\nid: 7\nretry: 1000\n\n")
+
+			assert.equals(0, #received)
+		end)
+	end)
+
+	describe("dispatch", function()
+		local original_decode
+		local autocmds
+
+		before_each(function()
+			original_decode = vim.fn.json_decode
+			autocmds = {}
+			vim.api = {
+				nvim_exec_autocmds = function(event, opts)
+					table.insert(autocmds, { event = event, opts = opts })
+				end,
+			}
+		end)
+
+		after_each(function()
+			vim.fn.json_decode = original_decode
+			vim.api = nil
+		end)
+
+		--- Decode event data by looking it up in payloads
+		local function decode_from(payloads)
+			vim.fn.json_decode = function(data)
+				return payloads[data]
+			end
+		end
+
+		local reminders = {
+			due = {
+				kind = "due",
+				due = "2025-01-15",
+				task = { title = "Pay rent" },
+				list = { title = "Home" },
+			},
+			overdue = {
+				kind = "overdue",
+				due = "2025-01-14",
+				task = { title = "File taxes" },
+				list = { title = "Admin" },
+			},
+		}
+
+		it("should show due and overdue reminders regardless of verbosity", function()
+			decode_from(reminders)
+			config.setup({ verbosity = "error", reminders = true })
+
+			local parse = events.event_parser()
+			parse("event: reminder\ndata: due\n\nevent: reminder\ndata: overdue\n\n")
+
+			local due = vim_mock.find_notification("^Due 2025%-01%-15: Pay rent %(Home%)$")
+			assert.is_not_nil(due)
+			assert.equals(vim.log.levels.INFO, due.level)
+			local overdue = vim_mock.find_notification("^Overdue since 2025%-01%-14: File taxes %(Admin%)$")
+			assert.is_not_nil(overdue)
+			assert.equals(vim.log.levels.WARN, overdue.level)
+		end)
+
+		it("should not show reminders unless they are enabled", function()
+			decode_from(reminders)
+			config.setup({ verbosity = "info", notify_changes = true })
+
+			events.event_parser()("event: reminder\ndata: due\n\n")
+
+			assert.equals(0, #vim_mock.get_notifications())
+		end)
+
+		it("should fire GtaskChange for every change and describe it when asked to", function()
+			decode_from({
+				renamed = { type = "task.title_changed", from = "Old", to = "New", list = { title = "Home" } },
+				unknown = { type = "task.something_new", task = { title = "Mystery" } },
+			})
+			local parse = events.event_parser()
+
+			parse("event: change\ndata: renamed\n\n")
+			assert.equals(0, #vim_mock.get_notifications())
+			assert.equals(1, #autocmds)
+			assert.equals("User", autocmds[1].event)
+			assert.equals("GtaskChange", autocmds[1].opts.pattern)
+			assert.equals("New", autocmds[1].opts.data.to)
+
+			config.setup({ notify_changes = true })
+			parse("event: change\ndata: renamed\n\nevent: change\ndata: unknown\n\n")
+			assert.is_not_nil(vim_mock.find_notification("^Task renamed: Old %-> New$"))
+			assert.is_not_nil(vim_mock.find_notification("^Task changed: Mystery$"))
+			assert.equals(3, #autocmds)
+		end)
+
+		it("should warn about events that are not JSON objects", function()
+			vim.fn.json_decode = function()
+				error("not JSON")
+			end
+			config.setup({ verbosity = "warn" })
+
+			events.event_parser()("event: reminder\ndata: nope\n\n")
+
+			assert.is_not_nil(vim_mock.find_notification("^Invalid event from proxy: nope$"))
+		end)
+	end)
+end)
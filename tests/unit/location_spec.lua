@@ -0,0 +1,218 @@
+---Unit tests for code locations and :GtaskJump
+describe("location module", function()
+	local location
+	local api
+	local vim_mock
+	local restores
+	local opened
+	local cursor
+
+	--- Replace tbl[key] until the end of the test
+	local function stub(tbl, key, value)
+		local original = tbl[key]
+		table.insert(restores, function()
+			tbl[key] = original
+		end)
+		tbl[key] = value
+	end
+
+	--- A git repository at root; commands outside it fail like git does
+	local function repository(root, commit)
+		stub(vim, "v", { shell_error = 0 })
+		stub(vim.fn, "systemlist", function(cmd)
+			local dir = cmd[3]
+			if not root or (dir ~= root and dir:sub(1, #root + 1) ~= root .. "/") then
+				vim.v.shell_error = 128
+				return { "fatal: not a git repository" }
+			end
+			vim.v.shell_error = 0
+			if cmd[5] == "--show-toplevel" then
+				return { root }
+			end
+			if not commit then
+				vim.v.shell_error = 128
+			end
+			return { commit }
+		end)
+	end
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+		restores = {}
+		opened = nil
+		cursor = nil
+
+		stub(vim, "NIL", vim.NIL or {})
+		stub(vim, "tbl_filter", function(fn, items)
+			local kept = {}
+			for _, item in ipairs(items) do
+				if fn(item) then
+					table.insert(kept, item)
+				end
+			end
+			return kept
+		end)
+		stub(vim.fn, "fnamemodify", function(path, mods)
+			if mods == ":t" then
+				return path:match("([^/]+)$")
+			elseif mods == ":h" then
+				return path:match("^(.*)/[^/]*$")
+			end
+			return path
+		end)
+		stub(vim.fn, "getcwd", function()
+			return "/home/me/gtask.nvim"
+		end)
+		stub(vim.fn, "filereadable", function(path)
+			return path:match("missing") and 0 or 1
+		end)
+		stub(vim.fn, "fnameescape", function(path)
+			return path
+		end)
+		stub(vim, "cmd", {
+			edit = function(path)
+				opened = path
+			end,
+		})
+		stub(vim, "bo", { buftype = "" })
+		stub(vim, "api", {
+			nvim_buf_get_name = function()
+				return "/home/me/gtask.nvim/lua/gtask/api.lua"
+			end,
+			nvim_win_get_cursor = function()
+				return { 42, 7 }
+			end,
+			nvim_buf_line_count = function()
+				return 120
+			end,
+			nvim_win_set_cursor = function(_, pos)
+				cursor = pos
+			end,
+		})
+		repository("/home/me/gtask.nvim", "4f2a9c1")
+
+		api = require("gtask.api")
+		location = require("gtask.location")
+	end)
+
+	after_each(function()
+		for i = #restores, 1, -1 do
+			restores[i]()
+		end
+	end)
+
+	describe("current", function()
+		it("should give a file in a repository relative to its root, with the commit", function()
+			assert.same(
+				{ file = "lua/gtask/api.lua", line = 42, repo = "gtask.nvim", commit = "4f2a9c1" },
+				location.current()
+			)
+		end)
+
+		it("should leave out the commit of a repository without one", function()
+			repository("/home/me/gtask.nvim", nil)
+
+			assert.is_nil(location.current().commit)
+		end)
+
+		it("should give the full path of a file outside any repository", function()
+			repository(nil)
+
+			assert.same({ file = "/home/me/gtask.nvim/lua/gtask/api.lua", line = 42 }, location.current())
+		end)
+
+		it("should give nothing for buffers without a file", function()
+			vim.bo.buftype = "nofile"
+			assert.is_nil(location.current())
+
+			vim.bo.buftype = ""
+			vim.api.nvim_buf_get_name = function()
+				return ""
+			end
+			assert.is_nil(location.current())
+		end)
+	end)
+
+	describe("jump", function()
+		it("should open a repository's file at its line", function()
+			location.jump({ file = "lua/gtask/api.lua", line = 42, repo = "gtask.nvim" })
+
+			assert.equals("/home/me/gtask.nvim/lua/gtask/api.lua", opened)
+			assert.same({ 42, 0 }, cursor)
+		end)
+
+		it("should stay within a file that got shorter", function()
+			location.jump({ file = "/etc/hosts", line = 500 })
+			assert.same({ 120, 0 }, cursor)
+
+			location.jump({ file = "/etc/hosts", line = 0 })
+			assert.same({ 1, 0 }, cursor)
+		end)
+
+		it("should not open a file of another repository", function()
+			location.jump({ file = "main.go", line = 3, repo = "other" })
+
+			assert.is_nil(opened)
+			assert.is_not_nil(vim_mock.find_notification("^main%.go:3 is in other, not the current repository$"))
+		end)
+
+		it("should report a file that no longer exists", function()
+			location.jump({ file = "lua/missing.lua", line = 1, repo = "gtask.nvim" })
+
+			assert.is_nil(opened)
+			assert.is_not_nil(vim_mock.find_notification("/home/me/gtask%.nvim/lua/missing%.lua no longer exists$"))
+		end)
+	end)
+
+	describe("pick", function()
+		local original_fuzzy_search
+		local offered
+
+		before_each(function()
+			original_fuzzy_search = api.fuzzy_search
+			offered = nil
+			stub(vim, "ui", {
+				select = function(items, opts, on_choice)
+					offered = {}
+					for _, item in ipairs(items) do
+						table.insert(offered, opts.format_item(item))
+					end
+					on_choice(items[1])
+				end,
+			})
+		end)
+
+		after_each(function()
+			api.fuzzy_search = original_fuzzy_search
+		end)
+
+		it("should offer only tasks created from code and jump to the one picked", function()
+			api.fuzzy_search = function(_, _, callback)
+				callback({
+					matches = {
+						{ task = { title = "buy milk" } },
+						{ task = { title = "unplaced" }, location = vim.NIL },
+						{ task = { title = "retry on 429" }, location = { file = "/etc/hosts", line = 7 } },
+					},
+				}, nil)
+			end
+
+			location.pick(nil)
+
+			assert.same({ "retry on 429 (/etc/hosts:7)" }, offered)
+			assert.equals("/etc/hosts", opened)
+		end)
+
+		it("should say so when no task was created from code", function()
+			api.fuzzy_search = function(_, _, callback)
+				callback({ matches = { { task = { title = "buy milk" } } } }, nil)
+			end
+
+			location.pick("milk")
+
+			assert.is_nil(offered)
+			assert.is_not_nil(vim_mock.find_notification("^No tasks created from code match$"))
+		end)
+	end)
+end)
@@ -0,0 +1,97 @@
+---Unit tests for :GtaskAdd's one-line task capture
+describe("quickadd module", function()
+	local quickadd
+	local api
+	local vim_mock
+	local original_quick_add
+	local sent
+	local locations
+	local prompts
+	local answer
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		api = require("gtask.api")
+		original_quick_add = api.quick_add
+		sent = {}
+		locations = {}
+		api.quick_add = function(text, callback, location)
+			table.insert(sent, text)
+			table.insert(locations, location or false)
+			callback({ title = "pay rent", list = { title = "Home" } }, nil)
+		end
+		prompts = {}
+		answer = nil
+		vim.ui = {
+			input = function(opts, on_confirm)
+				table.insert(prompts, opts.prompt)
+				on_confirm(answer)
+			end,
+		}
+
+		quickadd = require("gtask.quickadd")
+	end)
+
+	after_each(function()
+		api.quick_add = original_quick_add
+		vim.ui = nil
+	end)
+
+	it("should send the command's arguments without asking", function()
+		quickadd.add("pay rent tomorrow #finance")
+
+		assert.same({ "pay rent tomorrow #finance" }, sent)
+		assert.equals(0, #prompts)
+		assert.is_not_nil(vim_mock.find_notification("^Added pay rent to Home$"))
+	end)
+
+	it("should ask for the task when there are no arguments", function()
+		answer = "call mom sat"
+
+		quickadd.add(nil)
+
+		assert.same({ "Add task: " }, prompts)
+		assert.same({ "call mom sat" }, sent)
+	end)
+
+	it("should keep the location given with the task", function()
+		quickadd.add("handle nil due dates", { file = "lua/gtask/api.lua", line = 42, repo = "gtask.nvim" })
+		quickadd.add("pay rent")
+
+		assert.same({ { file = "lua/gtask/api.lua", line = 42, repo = "gtask.nvim" }, false }, locations)
+	end)
+
+	it("should do nothing when the prompt is cancelled or left blank", function()
+		quickadd.add(nil)
+		answer = "   "
+		quickadd.add(nil)
+		quickadd.add("  ")
+
+		assert.equals(0, #sent)
+		assert.equals(0, #vim_mock.get_notifications())
+	end)
+
+	it("should show the due date of the created task", function()
+		api.quick_add = function(_, callback)
+			callback({ title = "dentist", due = "2025-03-17", list = { title = "Health" } }, nil)
+		end
+
+		quickadd.add("dentist on mon")
+
+		assert.is_not_nil(vim_mock.find_notification("^Added dentist to Health %(due 2025%-03%-17%)$"))
+	end)
+
+	it("should report a task the proxy could not add", function()
+		api.quick_add = function(_, callback)
+			callback(nil, "a priority is !p1 to !p4")
+		end
+
+		quickadd.add("pay rent !p5")
+
+		local failed = vim_mock.find_notification("^Failed to add task: a priority is !p1 to !p4$")
+		assert.is_not_nil(failed)
+		assert.equals(vim.log.levels.ERROR, failed.level)
+	end)
+end)
@@ -0,0 +1,126 @@
+---Unit tests for :GtaskShare
+describe("share module", function()
+	local share
+	local api
+	local vim_mock
+	local originals
+	local lists
+	local shares
+	local shared
+	local revoked
+	local choices
+	local shown
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		api = require("gtask.api")
+		originals = {
+			get_task_lists = api.get_task_lists,
+			share_list = api.share_list,
+			list_shares = api.list_shares,
+			revoke_share = api.revoke_share,
+		}
+		lists = { { id = "L1", title = "Groceries" }, { id = "L2", title = "Work" } }
+		shares = {
+			{
+				id = "s1",
+				list = { id = "L1", title = "Groceries" },
+				tasks = 3,
+				created_at = "2025-03-14T09:00:00Z",
+				expires_at = "2025-03-17T09:00:00Z",
+			},
+		}
+		shared = {}
+		revoked = {}
+		api.get_task_lists = function(callback)
+			callback({ items = lists }, nil)
+		end
+		api.share_list = function(list_id, expires, callback)
+			table.insert(shared, { list = list_id, expires = expires or false })
+			callback({ id = "s2", tasks = 2, url = "http://localhost:3000/share/abc" }, nil)
+		end
+		api.list_shares = function(callback)
+			callback({ shares = shares }, nil)
+		end
+		api.revoke_share = function(id, callback)
+			table.insert(revoked, id)
+			callback({ revoked = true }, nil)
+		end
+
+		-- Each select picks the next of choices (an index, or nil to cancel)
+		-- and records how its items were shown
+		choices = {}
+		shown = {}
+		vim.ui = {
+			select = function(items, opts, on_choice)
+				local lines = {}
+				for _, item in ipairs(items) do
+					table.insert(lines, opts.format_item(item))
+				end
+				table.insert(shown, lines)
+				local index = table.remove(choices, 1)
+				on_choice(index and items[index], index)
+			end,
+		}
+
+		share = require("gtask.share")
+	end)
+
+	after_each(function()
+		for name, fn in pairs(originals) do
+			api[name] = fn
+		end
+		vim.ui = nil
+	end)
+
+	it("should share the picked list and show its link", function()
+		choices = { 2 }
+
+		share.share("3d")
+
+		assert.same({ "Groceries", "Work" }, shown[1])
+		assert.same({ { list = "L2", expires = "3d" } }, shared)
+		assert.is_not_nil(vim_mock.find_notification("^Shared 2 task%(s%) of Work:$"))
+		assert.is_not_nil(vim_mock.find_notification("^http://localhost:3000/share/abc$"))
+	end)
+
+	it("should share nothing when the pick is cancelled", function()
+		share.share(nil)
+
+		assert.equals(0, #shared)
+	end)
+
+	it("should report a failed share", function()
+		choices = { 1 }
+		api.share_list = function(_, _, callback)
+			callback(nil, "HTTP 404")
+		end
+
+		share.share(nil)
+
+		local err = vim_mock.find_notification("^Failed to share Groceries: HTTP 404$")
+		assert.is_not_nil(err)
+		assert.equals(vim.log.levels.ERROR, err.level)
+	end)
+
+	it("should revoke the picked share", function()
+		choices = { 1 }
+
+		share.revoke()
+
+		assert.same({ "Groceries (3 tasks, shared 2025-03-14T09:00:00Z, expires 2025-03-17T09:00:00Z)" }, shown[1])
+		assert.same({ "s1" }, revoked)
+		assert.is_not_nil(vim_mock.find_notification("^Revoked the share of Groceries$"))
+	end)
+
+	it("should say so when nothing is shared", function()
+		shares = {}
+
+		share.revoke()
+
+		assert.equals(0, #shown)
+		assert.is_not_nil(vim_mock.find_notification("^No shared lists$"))
+	end)
+end)
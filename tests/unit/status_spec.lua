@@ -0,0 +1,158 @@
+---Unit tests for :GtaskDone
+describe("status module", function()
+	local status
+	local api
+	local mapping
+	local vim_mock
+	local original_bulk_status
+	local original_load
+	local buffer
+	local valid
+	local requests
+	local response
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		buffer = {
+			"# Home",
+			"- [ ] buy milk",
+			"<!-- gtask:u1 -->",
+			"  - [ ] skimmed",
+			"  <!-- gtask:u2 -->",
+			"- [ ] not synced",
+			"- [x] call mum | 2025-03-14",
+			"<!-- gtask:u3 -->",
+		}
+		valid = true
+		vim.api = {
+			nvim_get_current_buf = function()
+				return 1
+			end,
+			nvim_buf_is_valid = function()
+				return valid
+			end,
+			nvim_buf_get_lines = function(_, start, finish)
+				local lines = {}
+				for i = start + 1, finish == -1 and #buffer or finish do
+					table.insert(lines, buffer[i])
+				end
+				return lines
+			end,
+			nvim_buf_set_lines = function(_, start, _, _, replacement)
+				buffer[start + 1] = replacement[1]
+			end,
+		}
+
+		mapping = require("gtask.mapping")
+		original_load = mapping.load
+		mapping.load = function()
+			return {
+				lists = { Home = "L1" },
+				tasks = {
+					u1 = { google_id = "g1", list_name = "Home" },
+					u2 = { google_id = "g2", list_name = "Home" },
+					u3 = { google_id = "g3", list_name = "Home" },
+				},
+			}
+		end
+
+		api = require("gtask.api")
+		original_bulk_status = api.bulk_status
+		requests = {}
+		response = nil
+		api.bulk_status = function(tasks, new_status, callback)
+			table.insert(requests, { tasks = tasks, status = new_status })
+			if response then
+				callback(response, nil)
+			else
+				-- Every task succeeds
+				local results = {}
+				for _ in ipairs(tasks) do
+					table.insert(results, {})
+				end
+				callback({ updated = #tasks, failed = 0, results = results }, nil)
+			end
+		end
+
+		status = require("gtask.status")
+	end)
+
+	after_each(function()
+		api.bulk_status = original_bulk_status
+		mapping.load = original_load
+		vim.api = nil
+	end)
+
+	it("should complete the synced tasks of the lines and tick them", function()
+		status.set(2, 5, true)
+
+		assert.same({
+			{ tasks = { { list = "L1", task = "g1" }, { list = "L1", task = "g2" } }, status = "completed" },
+		}, requests)
+		assert.equals("- [x] buy milk", buffer[2])
+		assert.equals("  - [x] skimmed", buffer[4])
+		assert.is_not_nil(vim_mock.find_notification("^Completed 2 task%(s%)$"))
+	end)
+
+	it("should reopen tasks and clear their checkboxes, keeping the due date", function()
+		status.set(7, 7, false)
+
+		assert.equals("needsAction", requests[1].status)
+		assert.equals("- [ ] call mum | 2025-03-14", buffer[7])
+		assert.is_not_nil(vim_mock.find_notification("^Reopened 1 task%(s%)$"))
+	end)
+
+	it("should tick only the tasks that were updated and count the rest", function()
+		response = {
+			updated = 2,
+			failed = 1,
+			results = { {}, { error = { message = "Task not found" } }, {} },
+		}
+
+		status.set(1, #buffer, true)
+
+		assert.equals(3, #requests[1].tasks)
+		assert.equals("- [x] buy milk", buffer[2])
+		assert.equals("  - [ ] skimmed", buffer[4])
+		assert.equals("- [ ] not synced", buffer[6])
+		local summary = vim_mock.find_notification("^Completed 2 task%(s%), 1 failed, 1 not synced yet$")
+		assert.is_not_nil(summary)
+		assert.equals(vim.log.levels.WARN, summary.level)
+	end)
+
+	it("should not send a request without synced tasks", function()
+		status.set(6, 6, true)
+
+		assert.equals(0, #requests)
+		local warning = vim_mock.find_notification("^No synced tasks on these lines, run :GtaskSync first$")
+		assert.is_not_nil(warning)
+		assert.equals(vim.log.levels.WARN, warning.level)
+	end)
+
+	it("should leave a buffer closed before the response alone", function()
+		api.bulk_status = function(tasks, _, callback)
+			valid = false
+			callback({ updated = #tasks, failed = 0, results = { {} } }, nil)
+		end
+
+		status.set(2, 2, true)
+
+		assert.equals("- [ ] buy milk", buffer[2])
+		assert.is_not_nil(vim_mock.find_notification("^Completed 1 task%(s%)$"))
+	end)
+
+	it("should report a failed request", function()
+		api.bulk_status = function(_, _, callback)
+			callback(nil, "connection refused")
+		end
+
+		status.set(2, 2, true)
+
+		assert.equals("- [ ] buy milk", buffer[2])
+		local failed = vim_mock.find_notification("^Failed to update tasks: connection refused$")
+		assert.is_not_nil(failed)
+		assert.equals(vim.log.levels.ERROR, failed.level)
+	end)
+end)
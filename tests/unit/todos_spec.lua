@@ -0,0 +1,123 @@
+---Unit tests for :GtaskImportTodos
+describe("todos module", function()
+	local todos
+	local api
+	local vim_mock
+	local original_scan
+	local original_import_todos
+	local sent
+
+	before_each(function()
+		vim_mock = require("tests.helpers.vim_mock")
+		vim_mock.reset()
+
+		api = require("gtask.api")
+		todos = require("gtask.todos")
+		original_scan = todos.scan
+		original_import_todos = api.import_todos
+		sent = {}
+		api.import_todos = function(list_title, repo, commit, comments, callback)
+			table.insert(sent, { list_title = list_title, repo = repo, commit = commit, todos = comments })
+			callback({ list = { title = list_title }, created = 1, updated = 0, unchanged = 2 }, nil)
+		end
+	end)
+
+	after_each(function()
+		todos.scan = original_scan
+		api.import_todos = original_import_todos
+	end)
+
+	local function found(repo, comments, commit)
+		todos.scan = function(_, callback)
+			callback(repo, comments, commit)
+		end
+	end
+
+	describe("parse", function()
+		it("should take the text after the keyword and its separator", function()
+			assert.same({ "TODO", "retry on 429" }, { todos.parse("-- TODO: retry on 429") })
+			assert.same({ "HACK", "skip the cache" }, { todos.parse("/* HACK - skip the cache */") })
+			assert.same({ "XXX", "remove before release" }, { todos.parse("<!-- XXX remove before release -->") })
+		end)
+
+		it("should take the author from TODO(name)", function()
+			local kind, text, author = todos.parse("// FIXME(pritesh): handle a nil due date")
+
+			assert.equals("FIXME", kind)
+			assert.equals("handle a nil due date", text)
+			assert.equals("pritesh", author)
+			assert.is_nil(select(3, todos.parse("// TODO(): no one in particular")))
+		end)
+
+		it("should ignore keywords inside other words and comments without text", function()
+			assert.is_nil(todos.parse("local todos = {}"))
+			assert.is_nil(todos.parse("-- MYTODO: not a keyword"))
+			assert.is_nil(todos.parse("-- TODOS are tracked elsewhere"))
+			assert.is_nil(todos.parse("-- TODO:   "))
+		end)
+	end)
+
+	describe("import", function()
+		it("should send the comments to a list named after the repository", function()
+			local comments = { { file = "lua/gtask/api.lua", line = 12, kind = "TODO", text = "retry on 429" } }
+			found("gtask.nvim", comments, "4f2a9c1")
+
+			todos.import(nil)
+
+			assert.equals(1, #sent)
+			assert.equals("TODO: gtask.nvim", sent[1].list_title)
+			assert.equals("gtask.nvim", sent[1].repo)
+			assert.equals("4f2a9c1", sent[1].commit)
+			assert.same(comments, sent[1].todos)
+			assert.is_not_nil(
+				vim_mock.find_notification("^TODO: gtask%.nvim: 1 created, 0 updated, 2 unchanged$")
+			)
+		end)
+
+		it("should use the list title given", function()
+			found("gtask.nvim", { { file = "a.lua", line = 1, kind = "TODO", text = "x" } })
+
+			todos.import("Plugin work")
+
+			assert.equals("Plugin work", sent[1].list_title)
+		end)
+
+		it("should not call the proxy when there is nothing to import", function()
+			found("gtask.nvim", {})
+
+			todos.import(nil)
+
+			assert.equals(0, #sent)
+			assert.is_not_nil(vim_mock.find_notification("^No TODO comments found in gtask%.nvim$"))
+		end)
+
+		it("should refuse more comments than one import takes", function()
+			local original_max = todos.max_todos
+			todos.max_todos = 2
+			found("big", { {}, {}, {} })
+
+			todos.import(nil)
+			todos.max_todos = original_max
+
+			assert.equals(0, #sent)
+			local refused = vim_mock.find_notification("^big has 3 TODO comments, more than the 2 one import takes$")
+			assert.is_not_nil(refused)
+			assert.equals(vim.log.levels.ERROR, refused.level)
+		end)
+
+		it("should report a directory outside git and a failed import", function()
+			found(nil, "not in a git repository")
+			todos.import(nil)
+			assert.is_not_nil(vim_mock.find_notification("^Failed to scan for TODOs: not in a git repository$"))
+
+			found("gtask.nvim", { { file = "a.lua", line = 1, kind = "TODO", text = "x" } })
+			api.import_todos = function(_, _, _, _, callback)
+				callback(nil, "at most 500 TODO comments can be imported at once")
+			end
+			todos.import(nil)
+			local failed = vim_mock.find_notification("^Failed to import TODOs: at most 500")
+			assert.is_not_nil(failed)
+			assert.equals(vim.log.levels.ERROR, failed.level)
+		end)
+	end)
+end)